@@ -64,6 +64,7 @@ func AuditbeatSettings(globals processors.PluginConfig) instance.Settings {
 	return instance.Settings{
 		RunFlags:      runFlags,
 		Name:          Name,
+		InputsKey:     "auditbeat.modules",
 		HasDashboards: true,
 		Processing:    processing.MakeDefaultSupport(true, globals, withECSVersion, processing.WithHost, processing.WithAgentMeta()),
 	}
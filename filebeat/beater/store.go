@@ -35,6 +35,7 @@ import (
 	"github.com/elastic/beats/v7/libbeat/statestore/backend/es"
 	"github.com/elastic/beats/v7/libbeat/statestore/backend/memlog"
 	"github.com/elastic/beats/v7/libbeat/statestore/backend/otelstorage"
+	"github.com/elastic/beats/v7/libbeat/statestore/backend/sqlite"
 	"github.com/elastic/elastic-agent-libs/logp"
 	"github.com/elastic/elastic-agent-libs/paths"
 )
@@ -101,6 +102,11 @@ func openStateStore(ctx context.Context, info beat.Info, logger *logp.Logger, cf
 				ReceiverID: recvID,
 				Logger:     logger,
 			})
+		case "sqlite":
+			reg, err = sqlite.New(logger, sqlite.Settings{
+				Root:     resolvedPath,
+				FileMode: cfg.Permissions,
+			})
 		case "memlog", "":
 			reg, err = memlog.New(logger, memlog.Settings{
 				Root:     resolvedPath,
@@ -0,0 +1,58 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package beater
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/filebeat/config"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+func TestOpenStateStore_SqliteSetGet(t *testing.T) {
+	dir := t.TempDir()
+	beatPaths := paths.New()
+	beatPaths.Data = dir
+
+	fb, err := openStateStore(t.Context(), beat.Info{Beat: "testbeat", Paths: beatPaths}, logp.NewNopLogger(), config.Registry{
+		Path:          "registry",
+		Permissions:   0o600,
+		CleanInterval: 5 * time.Second,
+		Backend:       "sqlite",
+	})
+	require.NoError(t, err)
+	defer fb.Close()
+	assert.Equal(t, "sqlite://"+filepath.Join(dir, "registry"), fb.StoreKey())
+
+	st, err := fb.StoreFor("filestream")
+	require.NoError(t, err)
+	defer st.Close()
+
+	require.NoError(t, st.Set("k1", map[string]any{"n": "hello"}))
+	var got map[string]any
+	require.NoError(t, st.Get("k1", &got))
+	assert.Equal(t, "hello", got["n"])
+	assert.FileExists(t, filepath.Join(dir, "registry", "testbeat.db"))
+}
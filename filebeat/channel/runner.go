@@ -48,6 +48,8 @@ type commonInputConfig struct {
 		DisableHost bool `config:"disable_host"` // Disable addition of host.name.
 	} `config:"publisher_pipeline"`
 
+	ID string `config:"id"` // input id, processor metrics are reported under
+
 	// implicit event fields
 	Type        string `config:"type"`         // input.type
 	ServiceType string `config:"service.type"` // service.type
@@ -144,7 +146,11 @@ func newCommonConfigEditor(
 			indexProcessor = add_formatted_index.New(timestampFormat)
 		}
 
-		userProcessors, err := processors.New(config.Processors, beatInfo.Logger)
+		owner := processors.DefaultOwner
+		if config.ID != "" {
+			owner += "." + config.ID
+		}
+		userProcessors, err := processors.NewWithOwner(config.Processors, owner, beatInfo.Logger)
 		if err != nil {
 			return clientCfg, err
 		}
@@ -31,6 +31,7 @@ import (
 
 	// Import processors.
 	_ "github.com/elastic/beats/v7/libbeat/processors/cache"
+	_ "github.com/elastic/beats/v7/libbeat/processors/dedup"
 	_ "github.com/elastic/beats/v7/libbeat/processors/timestamp"
 )
 
@@ -53,6 +54,7 @@ func FilebeatSettings(moduleNameSpace string) instance.Settings {
 	return instance.Settings{
 		RunFlags:      runFlags,
 		Name:          Name,
+		InputsKey:     "filebeat.inputs",
 		HasDashboards: true,
 		Initialize: []func(){
 			include.InitializeModule,
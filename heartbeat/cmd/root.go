@@ -55,6 +55,7 @@ var withECSVersion = processing.WithFields(mapstr.M{
 func HeartbeatSettings() instance.Settings {
 	return instance.Settings{
 		Name:          Name,
+		InputsKey:     "heartbeat.monitors",
 		Processing:    processing.MakeDefaultSupport(true, nil, withECSVersion, processing.WithAgentMeta()),
 		HasDashboards: false,
 		Initialize:    []func(){include.InitializeModule},
@@ -0,0 +1,70 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package deadletter
+
+import (
+	"github.com/elastic/beats/v7/libbeat/publisher"
+)
+
+// replayResult is the outcome of publishing a batch, as signaled by the
+// output.
+type replayResult struct {
+	// events of the batch
+	events []publisher.Event
+	// retry holds the events to publish again
+	retry []publisher.Event
+	// dropped is set when the output dropped the batch
+	dropped bool
+}
+
+// replayBatch implements publisher.Batch for publishing dead letter
+// records. The outcome of the batch is sent to done.
+type replayBatch struct {
+	events []publisher.Event
+	done   chan replayResult
+}
+
+func (b *replayBatch) Events() []publisher.Event {
+	return b.events
+}
+
+func (b *replayBatch) ACK() {
+	b.done <- replayResult{events: b.events}
+}
+
+func (b *replayBatch) Drop() {
+	b.done <- replayResult{events: b.events, dropped: true}
+}
+
+func (b *replayBatch) Retry() {
+	b.done <- replayResult{events: b.events, retry: b.events}
+}
+
+func (b *replayBatch) RetryEvents(events []publisher.Event) {
+	b.done <- replayResult{events: b.events, retry: events}
+}
+
+// SplitRetry isn't supported, the batch size is taken from the output
+// settings already.
+func (b *replayBatch) SplitRetry() bool {
+	return false
+}
+
+func (b *replayBatch) Cancelled() {
+	b.Retry()
+}
@@ -0,0 +1,429 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package deadletter implements the commands handling the dead letter files
+// written by outputs.
+package deadletter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/cmd/instance"
+	"github.com/elastic/beats/v7/libbeat/cmd/instance/locks"
+	"github.com/elastic/beats/v7/libbeat/common/backoff"
+	"github.com/elastic/beats/v7/libbeat/idxmgmt"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	outdeadletter "github.com/elastic/beats/v7/libbeat/outputs/deadletter"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+const (
+	defaultBatchSize = 1600
+
+	replayBackoffInit = 1 * time.Second
+	replayBackoffMax  = 60 * time.Second
+)
+
+// GenReplayCmd generates the command that publishes the events of dead
+// letter files again through the configured output.
+func GenReplayCmd(settings instance.Settings) *cobra.Command {
+	var keep bool
+	var maxRetries int
+
+	cmd := &cobra.Command{
+		Use:   "replay [file...]",
+		Short: "Publish the events of dead letter files again by using the configured output",
+		Long: `Publish the events of dead letter files again by using the configured output.
+
+When no file is given, the dead letter files of the configured output are
+replayed, oldest first. Files are removed once all their events are published,
+events failing again are kept in the file they were read from. The Beat must
+be stopped while replaying.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := runReplay(settings, args, keep, maxRetries); err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().BoolVar(&keep, "keep", false, "Keep the dead letter files after replaying them")
+	cmd.Flags().IntVar(&maxRetries, "max-retries", 3, "Number of times a batch is retried before its events are kept as failed")
+	return cmd
+}
+
+func runReplay(settings instance.Settings, files []string, keep bool, maxRetries int) error {
+	b, err := instance.NewInitializedBeat(settings)
+	if err != nil {
+		return fmt.Errorf("error initializing beat: %w", err)
+	}
+
+	// The active dead letter file is still written by a running Beat, and
+	// replaying it would lose the records written meanwhile.
+	lock := locks.New(b.Info)
+	if err := lock.Lock(); err != nil {
+		return fmt.Errorf("%s must be stopped to replay its dead letter files: %w", settings.Name, err)
+	}
+	defer func() {
+		_ = lock.Unlock()
+	}()
+
+	name := b.Config.Output.Name()
+	outputConfig := b.Config.Output.Config()
+	if len(files) == 0 {
+		dlConfig, err := outdeadletter.ConfigFromOutput(outputConfig)
+		if err != nil {
+			return fmt.Errorf("error reading dead letter settings: %w", err)
+		}
+		files, err = dlConfig.Files(b.Info, name)
+		if err != nil {
+			return err
+		}
+	}
+	if len(files) == 0 {
+		fmt.Println("No dead letter files to replay") //nolint:forbidigo // command output
+		return nil
+	}
+
+	im, _ := idxmgmt.DefaultSupport(b.Info, nil)
+	group, err := outputs.Load(im, b.Info, nil, name, outputConfig)
+	if err != nil {
+		return fmt.Errorf("error initializing output: %w", err)
+	}
+
+	r, err := newReplayer(b.Info.Logger, group, maxRetries)
+	if err != nil {
+		return err
+	}
+	defer r.close()
+
+	ctx := context.Background()
+	var failedFiles int
+	for _, path := range files {
+		stats, err := r.replayFile(ctx, path, keep)
+		if err != nil {
+			return fmt.Errorf("error replaying %s: %w", path, err)
+		}
+		fmt.Printf("%s: %d events published, %d events failed\n", path, stats.published, stats.failed) //nolint:forbidigo // command output
+		if stats.failed > 0 {
+			failedFiles++
+		}
+	}
+	if failedFiles > 0 {
+		return fmt.Errorf("events of %d files could not be published", failedFiles)
+	}
+	return nil
+}
+
+// replayer publishes dead letter records through the client of an output
+// group, outside of the publishing pipeline.
+type replayer struct {
+	log        *logp.Logger
+	client     outputs.Client
+	group      outputs.Group
+	encoder    queue.Encoder[publisher.Event]
+	batchSize  int
+	maxRetries int
+	backoff    backoff.Backoff
+	connected  bool
+}
+
+type replayStats struct {
+	published int
+	failed    int
+}
+
+// replayItem is a dead letter record waiting to be published.
+type replayItem struct {
+	event beat.Event
+	raw   []byte
+}
+
+func newReplayer(log *logp.Logger, group outputs.Group, maxRetries int) (*replayer, error) {
+	if len(group.Clients) == 0 {
+		return nil, errors.New("output has no clients")
+	}
+	if log == nil {
+		log = logp.NewNopLogger()
+	}
+
+	r := &replayer{
+		log:        log.Named("dead_letter_replay"),
+		client:     group.Clients[0],
+		group:      group,
+		batchSize:  group.BatchSize,
+		maxRetries: maxRetries,
+		backoff:    backoff.NewEqualJitterBackoff(replayBackoffInit, replayBackoffMax),
+	}
+	if r.batchSize <= 0 {
+		r.batchSize = defaultBatchSize
+	}
+	if group.EncoderFactory != nil {
+		r.encoder = group.EncoderFactory()
+	}
+	return r, nil
+}
+
+func (r *replayer) close() {
+	for _, client := range r.group.Clients {
+		if err := client.Close(); err != nil {
+			r.log.Errorf("Failed to close output client: %v", err)
+		}
+	}
+	if r.group.DeadLetter != nil {
+		if err := r.group.DeadLetter.Close(); err != nil {
+			r.log.Errorf("Failed to close dead letter file: %v", err)
+		}
+	}
+}
+
+// replayFile publishes all the records of the dead letter file at path. The
+// file is removed if all of them are published, otherwise it is rewritten
+// with the records that failed again. With keep the file is left untouched.
+func (r *replayer) replayFile(ctx context.Context, path string, keep bool) (replayStats, error) {
+	var stats replayStats
+
+	f, err := os.Open(path)
+	if err != nil {
+		return stats, err
+	}
+	defer f.Close()
+
+	var failed [][]byte
+	items := make([]replayItem, 0, r.batchSize)
+	flush := func() error {
+		if len(items) == 0 {
+			return nil
+		}
+		notPublished, err := r.publish(ctx, items)
+		if err != nil {
+			return err
+		}
+		for _, item := range notPublished {
+			failed = append(failed, item.raw)
+		}
+		stats.published += len(items) - len(notPublished)
+		stats.failed += len(notPublished)
+		items = make([]replayItem, 0, r.batchSize)
+		return nil
+	}
+
+	reader := outdeadletter.NewReader(f)
+	for {
+		rec, raw, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return stats, err
+		}
+
+		event, err := rec.BeatEvent()
+		if err != nil {
+			r.log.Errorf("Skipping dead letter record: %v", err)
+			failed = append(failed, raw)
+			stats.failed++
+			continue
+		}
+
+		items = append(items, replayItem{event: event, raw: raw})
+		if len(items) >= r.batchSize {
+			if err := flush(); err != nil {
+				return stats, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return stats, err
+	}
+	f.Close()
+
+	switch {
+	case keep:
+	case len(failed) == 0:
+		if err := os.Remove(path); err != nil {
+			return stats, fmt.Errorf("failed to remove replayed file: %w", err)
+		}
+	default:
+		if err := rewriteFile(path, failed); err != nil {
+			return stats, fmt.Errorf("failed to rewrite dead letter file: %w", err)
+		}
+	}
+	return stats, nil
+}
+
+// publish publishes items as one batch, retrying the events the output
+// asks to retry. It returns the items that couldn't be published.
+func (r *replayer) publish(ctx context.Context, items []replayItem) ([]replayItem, error) {
+	// Events are matched back to their items through a key that survives
+	// early encoding: encoders replace the content of the event by a
+	// pointer to their encoded form.
+	byKey := make(map[any]replayItem, len(items))
+	pending := make([]publisher.Event, len(items))
+	for i := range items {
+		event := publisher.Event{Content: items[i].event, Flags: publisher.GuaranteedSend}
+		event.Content.Private = &items[i]
+		if r.encoder != nil {
+			event, _ = r.encoder.EncodeEntry(event)
+		}
+		pending[i] = event
+		byKey[eventKey(event)] = items[i]
+	}
+
+	// Events the output fails permanently while acknowledging the rest of
+	// the batch are collected to be kept in the file.
+	rejected := &rejectedEvents{}
+	ctx = outputs.WithDeadLetterWriter(ctx, rejected)
+
+	r.backoff.Reset()
+	for attempt := 0; ; attempt++ {
+		var result replayResult
+		if err := r.connect(ctx); err != nil {
+			r.log.Errorf("Failed to connect to the output: %v", err)
+			result = replayResult{retry: pending}
+		} else {
+			result, err = r.publishBatch(ctx, pending)
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if err != nil {
+				r.log.Errorf("Failed to publish events: %v", err)
+				r.connected = false
+			}
+		}
+
+		pending = result.retry
+		if result.dropped {
+			pending = result.events
+		}
+		if len(pending) == 0 {
+			break
+		}
+		if result.dropped || attempt >= r.maxRetries {
+			break
+		}
+		if !r.backoff.Wait(ctx) {
+			return nil, ctx.Err()
+		}
+	}
+
+	var failed []replayItem
+	for _, event := range append(pending, rejected.events...) {
+		key := eventKey(event)
+		if item, ok := byKey[key]; ok {
+			failed = append(failed, item)
+			delete(byKey, key)
+		}
+	}
+	return failed, nil
+}
+
+func (r *replayer) connect(ctx context.Context) error {
+	if r.connected {
+		return nil
+	}
+	if client, ok := r.client.(outputs.NetworkClient); ok {
+		if err := client.Connect(ctx); err != nil {
+			return err
+		}
+	}
+	r.connected = true
+	return nil
+}
+
+func (r *replayer) publishBatch(ctx context.Context, events []publisher.Event) (replayResult, error) {
+	batch := &replayBatch{
+		events: events,
+		done:   make(chan replayResult, 1),
+	}
+	err := r.client.Publish(ctx, batch)
+	select {
+	case result := <-batch.done:
+		return result, err
+	case <-ctx.Done():
+		return replayResult{}, ctx.Err()
+	}
+}
+
+// rejectedEvents is the dead letter writer given to the output while
+// replaying.
+type rejectedEvents struct {
+	mu     sync.Mutex
+	events []publisher.Event
+}
+
+func (r *rejectedEvents) WriteDeadLetters(events []publisher.Event, _ error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, events...)
+}
+
+func (r *rejectedEvents) Close() error {
+	return nil
+}
+
+func eventKey(event publisher.Event) any {
+	if event.EncodedEvent != nil {
+		return event.EncodedEvent
+	}
+	return event.Content.Private
+}
+
+// rewriteFile atomically replaces the content of path with lines.
+func rewriteFile(path string, lines [][]byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	for _, line := range lines {
+		if _, err := fmt.Fprintf(tmp, "%s\n", line); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
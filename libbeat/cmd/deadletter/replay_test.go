@@ -0,0 +1,152 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package deadletter
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common/backoff"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	outdeadletter "github.com/elastic/beats/v7/libbeat/outputs/deadletter"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// testClient rejects events with a "bad" message, asks to retry events with
+// a "flaky" message once, and acknowledges everything else.
+type testClient struct {
+	published []string
+	retried   map[string]bool
+}
+
+func (c *testClient) Close() error   { return nil }
+func (c *testClient) String() string { return "test" }
+
+func (c *testClient) Publish(ctx context.Context, batch publisher.Batch) error {
+	var retry, rejected []publisher.Event
+	for _, event := range batch.Events() {
+		msg, _ := event.Content.Fields.GetValue("message")
+		switch msg {
+		case "bad":
+			rejected = append(rejected, event)
+		case "flaky":
+			if !c.retried[msg.(string)] {
+				c.retried[msg.(string)] = true
+				retry = append(retry, event)
+				continue
+			}
+			fallthrough
+		default:
+			c.published = append(c.published, msg.(string))
+		}
+	}
+
+	outputs.DeadLetterWriterFrom(ctx).WriteDeadLetters(rejected, errors.New("rejected"))
+	if len(retry) > 0 {
+		batch.RetryEvents(retry)
+		return nil
+	}
+	batch.ACK()
+	return nil
+}
+
+func writeDeadLetterFile(t *testing.T, messages ...string) string {
+	t.Helper()
+	info := beat.Info{Beat: "testbeat", Logger: logptest.NewTestingLogger(t, "")}
+	c := outdeadletter.DefaultConfig()
+	c.Path = t.TempDir()
+
+	w := outdeadletter.NewWriter(info, "test", c)
+	for _, msg := range messages {
+		w.WriteDeadLetters([]publisher.Event{
+			{Content: beat.Event{Timestamp: time.Now(), Fields: mapstr.M{"message": msg}}},
+		}, errors.New("failed"))
+	}
+	require.NoError(t, w.Close())
+
+	files, err := c.Files(info, "test")
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	return files[0]
+}
+
+func newTestReplayer(t *testing.T, client outputs.Client) *replayer {
+	t.Helper()
+	r, err := newReplayer(logptest.NewTestingLogger(t, ""), outputs.Group{
+		Clients:   []outputs.Client{client},
+		BatchSize: 2,
+	}, 3)
+	require.NoError(t, err)
+	r.backoff = backoff.NewEqualJitterBackoff(time.Millisecond, time.Millisecond)
+	return r
+}
+
+func TestReplayFile(t *testing.T) {
+	path := writeDeadLetterFile(t, "one", "flaky", "bad", "two", "three")
+	client := &testClient{retried: map[string]bool{}}
+	r := newTestReplayer(t, client)
+
+	stats, err := r.replayFile(context.Background(), path, false)
+	require.NoError(t, err)
+	assert.Equal(t, replayStats{published: 4, failed: 1}, stats)
+	assert.ElementsMatch(t, []string{"one", "flaky", "two", "three"}, client.published)
+
+	// Only the rejected event is kept.
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	require.Len(t, lines, 1)
+	assert.Contains(t, lines[0], `"message":"bad"`)
+}
+
+func TestReplayFileRemovesPublishedFile(t *testing.T) {
+	path := writeDeadLetterFile(t, "one", "two", "three")
+	r := newTestReplayer(t, &testClient{retried: map[string]bool{}})
+
+	stats, err := r.replayFile(context.Background(), path, false)
+	require.NoError(t, err)
+	assert.Equal(t, replayStats{published: 3}, stats)
+	assert.NoFileExists(t, path)
+}
+
+func TestReplayFileKeep(t *testing.T) {
+	path := writeDeadLetterFile(t, "one", "bad")
+	before, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	r := newTestReplayer(t, &testClient{retried: map[string]bool{}})
+	stats, err := r.replayFile(context.Background(), path, true)
+	require.NoError(t, err)
+	assert.Equal(t, replayStats{published: 1, failed: 1}, stats)
+
+	after, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+}
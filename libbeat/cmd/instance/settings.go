@@ -46,6 +46,11 @@ type Settings struct {
 
 	Processing processing.SupportFactory
 
+	// InputsKey is the setting holding the list of inputs of the Beat, like
+	// filebeat.inputs. The test processors command selects the processors of
+	// an input from it. Defaults to "<name>.inputs".
+	InputsKey string
+
 	// InputQueueSize is the size for the internal publisher queue in the
 	// publisher pipeline. This is only useful when the Beat plans to use
 	// beat.DropIfFull PublishMode. Leave as zero for default.
@@ -33,6 +33,7 @@ func genTestCmd(settings instance.Settings, beatCreator beat.Creator) *cobra.Com
 
 	exportCmd.AddCommand(test.GenTestConfigCmd(settings, beatCreator))
 	exportCmd.AddCommand(test.GenTestOutputCmd(settings))
+	exportCmd.AddCommand(test.GenTestProcessorsCmd(settings))
 
 	return exportCmd
 }
@@ -0,0 +1,300 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/cmd/instance"
+	"github.com/elastic/beats/v7/libbeat/common/jsontransform"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// maxEventSize is the maximum size of a line of the events file.
+const maxEventSize = 10 * 1024 * 1024
+
+// GenTestProcessorsCmd generates the command that runs sample events through
+// the configured processors.
+func GenTestProcessorsCmd(settings instance.Settings) *cobra.Command {
+	var inputID, inputsKey string
+
+	cmd := &cobra.Command{
+		Use:   "processors [file]",
+		Short: "Run sample events through the configured processors",
+		Long: `Run sample events through the configured processors and print the event
+after each processor, or the error it returned, with the time it took.
+
+Events are read as newline-delimited JSON from the given file, or from stdin
+when no file or - is given. The global processors are applied, preceded by
+the processors of the input selected with --input. The fields, tags and
+metadata the Beat adds to every event are not added.`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			path := "-"
+			if len(args) > 0 {
+				path = args[0]
+			}
+			if err := runTestProcessors(settings, path, inputsKey, inputID); err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&inputID, "input", "", "ID or position in the list of inputs of the input whose processors are applied")
+	defaultInputsKey := settings.InputsKey
+	if defaultInputsKey == "" {
+		defaultInputsKey = settings.Name + ".inputs"
+	}
+	cmd.Flags().StringVar(&inputsKey, "inputs-key", defaultInputsKey, "Setting holding the list of inputs")
+	return cmd
+}
+
+func runTestProcessors(settings instance.Settings, path, inputsKey, inputID string) error {
+	b, err := instance.NewInitializedBeat(settings)
+	if err != nil {
+		return fmt.Errorf("error initializing beat: %w", err)
+	}
+	log := b.Info.Logger.Named("test_processors")
+
+	// Input processors run before the global processors, as in the pipeline.
+	procs := processors.NewList(log)
+	if inputID != "" {
+		inputConfig, err := findInput(b.RawConfig, inputsKey, inputID)
+		if err != nil {
+			return err
+		}
+		inputProcs, err := loadProcessors(inputConfig, log)
+		if err != nil {
+			return fmt.Errorf("error loading the processors of input %v: %w", inputID, err)
+		}
+		procs.AddProcessors(*inputProcs)
+	}
+	globalProcs, err := loadProcessors(b.RawConfig, log)
+	if err != nil {
+		_ = procs.Close()
+		return fmt.Errorf("error loading the global processors: %w", err)
+	}
+	procs.AddProcessors(*globalProcs)
+
+	closed := false
+	defer func() {
+		if !closed {
+			_ = procs.Close()
+		}
+	}()
+
+	published := &publishedEvents{}
+	defer processors.RegisterPipeline(b.Info.Paths, published)()
+	for _, p := range procs.List {
+		if setter, ok := p.(processors.PathSetter); ok {
+			if err := setter.SetPaths(b.Info.Paths); err != nil {
+				return fmt.Errorf("error initializing processor %v: %w", p, err)
+			}
+		}
+	}
+
+	in := os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	failed, err := testProcessors(os.Stdout, in, procs.List)
+	if err != nil {
+		return err
+	}
+
+	// Processors like aggregate publish their pending events when closed.
+	closed = true
+	closeErr := procs.Close()
+	published.print(os.Stdout)
+	if closeErr != nil {
+		return fmt.Errorf("error closing the processors: %w", closeErr)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d events failed in at least one processor", failed)
+	}
+	return nil
+}
+
+// findInput returns the configuration of the input with the given ID in the
+// list of inputs at key. An ID that is a number and matches no input ID is
+// the position of the input in the list, starting at 0.
+func findInput(cfg *config.C, key, id string) (*config.C, error) {
+	// A missing list is reported as a missing input below.
+	var inputs []*config.C
+	if list, err := cfg.Child(key, -1); err == nil {
+		if err := list.Unpack(&inputs); err != nil {
+			return nil, fmt.Errorf("error reading %v: %w", key, err)
+		}
+	}
+
+	for _, input := range inputs {
+		var settings struct {
+			ID string `config:"id"`
+		}
+		if err := input.Unpack(&settings); err == nil && settings.ID == id {
+			return input, nil
+		}
+	}
+	if i, err := strconv.Atoi(id); err == nil && i >= 0 && i < len(inputs) {
+		return inputs[i], nil
+	}
+	return nil, fmt.Errorf("no input with ID %v in %v", id, key)
+}
+
+// loadProcessors creates the processors configured in the processors setting
+// of cfg.
+func loadProcessors(cfg *config.C, log *logp.Logger) (*processors.Processors, error) {
+	var settings struct {
+		Processors processors.PluginConfig `config:"processors"`
+	}
+	if err := cfg.Unpack(&settings); err != nil {
+		return nil, err
+	}
+	return processors.New(settings.Processors, log)
+}
+
+// testProcessors runs the events read as NDJSON from in through chain and
+// writes the event after each processor to w. As in the pipeline, an error
+// does not stop the processing of an event as long as the processor returned
+// it. It returns the number of events for which a processor failed.
+func testProcessors(w io.Writer, in io.Reader, chain []beat.Processor) (int, error) {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEventSize)
+
+	var n, failed int
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		event, err := decodeEvent(data)
+		if err != nil {
+			return failed, fmt.Errorf("error decoding the event on line %d: %w", line, err)
+		}
+
+		n++
+		fmt.Fprintf(w, "Event %d (line %d):\n", n, line)
+		fmt.Fprintf(w, "  input: %s\n", event)
+
+		var total time.Duration
+		var hasFailed bool
+		for i, p := range chain {
+			start := time.Now()
+			event, err = p.Run(event)
+			elapsed := time.Since(start)
+			total += elapsed
+
+			fmt.Fprintf(w, "  [%d] %v (%v)\n", i+1, p, elapsed)
+			if err != nil {
+				hasFailed = true
+				fmt.Fprintf(w, "    error: %v\n", err)
+			}
+			if event == nil {
+				fmt.Fprintf(w, "    dropped\n")
+				break
+			}
+			fmt.Fprintf(w, "    %s\n", event)
+		}
+		if hasFailed {
+			failed++
+		}
+
+		if event != nil {
+			fmt.Fprintf(w, "  output (%v): %s\n\n", total, event)
+		} else {
+			fmt.Fprintf(w, "  output (%v): dropped\n\n", total)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return failed, fmt.Errorf("error reading events: %w", err)
+	}
+	return failed, nil
+}
+
+// decodeEvent decodes a JSON object into an event. The @timestamp and
+// @metadata keys set the timestamp and metadata of the event, a missing
+// timestamp is the current time.
+func decodeEvent(data []byte) (*beat.Event, error) {
+	var fields mapstr.M
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&fields); err != nil {
+		return nil, err
+	}
+	jsontransform.TransformNumbers(fields)
+
+	event := &beat.Event{Timestamp: time.Now(), Fields: mapstr.M{}}
+	jsontransform.WriteJSONKeys(event, fields, false, true, true)
+	return event, nil
+}
+
+// publishedEvents is the pipeline given to processors publishing events of
+// their own, like the rollups of the aggregate processor. It keeps the
+// events to print them.
+type publishedEvents struct {
+	mu     sync.Mutex
+	events []beat.Event
+}
+
+func (p *publishedEvents) ConnectWith(beat.ClientConfig) (beat.Client, error) { return p, nil }
+
+func (p *publishedEvents) Connect() (beat.Client, error) { return p, nil }
+
+func (p *publishedEvents) Disconnect(context.Context) error { return nil }
+
+func (p *publishedEvents) Publish(event beat.Event) {
+	p.PublishAll([]beat.Event{event})
+}
+
+func (p *publishedEvents) PublishAll(events []beat.Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, events...)
+}
+
+func (p *publishedEvents) Close() error { return nil }
+
+func (p *publishedEvents) print(w io.Writer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i := range p.events {
+		fmt.Fprintf(w, "Published by a processor: %s\n", &p.events[i])
+	}
+}
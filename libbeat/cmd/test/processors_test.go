@@ -0,0 +1,124 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+type testProcessor struct {
+	name string
+	fn   func(*beat.Event) (*beat.Event, error)
+}
+
+func (p testProcessor) Run(event *beat.Event) (*beat.Event, error) { return p.fn(event) }
+
+func (p testProcessor) String() string { return p.name }
+
+func TestTestProcessors(t *testing.T) {
+	chain := []beat.Processor{
+		testProcessor{name: "tag", fn: func(event *beat.Event) (*beat.Event, error) {
+			_, err := event.PutValue("tags", []string{"tested"})
+			return event, err
+		}},
+		testProcessor{name: "fail_on_error", fn: func(event *beat.Event) (*beat.Event, error) {
+			if level, _ := event.GetValue("log.level"); level == "error" {
+				return event, errors.New("error level")
+			}
+			return event, nil
+		}},
+		testProcessor{name: "drop_debug", fn: func(event *beat.Event) (*beat.Event, error) {
+			if level, _ := event.GetValue("log.level"); level == "debug" {
+				return nil, nil
+			}
+			return event, nil
+		}},
+	}
+
+	in := strings.NewReader(`{"@timestamp": "2024-05-06T07:08:09Z", "log": {"level": "info"}, "count": 3}
+
+{"log": {"level": "debug"}}
+{"log": {"level": "error"}}
+`)
+	var out strings.Builder
+	failed, err := testProcessors(&out, in, chain)
+	require.NoError(t, err)
+	assert.Equal(t, 1, failed)
+
+	events := strings.Split(strings.TrimSpace(out.String()), "\n\n")
+	require.Len(t, events, 3)
+
+	assert.Contains(t, events[0], "Event 1 (line 1):")
+	assert.Contains(t, events[0], `"@timestamp":"2024-05-06T07:08:09Z"`)
+	assert.Contains(t, events[0], `"count":3`)
+	assert.Contains(t, events[0], "[1] tag (")
+	assert.Contains(t, events[0], "[3] drop_debug (")
+	assert.Regexp(t, `output \(.+\): \{.*"tags":\["tested"\]`, events[0])
+
+	assert.Contains(t, events[1], "Event 2 (line 3):")
+	assert.Contains(t, events[1], "    dropped")
+	assert.Contains(t, events[1], "): dropped")
+
+	assert.Contains(t, events[2], "Event 3 (line 4):")
+	assert.Contains(t, events[2], "    error: error level")
+	assert.Contains(t, events[2], "[3] drop_debug (", "processing continues after an error")
+
+	_, err = testProcessors(&out, strings.NewReader("{\"valid\": true}\nnot json\n"), chain)
+	assert.ErrorContains(t, err, "line 2")
+}
+
+func TestDecodeEvent(t *testing.T) {
+	event, err := decodeEvent([]byte(`{"@timestamp": "2024-05-06T07:08:09Z", "@metadata": {"pipeline": "p"}, "n": 1, "f": 1.5}`))
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC), event.Timestamp.UTC())
+	assert.Equal(t, mapstr.M{"pipeline": "p"}, event.Meta)
+	assert.Equal(t, mapstr.M{"n": int64(1), "f": 1.5}, event.Fields)
+}
+
+func TestFindInput(t *testing.T) {
+	cfg := config.MustNewConfigFrom(map[string]any{
+		"filebeat.inputs": []any{
+			map[string]any{"id": "logs", "processors": []any{map[string]any{"drop_event": nil}}},
+			map[string]any{"type": "udp"},
+		},
+	})
+
+	input, err := findInput(cfg, "filebeat.inputs", "logs")
+	require.NoError(t, err)
+	assert.True(t, input.HasField("processors"))
+
+	input, err = findInput(cfg, "filebeat.inputs", "1")
+	require.NoError(t, err)
+	typ, _ := input.String("type", -1)
+	assert.Equal(t, "udp", typ)
+
+	_, err = findInput(cfg, "filebeat.inputs", "2")
+	assert.Error(t, err)
+	_, err = findInput(cfg, "metricbeat.modules", "logs")
+	assert.Error(t, err)
+}
@@ -17,7 +17,12 @@
 
 package conditions
 
-import "strings"
+import (
+	"errors"
+	"strings"
+
+	"github.com/elastic/elastic-agent-libs/paths"
+)
 
 // And is a compound condition that combines multiple conditions with logical AND.
 type And []Condition
@@ -37,6 +42,15 @@ func (c And) Check(event ValuesMap) bool {
 	return true
 }
 
+// SetPaths sets the paths of the combined conditions.
+func (c And) SetPaths(p *paths.Path) error {
+	var errs []error
+	for _, cond := range c {
+		errs = append(errs, SetPaths(cond, p))
+	}
+	return errors.Join(errs...)
+}
+
 func (c And) String() (s string) {
 	var strSlice = make([]string, len(c))
 
@@ -22,21 +22,25 @@ import (
 
 	"github.com/elastic/beats/v7/libbeat/common/match"
 	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/paths"
 )
 
 const logName = "conditions"
 
 // Config represents a configuration for a condition, as you would find it in the config files.
 type Config struct {
-	Equals    *Fields        `config:"equals"`
-	Contains  *Fields        `config:"contains"`
-	Regexp    *Fields        `config:"regexp"`
-	Range     *Fields        `config:"range"`
-	HasFields []string       `config:"has_fields"`
-	Network   map[string]any `config:"network"`
-	OR        []Config       `config:"or"`
-	AND       []Config       `config:"and"`
-	NOT       *Config        `config:"not"`
+	Equals     *Fields           `config:"equals"`
+	Contains   *Fields           `config:"contains"`
+	Regexp     *Fields           `config:"regexp"`
+	Range      *Fields           `config:"range"`
+	HasFields  []string          `config:"has_fields"`
+	Network    map[string]any    `config:"network"`
+	InList     *InListConfig     `config:"in_list"`
+	TimeWindow *TimeWindowConfig `config:"time_window"`
+	Expression string            `config:"expression"`
+	OR         []Config          `config:"or"`
+	AND        []Config          `config:"and"`
+	NOT        *Config           `config:"not"`
 }
 
 // Condition is the interface for all defined conditions
@@ -45,6 +49,21 @@ type Condition interface {
 	String() string
 }
 
+// PathSetter is implemented by conditions reading files, whose relative paths
+// are resolved against the configuration path. SetPaths must be called before
+// such a condition can match events.
+type PathSetter interface {
+	SetPaths(*paths.Path) error
+}
+
+// SetPaths sets the paths of the condition if it implements PathSetter.
+func SetPaths(c Condition, p *paths.Path) error {
+	if setter, ok := c.(PathSetter); ok {
+		return setter.SetPaths(p)
+	}
+	return nil
+}
+
 // ValuesMap provides a common interface to read matchers for condition checking
 type ValuesMap interface {
 	// GetValue returns the given field from the map
@@ -73,6 +92,12 @@ func NewCondition(config *Config, logger *logp.Logger) (Condition, error) {
 		condition = NewHasFieldsCondition(config.HasFields)
 	case config.Network != nil && len(config.Network) > 0:
 		condition, err = NewNetworkCondition(config.Network, logger)
+	case config.InList != nil:
+		condition, err = NewInListCondition(*config.InList, logger)
+	case config.TimeWindow != nil:
+		condition, err = NewTimeWindowCondition(*config.TimeWindow)
+	case config.Expression != "":
+		condition, err = NewExpressionCondition(config.Expression)
 	case len(config.OR) > 0:
 		var conditionsList []Condition
 		conditionsList, err = NewConditionList(config.OR, logger)
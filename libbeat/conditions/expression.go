@@ -0,0 +1,97 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package conditions
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	celscript "github.com/elastic/beats/v7/libbeat/processors/script/cel"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// Expression is a Condition evaluating a CEL expression over the event, for
+// example:
+//
+//	event.http.response.bytes > 1024 * 1024 && event.source.ip != event.destination.ip
+//
+// The expression is compiled in the environment of the cel script processor,
+// where event holds the fields of the event, and must evaluate to a bool. A
+// failed evaluation, for example because of a missing field, is false.
+type Expression struct {
+	source string
+	prg    cel.Program
+}
+
+// rawMapper is implemented by the values maps that are not maps themselves,
+// such as the pdata values of the otel processors.
+type rawMapper interface {
+	AsRaw() map[string]any
+}
+
+var mapType = reflect.TypeOf(mapstr.M{})
+
+// NewExpressionCondition compiles the given expression into an Expression condition.
+func NewExpressionCondition(source string) (*Expression, error) {
+	env, err := celscript.NewEnv()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the CEL environment: %w", err)
+	}
+	ast, iss := env.Compile(source)
+	if iss.Err() != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", source, iss.Err())
+	}
+	if t := ast.OutputType(); !t.IsExactType(cel.BoolType) {
+		return nil, fmt.Errorf("invalid expression %q: it must evaluate to a bool, not %v", source, t)
+	}
+	prg, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", source, err)
+	}
+	return &Expression{source: source, prg: prg}, nil
+}
+
+// Check determines whether the given event matches this condition.
+func (c *Expression) Check(event ValuesMap) bool {
+	var fields map[string]any
+	switch v := event.(type) {
+	case *beat.Event:
+		fields = celscript.EventFields(v)
+	case mapstr.M:
+		fields = v
+	case rawMapper:
+		fields = v.AsRaw()
+	default:
+		rv := reflect.ValueOf(event)
+		if !rv.IsValid() || !rv.Type().ConvertibleTo(mapType) {
+			return false
+		}
+		fields = rv.Convert(mapType).Interface().(mapstr.M)
+	}
+
+	out, _, err := c.prg.Eval(celscript.Activation(fields, nil))
+	return err == nil && out == types.True
+}
+
+func (c *Expression) String() string {
+	return "expression: " + c.source
+}
@@ -0,0 +1,101 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package conditions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestExpression(t *testing.T) {
+	event := &beat.Event{
+		Timestamp: time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC),
+		Meta:      mapstr.M{"pipeline": "logs"},
+		Fields: mapstr.M{
+			"source":      mapstr.M{"ip": "10.0.0.1", "port": 51234, "bytes": int64(512)},
+			"destination": mapstr.M{"ip": "10.0.0.2", "port": uint16(443), "bytes": 2048.5},
+			"user-agent":  "curl/8.0",
+			"tls":         true,
+			"tags":        []string{"edge", "prod"},
+		},
+	}
+
+	tests := map[string]bool{
+		`event.source.ip != event.destination.ip`:                            true,
+		`event.source.ip == event.destination.ip`:                            false,
+		`event.destination.port == 443`:                                      true,
+		`double(event.destination.bytes) > double(event.source.bytes) * 4.0`: true,
+		`event.source.port > 1024 || event.missing.field == 1`:               true,
+		`event.missing.field == 1`:                                           false,
+		`!(event.missing.field == 1)`:                                        false,
+		`!has(event.missing)`:                                                true,
+		`event["user-agent"].startsWith("curl/")`:                            true,
+		`event.tls && event.destination.port == 443`:                         true,
+		`event.tls == false`:                                                 false,
+		`"prod" in event.tags`:                                               true,
+		`event["@metadata"].pipeline == "logs"`:                              true,
+		`event["@timestamp"] < timestamp("2026-10-17T00:00:00Z")`:            true,
+		`event.source.ip.matches("^10[.]")`:                                  true,
+	}
+
+	for expr, expected := range tests {
+		t.Run(expr, func(t *testing.T) {
+			cond, err := NewExpressionCondition(expr)
+			require.NoError(t, err)
+			assert.Equal(t, expected, cond.Check(event))
+		})
+	}
+}
+
+func TestExpressionValuesMaps(t *testing.T) {
+	cond, err := NewExpressionCondition(`event.source.port == 22`)
+	require.NoError(t, err)
+
+	assert.True(t, cond.Check(mapstr.M{"source": mapstr.M{"port": 22}}))
+	assert.False(t, cond.Check(mapstr.M{"source": mapstr.M{"port": "22"}}))
+	assert.True(t, cond.Check(eventMap{"source": mapstr.M{"port": 22}}))
+}
+
+// eventMap is a values map of a type of its own, like the events of
+// autodiscover templates.
+type eventMap mapstr.M
+
+func (m eventMap) GetValue(key string) (any, error) { return mapstr.M(m).GetValue(key) }
+
+func TestExpressionInvalid(t *testing.T) {
+	for _, expr := range []string{
+		``,
+		`event.source.port >`,
+		`(event.source.port > 1`,
+		`event.source.port = 1`,
+		`'unterminated`,
+		`"a" == 1`,
+		`event.source.port + 1`,
+		`event.tls`,
+		`source.port == 1`,
+	} {
+		_, err := NewExpressionCondition(expr)
+		assert.Error(t, err, expr)
+	}
+}
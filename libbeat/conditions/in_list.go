@@ -0,0 +1,361 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package conditions
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+const (
+	inListMatchExact = "exact"
+	inListMatchCIDR  = "cidr"
+)
+
+// InListConfig is the configuration of the in_list condition.
+type InListConfig struct {
+	Field          string        `config:"field" validate:"required"`
+	Values         []string      `config:"values"`
+	File           string        `config:"file"`
+	Match          string        `config:"match"`
+	IgnoreCase     bool          `config:"ignore_case"`
+	ReloadInterval time.Duration `config:"reload_interval"`
+}
+
+// InitDefaults initializes the configuration defaults.
+func (c *InListConfig) InitDefaults() {
+	c.Match = inListMatchExact
+	c.ReloadInterval = time.Minute
+}
+
+// Validate checks the configuration.
+func (c *InListConfig) Validate() error {
+	switch c.Match {
+	case "", inListMatchExact, inListMatchCIDR:
+	default:
+		return fmt.Errorf("invalid in_list match type %q, expected one of %q or %q", c.Match, inListMatchExact, inListMatchCIDR)
+	}
+	if len(c.Values) == 0 && c.File == "" {
+		return errors.New("in_list condition requires values or a file")
+	}
+	if c.ReloadInterval < 0 {
+		return errors.New("in_list reload_interval must not be negative")
+	}
+	return nil
+}
+
+// InList is a Condition checking whether the value of a field is part of a
+// list of values. The list is given inline, read from a file, or both. When
+// read from a file, the file is checked for changes at most once per reload
+// interval and reloaded when it changed.
+//
+// A relative file path is resolved against the configuration path, so the
+// list is only loaded once SetPaths is called. Until then the condition
+// doesn't match any event.
+type InList struct {
+	config InListConfig
+	log    *logp.Logger
+
+	// path is the resolved path of the list file, list is nil until the file
+	// is loaded.
+	path string
+	list atomic.Pointer[valueList]
+
+	// reloadMu serializes reloads, nextCheck holds the earliest time, in Unix
+	// nanoseconds, at which the file is checked for changes again.
+	reloadMu  sync.Mutex
+	nextCheck atomic.Int64
+	modTime   time.Time
+	size      int64
+}
+
+type valueList interface {
+	contains(value string) bool
+	len() int
+}
+
+// NewInListCondition builds a new InList condition from the given configuration.
+func NewInListCondition(config InListConfig, logger *logp.Logger) (*InList, error) {
+	if config.Match == "" {
+		config.Match = inListMatchExact
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	c := &InList{
+		config: config,
+		log:    logger.Named(logName),
+		path:   config.File,
+	}
+	if config.File != "" && !filepath.IsAbs(config.File) {
+		// Validate the inline values now, the list is loaded by SetPaths.
+		if _, err := c.buildList(nil); err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// SetPaths loads the list file when its path is relative, resolving it
+// against the configuration path.
+func (c *InList) SetPaths(p *paths.Path) error {
+	if c.list.Load() != nil {
+		return nil
+	}
+	c.path = p.Resolve(paths.Config, c.config.File)
+	return c.load()
+}
+
+// load reads the list file, if any, and builds the list in use.
+func (c *InList) load() error {
+	var values []string
+	if c.path != "" {
+		info, err := os.Stat(c.path)
+		if err != nil {
+			return fmt.Errorf("in_list condition failed to read %v: %w", c.path, err)
+		}
+		values, err = readListFile(c.path)
+		if err != nil {
+			return fmt.Errorf("in_list condition failed to read %v: %w", c.path, err)
+		}
+		c.modTime, c.size = info.ModTime(), info.Size()
+		c.nextCheck.Store(time.Now().Add(c.config.ReloadInterval).UnixNano())
+	}
+
+	list, err := c.buildList(values)
+	if err != nil {
+		return err
+	}
+	c.list.Store(&list)
+	return nil
+}
+
+// Check determines whether the given event matches this condition. Fields
+// holding multiple values match if any of them is part of the list.
+func (c *InList) Check(event ValuesMap) bool {
+	if c.list.Load() == nil {
+		return false
+	}
+	c.maybeReload()
+
+	value, err := event.GetValue(c.config.Field)
+	if err != nil {
+		return false
+	}
+
+	list := *c.list.Load()
+	switch v := value.(type) {
+	case string:
+		return list.contains(v)
+	case []string:
+		return slices.ContainsFunc(v, list.contains)
+	case []any:
+		for _, elem := range v {
+			if list.contains(fmt.Sprint(elem)) {
+				return true
+			}
+		}
+		return false
+	default:
+		return list.contains(fmt.Sprint(v))
+	}
+}
+
+func (c *InList) String() string {
+	list := c.list.Load()
+	if list == nil {
+		return fmt.Sprintf("in_list: %v %v (file %v not loaded)", c.config.Field, c.config.Match, c.config.File)
+	}
+	if c.config.File != "" {
+		return fmt.Sprintf("in_list: %v %v %d values (file %v)", c.config.Field, c.config.Match, (*list).len(), c.path)
+	}
+	return fmt.Sprintf("in_list: %v %v %d values", c.config.Field, c.config.Match, (*list).len())
+}
+
+// maybeReload reloads the list file when the reload interval has elapsed and
+// the file changed. Events checked concurrently with a reload keep using the
+// current list. A file that cannot be read keeps the current list in use.
+func (c *InList) maybeReload() {
+	if c.config.File == "" || c.config.ReloadInterval <= 0 {
+		return
+	}
+	now := time.Now()
+	if now.UnixNano() < c.nextCheck.Load() || !c.reloadMu.TryLock() {
+		return
+	}
+	defer c.reloadMu.Unlock()
+	c.nextCheck.Store(now.Add(c.config.ReloadInterval).UnixNano())
+
+	info, err := os.Stat(c.path)
+	if err != nil {
+		c.log.Errorf("in_list condition failed to check %v, keeping the current list: %v", c.path, err)
+		return
+	}
+	if info.ModTime().Equal(c.modTime) && info.Size() == c.size {
+		return
+	}
+
+	values, err := readListFile(c.path)
+	if err == nil {
+		var list valueList
+		list, err = c.buildList(values)
+		if err == nil {
+			c.list.Store(&list)
+		}
+	}
+	if err != nil {
+		c.log.Errorf("in_list condition failed to reload %v, keeping the current list: %v", c.path, err)
+		return
+	}
+	c.modTime, c.size = info.ModTime(), info.Size()
+	c.log.Debugf("in_list condition reloaded %v", c.path)
+}
+
+func (c *InList) buildList(fileValues []string) (valueList, error) {
+	values := make([]string, 0, len(c.config.Values)+len(fileValues))
+	values = append(values, c.config.Values...)
+	values = append(values, fileValues...)
+
+	if c.config.Match == inListMatchCIDR {
+		return newCIDRList(values)
+	}
+	return newExactList(values, c.config.IgnoreCase), nil
+}
+
+// readListFile reads a file holding one value per line. Empty lines and lines
+// starting with # are ignored.
+func readListFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var values []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		values = append(values, line)
+	}
+	return values, scanner.Err()
+}
+
+type exactList struct {
+	values     map[string]struct{}
+	ignoreCase bool
+}
+
+func newExactList(values []string, ignoreCase bool) *exactList {
+	l := &exactList{values: make(map[string]struct{}, len(values)), ignoreCase: ignoreCase}
+	for _, v := range values {
+		if ignoreCase {
+			v = strings.ToLower(v)
+		}
+		l.values[v] = struct{}{}
+	}
+	return l
+}
+
+func (l *exactList) contains(value string) bool {
+	if l.ignoreCase {
+		value = strings.ToLower(value)
+	}
+	_, found := l.values[value]
+	return found
+}
+
+func (l *exactList) len() int { return len(l.values) }
+
+// cidrList holds networks indexed by prefix length, so that a lookup costs one
+// map access per distinct prefix length in the list.
+type cidrList struct {
+	prefixes map[netip.Prefix]struct{}
+	bits     []int
+}
+
+func newCIDRList(values []string) (*cidrList, error) {
+	l := &cidrList{prefixes: make(map[netip.Prefix]struct{}, len(values))}
+	for _, v := range values {
+		var prefix netip.Prefix
+		if strings.Contains(v, "/") {
+			p, err := netip.ParsePrefix(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid network %q in in_list condition: %w", v, err)
+			}
+			if p.Addr().Is4In6() && p.Bits() >= 96 {
+				p = netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96)
+			}
+			prefix = p.Masked()
+		} else {
+			addr, err := netip.ParseAddr(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid IP address %q in in_list condition: %w", v, err)
+			}
+			addr = addr.Unmap()
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		l.prefixes[prefix] = struct{}{}
+		if !slices.Contains(l.bits, prefix.Bits()) {
+			l.bits = append(l.bits, prefix.Bits())
+		}
+	}
+	return l, nil
+}
+
+func (l *cidrList) contains(value string) bool {
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap().WithZone("")
+	for _, bits := range l.bits {
+		if bits > addr.BitLen() {
+			continue
+		}
+		prefix, err := addr.Prefix(bits)
+		if err != nil {
+			continue
+		}
+		if _, found := l.prefixes[prefix]; found {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *cidrList) len() int { return len(l.prefixes) }
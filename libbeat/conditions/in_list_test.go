@@ -0,0 +1,193 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package conditions
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+func TestInListConfigUnpack(t *testing.T) {
+	c, err := config.NewConfigWithYAML([]byte(`
+in_list:
+  field: client_ip
+  values: [10.0.0.0/8, 127.0.0.1]
+  match: cidr
+`), "test")
+	require.NoError(t, err)
+
+	var cfg Config
+	require.NoError(t, c.Unpack(&cfg))
+	assert.Equal(t, time.Minute, cfg.InList.ReloadInterval)
+	testConfig(t, true, httpResponseTestEvent, &cfg)
+
+	c, err = config.NewConfigWithYAML([]byte(`
+in_list:
+  field: client_ip
+`), "test")
+	require.NoError(t, err)
+	assert.Error(t, c.Unpack(&Config{}), "values or file are required")
+}
+
+func TestInListExact(t *testing.T) {
+	tests := map[string]struct {
+		config   InListConfig
+		event    mapstr.M
+		expected bool
+	}{
+		"match": {
+			config:   InListConfig{Field: "user.name", Values: []string{"alice", "bob"}},
+			event:    mapstr.M{"user": mapstr.M{"name": "bob"}},
+			expected: true,
+		},
+		"no match": {
+			config: InListConfig{Field: "user.name", Values: []string{"alice", "bob"}},
+			event:  mapstr.M{"user": mapstr.M{"name": "Bob"}},
+		},
+		"ignore case": {
+			config:   InListConfig{Field: "user.name", Values: []string{"alice", "BOB"}, IgnoreCase: true},
+			event:    mapstr.M{"user": mapstr.M{"name": "Bob"}},
+			expected: true,
+		},
+		"any value of a list": {
+			config:   InListConfig{Field: "tags", Values: []string{"blocked"}},
+			event:    mapstr.M{"tags": []string{"web", "blocked"}},
+			expected: true,
+		},
+		"any value of an untyped list": {
+			config:   InListConfig{Field: "ports", Values: []string{"22"}},
+			event:    mapstr.M{"ports": []any{80, 22}},
+			expected: true,
+		},
+		"number": {
+			config:   InListConfig{Field: "port", Values: []string{"22", "23"}},
+			event:    mapstr.M{"port": 22},
+			expected: true,
+		},
+		"missing field": {
+			config: InListConfig{Field: "user.name", Values: []string{"alice"}},
+			event:  mapstr.M{},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cond, err := NewInListCondition(tc.config, logptest.NewTestingLogger(t, ""))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, cond.Check(&beat.Event{Fields: tc.event}))
+		})
+	}
+}
+
+func TestInListCIDR(t *testing.T) {
+	cond, err := NewInListCondition(InListConfig{
+		Field:  "source.ip",
+		Values: []string{"10.0.0.0/8", "192.168.1.1", "2001:db8::/32", "::ffff:172.16.0.0/108"},
+		Match:  inListMatchCIDR,
+	}, logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+
+	for ip, expected := range map[string]bool{
+		"10.1.2.3":          true,
+		"11.1.2.3":          false,
+		"192.168.1.1":       true,
+		"192.168.1.2":       false,
+		"::ffff:10.0.0.1":   true,
+		"2001:db8::1":       true,
+		"2001:db9::1":       false,
+		"172.16.5.5":        true,
+		"172.32.0.1":        false,
+		"not an IP address": false,
+	} {
+		assert.Equal(t, expected, cond.Check(&beat.Event{Fields: mapstr.M{"source": mapstr.M{"ip": ip}}}), ip)
+	}
+
+	_, err = NewInListCondition(InListConfig{
+		Field:  "source.ip",
+		Values: []string{"10.0.0.0/33"},
+		Match:  inListMatchCIDR,
+	}, logptest.NewTestingLogger(t, ""))
+	assert.Error(t, err)
+}
+
+func TestInListFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# blocked users\nalice\n\n  mallory  \n"), 0o600))
+
+	cond, err := NewInListCondition(InListConfig{
+		Field:          "user.name",
+		Values:         []string{"eve"},
+		File:           path,
+		ReloadInterval: time.Millisecond,
+	}, logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+
+	check := func(name string) bool {
+		return cond.Check(&beat.Event{Fields: mapstr.M{"user": mapstr.M{"name": name}}})
+	}
+	assert.True(t, check("alice"))
+	assert.True(t, check("mallory"))
+	assert.True(t, check("eve"))
+	assert.False(t, check("# blocked users"))
+	assert.False(t, check("bob"))
+
+	require.NoError(t, os.WriteFile(path, []byte("bob\n"), 0o600))
+	assert.Eventually(t, func() bool { return check("bob") }, 5*time.Second, time.Millisecond)
+	assert.False(t, check("alice"))
+	assert.True(t, check("eve"), "inline values are kept on reload")
+
+	// A file that can no longer be read keeps the current list.
+	require.NoError(t, os.Remove(path))
+	time.Sleep(5 * time.Millisecond)
+	assert.True(t, check("bob"))
+
+	_, err = NewInListCondition(InListConfig{Field: "user.name", File: path}, logptest.NewTestingLogger(t, ""))
+	assert.Error(t, err)
+}
+
+func TestInListRelativeFile(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "blocklist.txt"), []byte("alice\n"), 0o600))
+
+	c, err := NewCondition(&Config{NOT: &Config{InList: &InListConfig{
+		Field: "user.name",
+		File:  "blocklist.txt",
+	}}}, logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+
+	event := &beat.Event{Fields: mapstr.M{"user": mapstr.M{"name": "alice"}}}
+	assert.True(t, c.Check(event), "the list is empty until SetPaths is called")
+
+	require.NoError(t, SetPaths(c, &paths.Path{Config: dir}))
+	assert.False(t, c.Check(event))
+
+	missing, err := NewInListCondition(InListConfig{Field: "user.name", File: "blocklist.txt"}, logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	assert.Error(t, missing.SetPaths(&paths.Path{Config: t.TempDir()}))
+}
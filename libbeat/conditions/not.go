@@ -17,7 +17,11 @@
 
 package conditions
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/elastic/elastic-agent-libs/paths"
+)
 
 // Not is a condition that negates its inner condition.
 type Not struct {
@@ -37,6 +41,11 @@ func (c Not) Check(event ValuesMap) bool {
 	return !c.inner.Check(event)
 }
 
+// SetPaths sets the paths of the inner condition.
+func (c Not) SetPaths(p *paths.Path) error {
+	return SetPaths(c.inner, p)
+}
+
 func (c Not) String() string {
 	return "!" + c.inner.String()
 }
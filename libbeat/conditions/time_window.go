@@ -0,0 +1,178 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package conditions
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TimeWindowConfig is the configuration of the time_window condition.
+type TimeWindowConfig struct {
+	Start    string   `config:"start" validate:"required"`
+	End      string   `config:"end" validate:"required"`
+	Weekdays []string `config:"weekdays"`
+	Timezone string   `config:"timezone"`
+	Field    string   `config:"field"`
+}
+
+// InitDefaults initializes the configuration defaults.
+func (c *TimeWindowConfig) InitDefaults() {
+	c.Field = "@timestamp"
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// TimeWindow is a Condition checking whether a timestamp falls into a daily
+// time window. A window whose end is before its start spans midnight. Weekdays
+// refer to the day on which the window starts.
+type TimeWindow struct {
+	config   TimeWindowConfig
+	start    time.Duration
+	end      time.Duration
+	weekdays [7]bool
+	location *time.Location
+}
+
+// NewTimeWindowCondition builds a new TimeWindow condition from the given configuration.
+func NewTimeWindowCondition(config TimeWindowConfig) (*TimeWindow, error) {
+	if config.Field == "" {
+		config.Field = "@timestamp"
+	}
+	c := &TimeWindow{config: config, location: time.Local}
+
+	var err error
+	if c.start, err = parseTimeOfDay(config.Start); err != nil {
+		return nil, fmt.Errorf("invalid time_window start: %w", err)
+	}
+	if c.end, err = parseTimeOfDay(config.End); err != nil {
+		return nil, fmt.Errorf("invalid time_window end: %w", err)
+	}
+	if c.start == c.end {
+		return nil, errors.New("time_window start and end must differ")
+	}
+
+	if config.Timezone != "" {
+		c.location, err = time.LoadLocation(config.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time_window timezone: %w", err)
+		}
+	}
+
+	if len(config.Weekdays) == 0 {
+		for i := range c.weekdays {
+			c.weekdays[i] = true
+		}
+	}
+	for _, name := range config.Weekdays {
+		day, found := weekdayNames[strings.ToLower(name)]
+		if !found {
+			return nil, fmt.Errorf("invalid time_window weekday %q", name)
+		}
+		c.weekdays[day] = true
+	}
+
+	return c, nil
+}
+
+// parseTimeOfDay parses a time of day given as HH:MM or HH:MM:SS.
+func parseTimeOfDay(s string) (time.Duration, error) {
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return time.Duration(t.Hour())*time.Hour +
+				time.Duration(t.Minute())*time.Minute +
+				time.Duration(t.Second())*time.Second, nil
+		}
+	}
+	return 0, fmt.Errorf("%q is not a time of day in the form HH:MM or HH:MM:SS", s)
+}
+
+// Check determines whether the given event matches this condition. Events
+// without a valid timestamp in the configured field never match.
+func (c *TimeWindow) Check(event ValuesMap) bool {
+	value, err := event.GetValue(c.config.Field)
+	if err != nil {
+		return false
+	}
+	ts, ok := extractTime(value)
+	if !ok {
+		return false
+	}
+
+	// Use the wall clock time, so that windows are not shifted on days with
+	// a daylight saving time transition.
+	ts = ts.In(c.location)
+	hour, minute, second := ts.Clock()
+	offset := time.Duration(hour)*time.Hour +
+		time.Duration(minute)*time.Minute +
+		time.Duration(second)*time.Second
+	day := ts.Weekday()
+
+	if c.start < c.end {
+		return c.weekdays[day] && offset >= c.start && offset < c.end
+	}
+	// The window spans midnight: the part after midnight belongs to the
+	// window that started the day before.
+	if offset >= c.start {
+		return c.weekdays[day]
+	}
+	if offset < c.end {
+		return c.weekdays[(day+6)%7]
+	}
+	return false
+}
+
+func (c *TimeWindow) String() string {
+	s := fmt.Sprintf("time_window: %v %v-%v %v", c.config.Field, c.config.Start, c.config.End, c.location)
+	if len(c.config.Weekdays) > 0 {
+		s += " " + strings.Join(c.config.Weekdays, ",")
+	}
+	return s
+}
+
+// extractTime returns the time held by v, which is either a time.Time, an
+// RFC 3339 formatted string or a value whose String method returns one.
+func extractTime(v any) (time.Time, bool) {
+	var s string
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case string:
+		s = t
+	case fmt.Stringer:
+		s = t.String()
+	default:
+		return time.Time{}, false
+	}
+	ts, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return ts, true
+}
@@ -0,0 +1,126 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package conditions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestTimeWindow(t *testing.T) {
+	tests := map[string]struct {
+		config   TimeWindowConfig
+		ts       string
+		expected bool
+	}{
+		"inside": {
+			config:   TimeWindowConfig{Start: "09:00", End: "17:00", Timezone: "UTC"},
+			ts:       "2024-05-06T12:00:00Z",
+			expected: true,
+		},
+		"start is inclusive": {
+			config:   TimeWindowConfig{Start: "09:00", End: "17:00", Timezone: "UTC"},
+			ts:       "2024-05-06T09:00:00Z",
+			expected: true,
+		},
+		"end is exclusive": {
+			config: TimeWindowConfig{Start: "09:00", End: "17:00", Timezone: "UTC"},
+			ts:     "2024-05-06T17:00:00Z",
+		},
+		"timezone": {
+			config:   TimeWindowConfig{Start: "09:00", End: "17:00", Timezone: "Europe/Berlin"},
+			ts:       "2024-05-06T07:30:00Z",
+			expected: true,
+		},
+		"weekday": {
+			config: TimeWindowConfig{Start: "09:00", End: "17:00", Timezone: "UTC", Weekdays: []string{"saturday", "Sunday"}},
+			ts:     "2024-05-06T12:00:00Z", // Monday
+		},
+		"across midnight before midnight": {
+			config:   TimeWindowConfig{Start: "22:00", End: "06:00", Timezone: "UTC", Weekdays: []string{"friday"}},
+			ts:       "2024-05-10T23:00:00Z", // Friday
+			expected: true,
+		},
+		"across midnight after midnight": {
+			config:   TimeWindowConfig{Start: "22:00", End: "06:00", Timezone: "UTC", Weekdays: []string{"friday"}},
+			ts:       "2024-05-11T05:59:59Z", // Saturday
+			expected: true,
+		},
+		"across midnight on the wrong day": {
+			config: TimeWindowConfig{Start: "22:00", End: "06:00", Timezone: "UTC", Weekdays: []string{"friday"}},
+			ts:     "2024-05-10T05:00:00Z", // Friday, the window started on Thursday
+		},
+		"across midnight outside": {
+			config: TimeWindowConfig{Start: "22:00", End: "06:00", Timezone: "UTC"},
+			ts:     "2024-05-10T12:00:00Z",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cond, err := NewTimeWindowCondition(tc.config)
+			require.NoError(t, err)
+
+			ts, err := time.Parse(time.RFC3339, tc.ts)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, cond.Check(&beat.Event{Timestamp: ts}))
+		})
+	}
+}
+
+func TestTimeWindowField(t *testing.T) {
+	cond, err := NewTimeWindowCondition(TimeWindowConfig{Start: "09:00", End: "17:00:30", Timezone: "UTC", Field: "event.created"})
+	require.NoError(t, err)
+
+	assert.True(t, cond.Check(&beat.Event{Fields: mapstr.M{"event": mapstr.M{"created": "2024-05-06T17:00:15Z"}}}))
+	assert.False(t, cond.Check(&beat.Event{Fields: mapstr.M{"event": mapstr.M{"created": "2024-05-06T18:00:00Z"}}}))
+	assert.False(t, cond.Check(&beat.Event{Fields: mapstr.M{"event": mapstr.M{"created": "yesterday"}}}))
+	assert.False(t, cond.Check(&beat.Event{Fields: mapstr.M{}}))
+}
+
+func TestTimeWindowConfig(t *testing.T) {
+	c, err := config.NewConfigWithYAML([]byte(`
+time_window:
+  start: "22:00"
+  end: "06:00"
+  weekdays: [saturday, sunday]
+`), "test")
+	require.NoError(t, err)
+
+	var cfg Config
+	require.NoError(t, c.Unpack(&cfg))
+	assert.Equal(t, "@timestamp", cfg.TimeWindow.Field)
+
+	for _, invalid := range []TimeWindowConfig{
+		{Start: "9am", End: "17:00"},
+		{Start: "09:00", End: "25:00"},
+		{Start: "09:00", End: "09:00"},
+		{Start: "09:00", End: "17:00", Weekdays: []string{"someday"}},
+		{Start: "09:00", End: "17:00", Timezone: "Nowhere/Special"},
+	} {
+		_, err := NewTimeWindowCondition(invalid)
+		assert.Error(t, err, "%+v", invalid)
+	}
+}
@@ -295,6 +295,12 @@ func (p PdataValuesMap) GetValue(key string) (any, error) {
 	return v.AsRaw(), nil
 }
 
+// AsRaw returns the map as Go primitives, for the conditions evaluated on all
+// the fields at once.
+func (p PdataValuesMap) AsRaw() map[string]any {
+	return p.M.AsRaw()
+}
+
 // GetAtPath retrieves the value at a dotted key path (e.g. "cloud.instance.id")
 // from m, traversing nested maps as needed.
 // For keys that contain dots, it tries the full key as a literal name first
@@ -0,0 +1,87 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cbor
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ugorjicodec "github.com/ugorji/go/codec"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestCBORCodec(t *testing.T) {
+	cases := map[string]struct {
+		config   Config
+		ts       time.Time
+		meta     mapstr.M
+		in       mapstr.M
+		expected string
+	}{
+		"default cbor": {
+			config:   defaultConfig,
+			in:       mapstr.M{"msg": "message", "count": 3},
+			expected: `{"@timestamp":"0001-01-01T00:00:00.000Z","@metadata":{"beat":"test","type":"_doc","version":"1.2.3"},"msg":"message","count":3}`,
+		},
+		"event metadata": {
+			config:   defaultConfig,
+			meta:     mapstr.M{"pipeline": "logs"},
+			in:       mapstr.M{"msg": "message"},
+			expected: `{"@timestamp":"0001-01-01T00:00:00.000Z","@metadata":{"beat":"test","type":"_doc","version":"1.2.3","pipeline":"logs"},"msg":"message"}`,
+		},
+		"nested timestamps": {
+			config: defaultConfig,
+			in: mapstr.M{
+				"event": mapstr.M{"created": common.Time(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))},
+				"tags":  []string{"a", "b"},
+			},
+			expected: `{"@timestamp":"0001-01-01T00:00:00.000Z","@metadata":{"beat":"test","type":"_doc","version":"1.2.3"},"event":{"created":"2024-01-02T03:04:05.000Z"},"tags":["a","b"]}`,
+		},
+		"PST timezone offset": {
+			config:   Config{LocalTime: true},
+			ts:       time.Time{}.In(time.FixedZone("PST", -8*60*60)),
+			in:       mapstr.M{"msg": "message"},
+			expected: `{"@timestamp":"0000-12-31T16:00:00.000-08:00","@metadata":{"beat":"test","type":"_doc","version":"1.2.3"},"msg":"message"}`,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			codec := New("1.2.3", tc.config)
+			encoded, err := codec.Encode("test", &beat.Event{Timestamp: tc.ts, Meta: tc.meta, Fields: tc.in})
+			require.NoError(t, err)
+
+			handle := &ugorjicodec.CborHandle{}
+			handle.MapType = reflect.TypeOf(map[string]any(nil))
+			var decoded map[string]any
+			require.NoError(t, ugorjicodec.NewDecoder(bytes.NewReader(encoded), handle).Decode(&decoded))
+
+			actual, err := json.Marshal(decoded)
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.expected, string(actual))
+		})
+	}
+}
@@ -32,6 +32,10 @@ type Config struct {
 
 var codecs = map[string]Factory{}
 
+// binaryCodecs holds the codecs whose encoded events can contain any byte,
+// including newlines.
+var binaryCodecs = map[string]bool{}
+
 func RegisterType(name string, gen Factory) {
 	if _, exists := codecs[name]; exists {
 		panic(fmt.Sprintf("output codec '%v' already registered ", name))
@@ -39,6 +43,27 @@ func RegisterType(name string, gen Factory) {
 	codecs[name] = gen
 }
 
+// RegisterBinaryType registers a codec producing binary documents. Binary
+// codecs can't be used by outputs that separate events with newlines.
+func RegisterBinaryType(name string, gen Factory) {
+	RegisterType(name, gen)
+	binaryCodecs[name] = true
+}
+
+// IsBinary reports whether the configured codec produces binary documents.
+func (c Config) IsBinary() bool {
+	return binaryCodecs[c.Namespace.Name()]
+}
+
+// ValidateNewlineDelimited returns an error if the configured codec can't be
+// used by an output writing one event per line.
+func (c Config) ValidateNewlineDelimited() error {
+	if c.IsBinary() {
+		return fmt.Errorf("the %q codec produces binary documents and can't be used with newline delimited events", c.Namespace.Name())
+	}
+	return nil
+}
+
 func CreateEncoder(info beat.Info, cfg Config) (Codec, error) {
 	// default to json codec
 	codec := "json"
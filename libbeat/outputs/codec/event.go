@@ -15,7 +15,7 @@
 // specific language governing permissions and limitations
 // under the License.
 
-package json
+package codec
 
 import (
 	"time"
@@ -24,26 +24,29 @@ import (
 	"github.com/elastic/elastic-agent-libs/mapstr"
 )
 
-// Event describes the event structure for events
-// (in-)directly send to logstash
-type event struct {
+// Event describes the document structure codecs produce for an event: the
+// event fields, plus the timestamp and the event metadata in '@timestamp'
+// and '@metadata'.
+type Event struct {
 	Timestamp time.Time `struct:"@timestamp"`
-	Meta      meta      `struct:"@metadata"`
+	Meta      EventMeta `struct:"@metadata"`
 	Fields    mapstr.M  `struct:",inline"`
 }
 
-// Meta defines common event metadata to be stored in '@metadata'
-type meta struct {
+// EventMeta defines common event metadata to be stored in '@metadata'
+type EventMeta struct {
 	Beat    string         `struct:"beat"`
 	Type    string         `struct:"type"`
 	Version string         `struct:"version"`
 	Fields  map[string]any `struct:",inline"`
 }
 
-func makeEvent(index, version string, in *beat.Event) event {
-	return event{
+// MakeEvent returns the document of an event for the given index and beat
+// version.
+func MakeEvent(index, version string, in *beat.Event) Event {
+	return Event{
 		Timestamp: in.Timestamp,
-		Meta: meta{
+		Meta: EventMeta{
 			Beat:    index,
 			Version: version,
 			Type:    "_doc",
@@ -33,3 +33,8 @@ type Config struct {
 }
 
 var defaultConfig = Config{}
+
+func (c *Config) Validate() error {
+	// events are written to stdout one per line
+	return c.Codec.ValidateNewlineDelimited()
+}
@@ -96,14 +96,15 @@ func newConsole(index string, observer outputs.Observer, codec codec.Codec, logg
 }
 
 func (c *console) Close() error { return nil }
-func (c *console) Publish(_ context.Context, batch publisher.Batch) error {
+func (c *console) Publish(ctx context.Context, batch publisher.Batch) error {
 	st := c.observer
 	events := batch.Events()
 	st.NewBatch(len(events))
 
+	deadLetter := outputs.DeadLetterWriterFrom(ctx)
 	dropped := 0
 	for i := range events {
-		ok := c.publishEvent(&events[i])
+		ok := c.publishEvent(&events[i], deadLetter)
 		if !ok {
 			dropped++
 		}
@@ -120,9 +121,10 @@ func (c *console) Publish(_ context.Context, batch publisher.Batch) error {
 
 var nl = []byte("\n")
 
-func (c *console) publishEvent(event *publisher.Event) bool {
+func (c *console) publishEvent(event *publisher.Event, deadLetter outputs.DeadLetterWriter) bool {
 	serializedEvent, err := c.codec.Encode(c.index, &event.Content)
 	if err != nil {
+		deadLetter.WriteDeadLetters([]publisher.Event{*event}, err)
 		if !event.Guaranteed() {
 			return false
 		}
@@ -135,6 +137,7 @@ func (c *console) publishEvent(event *publisher.Event) bool {
 	if err := c.writeBuffer(serializedEvent); err != nil {
 		c.observer.WriteError(err)
 		c.log.Errorf("Unable to publish events to console: %+v", err)
+		deadLetter.WriteDeadLetters([]publisher.Event{*event}, err)
 		return false
 	}
 
@@ -33,6 +33,7 @@ import (
 	"github.com/elastic/beats/v7/libbeat/common/fmtstr"
 	"github.com/elastic/beats/v7/libbeat/outputs"
 	"github.com/elastic/beats/v7/libbeat/outputs/codec"
+	_ "github.com/elastic/beats/v7/libbeat/outputs/codec/cbor"
 	"github.com/elastic/beats/v7/libbeat/outputs/codec/format"
 	"github.com/elastic/beats/v7/libbeat/outputs/codec/json"
 	"github.com/elastic/beats/v7/libbeat/outputs/outest"
@@ -194,6 +195,15 @@ func TestMakeConsoleCodecConfigErrors(t *testing.T) {
 			},
 			errMsg: "missing required field accessing 'codec.format.string'",
 		},
+		{
+			name: "binary codec",
+			config: mapstr.M{
+				"codec": mapstr.M{
+					"cbor": mapstr.M{},
+				},
+			},
+			errMsg: `the "cbor" codec produces binary documents`,
+		},
 	}
 
 	for _, tc := range tests {
@@ -0,0 +1,61 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package outputs
+
+import (
+	"context"
+
+	"github.com/elastic/beats/v7/libbeat/publisher"
+)
+
+// DeadLetterWriter stores events that an output failed to publish
+// permanently, together with the reason of the failure, so they can be
+// replayed later.
+type DeadLetterWriter interface {
+	WriteDeadLetters(events []publisher.Event, reason error)
+	Close() error
+}
+
+type deadLetterWriterKey struct{}
+
+type nilDeadLetterWriter struct{}
+
+func (nilDeadLetterWriter) WriteDeadLetters([]publisher.Event, error) {}
+func (nilDeadLetterWriter) Close() error                              { return nil }
+
+// WithDeadLetterWriter returns a copy of ctx carrying w. The publisher
+// pipeline passes the dead letter writer of an output group to its clients
+// this way.
+func WithDeadLetterWriter(ctx context.Context, w DeadLetterWriter) context.Context {
+	if w == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, deadLetterWriterKey{}, w)
+}
+
+// DeadLetterWriterFrom returns the dead letter writer carried by the context
+// passed to Publish. If there is none, the returned writer discards events, so
+// outputs can always report the events they drop permanently.
+func DeadLetterWriterFrom(ctx context.Context) DeadLetterWriter {
+	if ctx != nil {
+		if w, ok := ctx.Value(deadLetterWriterKey{}).(DeadLetterWriter); ok {
+			return w
+		}
+	}
+	return nilDeadLetterWriter{}
+}
@@ -0,0 +1,116 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package deadletter writes the events that outputs fail to publish
+// permanently to rotating local files, and reads them back so they can be
+// replayed.
+//
+// Every line of a dead letter file is a JSON record holding the time of the
+// failure, the output type, the reason of the failure and the event, encoded
+// like the JSON codec does.
+package deadletter
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/file"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+// fileExtension is the extension the rotator gives to the files it writes.
+const fileExtension = ".ndjson"
+
+// Config is the `dead_letter` section of the output settings.
+type Config struct {
+	Enabled       bool   `config:"enabled"`
+	Path          string `config:"path"`
+	Filename      string `config:"filename"`
+	RotateEveryKb uint   `config:"rotate_every_kb" validate:"min=1"`
+	NumberOfFiles uint   `config:"number_of_files"`
+	Permissions   uint32 `config:"permissions"`
+}
+
+// DefaultConfig returns the default dead letter settings. Dead letter files
+// are disabled by default.
+func DefaultConfig() Config {
+	return Config{
+		Enabled:       false,
+		RotateEveryKb: 10 * 1024,
+		NumberOfFiles: 7,
+		Permissions:   0600,
+	}
+}
+
+func (c *Config) Validate() error {
+	if c.NumberOfFiles < 2 || c.NumberOfFiles > file.MaxBackupsLimit {
+		return fmt.Errorf("the number_of_files to keep should be between 2 and %v",
+			file.MaxBackupsLimit)
+	}
+	return nil
+}
+
+// ConfigFromOutput reads the `dead_letter` section of the settings of an
+// output.
+func ConfigFromOutput(cfg *config.C) (Config, error) {
+	c := DefaultConfig()
+	if cfg == nil || !cfg.HasField("dead_letter") {
+		return c, nil
+	}
+
+	sub, err := cfg.Child("dead_letter", -1)
+	if err != nil {
+		return c, err
+	}
+	if err := sub.Unpack(&c); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// FilePath returns the path, without the rotation suffix, of the dead letter
+// files of an output.
+func (c Config) FilePath(info beat.Info, output string) string {
+	dir := c.Path
+	if dir == "" {
+		beatPaths := info.Paths
+		if beatPaths == nil {
+			beatPaths = paths.Paths
+		}
+		dir = beatPaths.Resolve(paths.Data, "dead_letter")
+	}
+
+	name := c.Filename
+	if name == "" {
+		name = info.Beat + "-" + output
+	}
+	return filepath.Join(dir, name)
+}
+
+// Files returns the existing dead letter files of an output, oldest first.
+func (c Config) Files(info beat.Info, output string) ([]string, error) {
+	files, err := filepath.Glob(c.FilePath(info, output) + "-*" + fileExtension)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead letter files: %w", err)
+	}
+	// The rotator suffixes are dates followed by an index, which sort in
+	// the order the files were written.
+	sortRotatedFiles(files)
+	return files, nil
+}
@@ -0,0 +1,210 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package deadletter
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func testInfo(t *testing.T) beat.Info {
+	return beat.Info{
+		Beat:    "testbeat",
+		Version: "9.9.9",
+		Logger:  logptest.NewTestingLogger(t, ""),
+	}
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	c := DefaultConfig()
+	c.Path = t.TempDir()
+
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	w := NewWriter(testInfo(t), "kafka", c)
+	w.WriteDeadLetters([]publisher.Event{
+		{Content: beat.Event{
+			Timestamp: ts,
+			Meta:      mapstr.M{"pipeline": "logs"},
+			Fields: mapstr.M{
+				"message": "hello",
+				"count":   42,
+				"ratio":   0.5,
+				"tags":    []string{"a", "b"},
+				"host":    mapstr.M{"name": "test"},
+			},
+		}},
+		// Already encoded events are skipped.
+		{EncodedEvent: struct{}{}},
+	}, errors.New("message too large"))
+	require.NoError(t, w.Close())
+
+	files, err := c.Files(testInfo(t), "kafka")
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, c.Path, filepath.Dir(files[0]))
+
+	f, err := os.Open(files[0])
+	require.NoError(t, err)
+	defer f.Close()
+
+	r := NewReader(f)
+	rec, raw, err := r.Next()
+	require.NoError(t, err)
+	assert.NotEmpty(t, raw)
+	assert.Equal(t, "kafka", rec.Output)
+	assert.Equal(t, "message too large", rec.Reason)
+
+	event, err := rec.BeatEvent()
+	require.NoError(t, err)
+	assert.True(t, ts.Equal(event.Timestamp))
+	assert.Equal(t, mapstr.M{"pipeline": "logs"}, event.Meta)
+	assert.Equal(t, mapstr.M{
+		"message": "hello",
+		"count":   int64(42),
+		"ratio":   0.5,
+		"tags":    []any{"a", "b"},
+		"host":    mapstr.M{"name": "test"},
+	}, event.Fields)
+
+	_, _, err = r.Next()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestWriterClosed(t *testing.T) {
+	c := DefaultConfig()
+	c.Path = t.TempDir()
+
+	w := NewWriter(testInfo(t), "redis", c)
+	require.NoError(t, w.Close())
+	w.WriteDeadLetters([]publisher.Event{
+		{Content: beat.Event{Fields: mapstr.M{"message": "late"}}},
+	}, errors.New("failed"))
+
+	files, err := c.Files(testInfo(t), "redis")
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestReaderInvalidRecord(t *testing.T) {
+	r := NewReader(strings.NewReader("\n{\"output\":\"http\",\"event\":{}}\nnot json\n"))
+	rec, _, err := r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "http", rec.Output)
+
+	_, _, err = r.Next()
+	assert.ErrorContains(t, err, "line 3")
+}
+
+func TestConfigFromOutput(t *testing.T) {
+	c, err := ConfigFromOutput(config.MustNewConfigFrom(map[string]any{
+		"hosts": []string{"localhost:9092"},
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, DefaultConfig(), c)
+
+	c, err = ConfigFromOutput(config.MustNewConfigFrom(map[string]any{
+		"dead_letter.enabled":         true,
+		"dead_letter.filename":        "failed",
+		"dead_letter.number_of_files": 3,
+	}))
+	require.NoError(t, err)
+	assert.True(t, c.Enabled)
+	assert.Equal(t, uint(3), c.NumberOfFiles)
+	assert.Equal(t, filepath.Join("/data", "failed"), Config{Path: "/data", Filename: "failed"}.FilePath(beat.Info{}, "kafka"))
+
+	_, err = ConfigFromOutput(config.MustNewConfigFrom(map[string]any{
+		"dead_letter.number_of_files": 1,
+	}))
+	assert.ErrorContains(t, err, "number_of_files")
+}
+
+func TestSortRotatedFiles(t *testing.T) {
+	files := []string{
+		"/data/beat-kafka-20240102.ndjson",
+		"/data/beat-kafka-20240101-2.ndjson",
+		"/data/beat-kafka-20240101-10.ndjson",
+		"/data/beat-kafka-20240101.ndjson",
+	}
+	sortRotatedFiles(files)
+	assert.Equal(t, []string{
+		"/data/beat-kafka-20240101.ndjson",
+		"/data/beat-kafka-20240101-2.ndjson",
+		"/data/beat-kafka-20240101-10.ndjson",
+		"/data/beat-kafka-20240102.ndjson",
+	}, files)
+}
+
+type testEncodedEvent struct {
+	doc  string
+	meta mapstr.M
+}
+
+func (e testEncodedEvent) DeadLetterDocument() ([]byte, mapstr.M) {
+	return []byte(e.doc), e.meta
+}
+
+func TestWriterEncodedEvent(t *testing.T) {
+	c := DefaultConfig()
+	c.Path = t.TempDir()
+
+	w := NewWriter(testInfo(t), "elasticsearch", c)
+	w.WriteDeadLetters([]publisher.Event{
+		{EncodedEvent: testEncodedEvent{
+			doc:  `{"@timestamp":"2024-01-02T03:04:05.000Z","message":"hello","count":1}`,
+			meta: mapstr.M{"pipeline": "logs"},
+		}},
+		// An event that failed to encode has no document left.
+		{EncodedEvent: testEncodedEvent{}},
+	}, errors.New("mapping conflict"))
+	require.NoError(t, w.Close())
+
+	files, err := c.Files(testInfo(t), "elasticsearch")
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	f, err := os.Open(files[0])
+	require.NoError(t, err)
+	defer f.Close()
+
+	r := NewReader(f)
+	rec, _, err := r.Next()
+	require.NoError(t, err)
+	event, err := rec.BeatEvent()
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), event.Timestamp)
+	assert.Equal(t, mapstr.M{"pipeline": "logs"}, event.Meta)
+	assert.Equal(t, mapstr.M{"message": "hello", "count": int64(1)}, event.Fields)
+
+	_, _, err = r.Next()
+	assert.ErrorIs(t, err, io.EOF)
+}
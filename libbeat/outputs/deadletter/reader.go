@@ -0,0 +1,167 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package deadletter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/file"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// maxRecordSize is the maximum size of a line in a dead letter file.
+const maxRecordSize = 64 * 1024 * 1024
+
+// codecMetadataKeys are the @metadata fields added by the JSON codec when
+// the event was written. They aren't part of the original event.
+var codecMetadataKeys = []string{"beat", "type", "version"}
+
+// Reader reads the records of a dead letter file.
+type Reader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewReader creates a Reader reading records from r.
+func NewReader(r io.Reader) *Reader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxRecordSize)
+	return &Reader{scanner: scanner}
+}
+
+// Next returns the next record, or io.EOF once all records were read.
+func (r *Reader) Next() (Record, []byte, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := bytes.TrimSpace(r.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var rec Record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return Record{}, nil, fmt.Errorf("invalid dead letter record on line %d: %w", r.line, err)
+		}
+		raw := make([]byte, len(line))
+		copy(raw, line)
+		return rec, raw, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return Record{}, nil, err
+	}
+	return Record{}, nil, io.EOF
+}
+
+// BeatEvent decodes the event stored in the record.
+func (rec Record) BeatEvent() (beat.Event, error) {
+	dec := json.NewDecoder(bytes.NewReader(rec.Event))
+	dec.UseNumber()
+
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		return beat.Event{}, fmt.Errorf("invalid event in dead letter record: %w", err)
+	}
+
+	var event beat.Event
+	if ts, ok := doc["@timestamp"].(string); ok {
+		t, err := time.Parse(time.RFC3339Nano, ts)
+		if err != nil {
+			return beat.Event{}, fmt.Errorf("invalid @timestamp in dead letter record: %w", err)
+		}
+		event.Timestamp = t
+	}
+	delete(doc, "@timestamp")
+
+	if meta, ok := normalize(doc["@metadata"]).(mapstr.M); ok {
+		for _, k := range codecMetadataKeys {
+			delete(meta, k)
+		}
+		if len(meta) > 0 {
+			event.Meta = meta
+		}
+	}
+	delete(doc, "@metadata")
+
+	event.Fields, _ = normalize(doc).(mapstr.M)
+	return event, nil
+}
+
+// normalize converts decoded JSON objects to mapstr.M and numbers to int64
+// or float64, the types beats use for events.
+func normalize(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		m := make(mapstr.M, len(v))
+		for k, val := range v {
+			m[k] = normalize(val)
+		}
+		return m
+	case []any:
+		for i, val := range v {
+			v[i] = normalize(val)
+		}
+		return v
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	default:
+		return v
+	}
+}
+
+// sortRotatedFiles sorts files named {name}-{date}[-{index}].ndjson in the
+// order they were written.
+func sortRotatedFiles(files []string) {
+	type key struct {
+		date  string
+		index int
+	}
+	keys := make(map[string]key, len(files))
+	for _, f := range files {
+		base := strings.TrimSuffix(filepath.Base(f), fileExtension)
+		parts := strings.Split(base, "-")
+		k := key{date: parts[len(parts)-1]}
+		if len(parts) >= 3 {
+			if index, err := strconv.Atoi(parts[len(parts)-1]); err == nil && len(parts[len(parts)-2]) == len(file.DateFormat) {
+				k = key{date: parts[len(parts)-2], index: index}
+			}
+		}
+		keys[f] = k
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		a, b := keys[files[i]], keys[files[j]]
+		if a.date != b.date {
+			return a.date < b.date
+		}
+		return a.index < b.index
+	})
+}
@@ -0,0 +1,199 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package deadletter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	jsoncodec "github.com/elastic/beats/v7/libbeat/outputs/codec/json"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/elastic-agent-libs/file"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// Record is a line of a dead letter file.
+type Record struct {
+	Timestamp time.Time       `json:"@timestamp"`
+	Output    string          `json:"output"`
+	Reason    string          `json:"reason"`
+	Event     json.RawMessage `json:"event"`
+}
+
+// EncodedEvent is implemented by the publisher.Event.EncodedEvent of outputs
+// that encode events early and release their content, so that those events
+// can still be written to dead letter files.
+type EncodedEvent interface {
+	// DeadLetterDocument returns the event encoded as a JSON object, and its
+	// metadata.
+	DeadLetterDocument() ([]byte, mapstr.M)
+}
+
+// Writer writes dead letter records to rotating files. The files are only
+// created once the first event is written. Writer is safe for concurrent
+// use.
+type Writer struct {
+	log    *logp.Logger
+	info   beat.Info
+	output string
+	config Config
+
+	mu      sync.Mutex
+	codec   *jsoncodec.Encoder
+	rotator *file.Rotator
+	closed  bool
+}
+
+// NewWriter creates a Writer for the events failed by an output of the given
+// type.
+func NewWriter(info beat.Info, output string, c Config) *Writer {
+	log := info.Logger
+	if log == nil {
+		log = logp.NewNopLogger()
+	}
+	return &Writer{
+		log:    log.Named("dead_letter"),
+		info:   info,
+		output: output,
+		config: c,
+		codec:  jsoncodec.New(info.Version, jsoncodec.Config{}),
+	}
+}
+
+// WriteDeadLetters writes events to the dead letter file. Failures are logged,
+// as there is nothing else the output could do about them.
+func (w *Writer) WriteDeadLetters(events []publisher.Event, reason error) {
+	if len(events) == 0 {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		w.log.Warnf("Dead letter file is closed, dropping %d events", len(events))
+		return
+	}
+	if w.rotator == nil {
+		if err := w.open(); err != nil {
+			w.log.Errorf("Failed to open dead letter file, dropping %d events: %v", len(events), err)
+			return
+		}
+	}
+
+	msg := "unknown error"
+	if reason != nil {
+		msg = reason.Error()
+	}
+	now := time.Now().UTC()
+
+	for i := range events {
+		encoded, err := w.encodeEvent(&events[i])
+		if err != nil {
+			w.log.Errorf("Failed to encode dead letter event: %v", err)
+			continue
+		}
+
+		line, err := json.Marshal(Record{
+			Timestamp: now,
+			Output:    w.output,
+			Reason:    msg,
+			Event:     encoded,
+		})
+		if err != nil {
+			w.log.Errorf("Failed to encode dead letter record: %v", err)
+			continue
+		}
+		if _, err := w.rotator.Write(append(line, '\n')); err != nil {
+			w.log.Errorf("Failed to write dead letter record: %v", err)
+		}
+	}
+}
+
+// encodeEvent returns the JSON encoding of event. Events that were encoded
+// early by the output are taken from their encoded form.
+func (w *Writer) encodeEvent(event *publisher.Event) ([]byte, error) {
+	if event.Content.Fields != nil || event.EncodedEvent == nil {
+		return w.codec.Encode(w.info.Beat, &event.Content)
+	}
+
+	encoded, ok := event.EncodedEvent.(EncodedEvent)
+	if !ok {
+		return nil, fmt.Errorf("event encoded as %T can't be written to the dead letter file", event.EncodedEvent)
+	}
+	doc, meta := encoded.DeadLetterDocument()
+	if len(doc) == 0 {
+		return nil, errors.New("event failed to encode and has no content left")
+	}
+	if len(meta) == 0 {
+		return doc, nil
+	}
+
+	// Add the metadata the output left out of the document, the way the JSON
+	// codec does.
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &fields); err != nil {
+		return nil, fmt.Errorf("invalid encoded event: %w", err)
+	}
+	metadata, err := json.Marshal(meta)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode event metadata: %w", err)
+	}
+	fields["@metadata"] = metadata
+	return json.Marshal(fields)
+}
+
+func (w *Writer) open() error {
+	path := w.config.FilePath(w.info, w.output)
+	rotator, err := file.NewFileRotator(
+		path,
+		file.MaxSizeBytes(w.config.RotateEveryKb*1024),
+		file.MaxBackups(w.config.NumberOfFiles),
+		file.Permissions(os.FileMode(w.config.Permissions)),
+		file.WithLogger(w.log.Named("rotator").With(logp.Namespace("rotator"))),
+	)
+	if err != nil {
+		return err
+	}
+	w.rotator = rotator
+	w.log.Infof("Writing events failed permanently by the %s output to %s", w.output, path)
+	return nil
+}
+
+// Close closes the dead letter file. Events written after Close are dropped.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.closed = true
+	if w.rotator == nil {
+		return nil
+	}
+	err := w.rotator.Close()
+	w.rotator = nil
+	if err != nil {
+		return fmt.Errorf("failed to close dead letter file: %w", err)
+	}
+	return nil
+}
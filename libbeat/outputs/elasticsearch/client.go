@@ -112,6 +112,10 @@ type bulkResult struct {
 
 	// The API response from Elasticsearch.
 	response eslegclient.BulkResponse
+
+	// deadLetter receives the events Elasticsearch won't accept. It may be
+	// nil.
+	deadLetter outputs.DeadLetterWriter
 }
 
 const (
@@ -295,13 +299,13 @@ func (client *Client) doBulkRequest(
 	ctx context.Context,
 	batch publisher.Batch,
 ) bulkResult {
-	var result bulkResult
+	result := bulkResult{deadLetter: outputs.DeadLetterWriterFrom(ctx)}
 
 	rawEvents := batch.Events()
 
 	// encode events into bulk request buffer, dropping failed elements from
 	// events slice
-	resultEvents, bulkItems := client.bulkEncodePublishRequest(client.conn.GetVersion(), rawEvents)
+	resultEvents, bulkItems := client.bulkEncodePublishRequest(client.conn.GetVersion(), rawEvents, result.deadLetter)
 	result.events = resultEvents
 	client.observer.PermanentErrors(len(rawEvents) - len(resultEvents))
 
@@ -338,6 +342,9 @@ func (client *Client) handleBulkResultError(
 			batch.Drop()
 			client.observer.PermanentErrors(len(bulkResult.events))
 			client.log.Error(errPayloadTooLarge)
+			if bulkResult.deadLetter != nil {
+				bulkResult.deadLetter.WriteDeadLetters(bulkResult.events, errPayloadTooLarge)
+			}
 		}
 		// Don't propagate a too-large error since it doesn't indicate a problem
 		// with the connection.
@@ -360,12 +367,13 @@ func (client *Client) handleBulkResultError(
 
 // bulkEncodePublishRequest encodes all bulk requests and returns slice of events
 // successfully added to the list of bulk items and the list of bulk items.
-func (client *Client) bulkEncodePublishRequest(version version.V, data []publisher.Event) ([]publisher.Event, []any) {
+func (client *Client) bulkEncodePublishRequest(version version.V, data []publisher.Event, deadLetter outputs.DeadLetterWriter) ([]publisher.Event, []any) {
 	okEvents := data[:0]
 	bulkItems := make([]any, 0, len(data)*2)
 	for i := range data {
 		if data[i].EncodedEvent == nil {
 			client.log.Error("Elasticsearch output received unencoded publisher.Event")
+			writeDeadLetter(deadLetter, data[i], errors.New("event was not encoded"))
 			continue
 		}
 		event := data[i].EncodedEvent.(*encodedEvent) //nolint:errcheck //safe to ignore type check
@@ -373,11 +381,13 @@ func (client *Client) bulkEncodePublishRequest(version version.V, data []publish
 			// This means there was an error when encoding the event and it isn't
 			// ingestable, so report the error and continue.
 			client.log.Error(event.err)
+			writeDeadLetter(deadLetter, data[i], event.err)
 			continue
 		}
 		meta, err := client.createEventBulkMeta(version, event)
 		if err != nil {
 			client.log.Errorf("Failed to encode event meta data: %+v", err)
+			writeDeadLetter(deadLetter, data[i], err)
 			continue
 		}
 		if event.opType == events.OpTypeDelete {
@@ -475,7 +485,7 @@ func (client *Client) bulkCollectPublishFails(bulkResult bulkResult) ([]publishe
 			break
 		}
 
-		if client.applyItemStatus(events[i], itemStatus, itemMessage, &stats) {
+		if client.applyItemStatus(events[i], itemStatus, itemMessage, &stats, bulkResult.deadLetter) {
 			eventsToRetry = append(eventsToRetry, events[i])
 			client.log.Debugf("Bulk item insert failed (i=%v, status=%v): %s", i, itemStatus, itemMessage)
 		}
@@ -497,6 +507,7 @@ func (client *Client) applyItemStatus(
 	itemStatus int,
 	itemMessage []byte,
 	stats *bulkResultStats,
+	deadLetter outputs.DeadLetterWriter,
 ) bool {
 	encodedEvent := event.EncodedEvent.(*encodedEvent) //nolint:errcheck //safe to ignore type check
 	if itemStatus < 300 {
@@ -530,6 +541,7 @@ func (client *Client) applyItemStatus(
 			client.pLogDeadLetter.Add()
 			client.log.Errorw(fmt.Sprintf("Can't deliver to dead letter index event '%s' (status=%v): %s", encodedEvent, itemStatus, itemMessage), logp.TypeKey, logp.EventType)
 			stats.nonIndexable++
+			writeDeadLetter(deadLetter, event, fmt.Errorf("dead letter index rejected the event (status=%v): %s", itemStatus, itemMessage))
 			return false
 		}
 		if client.deadLetterIndex == "" {
@@ -537,6 +549,7 @@ func (client *Client) applyItemStatus(
 			client.pLogIndex.Add()
 			client.log.Warnw(fmt.Sprintf("Cannot index event '%s' (status=%v): %s, dropping event!", encodedEvent, itemStatus, itemMessage), logp.TypeKey, logp.EventType)
 			stats.nonIndexable++
+			writeDeadLetter(deadLetter, event, fmt.Errorf("cannot index event (status=%v): %s", itemStatus, itemMessage))
 			return false
 		}
 		// Send this failure to the dead letter index and "retry".
@@ -553,6 +566,14 @@ func (client *Client) applyItemStatus(
 	return true
 }
 
+// writeDeadLetter writes an event dropped because of a permanent error to
+// the dead letter file of the output, if there is one.
+func writeDeadLetter(deadLetter outputs.DeadLetterWriter, event publisher.Event, reason error) {
+	if deadLetter != nil {
+		deadLetter.WriteDeadLetters([]publisher.Event{event}, reason)
+	}
+}
+
 func (client *Client) Connect(ctx context.Context) error {
 	return client.conn.Connect(ctx)
 }
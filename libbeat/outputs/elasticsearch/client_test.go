@@ -47,6 +47,7 @@ import (
 	"github.com/elastic/beats/v7/libbeat/idxmgmt"
 	"github.com/elastic/beats/v7/libbeat/internal/testutil"
 	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/outputs/deadletter"
 	"github.com/elastic/beats/v7/libbeat/outputs/outest"
 	"github.com/elastic/beats/v7/libbeat/outputs/outil"
 	"github.com/elastic/beats/v7/libbeat/publisher"
@@ -1095,7 +1096,7 @@ func TestBulkEncodeEvents(t *testing.T) {
 			}
 			encodeEvents(client, events)
 
-			encoded, bulkItems := client.bulkEncodePublishRequest(*libversion.MustNew(test.version), events)
+			encoded, bulkItems := client.bulkEncodePublishRequest(*libversion.MustNew(test.version), events, nil)
 			assert.Equal(t, len(events), len(encoded), "all events should have been encoded")
 			assert.Equal(t, 2*len(events), len(bulkItems), "incomplete bulk")
 
@@ -1174,7 +1175,7 @@ func TestBulkEncodeEventsWithOpType(t *testing.T) {
 	}
 	encodeEvents(client, events)
 
-	encoded, bulkItems := client.bulkEncodePublishRequest(*libversion.MustNew(version.GetDefaultVersion()), events)
+	encoded, bulkItems := client.bulkEncodePublishRequest(*libversion.MustNew(version.GetDefaultVersion()), events, nil)
 	require.Equal(t, len(events)-1, len(encoded), "all events should have been encoded")
 	require.Equal(t, 9, len(bulkItems), "incomplete bulk")
 
@@ -1330,3 +1331,45 @@ func TestSetDeadLetter(t *testing.T) {
 	assert.Equal(t, errType, errFields.ErrType, "encoded error.type should match value in setDeadLetter")
 	assert.Equal(t, errStr, errFields.ErrMessage, "encoded error.message should match value in setDeadLetter")
 }
+
+func TestCollectPublishFailsWritesDeadLetters(t *testing.T) {
+	client, err := NewClient(
+		clientSettings{observer: outputs.NewNilObserver()},
+		nil,
+		logptest.NewTestingLogger(t, ""),
+	)
+	require.NoError(t, err)
+
+	response := []byte(`
+    { "items": [
+      {"create": {"status": 200}},
+      {"create": {"status": 400, "error": "mapping conflict"}}
+    ]}
+  `)
+
+	eventOK := encodeEvent(client, publisher.Event{Content: beat.Event{Fields: mapstr.M{"field": 1}}})
+	eventFail := encodeEvent(client, publisher.Event{Content: beat.Event{
+		Meta:   mapstr.M{"pipeline": "test"},
+		Fields: mapstr.M{"field": 2},
+	}})
+
+	deadLetters := &outest.DeadLetters{}
+	res, stats := client.bulkCollectPublishFails(bulkResult{
+		events:     []publisher.Event{eventOK, eventFail},
+		status:     200,
+		response:   response,
+		deadLetter: deadLetters,
+	})
+	assert.Empty(t, res)
+	assert.Equal(t, bulkResultStats{acked: 1, nonIndexable: 1}, stats)
+
+	require.Len(t, deadLetters.Events(), 1)
+	assert.ErrorContains(t, deadLetters.Reasons()[0], "status=400")
+
+	// The dead letter file gets the event from its encoded form.
+	encoded, ok := deadLetters.Events()[0].EncodedEvent.(deadletter.EncodedEvent)
+	require.True(t, ok)
+	doc, meta := encoded.DeadLetterDocument()
+	assert.Contains(t, string(doc), `"field":2`)
+	assert.Equal(t, mapstr.M{"pipeline": "test"}, meta)
+}
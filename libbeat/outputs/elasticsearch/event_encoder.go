@@ -64,6 +64,10 @@ type encodedEvent struct {
 	pipeline string
 	index    string
 	encoding []byte
+
+	// originalEncoding is the encoding of the event before it was redirected
+	// to the dead letter index.
+	originalEncoding []byte
 }
 
 func newEventEncoderFactory(
@@ -142,6 +146,7 @@ func (e *encodedEvent) setDeadLetter(
 ) {
 	e.deadLetter = true
 	e.index = deadLetterIndex
+	e.originalEncoding = e.encoding
 	deadLetterReencoding := mapstr.M{
 		"@timestamp":    e.timestamp,
 		"message":       string(e.encoding),
@@ -151,6 +156,15 @@ func (e *encodedEvent) setDeadLetter(
 	e.encoding = []byte(deadLetterReencoding.String())
 }
 
+// DeadLetterDocument implements deadletter.EncodedEvent. It returns the
+// event as it was encoded for its original index.
+func (e *encodedEvent) DeadLetterDocument() ([]byte, mapstr.M) {
+	if e.originalEncoding != nil {
+		return e.originalEncoding, e.meta
+	}
+	return e.encoding, e.meta
+}
+
 // String converts e.encoding (and meta fields if present)
 // to string and returns it.
 // The goal of this method is to provide an easy way to log
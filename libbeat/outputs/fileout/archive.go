@@ -0,0 +1,399 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package fileout
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/elastic/elastic-agent-libs/file"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+const (
+	compressionNone = "none"
+	compressionGzip = "gzip"
+	compressionZstd = "zstd"
+
+	// fileExtension is the extension the rotator gives to the files it writes.
+	fileExtension = ".ndjson"
+)
+
+// compressor compresses the contents of archived files.
+type compressor struct {
+	extension string
+	newWriter func(w io.Writer) (io.WriteCloser, error)
+}
+
+// compressors holds the supported values of the `compression` setting. A nil
+// compressor stores archived files uncompressed.
+var compressors = map[string]*compressor{
+	compressionNone: nil,
+	compressionGzip: {
+		extension: ".gz",
+		newWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriter(w), nil
+		},
+	},
+	compressionZstd: {
+		extension: ".zst",
+		newWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return zstd.NewWriter(w)
+		},
+	},
+}
+
+// archiver post-processes the files rotated out by the file rotator. Every
+// rotated file is optionally renamed using the archive filename template and
+// compressed, and the number of archived files is kept under maxArchives.
+//
+// Archiving runs in a background goroutine, started with start, so that
+// compressing large files doesn't block publishing. It is triggered by
+// notify whenever the content of the output directory changed, which is the
+// case after a rotation.
+type archiver struct {
+	log         *logp.Logger
+	filename    string
+	archiveName *PathFormatString
+	compressor  *compressor
+	maxArchives uint
+	permissions os.FileMode
+
+	// dirModTime is the modification time of the output directory when
+	// notify last ran.
+	dirModTime time.Time
+
+	trigger  chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+func newArchiver(log *logp.Logger, filename string, c fileOutConfig) *archiver {
+	return &archiver{
+		log:         log,
+		filename:    filename,
+		archiveName: c.ArchiveFilename,
+		compressor:  compressors[c.Compression],
+		maxArchives: c.NumberOfFiles,
+		permissions: os.FileMode(c.Permissions),
+		trigger:     make(chan struct{}, 1),
+		done:        make(chan struct{}),
+	}
+}
+
+// start starts archiving in the background. Files rotated out before the
+// output was started are archived right away.
+func (a *archiver) start() {
+	a.trigger <- struct{}{}
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		for {
+			select {
+			case <-a.trigger:
+				a.run()
+			case <-a.done:
+				// Pick up rotations notify may have missed.
+				a.run()
+				return
+			}
+		}
+	}()
+}
+
+// stop waits for the running archiving to finish and stops the background
+// goroutine.
+func (a *archiver) stop() {
+	a.stopOnce.Do(func() { close(a.done) })
+	a.wg.Wait()
+}
+
+// notify triggers archiving if files were added to or removed from the
+// output directory since the last call. Writes to the active file don't
+// change the modification time of the directory, so this only costs a stat
+// call until the rotator rotates the file.
+func (a *archiver) notify() {
+	info, err := os.Stat(filepath.Dir(a.filename))
+	if err != nil {
+		a.log.Debugf("Failed to stat the output directory: %v", err)
+		return
+	}
+	if info.ModTime().Equal(a.dirModTime) {
+		return
+	}
+	a.dirModTime = info.ModTime()
+
+	select {
+	case a.trigger <- struct{}{}:
+	default:
+		// Archiving is triggered already.
+	}
+}
+
+func (a *archiver) run() {
+	if err := a.archive(); err != nil {
+		a.log.Errorf("Failed to archive rotated files: %+v", err)
+	}
+}
+
+// rotatedFile is a file written by the rotator, named
+// {filename}-{date}[-{index}].ndjson.
+type rotatedFile struct {
+	path  string
+	date  time.Time
+	index int
+}
+
+// archive archives all the files that were rotated out by the rotator and
+// purges the oldest archives.
+func (a *archiver) archive() error {
+	files, err := a.rotatedFiles()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, f := range files {
+		dst, err := a.archiveFile(f.path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to archive %s: %w", f.path, err))
+			continue
+		}
+		a.log.Debugf("Archived rotated file %s to %s", f.path, dst)
+	}
+
+	if err := a.purge(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// rotatedFiles returns the files that are no longer written by the rotator,
+// oldest first. The active file is the newest one following the rotator
+// naming scheme.
+func (a *archiver) rotatedFiles() ([]rotatedFile, error) {
+	paths, err := filepath.Glob(a.filename + "-*" + fileExtension)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rotated files: %w", err)
+	}
+
+	files := make([]rotatedFile, 0, len(paths))
+	for _, path := range paths {
+		if f, ok := a.parseRotatedFile(path); ok {
+			files = append(files, f)
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].date.Equal(files[j].date) {
+			return files[i].index < files[j].index
+		}
+		return files[i].date.Before(files[j].date)
+	})
+	return files[:len(files)-1], nil
+}
+
+// parseRotatedFile parses path according to the rotator naming scheme. It
+// returns false if path is not a file written by the rotator.
+func (a *archiver) parseRotatedFile(path string) (rotatedFile, bool) {
+	prefix := a.filename + "-"
+	if !strings.HasPrefix(path, prefix) || !strings.HasSuffix(path, fileExtension) {
+		return rotatedFile{}, false
+	}
+
+	suffix := strings.TrimSuffix(strings.TrimPrefix(path, prefix), fileExtension)
+	if len(suffix) < len(file.DateFormat) {
+		return rotatedFile{}, false
+	}
+	date, err := time.Parse(file.DateFormat, suffix[:len(file.DateFormat)])
+	if err != nil {
+		return rotatedFile{}, false
+	}
+
+	f := rotatedFile{path: path, date: date}
+	if rest := suffix[len(file.DateFormat):]; rest != "" {
+		if !strings.HasPrefix(rest, "-") {
+			return rotatedFile{}, false
+		}
+		if f.index, err = strconv.Atoi(rest[1:]); err != nil {
+			return rotatedFile{}, false
+		}
+	}
+	return f, true
+}
+
+// archiveFile copies src into its archive, compressing it if configured,
+// and removes src. It returns the path of the archive.
+func (a *archiver) archiveFile(src string) (string, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return "", err
+	}
+
+	dst, err := a.destination(src, info.ModTime())
+	if err != nil {
+		return "", err
+	}
+
+	if err := a.copyFile(src, dst); err != nil {
+		_ = os.Remove(dst)
+		return "", err
+	}
+
+	// Keep the modification time of the original file so that archives
+	// are purged in the order they were written.
+	if err := os.Chtimes(dst, info.ModTime(), info.ModTime()); err != nil {
+		return "", fmt.Errorf("failed to set modification time: %w", err)
+	}
+
+	if err := os.Remove(src); err != nil {
+		return "", fmt.Errorf("failed to remove rotated file: %w", err)
+	}
+	return dst, nil
+}
+
+// destination returns the path of the archive for src. When an archive
+// filename is configured it is expanded with the time of the last write to
+// src, and an index is appended if an archive with that name exists already.
+func (a *archiver) destination(src string, modTime time.Time) (string, error) {
+	ext := a.extension()
+	if a.archiveName == nil {
+		return src + ext, nil
+	}
+
+	name, err := a.archiveName.Run(modTime.UTC())
+	if err != nil {
+		return "", fmt.Errorf("failed to expand archive filename: %w", err)
+	}
+
+	base := filepath.Join(filepath.Dir(a.filename), name)
+	dst := base + fileExtension + ext
+	for i := 1; ; i++ {
+		_, err := os.Lstat(dst)
+		if errors.Is(err, fs.ErrNotExist) {
+			return dst, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		dst = base + "-" + strconv.Itoa(i) + fileExtension + ext
+	}
+}
+
+func (a *archiver) copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, a.permissions)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if a.compressor == nil {
+		if _, err := io.Copy(out, in); err != nil {
+			return err
+		}
+		return out.Sync()
+	}
+
+	w, err := a.compressor.newWriter(out)
+	if err != nil {
+		return fmt.Errorf("failed to create %s writer: %w", a.compressor.extension, err)
+	}
+	if _, err := io.Copy(w, in); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return out.Sync()
+}
+
+// purge removes the oldest archives, keeping at most maxArchives of them.
+func (a *archiver) purge() error {
+	var pattern string
+	if a.archiveName == nil {
+		pattern = a.filename + "-*" + fileExtension + a.extension()
+	} else {
+		pattern = filepath.Join(filepath.Dir(a.filename), a.archiveName.Glob()) + "*" + fileExtension + a.extension()
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return fmt.Errorf("failed to list archived files: %w", err)
+	}
+
+	// Uncompressed archives may share the naming scheme of the files that
+	// are still managed by the rotator, leave those alone.
+	paths := matches[:0]
+	for _, path := range matches {
+		if _, ok := a.parseRotatedFile(path); !ok {
+			paths = append(paths, path)
+		}
+	}
+	if uint(len(paths)) <= a.maxArchives {
+		return nil
+	}
+
+	modTimes := make(map[string]time.Time, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("failed to stat archived file: %w", err)
+		}
+		modTimes[path] = info.ModTime()
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return modTimes[paths[i]].Before(modTimes[paths[j]])
+	})
+
+	for _, path := range paths[:uint(len(paths))-a.maxArchives] {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to delete archived file %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func (a *archiver) extension() string {
+	if a.compressor == nil {
+		return ""
+	}
+	return a.compressor.extension
+}
@@ -0,0 +1,197 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package fileout
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+)
+
+func writeRotatedFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	modTime := time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(name+"\n"), 0600))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+		modTime = modTime.Add(time.Hour)
+	}
+}
+
+func newTestArchiver(t *testing.T, dir string, c fileOutConfig) *archiver {
+	t.Helper()
+	if c.NumberOfFiles == 0 {
+		c.NumberOfFiles = 7
+	}
+	if c.Permissions == 0 {
+		c.Permissions = 0600
+	}
+	return newArchiver(logptest.NewTestingLogger(t, ""), filepath.Join(dir, "beat"), c)
+}
+
+func TestArchiverCompression(t *testing.T) {
+	for name, test := range map[string]struct {
+		compression string
+		extension   string
+		newReader   func(r io.Reader) (io.Reader, error)
+	}{
+		"gzip": {
+			compression: compressionGzip,
+			extension:   ".gz",
+			newReader: func(r io.Reader) (io.Reader, error) {
+				return gzip.NewReader(r)
+			},
+		},
+		"zstd": {
+			compression: compressionZstd,
+			extension:   ".zst",
+			newReader: func(r io.Reader) (io.Reader, error) {
+				return zstd.NewReader(r)
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeRotatedFiles(t, dir,
+				"beat-20240101.ndjson",
+				"beat-20240101-1.ndjson",
+				"beat-20240102.ndjson",
+			)
+
+			a := newTestArchiver(t, dir, fileOutConfig{Compression: test.compression})
+			require.NoError(t, a.archive())
+
+			for _, name := range []string{"beat-20240101.ndjson", "beat-20240101-1.ndjson"} {
+				assert.NoFileExists(t, filepath.Join(dir, name))
+
+				f, err := os.Open(filepath.Join(dir, name+test.extension))
+				require.NoError(t, err)
+				defer f.Close()
+
+				r, err := test.newReader(f)
+				require.NoError(t, err)
+				content, err := io.ReadAll(r)
+				require.NoError(t, err)
+				assert.Equal(t, name+"\n", string(content))
+			}
+
+			// The active file is left to the rotator.
+			assert.FileExists(t, filepath.Join(dir, "beat-20240102.ndjson"))
+		})
+	}
+}
+
+func TestArchiverArchiveFilename(t *testing.T) {
+	dir := t.TempDir()
+	writeRotatedFiles(t, dir,
+		"beat-20240102.ndjson",
+		"beat-20240102-1.ndjson",
+		"beat-20240102-2.ndjson",
+	)
+
+	archiveName := &PathFormatString{}
+	require.NoError(t, archiveName.Unpack("archive-%{+yyyy-MM-dd}"))
+
+	a := newTestArchiver(t, dir, fileOutConfig{
+		Compression:     compressionNone,
+		ArchiveFilename: archiveName,
+	})
+	require.NoError(t, a.archive())
+
+	// Both rotated files were last written on the same day, the second one
+	// gets an index to keep the names unique.
+	assert.FileExists(t, filepath.Join(dir, "archive-2024-01-02.ndjson"))
+	assert.FileExists(t, filepath.Join(dir, "archive-2024-01-02-1.ndjson"))
+	assert.FileExists(t, filepath.Join(dir, "beat-20240102-2.ndjson"))
+	assert.NoFileExists(t, filepath.Join(dir, "beat-20240102.ndjson"))
+	assert.NoFileExists(t, filepath.Join(dir, "beat-20240102-1.ndjson"))
+}
+
+func TestArchiverPurge(t *testing.T) {
+	dir := t.TempDir()
+	writeRotatedFiles(t, dir,
+		"beat-20240101.ndjson",
+		"beat-20240101-1.ndjson",
+		"beat-20240101-2.ndjson",
+		"beat-20240101-3.ndjson",
+		"beat-20240102.ndjson",
+	)
+
+	a := newTestArchiver(t, dir, fileOutConfig{
+		Compression:   compressionGzip,
+		NumberOfFiles: 2,
+	})
+	require.NoError(t, a.archive())
+
+	matches, err := filepath.Glob(filepath.Join(dir, "*.gz"))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		filepath.Join(dir, "beat-20240101-2.ndjson.gz"),
+		filepath.Join(dir, "beat-20240101-3.ndjson.gz"),
+	}, matches)
+	assert.FileExists(t, filepath.Join(dir, "beat-20240102.ndjson"))
+}
+
+func TestArchiverIgnoresUnknownFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeRotatedFiles(t, dir,
+		"beat-notadate.ndjson",
+		"beat-20240101-x.ndjson",
+		"beat-20240101.ndjson",
+	)
+
+	a := newTestArchiver(t, dir, fileOutConfig{Compression: compressionGzip})
+	files, err := a.rotatedFiles()
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestArchiverBackground(t *testing.T) {
+	dir := t.TempDir()
+	a := newTestArchiver(t, dir, fileOutConfig{Compression: compressionGzip})
+	a.start()
+
+	writeRotatedFiles(t, dir,
+		"beat-20240101.ndjson",
+		"beat-20240102.ndjson",
+	)
+	a.notify()
+
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(dir, "beat-20240101.ndjson.gz"))
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Files rotated after the last notification are archived when stopping.
+	writeRotatedFiles(t, dir, "beat-20240103.ndjson")
+	a.stop()
+	assert.FileExists(t, filepath.Join(dir, "beat-20240102.ndjson.gz"))
+	assert.FileExists(t, filepath.Join(dir, "beat-20240103.ndjson"))
+}
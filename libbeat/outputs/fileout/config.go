@@ -19,6 +19,8 @@ package fileout
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/elastic/beats/v7/libbeat/outputs/codec"
 	"github.com/elastic/elastic-agent-libs/config"
@@ -29,10 +31,13 @@ type fileOutConfig struct {
 	Path            *PathFormatString `config:"path"`
 	Filename        string            `config:"filename"`
 	RotateEveryKb   uint              `config:"rotate_every_kb" validate:"min=1"`
+	RotateEvery     time.Duration     `config:"rotate_every"`
 	NumberOfFiles   uint              `config:"number_of_files"`
 	Codec           codec.Config      `config:"codec"`
 	Permissions     uint32            `config:"permissions"`
 	RotateOnStartup bool              `config:"rotate_on_startup"`
+	Compression     string            `config:"compression"`
+	ArchiveFilename *PathFormatString `config:"archive_filename"`
 	Queue           config.Namespace  `config:"queue"`
 }
 
@@ -43,6 +48,7 @@ func defaultConfig() fileOutConfig {
 		RotateEveryKb:   10 * 1024,
 		Permissions:     0600,
 		RotateOnStartup: true,
+		Compression:     compressionNone,
 	}
 }
 
@@ -64,5 +70,53 @@ func (c *fileOutConfig) Validate() error {
 			file.MaxBackupsLimit)
 	}
 
+	if c.RotateEvery != 0 && c.RotateEvery < time.Second {
+		return fmt.Errorf("the rotate_every interval must be at least 1s, got %v", c.RotateEvery)
+	}
+
+	if err := c.Codec.ValidateNewlineDelimited(); err != nil {
+		return err
+	}
+
+	if _, ok := compressors[c.Compression]; !ok {
+		return fmt.Errorf("unsupported compression %q, must be one of none, gzip or zstd", c.Compression)
+	}
+
+	if c.ArchiveFilename != nil {
+		if strings.ContainsAny(c.ArchiveFilename.pattern, `/\`) || strings.Contains(c.ArchiveFilename.pattern, "..") {
+			return fmt.Errorf("archive_filename %q must be a file name, archives are written to path", c.ArchiveFilename.pattern)
+		}
+		if c.Filename != "" {
+			return c.checkArchiveFilename(c.Filename)
+		}
+	}
+
 	return nil
 }
+
+// checkArchiveFilename checks that uncompressed archives don't follow the
+// naming scheme of the files written under filename, which the rotator would
+// purge as its own.
+func (c *fileOutConfig) checkArchiveFilename(filename string) error {
+	if c.ArchiveFilename == nil || c.Compression != compressionNone {
+		return nil
+	}
+
+	prefix := filename + "-"
+	static, _, _ := strings.Cut(c.ArchiveFilename.pattern, "%{")
+	expanded, err := c.ArchiveFilename.Run(time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("invalid archive_filename: %w", err)
+	}
+	if strings.HasPrefix(static, prefix) || strings.HasPrefix(expanded, prefix) {
+		return fmt.Errorf("archive_filename %q must not start with %q when compression is none",
+			c.ArchiveFilename.pattern, prefix)
+	}
+	return nil
+}
+
+// archiving reports whether rotated files are post-processed by the output,
+// either to compress them or to rename them using the archive filename.
+func (c *fileOutConfig) archiving() bool {
+	return c.Compression != compressionNone || c.ArchiveFilename != nil
+}
@@ -23,6 +23,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	_ "github.com/elastic/beats/v7/libbeat/outputs/codec/msgpack"
 	"github.com/elastic/elastic-agent-libs/config"
 	"github.com/elastic/elastic-agent-libs/mapstr"
 )
@@ -42,6 +43,7 @@ func TestConfig(t *testing.T) {
 					RotateEveryKb:   10 * 1024,
 					Permissions:     0600,
 					RotateOnStartup: true,
+					Compression:     "none",
 				}
 
 				assert.Equal(t, expectedConfig, actual)
@@ -91,6 +93,85 @@ func TestConfig(t *testing.T) {
 				assert.NoError(t, err)
 			},
 		},
+		"config given with time based rotation and compression": {
+			config: config.MustNewConfigFrom(mapstr.M{
+				"rotate_every":     "1h",
+				"compression":      "zstd",
+				"archive_filename": "archive-%{+yyyy-MM-dd-HH}",
+			}),
+			assertion: func(t *testing.T, actual *fileOutConfig, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, time.Hour, actual.RotateEvery)
+				assert.Equal(t, "zstd", actual.Compression)
+				assert.True(t, actual.archiving())
+
+				name, runErr := actual.ArchiveFilename.Run(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+				assert.NoError(t, runErr)
+				assert.Equal(t, "archive-2024-01-02-03", name)
+				assert.Equal(t, "archive-*", actual.ArchiveFilename.Glob())
+			},
+		},
+		"rotate_every below one second": {
+			config: config.MustNewConfigFrom(mapstr.M{
+				"rotate_every": "500ms",
+			}),
+			assertion: func(t *testing.T, actual *fileOutConfig, err error) {
+				assert.ErrorContains(t, err, "rotate_every")
+			},
+		},
+		"archive_filename with a path separator": {
+			config: config.MustNewConfigFrom(mapstr.M{
+				"compression":      "gzip",
+				"archive_filename": "archives/beat-%{+yyyy-MM-dd}",
+			}),
+			assertion: func(t *testing.T, actual *fileOutConfig, err error) {
+				assert.ErrorContains(t, err, "must be a file name")
+			},
+		},
+		"archive_filename leaving path": {
+			config: config.MustNewConfigFrom(mapstr.M{
+				"compression":      "gzip",
+				"archive_filename": "..%{+yyyy-MM-dd}",
+			}),
+			assertion: func(t *testing.T, actual *fileOutConfig, err error) {
+				assert.ErrorContains(t, err, "must be a file name")
+			},
+		},
+		"uncompressed archive_filename with the rotator naming scheme": {
+			config: config.MustNewConfigFrom(mapstr.M{
+				"filename":         "pb",
+				"archive_filename": "pb-archive-%{+yyyy-MM-dd}",
+			}),
+			assertion: func(t *testing.T, actual *fileOutConfig, err error) {
+				assert.ErrorContains(t, err, `must not start with "pb-"`)
+			},
+		},
+		"compressed archive_filename with the rotator naming scheme": {
+			config: config.MustNewConfigFrom(mapstr.M{
+				"filename":         "pb",
+				"compression":      "gzip",
+				"archive_filename": "pb-archive-%{+yyyy-MM-dd}",
+			}),
+			assertion: func(t *testing.T, actual *fileOutConfig, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		"unsupported compression": {
+			config: config.MustNewConfigFrom(mapstr.M{
+				"compression": "bzip2",
+			}),
+			assertion: func(t *testing.T, actual *fileOutConfig, err error) {
+				assert.ErrorContains(t, err, "unsupported compression")
+			},
+		},
+		"binary codec": {
+			config: config.MustNewConfigFrom(mapstr.M{
+				"codec.msgpack": mapstr.M{},
+			}),
+			assertion: func(t *testing.T, actual *fileOutConfig, err error) {
+				assert.ErrorContains(t, err, `the "msgpack" codec produces binary documents`)
+			},
+		},
 	} {
 		t.Run(name, func(t *testing.T) {
 			isWindowsPath = test.useWindowsPath
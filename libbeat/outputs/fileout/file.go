@@ -43,6 +43,7 @@ type fileOutput struct {
 	beat     beat.Info
 	observer outputs.Observer
 	rotator  *file.Rotator
+	archiver *archiver
 	codec    codec.Codec
 }
 
@@ -84,11 +85,17 @@ func (out *fileOutput) init(beat beat.Info, c fileOutConfig) error {
 
 	out.filePath = path
 
+	// The default filename is only known now.
+	if err := c.checkArchiveFilename(filepath.Base(path)); err != nil {
+		return err
+	}
+
 	var err error
 	out.rotator, err = file.NewFileRotator(
 		path,
 		file.MaxSizeBytes(c.RotateEveryKb*1024),
 		file.MaxBackups(c.NumberOfFiles),
+		file.Interval(c.RotateEvery),
 		file.Permissions(os.FileMode(c.Permissions)),
 		file.RotateOnStartup(c.RotateOnStartup),
 		file.WithLogger(beat.Logger.Named("rotator").With(logp.Namespace("rotator"))),
@@ -97,30 +104,42 @@ func (out *fileOutput) init(beat beat.Info, c fileOutConfig) error {
 		return err
 	}
 
+	if c.archiving() {
+		out.archiver = newArchiver(out.log, path, c)
+	}
+
 	out.codec, err = codec.CreateEncoder(beat, c.Codec)
 	if err != nil {
 		return err
 	}
 
 	out.log.Infof("Initialized file output. "+
-		"path=%v max_size_bytes=%v max_backups=%v permissions=%v",
-		path, c.RotateEveryKb*1024, c.NumberOfFiles, os.FileMode(c.Permissions))
+		"path=%v max_size_bytes=%v max_backups=%v permissions=%v rotate_every=%v compression=%v",
+		path, c.RotateEveryKb*1024, c.NumberOfFiles, os.FileMode(c.Permissions), c.RotateEvery, c.Compression)
 
+	if out.archiver != nil {
+		out.archiver.start()
+	}
 	return nil
 }
 
 // Implement Outputer
 func (out *fileOutput) Close() error {
-	return out.rotator.Close()
+	err := out.rotator.Close()
+	if out.archiver != nil {
+		out.archiver.stop()
+	}
+	return err
 }
 
-func (out *fileOutput) Publish(_ context.Context, batch publisher.Batch) error {
+func (out *fileOutput) Publish(ctx context.Context, batch publisher.Batch) error {
 	defer batch.ACK()
 
 	st := out.observer
 	events := batch.Events()
 	st.NewBatch(len(events))
 
+	deadLetter := outputs.DeadLetterWriterFrom(ctx)
 	dropped := 0
 
 	for i := range events {
@@ -128,6 +147,7 @@ func (out *fileOutput) Publish(_ context.Context, batch publisher.Batch) error {
 
 		serializedEvent, err := out.codec.Encode(out.beat.Beat, &event.Content)
 		if err != nil {
+			deadLetter.WriteDeadLetters([]publisher.Event{*event}, err)
 			if event.Guaranteed() {
 				out.log.Errorf("Failed to serialize the event: %+v", err)
 			} else {
@@ -149,6 +169,7 @@ func (out *fileOutput) Publish(_ context.Context, batch publisher.Batch) error {
 			} else {
 				out.log.Warnf("Writing event to file failed with: %+v", err)
 			}
+			deadLetter.WriteDeadLetters([]publisher.Event{*event}, err)
 
 			dropped++
 			continue
@@ -163,6 +184,10 @@ func (out *fileOutput) Publish(_ context.Context, batch publisher.Batch) error {
 
 	st.AckedEvents(len(events) - dropped)
 
+	if out.archiver != nil {
+		out.archiver.notify()
+	}
+
 	return nil
 }
 
@@ -20,6 +20,7 @@ package fileout
 import (
 	"fmt"
 	"os"
+	"regexp"
 	"strings"
 	"time"
 
@@ -30,13 +31,17 @@ import (
 
 var isWindowsPath = os.PathSeparator == '\\'
 
+// expansionRegexp matches the `%{...}` expansions of a format string.
+var expansionRegexp = regexp.MustCompile(`%\{[^}]*\}`)
+
 // PathFormatString is a wrapper around EventFormatString for the
 // handling paths with a format expression that has access to the timestamp format.
 // It has special handling for paths, specifically for windows path separator
 // which would be interpreted as an escape character. This formatter double escapes
 // the path separator so it is properly interpreted by the fmtstr processor
 type PathFormatString struct {
-	efs *fmtstr.EventFormatString
+	efs     *fmtstr.EventFormatString
+	pattern string
 }
 
 // Run executes the format string returning a new expanded string or an error
@@ -61,6 +66,7 @@ func (fs *PathFormatString) Unpack(v any) error {
 		return nil
 	}
 
+	fs.pattern = path
 	if isWindowsPath {
 		path = strings.ReplaceAll(path, "\\", "\\\\")
 	}
@@ -68,3 +74,9 @@ func (fs *PathFormatString) Unpack(v any) error {
 	fs.efs = &fmtstr.EventFormatString{}
 	return fs.efs.Unpack(path)
 }
+
+// Glob returns a glob pattern matching every string the format string can
+// expand to, obtained by replacing each `%{...}` expansion with `*`.
+func (fs *PathFormatString) Glob() string {
+	return expansionRegexp.ReplaceAllString(fs.pattern, "*")
+}
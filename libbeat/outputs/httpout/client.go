@@ -0,0 +1,269 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package httpout
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/transport/httpcommon"
+)
+
+// maxResponseBodySize limits how much of an error response is logged.
+const maxResponseBodySize = 1024
+
+type clientSettings struct {
+	url       string
+	index     string
+	userAgent string
+	config    httpConfig
+	policy    statusPolicy
+	codec     codec.Codec
+	observer  outputs.Observer
+}
+
+type client struct {
+	log      *logp.Logger
+	observer outputs.Observer
+	url      string
+	index    string
+	method   string
+	headers  map[string]string
+	format   string
+	level    int
+	policy   statusPolicy
+	codec    codec.Codec
+
+	transport httpcommon.HTTPTransportSettings
+	userAgent string
+	http      *http.Client
+
+	body bytes.Buffer
+}
+
+func newClient(log *logp.Logger, s clientSettings) *client {
+	headers := make(map[string]string, len(s.config.Headers))
+	for k, v := range s.config.Headers {
+		headers[k] = v
+	}
+	switch {
+	case s.config.BearerToken != "":
+		headers["Authorization"] = "Bearer " + s.config.BearerToken
+	case s.config.Username != "":
+		auth := base64.StdEncoding.EncodeToString([]byte(s.config.Username + ":" + s.config.Password))
+		headers["Authorization"] = "Basic " + auth
+	}
+
+	return &client{
+		log:       log,
+		observer:  s.observer,
+		url:       s.url,
+		index:     s.index,
+		method:    s.config.Method,
+		headers:   headers,
+		format:    s.config.BatchFormat,
+		level:     s.config.CompressionLevel,
+		policy:    s.policy,
+		codec:     s.codec,
+		transport: s.config.Transport,
+		userAgent: s.userAgent,
+	}
+}
+
+func (c *client) Connect(_ context.Context) error {
+	httpClient, err := c.transport.Client(
+		httpcommon.WithLogger(c.log),
+		httpcommon.WithIOStats(c.observer),
+		httpcommon.WithHeaderRoundTripper(map[string]string{"User-Agent": c.userAgent}),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP client: %w", err)
+	}
+	c.http = httpClient
+	return nil
+}
+
+func (c *client) Close() error {
+	if c.http != nil {
+		c.http.CloseIdleConnections()
+		c.http = nil
+	}
+	return nil
+}
+
+func (c *client) Publish(ctx context.Context, batch publisher.Batch) error {
+	events := batch.Events()
+	c.observer.NewBatch(len(events))
+	deadLetter := outputs.DeadLetterWriterFrom(ctx)
+
+	sent := c.encode(events, deadLetter)
+	if dropped := len(events) - len(sent); dropped > 0 {
+		c.observer.PermanentErrors(dropped)
+	}
+	if len(sent) == 0 {
+		batch.ACK()
+		return nil
+	}
+
+	retry := func() {
+		c.observer.RetryableErrors(len(sent))
+		if len(sent) == len(events) {
+			batch.Retry()
+		} else {
+			batch.RetryEvents(sent)
+		}
+	}
+
+	req, err := c.newRequest(ctx)
+	if err != nil {
+		c.log.Errorf("Dropping %d events, failed to create request: %v", len(sent), err)
+		deadLetter.WriteDeadLetters(sent, err)
+		c.observer.PermanentErrors(len(sent))
+		batch.Drop()
+		return nil
+	}
+
+	begin := time.Now()
+	resp, err := c.http.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			batch.Cancelled()
+			return ctx.Err()
+		}
+		retry()
+		return fmt.Errorf("failed to send events to %s: %w", c.url, err)
+	}
+	defer resp.Body.Close()
+	c.observer.ReportLatency(time.Since(begin))
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBodySize))
+	// Drain the rest of the body so the connection can be reused.
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	switch c.policy.action(resp.StatusCode) {
+	case actionACK:
+		c.observer.AckedEvents(len(sent))
+		batch.ACK()
+		return nil
+	case actionRetry:
+		if resp.StatusCode == http.StatusTooManyRequests {
+			c.observer.ErrTooMany(len(sent))
+		}
+		retry()
+		return fmt.Errorf("%s responded with %s: %s", c.url, resp.Status, bytes.TrimSpace(respBody))
+	default:
+		err := fmt.Errorf("%s responded with %s: %s", c.url, resp.Status, bytes.TrimSpace(respBody))
+		c.log.Errorf("Dropping %d events, %v", len(sent), err)
+		deadLetter.WriteDeadLetters(sent, err)
+		c.observer.PermanentErrors(len(sent))
+		batch.Drop()
+		return nil
+	}
+}
+
+// encode serializes the events into the request body and returns the events
+// that were encoded. Events that can't be encoded are dropped and written to
+// deadLetter.
+func (c *client) encode(events []publisher.Event, deadLetter outputs.DeadLetterWriter) []publisher.Event {
+	c.body.Reset()
+	if c.format == batchFormatJSONArray {
+		c.body.WriteByte('[')
+	}
+
+	sent := make([]publisher.Event, 0, len(events))
+	for i := range events {
+		event := &events[i]
+		serialized, err := c.codec.Encode(c.index, &event.Content)
+		if err != nil {
+			if event.Guaranteed() {
+				c.log.Errorf("Failed to serialize the event: %+v", err)
+			} else {
+				c.log.Warnf("Failed to serialize the event: %+v", err)
+			}
+			c.log.Debugw(fmt.Sprintf("Failed event: %v", event), logp.TypeKey, logp.EventType)
+			deadLetter.WriteDeadLetters(events[i:i+1], fmt.Errorf("failed to serialize the event: %w", err))
+			continue
+		}
+
+		if c.format == batchFormatJSONArray {
+			if len(sent) > 0 {
+				c.body.WriteByte(',')
+			}
+			c.body.Write(serialized)
+		} else {
+			c.body.Write(serialized)
+			c.body.WriteByte('\n')
+		}
+		sent = append(sent, *event)
+	}
+
+	if c.format == batchFormatJSONArray {
+		c.body.WriteByte(']')
+	}
+	return sent
+}
+
+func (c *client) newRequest(ctx context.Context) (*http.Request, error) {
+	body := c.body.Bytes()
+	if c.level > 0 {
+		var buf bytes.Buffer
+		w, err := gzip.NewWriterLevel(&buf, c.level)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(body); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		body = buf.Bytes()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, c.method, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	if c.format == batchFormatJSONArray {
+		req.Header.Set("Content-Type", "application/json")
+	} else {
+		req.Header.Set("Content-Type", "application/x-ndjson")
+	}
+	if c.level > 0 {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+	return req, nil
+}
+
+func (c *client) String() string {
+	return "http(" + c.url + ")"
+}
@@ -0,0 +1,350 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package httpout
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	_ "github.com/elastic/beats/v7/libbeat/outputs/codec/json"
+	"github.com/elastic/beats/v7/libbeat/outputs/outest"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+type request struct {
+	method  string
+	path    string
+	headers http.Header
+	body    []byte
+}
+
+// testServer records the requests it receives and responds with status.
+type testServer struct {
+	mu       sync.Mutex
+	status   int
+	requests []request
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body = zr
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, request{
+		method:  r.Method,
+		path:    r.URL.Path,
+		headers: r.Header.Clone(),
+		body:    data,
+	})
+	status := s.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+}
+
+func (s *testServer) received() []request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func startServer(t *testing.T, status int) (*testServer, string) {
+	t.Helper()
+	s := &testServer{status: status}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	return s, srv.URL
+}
+
+func testInfo(t *testing.T) beat.Info {
+	return beat.Info{
+		Beat:      "testbeat",
+		Version:   "9.9.9",
+		UserAgent: "testbeat/9.9.9",
+		Logger:    logptest.NewTestingLogger(t, ""),
+	}
+}
+
+func newTestClient(t *testing.T, settings map[string]any) outputs.NetworkClient {
+	t.Helper()
+	group, err := makeHTTP(nil, testInfo(t), outputs.NewNilObserver(), config.MustNewConfigFrom(settings))
+	require.NoError(t, err)
+	require.Len(t, group.Clients, 1)
+
+	client := group.Clients[0].(outputs.NetworkClient)
+	require.NoError(t, client.Connect(context.Background()))
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func testEvents(messages ...string) *outest.Batch {
+	events := make([]beat.Event, len(messages))
+	for i, msg := range messages {
+		events[i] = beat.Event{
+			Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			Fields:    mapstr.M{"message": msg},
+		}
+	}
+	return outest.NewBatch(events...)
+}
+
+func messagesFromNDJSON(t *testing.T, body []byte) []string {
+	t.Helper()
+	var messages []string
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		var doc map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &doc))
+		messages = append(messages, doc["message"].(string))
+	}
+	return messages
+}
+
+func TestPublishNDJSON(t *testing.T) {
+	server, url := startServer(t, http.StatusOK)
+	client := newTestClient(t, map[string]any{
+		"hosts":   []string{url},
+		"path":    "/ingest",
+		"headers": map[string]string{"X-Source": "beats"},
+	})
+
+	batch := testEvents("first", "second")
+	require.NoError(t, client.Publish(context.Background(), batch))
+	require.Len(t, batch.Signals, 1)
+	assert.Equal(t, outest.BatchACK, batch.Signals[0].Tag)
+
+	requests := server.received()
+	require.Len(t, requests, 1)
+	req := requests[0]
+	assert.Equal(t, http.MethodPost, req.method)
+	assert.Equal(t, "/ingest", req.path)
+	assert.Equal(t, "application/x-ndjson", req.headers.Get("Content-Type"))
+	assert.Equal(t, "beats", req.headers.Get("X-Source"))
+	assert.Equal(t, "testbeat/9.9.9", req.headers.Get("User-Agent"))
+	assert.Equal(t, []string{"first", "second"}, messagesFromNDJSON(t, req.body))
+}
+
+func TestPublishJSONArrayWithGzip(t *testing.T) {
+	server, url := startServer(t, http.StatusAccepted)
+	client := newTestClient(t, map[string]any{
+		"hosts":             []string{url},
+		"batch_format":      "json_array",
+		"compression_level": 5,
+		"method":            "PUT",
+	})
+
+	batch := testEvents("first", "second")
+	require.NoError(t, client.Publish(context.Background(), batch))
+	assert.Equal(t, outest.BatchACK, batch.Signals[0].Tag)
+
+	requests := server.received()
+	require.Len(t, requests, 1)
+	req := requests[0]
+	assert.Equal(t, http.MethodPut, req.method)
+	assert.Equal(t, "gzip", req.headers.Get("Content-Encoding"))
+	assert.Equal(t, "application/json", req.headers.Get("Content-Type"))
+
+	var docs []map[string]any
+	require.NoError(t, json.Unmarshal(req.body, &docs))
+	require.Len(t, docs, 2)
+	assert.Equal(t, "first", docs[0]["message"])
+	assert.Equal(t, "second", docs[1]["message"])
+	assert.Equal(t, "2024-01-02T03:04:05.000Z", docs[0]["@timestamp"])
+}
+
+func TestPublishAuth(t *testing.T) {
+	tests := map[string]struct {
+		settings map[string]any
+		header   string
+	}{
+		"basic": {
+			settings: map[string]any{"username": "beat", "password": "secret"},
+			header:   "Basic YmVhdDpzZWNyZXQ=",
+		},
+		"bearer": {
+			settings: map[string]any{"bearer_token": "token"},
+			header:   "Bearer token",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			server, url := startServer(t, http.StatusOK)
+			tc.settings["hosts"] = []string{url}
+			client := newTestClient(t, tc.settings)
+
+			require.NoError(t, client.Publish(context.Background(), testEvents("first")))
+			requests := server.received()
+			require.Len(t, requests, 1)
+			assert.Equal(t, tc.header, requests[0].headers.Get("Authorization"))
+		})
+	}
+}
+
+func TestPublishStatusPolicy(t *testing.T) {
+	tests := map[string]struct {
+		status int
+		policy map[string]string
+		signal outest.BatchSignalTag
+		err    bool
+	}{
+		"service unavailable is retried": {
+			status: http.StatusServiceUnavailable,
+			signal: outest.BatchRetry,
+			err:    true,
+		},
+		"bad request is dropped": {
+			status: http.StatusBadRequest,
+			signal: outest.BatchDrop,
+		},
+		"conflict acked by policy": {
+			status: http.StatusConflict,
+			policy: map[string]string{"409": "ack"},
+			signal: outest.BatchACK,
+		},
+		"server errors dropped by policy": {
+			status: http.StatusInternalServerError,
+			policy: map[string]string{"5xx": "drop"},
+			signal: outest.BatchDrop,
+		},
+		"not found retried by policy": {
+			status: http.StatusNotFound,
+			policy: map[string]string{"404": "retry"},
+			signal: outest.BatchRetry,
+			err:    true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, url := startServer(t, tc.status)
+			settings := map[string]any{
+				"hosts":   []string{url},
+				"backoff": map[string]any{"init": "1ms", "max": "1ms"},
+			}
+			if tc.policy != nil {
+				settings["status_policy"] = tc.policy
+			}
+			client := newTestClient(t, settings)
+
+			batch := testEvents("first")
+			err := client.Publish(context.Background(), batch)
+			if tc.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			require.Len(t, batch.Signals, 1)
+			assert.Equal(t, tc.signal, batch.Signals[0].Tag)
+		})
+	}
+}
+
+// failingCodec fails to encode events whose message is "bad".
+type failingCodec struct{}
+
+func (failingCodec) Encode(_ string, event *beat.Event) ([]byte, error) {
+	if event.Fields["message"] == "bad" {
+		return nil, errors.New("cannot encode")
+	}
+	msg, _ := event.Fields["message"].(string)
+	return json.Marshal(map[string]string{"message": msg})
+}
+
+func TestPublishRetriesOnlyEncodedEvents(t *testing.T) {
+	server, url := startServer(t, http.StatusServiceUnavailable)
+	c := defaultConfig()
+	c.Transport.Timeout = 5 * time.Second
+	client := newClient(logptest.NewTestingLogger(t, ""), clientSettings{
+		url:      url,
+		config:   c,
+		codec:    failingCodec{},
+		observer: outputs.NewNilObserver(),
+	})
+	require.NoError(t, client.Connect(context.Background()))
+	defer client.Close()
+
+	batch := testEvents("first", "bad", "second")
+	assert.Error(t, client.Publish(context.Background(), batch))
+	require.Len(t, batch.Signals, 1)
+	assert.Equal(t, outest.BatchRetryEvents, batch.Signals[0].Tag)
+	require.Len(t, batch.Signals[0].Events, 2)
+	assert.Equal(t, "second", batch.Signals[0].Events[1].Content.Fields["message"])
+
+	requests := server.received()
+	require.Len(t, requests, 1)
+	assert.Equal(t, []string{"first", "second"}, messagesFromNDJSON(t, requests[0].body))
+}
+
+func TestPublishWritesDeadLetters(t *testing.T) {
+	_, url := startServer(t, http.StatusBadRequest)
+	client := newClient(logptest.NewTestingLogger(t, ""), clientSettings{
+		url:      url,
+		config:   defaultConfig(),
+		codec:    failingCodec{},
+		observer: outputs.NewNilObserver(),
+	})
+	require.NoError(t, client.Connect(context.Background()))
+	defer client.Close()
+
+	deadLetters := &outest.DeadLetters{}
+	ctx := outputs.WithDeadLetterWriter(context.Background(), deadLetters)
+	batch := testEvents("first", "bad")
+	require.NoError(t, client.Publish(ctx, batch))
+	require.Len(t, batch.Signals, 1)
+	assert.Equal(t, outest.BatchDrop, batch.Signals[0].Tag)
+
+	// The event failing to encode and the one rejected by the server.
+	events := deadLetters.Events()
+	require.Len(t, events, 2)
+	assert.Equal(t, "bad", events[0].Content.Fields["message"])
+	assert.ErrorContains(t, deadLetters.Reasons()[0], "failed to serialize")
+	assert.Equal(t, "first", events[1].Content.Fields["message"])
+	assert.ErrorContains(t, deadLetters.Reasons()[1], "400")
+}
@@ -0,0 +1,107 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package httpout
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/outputs/codec"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/transport/httpcommon"
+)
+
+const (
+	batchFormatNDJSON    = "ndjson"
+	batchFormatJSONArray = "json_array"
+)
+
+type httpConfig struct {
+	Path             string                           `config:"path"`
+	Method           string                           `config:"method"`
+	Headers          map[string]string                `config:"headers"`
+	Username         string                           `config:"username"`
+	Password         string                           `config:"password"`
+	BearerToken      string                           `config:"bearer_token"`
+	BatchFormat      string                           `config:"batch_format"`
+	CompressionLevel int                              `config:"compression_level" validate:"min=0, max=9"`
+	StatusPolicy     statusPolicyConfig               `config:"status_policy"`
+	Codec            codec.Config                     `config:"codec"`
+	LoadBalance      bool                             `config:"loadbalance"`
+	BulkMaxSize      int                              `config:"bulk_max_size"`
+	MaxRetries       int                              `config:"max_retries"`
+	Backoff          backoff                          `config:"backoff"`
+	Transport        httpcommon.HTTPTransportSettings `config:",inline"`
+	Queue            config.Namespace                 `config:"queue"`
+}
+
+type backoff struct {
+	Init time.Duration
+	Max  time.Duration
+}
+
+func defaultConfig() httpConfig {
+	return httpConfig{
+		Method:           http.MethodPost,
+		BatchFormat:      batchFormatNDJSON,
+		CompressionLevel: 0,
+		LoadBalance:      true,
+		BulkMaxSize:      1600,
+		MaxRetries:       3,
+		Backoff: backoff{
+			Init: 1 * time.Second,
+			Max:  60 * time.Second,
+		},
+		Transport: httpcommon.DefaultHTTPTransportSettings(),
+	}
+}
+
+func (c *httpConfig) Validate() error {
+	switch c.Method {
+	case http.MethodPost, http.MethodPut:
+	default:
+		return fmt.Errorf("unsupported HTTP method %q, must be %s or %s", c.Method, http.MethodPost, http.MethodPut)
+	}
+
+	switch c.BatchFormat {
+	case batchFormatNDJSON, batchFormatJSONArray:
+	default:
+		return fmt.Errorf("unsupported batch_format %q, must be one of %q or %q", c.BatchFormat, batchFormatNDJSON, batchFormatJSONArray)
+	}
+	// A JSON array can only hold JSON documents, any other codec would
+	// produce an invalid body.
+	if name := c.Codec.Namespace.Name(); c.BatchFormat == batchFormatJSONArray && name != "" && name != "json" {
+		return fmt.Errorf("batch_format %q requires the json codec, got %q", batchFormatJSONArray, name)
+	}
+	if c.BatchFormat == batchFormatNDJSON {
+		if err := c.Codec.ValidateNewlineDelimited(); err != nil {
+			return err
+		}
+	}
+
+	if c.BearerToken != "" && (c.Username != "" || c.Password != "") {
+		return errors.New("cannot set both bearer_token and username/password")
+	}
+
+	if _, err := newStatusPolicy(c.StatusPolicy); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package httpout
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	_ "github.com/elastic/beats/v7/libbeat/outputs/codec/cbor"
+	_ "github.com/elastic/beats/v7/libbeat/outputs/codec/msgpack"
+	"github.com/elastic/elastic-agent-libs/config"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := map[string]struct {
+		settings map[string]any
+		err      string
+	}{
+		"defaults": {
+			settings: map[string]any{},
+		},
+		"json array with policy": {
+			settings: map[string]any{
+				"batch_format":  "json_array",
+				"status_policy": map[string]string{"409": "ack"},
+			},
+		},
+		"json array with json codec": {
+			settings: map[string]any{
+				"batch_format": "json_array",
+				"codec.json":   map[string]any{"pretty": false},
+			},
+		},
+		"json array with format codec": {
+			settings: map[string]any{
+				"batch_format":        "json_array",
+				"codec.format.string": "%{[message]}",
+			},
+			err: "requires the json codec",
+		},
+		"ndjson with format codec": {
+			settings: map[string]any{
+				"codec.format.string": "%{[message]}",
+			},
+		},
+		"ndjson with cbor codec": {
+			settings: map[string]any{
+				"codec.cbor": map[string]any{},
+			},
+			err: `the "cbor" codec produces binary documents`,
+		},
+		"ndjson with msgpack codec": {
+			settings: map[string]any{
+				"codec.msgpack": map[string]any{},
+			},
+			err: `the "msgpack" codec produces binary documents`,
+		},
+		"unknown batch format": {
+			settings: map[string]any{"batch_format": "csv"},
+			err:      "unsupported batch_format",
+		},
+		"unsupported method": {
+			settings: map[string]any{"method": "GET"},
+			err:      "unsupported HTTP method",
+		},
+		"bearer token and basic auth": {
+			settings: map[string]any{"bearer_token": "token", "username": "beat", "password": "secret"},
+			err:      "cannot set both bearer_token and username/password",
+		},
+		"invalid compression level": {
+			settings: map[string]any{"compression_level": 10},
+			err:      "compression_level",
+		},
+		"invalid status policy": {
+			settings: map[string]any{"status_policy": map[string]string{"5xx": "ignore"}},
+			err:      "invalid action",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := defaultConfig()
+			err := config.MustNewConfigFrom(tc.settings).Unpack(&c)
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
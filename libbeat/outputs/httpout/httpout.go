@@ -0,0 +1,104 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package httpout provides an output that sends batches of events to HTTP
+// endpoints, encoded as NDJSON or as a JSON array.
+package httpout
+
+import (
+	"errors"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/transport/tlscommon"
+)
+
+func init() {
+	outputs.RegisterType("http", makeHTTP)
+}
+
+func makeHTTP(
+	_ outputs.IndexManager,
+	beat beat.Info,
+	observer outputs.Observer,
+	cfg *config.C,
+) (outputs.Group, error) {
+	log := beat.Logger.Named("http")
+
+	hConfig := defaultConfig()
+	if err := cfg.Unpack(&hConfig); err != nil {
+		return outputs.Fail(err)
+	}
+
+	policy, err := newStatusPolicy(hConfig.StatusPolicy)
+	if err != nil {
+		return outputs.Fail(err)
+	}
+
+	hosts, err := outputs.ReadHostList(cfg)
+	if err != nil {
+		return outputs.Fail(err)
+	}
+	if len(hosts) == 0 {
+		return outputs.Fail(errors.New("the http output requires at least one host"))
+	}
+
+	tls, err := tlscommon.LoadTLSConfig(hConfig.Transport.TLS, beat.Logger)
+	if err != nil {
+		return outputs.Fail(err)
+	}
+	scheme := "http"
+	if tls != nil {
+		scheme = "https"
+	}
+
+	clients := make([]outputs.NetworkClient, len(hosts))
+	for i, host := range hosts {
+		url, err := common.MakeURL(scheme, hConfig.Path, host, 0)
+		if err != nil {
+			return outputs.Fail(err)
+		}
+
+		enc, err := codec.CreateEncoder(beat, hConfig.Codec)
+		if err != nil {
+			return outputs.Fail(err)
+		}
+
+		client := newClient(log, clientSettings{
+			url:       url,
+			index:     beat.Beat,
+			userAgent: beat.UserAgent,
+			config:    hConfig,
+			policy:    policy,
+			codec:     enc,
+			observer:  observer,
+		})
+		clients[i] = outputs.WithBackoff(client, hConfig.Backoff.Init, hConfig.Backoff.Max)
+	}
+
+	return outputs.SuccessNet(hConfig.Queue,
+		hConfig.LoadBalance,
+		hConfig.BulkMaxSize,
+		hConfig.MaxRetries,
+		nil,
+		beat.Logger,
+		beat.Paths,
+		outputs.NumofWorker(cfg), clients)
+}
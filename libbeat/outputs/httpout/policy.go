@@ -0,0 +1,142 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package httpout
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// action is what the output does with a batch after receiving a response.
+type action uint8
+
+const (
+	actionACK action = iota
+	actionRetry
+	actionDrop
+)
+
+var actionNames = map[string]action{
+	"ack":   actionACK,
+	"retry": actionRetry,
+	"drop":  actionDrop,
+}
+
+func (a action) String() string {
+	for name, v := range actionNames {
+		if v == a {
+			return name
+		}
+	}
+	return "unknown"
+}
+
+// statusPolicyConfig maps status codes or classes of status codes to actions.
+type statusPolicyConfig map[string]string
+
+// Unpack accepts the status_policy settings. The config library stores keys
+// made of digits like 409 as array indices, so exact status codes can come
+// in as a sparse list.
+func (c *statusPolicyConfig) Unpack(v any) error {
+	rules := statusPolicyConfig{}
+	add := func(status string, value any) error {
+		if value == nil {
+			return nil
+		}
+		name, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("invalid action %v for status %s in status_policy, must be a string", value, status)
+		}
+		rules[status] = name
+		return nil
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		for status, value := range v {
+			if err := add(status, value); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for i, value := range v {
+			if err := add(strconv.Itoa(i), value); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("status_policy must be a map of status codes to actions, got %T", v)
+	}
+	*c = rules
+	return nil
+}
+
+// statusPolicy decides the action for each response status code. Rules for
+// an exact status code take precedence over rules for a class of codes like
+// 5xx, which take precedence over the defaults.
+type statusPolicy struct {
+	codes   map[int]action
+	classes map[int]action
+}
+
+func newStatusPolicy(rules map[string]string) (statusPolicy, error) {
+	p := statusPolicy{codes: map[int]action{}, classes: map[int]action{}}
+	for status, name := range rules {
+		a, ok := actionNames[strings.ToLower(name)]
+		if !ok {
+			return p, fmt.Errorf("invalid action %q for status %s in status_policy, must be one of ack, retry or drop", name, status)
+		}
+
+		if len(status) == 3 && strings.HasSuffix(strings.ToLower(status), "xx") {
+			class := int(status[0] - '0')
+			if class < 1 || class > 5 {
+				return p, fmt.Errorf("invalid status class %q in status_policy", status)
+			}
+			p.classes[class] = a
+			continue
+		}
+
+		code, err := strconv.Atoi(status)
+		if err != nil || code < 100 || code > 599 {
+			return p, fmt.Errorf("invalid status code %q in status_policy", status)
+		}
+		p.codes[code] = a
+	}
+	return p, nil
+}
+
+// action returns the action for a response status code.
+func (p statusPolicy) action(code int) action {
+	if a, ok := p.codes[code]; ok {
+		return a
+	}
+	if a, ok := p.classes[code/100]; ok {
+		return a
+	}
+
+	switch {
+	case code >= 200 && code < 300:
+		return actionACK
+	case code == http.StatusRequestTimeout, code == http.StatusTooManyRequests, code >= 500:
+		return actionRetry
+	default:
+		return actionDrop
+	}
+}
@@ -0,0 +1,114 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package httpout
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/elastic-agent-libs/config"
+)
+
+func TestStatusPolicy(t *testing.T) {
+	p, err := newStatusPolicy(map[string]string{
+		"409": "ack",
+		"4xx": "retry",
+		"503": "drop",
+	})
+	require.NoError(t, err)
+
+	tests := map[int]action{
+		200: actionACK,
+		204: actionACK,
+		409: actionACK,   // exact code
+		404: actionRetry, // class rule
+		429: actionRetry, // class rule
+		503: actionDrop,  // exact code over default
+		500: actionRetry, // default
+		301: actionDrop,  // default
+	}
+	for code, want := range tests {
+		assert.Equal(t, want, p.action(code), "status %d", code)
+	}
+}
+
+func TestStatusPolicyConfig(t *testing.T) {
+	cfg, err := config.NewConfigWithYAML([]byte(`
+status_policy:
+  "409": ack
+  "404": drop
+  5xx: drop
+`), "")
+	require.NoError(t, err)
+
+	c := defaultConfig()
+	require.NoError(t, cfg.Unpack(&c))
+	assert.Equal(t, statusPolicyConfig{"404": "drop", "409": "ack", "5xx": "drop"}, c.StatusPolicy)
+}
+
+func TestStatusPolicyDefaults(t *testing.T) {
+	p, err := newStatusPolicy(nil)
+	require.NoError(t, err)
+
+	tests := map[int]action{
+		200: actionACK,
+		400: actionDrop,
+		401: actionDrop,
+		408: actionRetry,
+		429: actionRetry,
+		500: actionRetry,
+		502: actionRetry,
+	}
+	for code, want := range tests {
+		assert.Equal(t, want, p.action(code), "status %d", code)
+	}
+}
+
+func TestStatusPolicyErrors(t *testing.T) {
+	tests := map[string]struct {
+		rules map[string]string
+		err   string
+	}{
+		"unknown action": {
+			rules: map[string]string{"500": "ignore"},
+			err:   "invalid action",
+		},
+		"invalid code": {
+			rules: map[string]string{"abc": "drop"},
+			err:   "invalid status code",
+		},
+		"code out of range": {
+			rules: map[string]string{"600": "drop"},
+			err:   "invalid status code",
+		},
+		"invalid class": {
+			rules: map[string]string{"9xx": "drop"},
+			err:   "invalid status class",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := newStatusPolicy(tc.rules)
+			assert.ErrorContains(t, err, tc.err)
+		})
+	}
+}
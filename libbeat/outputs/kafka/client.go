@@ -33,6 +33,7 @@ import (
 	"github.com/elastic/beats/v7/libbeat/common/fmtstr"
 	"github.com/elastic/beats/v7/libbeat/outputs"
 	"github.com/elastic/beats/v7/libbeat/outputs/codec"
+	"github.com/elastic/beats/v7/libbeat/outputs/kafka/schemaregistry"
 	"github.com/elastic/beats/v7/libbeat/outputs/outil"
 	"github.com/elastic/beats/v7/libbeat/publisher"
 	"github.com/elastic/elastic-agent-libs/logp"
@@ -57,6 +58,10 @@ type client struct {
 
 	recordHeaders []sarama.RecordHeader
 
+	// registry encodes the events instead of codec when the messages use
+	// the schema registry wire format.
+	registry *schemaregistry.Encoder
+
 	wg sync.WaitGroup
 }
 
@@ -67,6 +72,16 @@ type msgRef struct {
 	failed []publisher.Event
 	batch  publisher.Batch
 
+	// deadLetter receives the events failed permanently.
+	deadLetter outputs.DeadLetterWriter
+
+	// flushed is closed once every message of a transactional batch is
+	// acknowledged or failed, instead of signaling the batch.
+	flushed chan struct{}
+	// written holds the events of a transactional batch acknowledged by
+	// Kafka, retried if the transaction is aborted.
+	written []publisher.Event
+
 	err error
 }
 
@@ -96,6 +111,7 @@ func newKafkaClient(
 	topic outil.Selector,
 	headers []header,
 	writer codec.Codec,
+	registry *schemaregistry.Encoder,
 	cfg *sarama.Config,
 	logger *logp.Logger,
 ) (*client, error) {
@@ -107,6 +123,7 @@ func newKafkaClient(
 		key:      key,
 		index:    strings.ToLower(index),
 		codec:    writer,
+		registry: registry,
 		config:   *cfg,
 		done:     make(chan struct{}),
 	}
@@ -136,6 +153,15 @@ func (c *client) Connect(_ context.Context) error {
 
 	c.log.Debugf("connect: %v", c.hosts)
 
+	if c.registry != nil {
+		if err := c.registry.Connect(); err != nil {
+			return err
+		}
+	}
+
+	// done was closed if the client was closed before reconnecting.
+	c.done = make(chan struct{})
+
 	// try to connect
 	producer, err := sarama.NewAsyncProducer(c.hosts, &c.config)
 	if err != nil {
@@ -157,6 +183,10 @@ func (c *client) Close() error {
 	defer c.mux.Unlock()
 	c.log.Debug("closed kafka client")
 
+	if c.registry != nil {
+		c.registry.Close()
+	}
+
 	// producer was not created before the close() was called.
 	if c.producer == nil {
 		return nil
@@ -176,24 +206,43 @@ func (c *client) Close() error {
 	return nil
 }
 
-func (c *client) Publish(_ context.Context, batch publisher.Batch) error {
+func (c *client) Publish(ctx context.Context, batch publisher.Batch) error {
 	events := batch.Events()
 	c.observer.NewBatch(len(events))
 
 	ref := &msgRef{
-		client: c,
-		count:  int32(len(events)), //nolint:gosec //keep old behavior
-		total:  len(events),
-		failed: nil,
-		batch:  batch,
+		client:     c,
+		count:      int32(len(events)), //nolint:gosec //keep old behavior
+		total:      len(events),
+		failed:     nil,
+		batch:      batch,
+		deadLetter: outputs.DeadLetterWriterFrom(ctx),
 	}
 
-	ch := c.producer.Input()
+	producer := c.producer
+	if c.transactional() {
+		if err := producer.BeginTxn(); err != nil {
+			// The producer is recreated on reconnect.
+			batch.Retry()
+			c.observer.RetryableErrors(len(events))
+			return fmt.Errorf("beginning kafka transaction failed: %w", err)
+		}
+		ref.flushed = make(chan struct{})
+	}
+
+	ch := producer.Input()
 	for i := range events {
 		d := &events[i]
-		msg, err := c.getEventMessage(d)
+		msg, err := c.getEventMessage(ctx, d)
+		if schemaregistry.IsTemporary(err) {
+			// The schema registry is unavailable, the event is retried
+			// with the rest of the batch.
+			ref.fail(&message{data: *d}, err)
+			continue
+		}
 		if err != nil {
 			c.log.Errorf("Dropping event: %+v", err)
+			ref.deadLetter.WriteDeadLetters(events[i:i+1], err)
 			ref.done()
 			c.observer.PermanentErrors(1)
 			continue
@@ -203,11 +252,51 @@ func (c *client) Publish(_ context.Context, batch publisher.Batch) error {
 		msg.initProducerMessage()
 		if !c.send(ch, &msg.msg) {
 			c.log.Errorf("output closing, dropping event")
+			ref.deadLetter.WriteDeadLetters(events[i:i+1], errors.New("output closing"))
 			ref.done()
 			c.observer.PermanentErrors(1)
 		}
 	}
 
+	if ref.flushed != nil {
+		<-ref.flushed
+		return c.endTxn(producer, ref)
+	}
+	return nil
+}
+
+// transactional returns true when each batch is written in a Kafka
+// transaction.
+func (c *client) transactional() bool {
+	return c.config.Producer.Transaction.ID != ""
+}
+
+// endTxn commits the transaction of a batch if all its messages were
+// written, and aborts it otherwise so that its events are retried. An
+// error is returned if the producer can't be used anymore.
+func (c *client) endTxn(producer sarama.AsyncProducer, r *msgRef) error {
+	stats := c.observer
+
+	err := r.err
+	if len(r.failed) == 0 {
+		err = producer.CommitTxn()
+		if err == nil {
+			r.batch.ACK()
+			stats.AckedEvents(len(r.written))
+			return nil
+		}
+	}
+
+	// Messages failed permanently are already dropped, everything else
+	// has to be written again in a new transaction.
+	retry := append(r.written, r.failed...)
+	r.batch.RetryEvents(retry)
+	stats.RetryableErrors(len(retry))
+	c.log.Errorf("Kafka publish failed, aborting transaction: %v", err)
+
+	if err := producer.AbortTxn(); err != nil {
+		return fmt.Errorf("aborting kafka transaction failed: %w", err)
+	}
 	return nil
 }
 
@@ -236,7 +325,7 @@ func (c *client) String() string {
 	return "kafka(" + strings.Join(c.hosts, ",") + ")"
 }
 
-func (c *client) getEventMessage(data *publisher.Event) (*message, error) {
+func (c *client) getEventMessage(ctx context.Context, data *publisher.Event) (*message, error) {
 	event := &data.Content
 	msg := &message{partition: -1, data: *data}
 
@@ -274,7 +363,12 @@ func (c *client) getEventMessage(data *publisher.Event) (*message, error) {
 		}
 	}
 
-	serializedEvent, err := c.codec.Encode(c.index, event)
+	var serializedEvent []byte
+	if c.registry != nil {
+		serializedEvent, err = c.registry.Encode(ctx, msg.topic, c.index, event)
+	} else {
+		serializedEvent, err = c.codec.Encode(c.index, event)
+	}
 	if err != nil {
 		if c.log.IsDebug() {
 			c.log.Debug("failed event logged to event log file")
@@ -311,7 +405,7 @@ func (c *client) successWorker(ch <-chan *sarama.ProducerMessage) {
 			c.log.Debug("Failed to assert libMsg.Metadata to *message")
 			return
 		}
-		msg.ref.done()
+		msg.ref.acked(msg)
 	}
 }
 
@@ -413,26 +507,34 @@ func (r *msgRef) done() {
 	r.dec()
 }
 
+// acked reports a message written by Kafka.
+func (r *msgRef) acked(msg *message) {
+	if r.flushed != nil {
+		r.written = append(r.written, msg.data)
+	}
+	r.dec()
+}
+
 func (r *msgRef) fail(msg *message, err error) {
 	switch {
 	case errors.Is(err, sarama.ErrInvalidMessage):
 		r.client.log.Errorf("Kafka (topic=%v): dropping invalid message", msg.topic)
-		r.client.observer.PermanentErrors(1)
+		r.dropped(msg, err)
 
 	case errors.Is(err, sarama.ErrMessageSizeTooLarge) || errors.Is(err, sarama.ErrInvalidMessageSize):
 		r.client.log.Errorf("Kafka (topic=%v): dropping too large message of size %v.",
 			msg.topic,
 			len(msg.key)+len(msg.value))
-		r.client.observer.PermanentErrors(1)
+		r.dropped(msg, err)
 
 	// drop event if it exceeds size larger than max_message_bytes
 	case strings.Contains(err.Error(), "Attempt to produce message larger than configured Producer.MaxMessageBytes"):
 		r.client.log.Errorf("Kafka (topic=%v): dropping message as it exceeds max_mesage_bytes:", msg.topic)
-		r.client.observer.PermanentErrors(1)
+		r.dropped(msg, err)
 
 	case isAuthError(err):
 		r.client.log.Errorf("Kafka (topic=%v): authorisation error: %s", msg.topic, err)
-		r.client.observer.PermanentErrors(1)
+		r.dropped(msg, err)
 
 	case errors.Is(err, breaker.ErrBreakerOpen):
 		// Add this message to the failed list, but don't overwrite r.err since
@@ -450,6 +552,12 @@ func (r *msgRef) fail(msg *message, err error) {
 	r.dec()
 }
 
+// dropped reports a message failed permanently.
+func (r *msgRef) dropped(msg *message, err error) {
+	r.deadLetter.WriteDeadLetters([]publisher.Event{msg.data}, fmt.Errorf("kafka (topic=%v): %w", msg.topic, err))
+	r.client.observer.PermanentErrors(1)
+}
+
 func (r *msgRef) dec() {
 	i := atomic.AddInt32(&r.count, -1)
 	if i > 0 {
@@ -457,6 +565,11 @@ func (r *msgRef) dec() {
 	}
 
 	r.client.log.Debug("finished kafka batch")
+	if r.flushed != nil {
+		// The batch is signaled once its transaction ends.
+		close(r.flushed)
+		return
+	}
 	stats := r.client.observer
 
 	err := r.err
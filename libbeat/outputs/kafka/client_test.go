@@ -19,6 +19,8 @@ package kafka
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -28,10 +30,12 @@ import (
 
 	"github.com/elastic/beats/v7/libbeat/beat"
 	"github.com/elastic/beats/v7/libbeat/outputs"
-	_ "github.com/elastic/beats/v7/libbeat/outputs/codec/json"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec/json"
 	"github.com/elastic/beats/v7/libbeat/outputs/outest"
+	"github.com/elastic/beats/v7/libbeat/outputs/outil"
 	"github.com/elastic/elastic-agent-libs/config"
 	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
 	"github.com/elastic/elastic-agent-libs/monitoring"
 	"github.com/elastic/elastic-agent-libs/paths"
 	"github.com/elastic/sarama"
@@ -159,3 +163,237 @@ func (p producerMock) AddOffsetsToTxn(offsets map[string][]*sarama.PartitionOffs
 func (p producerMock) AddMessageToTxn(msg *sarama.ConsumerMessage, groupId string, metadata *string) error {
 	panic("implement me")
 }
+
+func TestMsgRefDeadLetters(t *testing.T) {
+	deadLetters := &outest.DeadLetters{}
+	batch := outest.NewBatch(
+		beat.Event{Fields: map[string]any{"msg": "too large"}},
+		beat.Event{Fields: map[string]any{"msg": "unavailable"}},
+	)
+	events := batch.Events()
+	ref := &msgRef{
+		client: &client{
+			log:      logptest.NewTestingLogger(t, ""),
+			observer: outputs.NewNilObserver(),
+		},
+		count:      2,
+		total:      2,
+		batch:      batch,
+		deadLetter: deadLetters,
+	}
+
+	ref.fail(&message{topic: "test", data: events[0]}, sarama.ErrMessageSizeTooLarge)
+	ref.fail(&message{topic: "test", data: events[1]}, sarama.ErrLeaderNotAvailable)
+
+	// Only the permanent failure is written to the dead letter file, the
+	// other event is retried.
+	require.Len(t, deadLetters.Events(), 1)
+	assert.Equal(t, "too large", deadLetters.Events()[0].Content.Fields["msg"])
+	assert.ErrorIs(t, deadLetters.Reasons()[0], sarama.ErrMessageSizeTooLarge)
+	assert.ErrorContains(t, deadLetters.Reasons()[0], "topic=test")
+
+	require.Len(t, batch.Signals, 1)
+	assert.Equal(t, outest.BatchRetryEvents, batch.Signals[0].Tag)
+	require.Len(t, batch.Signals[0].Events, 1)
+	assert.Equal(t, "unavailable", batch.Signals[0].Events[0].Content.Fields["msg"])
+}
+
+// txnProducerMock is a transactional producer writing the messages
+// accepted by fail.
+type txnProducerMock struct {
+	input     chan *sarama.ProducerMessage
+	successes chan *sarama.ProducerMessage
+	errors    chan *sarama.ProducerError
+
+	// fail returns the error a message fails with, nil if it's written.
+	fail      func(*sarama.ProducerMessage) error
+	commitErr error
+	abortErr  error
+
+	began, committed, aborted int
+}
+
+func newTxnProducerMock(fail func(*sarama.ProducerMessage) error) *txnProducerMock {
+	p := &txnProducerMock{
+		input:     make(chan *sarama.ProducerMessage),
+		successes: make(chan *sarama.ProducerMessage),
+		errors:    make(chan *sarama.ProducerError),
+		fail:      fail,
+	}
+	go func() {
+		defer close(p.successes)
+		defer close(p.errors)
+		for msg := range p.input {
+			if err := p.fail(msg); err != nil {
+				p.errors <- &sarama.ProducerError{Msg: msg, Err: err}
+				continue
+			}
+			p.successes <- msg
+		}
+	}()
+	return p
+}
+
+func (p *txnProducerMock) AsyncClose() {
+	close(p.input)
+}
+
+func (p *txnProducerMock) Input() chan<- *sarama.ProducerMessage {
+	return p.input
+}
+
+func (p *txnProducerMock) Close() error {
+	p.AsyncClose()
+	return nil
+}
+
+func (p *txnProducerMock) Successes() <-chan *sarama.ProducerMessage {
+	return p.successes
+}
+
+func (p *txnProducerMock) Errors() <-chan *sarama.ProducerError {
+	return p.errors
+}
+
+func (p *txnProducerMock) IsTransactional() bool {
+	return true
+}
+
+func (p *txnProducerMock) TxnStatus() sarama.ProducerTxnStatusFlag {
+	return sarama.ProducerTxnFlagReady
+}
+
+func (p *txnProducerMock) BeginTxn() error {
+	p.began++
+	return nil
+}
+
+func (p *txnProducerMock) CommitTxn() error {
+	p.committed++
+	return p.commitErr
+}
+
+func (p *txnProducerMock) AbortTxn() error {
+	p.aborted++
+	return p.abortErr
+}
+
+func (p *txnProducerMock) AddOffsetsToTxn(map[string][]*sarama.PartitionOffsetMetadata, string) error {
+	panic("implement me")
+}
+
+func (p *txnProducerMock) AddMessageToTxn(*sarama.ConsumerMessage, string, *string) error {
+	panic("implement me")
+}
+
+// newTxnClient returns a transactional client connected to p.
+func newTxnClient(t *testing.T, p *txnProducerMock) *client {
+	t.Helper()
+
+	cfg := sarama.NewConfig()
+	cfg.Producer.Transaction.ID = "testbeat"
+	c, err := newKafkaClient(
+		outputs.NewNilObserver(),
+		[]string{"localhost:9092"},
+		"testbeat",
+		nil,
+		outil.MakeSelector(outil.ConstSelectorExpr("test", outil.SelectorKeepCase)),
+		nil,
+		json.New("1.2.3", json.Config{}),
+		nil,
+		cfg,
+		logptest.NewTestingLogger(t, ""),
+	)
+	require.NoError(t, err)
+
+	c.producer = p
+	c.wg.Add(2)
+	go c.successWorker(p.Successes())
+	go c.errorWorker(p.Errors())
+	t.Cleanup(func() { require.NoError(t, c.Close()) })
+	return c
+}
+
+func txnTestBatch() *outest.Batch {
+	return outest.NewBatch(
+		beat.Event{Fields: map[string]any{"msg": "message 1"}},
+		beat.Event{Fields: map[string]any{"msg": "message 2"}},
+		beat.Event{Fields: map[string]any{"msg": "message 3"}},
+	)
+}
+
+func TestTransactionalPublishCommits(t *testing.T) {
+	p := newTxnProducerMock(func(*sarama.ProducerMessage) error { return nil })
+	c := newTxnClient(t, p)
+
+	batch := txnTestBatch()
+	require.NoError(t, c.Publish(context.Background(), batch))
+
+	assert.Equal(t, 1, p.began)
+	assert.Equal(t, 1, p.committed)
+	assert.Zero(t, p.aborted)
+	require.Len(t, batch.Signals, 1)
+	assert.Equal(t, outest.BatchACK, batch.Signals[0].Tag)
+}
+
+func TestTransactionalPublishAbortsOnFailure(t *testing.T) {
+	p := newTxnProducerMock(func(msg *sarama.ProducerMessage) error {
+		if strings.Contains(string(msg.Value.(sarama.ByteEncoder)), "message 2") {
+			return sarama.ErrLeaderNotAvailable
+		}
+		return nil
+	})
+	c := newTxnClient(t, p)
+
+	batch := txnTestBatch()
+	require.NoError(t, c.Publish(context.Background(), batch))
+
+	assert.Zero(t, p.committed)
+	assert.Equal(t, 1, p.aborted)
+
+	// The events written in the aborted transaction are retried too.
+	require.Len(t, batch.Signals, 1)
+	assert.Equal(t, outest.BatchRetryEvents, batch.Signals[0].Tag)
+	assert.Len(t, batch.Signals[0].Events, 3)
+}
+
+func TestTransactionalPublishDropsPermanentFailures(t *testing.T) {
+	p := newTxnProducerMock(func(msg *sarama.ProducerMessage) error {
+		if strings.Contains(string(msg.Value.(sarama.ByteEncoder)), "message 2") {
+			return sarama.ErrMessageSizeTooLarge
+		}
+		return nil
+	})
+	p.commitErr = errors.New("transaction in error state")
+	c := newTxnClient(t, p)
+
+	deadLetters := &outest.DeadLetters{}
+	ctx := outputs.WithDeadLetterWriter(context.Background(), deadLetters)
+	batch := txnTestBatch()
+	require.NoError(t, c.Publish(ctx, batch))
+
+	assert.Equal(t, 1, p.committed)
+	assert.Equal(t, 1, p.aborted)
+	require.Len(t, deadLetters.Events(), 1)
+	assert.Equal(t, "message 2", deadLetters.Events()[0].Content.Fields["msg"])
+
+	require.Len(t, batch.Signals, 1)
+	assert.Equal(t, outest.BatchRetryEvents, batch.Signals[0].Tag)
+	assert.Len(t, batch.Signals[0].Events, 2)
+}
+
+func TestTransactionalPublishAbortFails(t *testing.T) {
+	p := newTxnProducerMock(func(*sarama.ProducerMessage) error { return nil })
+	p.commitErr = sarama.ErrProducerFenced
+	p.abortErr = sarama.ErrProducerFenced
+	c := newTxnClient(t, p)
+
+	// The producer can't be used anymore, the error makes the output
+	// reconnect.
+	batch := txnTestBatch()
+	require.ErrorIs(t, c.Publish(context.Background(), batch), sarama.ErrProducerFenced)
+
+	require.Len(t, batch.Signals, 1)
+	assert.Equal(t, outest.BatchRetryEvents, batch.Signals[0].Tag)
+	assert.Len(t, batch.Signals[0].Events, 3)
+}
@@ -31,6 +31,7 @@ import (
 	"github.com/elastic/beats/v7/libbeat/common/transport/kerberos"
 	"github.com/elastic/beats/v7/libbeat/management"
 	"github.com/elastic/beats/v7/libbeat/outputs/codec"
+	"github.com/elastic/beats/v7/libbeat/outputs/kafka/schemaregistry"
 	"github.com/elastic/elastic-agent-libs/config"
 	"github.com/elastic/elastic-agent-libs/logp"
 	"github.com/elastic/elastic-agent-libs/monitoring"
@@ -77,6 +78,9 @@ type KafkaConfig struct {
 	Sasl               kafka.SaslConfig          `config:"sasl"`
 	Queue              config.Namespace          `config:"queue"`
 	Idempotent         bool                      `config:"idempotent"`
+	Transactional      bool                      `config:"transactional"`
+	TransactionalID    string                    `config:"transactional_id"`
+	SchemaRegistry     *config.C                 `config:"schema_registry"`
 
 	// Currently only used for validation. Those values are later
 	// unpacked into temporary structs whenever they're necessary.
@@ -201,6 +205,22 @@ func (c *KafkaConfig) Validate() error {
 		}
 	}
 
+	if c.Transactional && !c.Idempotent {
+		return errors.New("transactional mode requires idempotent to be enabled")
+	}
+	if c.TransactionalID != "" && !c.Transactional {
+		return errors.New("transactional_id requires transactional to be enabled")
+	}
+
+	if c.SchemaRegistry != nil {
+		if c.Codec.Namespace.IsSet() {
+			return errors.New("codec can't be set together with schema_registry")
+		}
+		if _, err := c.schemaRegistryConfig(); err != nil {
+			return err
+		}
+	}
+
 	// When running under Elastic-Agent we do not support dynamic topic
 	// selection, so `topics` is not supported and `topic` is treated as an
 	// plain string
@@ -213,6 +233,19 @@ func (c *KafkaConfig) Validate() error {
 	return nil
 }
 
+// schemaRegistryConfig returns the schema_registry settings, nil when the
+// messages are encoded with the codec.
+func (c *KafkaConfig) schemaRegistryConfig() (*schemaregistry.Config, error) {
+	if c.SchemaRegistry == nil {
+		return nil, nil
+	}
+	regCfg := schemaregistry.DefaultConfig()
+	if err := c.SchemaRegistry.Unpack(&regCfg); err != nil {
+		return nil, fmt.Errorf("invalid schema_registry settings: %w", err)
+	}
+	return &regCfg, nil
+}
+
 func newSaramaConfig(log *logp.Logger, config *KafkaConfig) (*sarama.Config, error) {
 	partitioner, err := makePartitioner(log, config.Partition)
 	if err != nil {
@@ -238,6 +271,9 @@ func newSaramaConfig(log *logp.Logger, config *KafkaConfig) (*sarama.Config, err
 	if k.Producer.Idempotent {
 		k.Net.MaxOpenRequests = 1
 	}
+	if config.Transactional {
+		k.Producer.Transaction.ID = config.TransactionalID
+	}
 
 	tls, err := tlscommon.LoadTLSConfig(config.TLS, log)
 	if err != nil {
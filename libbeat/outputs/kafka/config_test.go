@@ -46,6 +46,13 @@ func TestConfigAcceptValid(t *testing.T) {
 			"version":     "1.0.0",
 			"topic":       "foo",
 		},
+		"schema registry": mapstr.M{
+			"topic": "foo",
+			"schema_registry": mapstr.M{
+				"url":    "http://localhost:8081",
+				"schema": `{"type": "record", "name": "Log", "fields": [{"name": "message", "type": "string"}]}`,
+			},
+		},
 	}
 
 	for name, test := range tests {
@@ -78,6 +85,20 @@ func TestConfigInvalid(t *testing.T) {
 		},
 		// The default config does not set `topic` nor `topics`.
 		"No topics or topic provided": mapstr.M{},
+		"schema registry with a codec": mapstr.M{
+			"topic":      "foo",
+			"codec.json": mapstr.M{},
+			"schema_registry": mapstr.M{
+				"url": "http://localhost:8081",
+			},
+		},
+		"schema registry with an unknown format": mapstr.M{
+			"topic": "foo",
+			"schema_registry": mapstr.M{
+				"url":    "http://localhost:8081",
+				"format": "thrift",
+			},
+		},
 	}
 
 	for name, test := range tests {
@@ -229,6 +250,63 @@ func TestIdempotentConfig(t *testing.T) {
 	}
 }
 
+func TestTransactionalConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         mapstr.M
+		expectError bool
+	}{
+		{
+			name: "valid transactional config",
+			cfg: mapstr.M{
+				"topic":            "foo",
+				"idempotent":       true,
+				"transactional":    true,
+				"transactional_id": "filebeat-1",
+				"required_acks":    -1,
+			},
+		},
+		{
+			name: "transactional requires idempotent",
+			cfg: mapstr.M{
+				"topic":            "foo",
+				"transactional":    true,
+				"transactional_id": "filebeat-1",
+				"required_acks":    -1,
+			},
+			expectError: true,
+		},
+		{
+			name: "transactional_id requires transactional",
+			cfg: mapstr.M{
+				"topic":            "foo",
+				"idempotent":       true,
+				"transactional_id": "filebeat-1",
+				"required_acks":    -1,
+			},
+			expectError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := config.MustNewConfigFrom(test.cfg)
+			assert.NoError(t, c.SetString("hosts", 0, "localhost"), "setting hosts should succeed")
+
+			cfg, err := ReadConfig(c)
+			if test.expectError {
+				assert.Error(t, err, "expected invalid transactional config to be rejected")
+				return
+			}
+			assert.NoError(t, err, "expected valid transactional config to unpack")
+
+			sc, err := newSaramaConfig(logptest.NewTestingLogger(t, ""), cfg)
+			assert.NoError(t, err, "expected sarama config creation to succeed")
+			assert.Equal(t, "filebeat-1", sc.Producer.Transaction.ID, "Producer.Transaction.ID should be set")
+		})
+	}
+}
+
 func TestConfig_defaults(t *testing.T) {
 	t.Run("SingleFlightOn_is_set", func(t *testing.T) {
 		c := config.MustNewConfigFrom(`
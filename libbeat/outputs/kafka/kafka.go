@@ -23,6 +23,7 @@ import (
 	"github.com/elastic/beats/v7/libbeat/beat"
 	"github.com/elastic/beats/v7/libbeat/outputs"
 	"github.com/elastic/beats/v7/libbeat/outputs/codec"
+	"github.com/elastic/beats/v7/libbeat/outputs/kafka/schemaregistry"
 	"github.com/elastic/beats/v7/libbeat/outputs/outil"
 	"github.com/elastic/elastic-agent-libs/config"
 	"github.com/elastic/elastic-agent-libs/logp"
@@ -58,6 +59,12 @@ func makeKafka(
 		return outputs.Fail(err)
 	}
 
+	if kConfig.Transactional && kConfig.TransactionalID == "" {
+		// The ID has to be stable across restarts: the producer of a new
+		// session fences the previous one and aborts its open transaction.
+		kConfig.TransactionalID = beat.Beat + "-" + beat.ID.String()
+	}
+
 	libCfg, err := newSaramaConfig(log, kConfig)
 	if err != nil {
 		return outputs.Fail(err)
@@ -73,7 +80,19 @@ func makeKafka(
 		return outputs.Fail(err)
 	}
 
-	client, err := newKafkaClient(observer, hosts, beat.IndexPrefix, kConfig.Key, topic, kConfig.Headers, codec, libCfg, beat.Logger)
+	var registry *schemaregistry.Encoder
+	regCfg, err := kConfig.schemaRegistryConfig()
+	if err != nil {
+		return outputs.Fail(err)
+	}
+	if regCfg != nil {
+		registry, err = schemaregistry.NewEncoder(log, *regCfg, beat.Version)
+		if err != nil {
+			return outputs.Fail(fmt.Errorf("schema_registry: %w", err))
+		}
+	}
+
+	client, err := newKafkaClient(observer, hosts, beat.IndexPrefix, kConfig.Key, topic, kConfig.Headers, codec, registry, libCfg, beat.Logger)
 	if err != nil {
 		return outputs.Fail(err)
 	}
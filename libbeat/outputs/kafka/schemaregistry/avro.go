@@ -0,0 +1,467 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package schemaregistry
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// avroSchema is a parsed Avro schema. Only the binary encoding is
+// implemented, values are read from the generic maps, slices and scalars an
+// event is converted to.
+type avroSchema struct {
+	typ string
+
+	// logicalType is only used for the timestamp and date types on long and
+	// int, values of other logical types are encoded with their base type.
+	logicalType string
+
+	name     string        // record, enum and fixed
+	fields   []avroField   // record
+	symbols  []string      // enum
+	items    *avroSchema   // array
+	values   *avroSchema   // map
+	size     int           // fixed
+	branches []*avroSchema // union
+}
+
+type avroField struct {
+	name       string
+	schema     *avroSchema
+	hasDefault bool
+	defaultVal any
+}
+
+var avroPrimitives = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true,
+	"float": true, "double": true, "bytes": true, "string": true,
+}
+
+// parseAvroSchema parses an Avro schema in its JSON form.
+func parseAvroSchema(schema string) (*avroSchema, error) {
+	var raw any
+	if err := json.Unmarshal([]byte(schema), &raw); err != nil {
+		return nil, fmt.Errorf("invalid Avro schema: %w", err)
+	}
+	p := avroParser{named: map[string]*avroSchema{}}
+	return p.parse(raw, "")
+}
+
+type avroParser struct {
+	named map[string]*avroSchema
+}
+
+func (p *avroParser) parse(raw any, namespace string) (*avroSchema, error) {
+	switch v := raw.(type) {
+	case string:
+		if avroPrimitives[v] {
+			return &avroSchema{typ: v}, nil
+		}
+		if s, ok := p.named[fullName(v, namespace)]; ok {
+			return s, nil
+		}
+		if s, ok := p.named[v]; ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("unknown Avro type %q", v)
+
+	case []any:
+		union := &avroSchema{typ: "union"}
+		for _, branch := range v {
+			s, err := p.parse(branch, namespace)
+			if err != nil {
+				return nil, err
+			}
+			if s.typ == "union" {
+				return nil, errors.New("Avro unions can't contain unions")
+			}
+			union.branches = append(union.branches, s)
+		}
+		return union, nil
+
+	case map[string]any:
+		return p.parseComplex(v, namespace)
+
+	default:
+		return nil, fmt.Errorf("invalid Avro schema %v", raw)
+	}
+}
+
+func (p *avroParser) parseComplex(v map[string]any, namespace string) (*avroSchema, error) {
+	typ, _ := v["type"].(string)
+	logicalType, _ := v["logicalType"].(string)
+	if typ == "" {
+		// {"type": {...}} or {"type": [...]}, the type is a nested schema.
+		return p.parse(v["type"], namespace)
+	}
+	if avroPrimitives[typ] {
+		return &avroSchema{typ: typ, logicalType: logicalType}, nil
+	}
+
+	switch typ {
+	case "record", "error", "enum", "fixed":
+		name, _ := v["name"].(string)
+		if name == "" {
+			return nil, fmt.Errorf("Avro %s without a name", typ)
+		}
+		if ns, ok := v["namespace"].(string); ok && !strings.Contains(name, ".") {
+			namespace = ns
+		}
+		s := &avroSchema{typ: typ, name: fullName(name, namespace), logicalType: logicalType}
+		if typ == "error" {
+			s.typ = "record"
+		}
+		if i := strings.LastIndex(s.name, "."); i >= 0 {
+			namespace = s.name[:i]
+		}
+		// Register the type before its fields, records may be recursive.
+		p.named[s.name] = s
+		return s, p.parseNamed(s, v, namespace)
+
+	case "array":
+		items, err := p.parse(v["items"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		return &avroSchema{typ: typ, items: items}, nil
+
+	case "map":
+		values, err := p.parse(v["values"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		return &avroSchema{typ: typ, values: values}, nil
+
+	default:
+		return p.parse(typ, namespace)
+	}
+}
+
+func (p *avroParser) parseNamed(s *avroSchema, v map[string]any, namespace string) error {
+	switch s.typ {
+	case "record":
+		fields, _ := v["fields"].([]any)
+		for _, f := range fields {
+			fv, ok := f.(map[string]any)
+			if !ok {
+				return fmt.Errorf("invalid field in Avro record %s", s.name)
+			}
+			name, _ := fv["name"].(string)
+			if name == "" {
+				return fmt.Errorf("Avro record %s has a field without a name", s.name)
+			}
+			fs, err := p.parse(fv["type"], namespace)
+			if err != nil {
+				return fmt.Errorf("field %s of Avro record %s: %w", name, s.name, err)
+			}
+			def, hasDefault := fv["default"]
+			s.fields = append(s.fields, avroField{name: name, schema: fs, hasDefault: hasDefault, defaultVal: def})
+		}
+
+	case "enum":
+		symbols, _ := v["symbols"].([]any)
+		for _, sym := range symbols {
+			str, ok := sym.(string)
+			if !ok {
+				return fmt.Errorf("invalid symbol in Avro enum %s", s.name)
+			}
+			s.symbols = append(s.symbols, str)
+		}
+
+	case "fixed":
+		size, ok := v["size"].(float64)
+		if !ok || size < 0 {
+			return fmt.Errorf("Avro fixed %s needs a size", s.name)
+		}
+		s.size = int(size)
+	}
+	return nil
+}
+
+func fullName(name, namespace string) string {
+	if namespace == "" || strings.Contains(name, ".") {
+		return name
+	}
+	return namespace + "." + name
+}
+
+// encode appends the Avro binary encoding of value to buf. It fails if the
+// value doesn't conform to the schema.
+func (s *avroSchema) encode(buf []byte, value any) ([]byte, error) {
+	switch s.typ {
+	case "null":
+		if value != nil {
+			return nil, fmt.Errorf("expected null, got %T", value)
+		}
+		return buf, nil
+
+	case "boolean":
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("expected boolean, got %T", value)
+		}
+		if b {
+			return append(buf, 1), nil
+		}
+		return append(buf, 0), nil
+
+	case "int", "long":
+		n, err := s.integer(value)
+		if err != nil {
+			return nil, err
+		}
+		if s.typ == "int" && (n < math.MinInt32 || n > math.MaxInt32) {
+			return nil, fmt.Errorf("%d overflows an Avro int", n)
+		}
+		return binary.AppendVarint(buf, n), nil
+
+	case "float":
+		f, ok := toFloat(value)
+		if !ok {
+			return nil, fmt.Errorf("expected float, got %T", value)
+		}
+		return binary.LittleEndian.AppendUint32(buf, math.Float32bits(float32(f))), nil
+
+	case "double":
+		f, ok := toFloat(value)
+		if !ok {
+			return nil, fmt.Errorf("expected double, got %T", value)
+		}
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(f)), nil
+
+	case "bytes", "string":
+		var b []byte
+		switch v := value.(type) {
+		case string:
+			b = []byte(v)
+		case []byte:
+			b = v
+		default:
+			return nil, fmt.Errorf("expected %s, got %T", s.typ, value)
+		}
+		buf = binary.AppendVarint(buf, int64(len(b)))
+		return append(buf, b...), nil
+
+	case "fixed":
+		var b []byte
+		switch v := value.(type) {
+		case string:
+			b = []byte(v)
+		case []byte:
+			b = v
+		default:
+			return nil, fmt.Errorf("expected fixed %s, got %T", s.name, value)
+		}
+		if len(b) != s.size {
+			return nil, fmt.Errorf("fixed %s needs %d bytes, got %d", s.name, s.size, len(b))
+		}
+		return append(buf, b...), nil
+
+	case "enum":
+		str, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected enum %s, got %T", s.name, value)
+		}
+		for i, sym := range s.symbols {
+			if sym == str {
+				return binary.AppendVarint(buf, int64(i)), nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not a symbol of enum %s", str, s.name)
+
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return nil, fmt.Errorf("expected array, got %T", value)
+		}
+		if len(items) > 0 {
+			buf = binary.AppendVarint(buf, int64(len(items)))
+			for i, item := range items {
+				var err error
+				if buf, err = s.items.encode(buf, item); err != nil {
+					return nil, fmt.Errorf("[%d]: %w", i, err)
+				}
+			}
+		}
+		return append(buf, 0), nil
+
+	case "map":
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("expected map, got %T", value)
+		}
+		if len(m) > 0 {
+			buf = binary.AppendVarint(buf, int64(len(m)))
+			for k, v := range m {
+				buf = binary.AppendVarint(buf, int64(len(k)))
+				buf = append(buf, k...)
+				var err error
+				if buf, err = s.values.encode(buf, v); err != nil {
+					return nil, fmt.Errorf("%s: %w", k, err)
+				}
+			}
+		}
+		return append(buf, 0), nil
+
+	case "record":
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("expected record %s, got %T", s.name, value)
+		}
+		for _, f := range s.fields {
+			v, found := recordValue(m, f.name)
+			if !found {
+				if !f.hasDefault && !f.schema.nullable() {
+					return nil, fmt.Errorf("missing field %s of record %s", f.name, s.name)
+				}
+				v = f.defaultVal
+			}
+			var err error
+			if buf, err = f.schema.encode(buf, v); err != nil {
+				return nil, fmt.Errorf("%s: %w", f.name, err)
+			}
+		}
+		return buf, nil
+
+	case "union":
+		// The value is written with the first branch it conforms to.
+		for i, branch := range s.branches {
+			encoded, err := branch.encode(binary.AppendVarint(buf, int64(i)), value)
+			if err == nil {
+				return encoded, nil
+			}
+		}
+		return nil, fmt.Errorf("%T doesn't match any type of the union", value)
+	}
+	return nil, fmt.Errorf("unsupported Avro type %s", s.typ)
+}
+
+// nullable reports whether a missing value can be written as null.
+func (s *avroSchema) nullable() bool {
+	if s.typ == "null" {
+		return true
+	}
+	for _, branch := range s.branches {
+		if branch.typ == "null" {
+			return true
+		}
+	}
+	return false
+}
+
+// integer returns the value of an int or long. Timestamps and dates are
+// read from the strings events hold them as.
+func (s *avroSchema) integer(value any) (int64, error) {
+	if str, ok := value.(string); ok && s.logicalType != "" {
+		t, err := time.Parse(time.RFC3339Nano, str)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s: %w", s.logicalType, err)
+		}
+		switch s.logicalType {
+		case "timestamp-millis", "local-timestamp-millis":
+			return t.UnixMilli(), nil
+		case "timestamp-micros", "local-timestamp-micros":
+			return t.UnixMicro(), nil
+		case "date":
+			return t.Unix() / 86400, nil
+		}
+	}
+
+	switch v := value.(type) {
+	case int:
+		return int64(v), nil
+	case int8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint8:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case uint:
+		if uint64(v) > math.MaxInt64 {
+			return 0, fmt.Errorf("%d overflows an Avro %s", v, s.typ)
+		}
+		return int64(v), nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, fmt.Errorf("%d overflows an Avro %s", v, s.typ)
+		}
+		return int64(v), nil
+	case float32, float64:
+		f, _ := toFloat(v)
+		if f != math.Trunc(f) || f < math.MinInt64 || f > math.MaxInt64 {
+			return 0, fmt.Errorf("%v is not an integer", f)
+		}
+		return int64(f), nil
+	}
+	return 0, fmt.Errorf("expected %s, got %T", s.typ, value)
+}
+
+func toFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// recordValue returns the value of a record field. Avro names can't start
+// with '@', so the fields named timestamp and metadata also read the
+// @timestamp and @metadata fields of the event.
+func recordValue(m map[string]any, name string) (any, bool) {
+	if v, ok := m[name]; ok {
+		return v, true
+	}
+	v, ok := m["@"+name]
+	return v, ok
+}
@@ -0,0 +1,154 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package schemaregistry
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAvroSchema = `{
+	"type": "record",
+	"name": "Log",
+	"namespace": "beats",
+	"fields": [
+		{"name": "timestamp", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+		{"name": "message", "type": "string"},
+		{"name": "count", "type": "int"},
+		{"name": "tags", "type": {"type": "array", "items": "string"}},
+		{"name": "level", "type": {"type": "enum", "name": "Level", "symbols": ["info", "warn"]}},
+		{"name": "user", "type": ["null", "string"]},
+		{"name": "ratio", "type": "double", "default": 0.5}
+	]
+}`
+
+func TestAvroEncode(t *testing.T) {
+	schema, err := parseAvroSchema(testAvroSchema)
+	require.NoError(t, err)
+
+	encoded, err := schema.encode(nil, map[string]any{
+		"@timestamp": "2024-01-02T03:04:05.000Z",
+		"message":    "hi",
+		"count":      uint64(3),
+		"tags":       []any{"a"},
+		"level":      "warn",
+		"ignored":    "not in the schema",
+	})
+	require.NoError(t, err)
+
+	var expected []byte
+	expected = binary.AppendVarint(expected, 1704164645000)
+	expected = append(expected, 0x04, 'h', 'i')
+	expected = append(expected, 0x06)
+	expected = append(expected, 0x02, 0x02, 'a', 0x00)
+	expected = append(expected, 0x02)
+	expected = append(expected, 0x00)
+	expected = binary.LittleEndian.AppendUint64(expected, math.Float64bits(0.5))
+	assert.Equal(t, expected, encoded)
+}
+
+func TestAvroEncodeUnion(t *testing.T) {
+	schema, err := parseAvroSchema(`["null", "long", "string"]`)
+	require.NoError(t, err)
+
+	for value, expected := range map[any][]byte{
+		nil:        {0x00},
+		int64(-1):  {0x02, 0x01},
+		"x":        {0x04, 0x02, 'x'},
+		float64(2): {0x02, 0x04},
+	} {
+		encoded, err := schema.encode(nil, value)
+		require.NoError(t, err, "%v", value)
+		assert.Equal(t, expected, encoded, "%v", value)
+	}
+
+	_, err = schema.encode(nil, true)
+	assert.ErrorContains(t, err, "doesn't match any type of the union")
+}
+
+func TestAvroNonConforming(t *testing.T) {
+	schema, err := parseAvroSchema(testAvroSchema)
+	require.NoError(t, err)
+	valid := func() map[string]any {
+		return map[string]any{
+			"@timestamp": "2024-01-02T03:04:05.000Z",
+			"message":    "hi",
+			"count":      int64(3),
+			"tags":       []any{},
+			"level":      "info",
+		}
+	}
+
+	tests := map[string]struct {
+		field string
+		value any
+		err   string
+	}{
+		"missing field":   {field: "message", err: "missing field message"},
+		"wrong type":      {field: "message", value: int64(1), err: "expected string"},
+		"int overflow":    {field: "count", value: int64(math.MaxInt32) + 1, err: "overflows an Avro int"},
+		"fraction in int": {field: "count", value: 1.5, err: "not an integer"},
+		"unknown symbol":  {field: "level", value: "debug", err: "not a symbol of enum beats.Level"},
+		"bad timestamp":   {field: "@timestamp", value: "yesterday", err: "invalid timestamp-millis"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			doc := valid()
+			if tc.value == nil {
+				delete(doc, tc.field)
+			} else {
+				doc[tc.field] = tc.value
+			}
+			_, err := schema.encode(nil, doc)
+			assert.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+func TestParseAvroSchema(t *testing.T) {
+	// Named types can be referenced, records can be recursive.
+	schema, err := parseAvroSchema(`{
+		"type": "record", "name": "Node", "fields": [
+			{"name": "value", "type": "string"},
+			{"name": "next", "type": ["null", "Node"]}
+		]
+	}`)
+	require.NoError(t, err)
+	encoded, err := schema.encode(nil, map[string]any{
+		"value": "a",
+		"next":  map[string]any{"value": "b"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x02, 'a', 0x02, 0x02, 'b', 0x00}, encoded)
+
+	for schema, errMsg := range map[string]string{
+		`{"type": "record", "fields": []}`: "without a name",
+		`"Unknown"`:                        "unknown Avro type",
+		`["null", ["string"]]`:             "can't contain unions",
+		`not json`:                         "invalid Avro schema",
+	} {
+		_, err := parseAvroSchema(schema)
+		assert.ErrorContains(t, err, errMsg, schema)
+	}
+}
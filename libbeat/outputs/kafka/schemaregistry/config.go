@@ -0,0 +1,111 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package schemaregistry
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/elastic/elastic-agent-libs/transport/httpcommon"
+)
+
+const (
+	formatAvro     = "avro"
+	formatProtobuf = "protobuf"
+
+	schemaTypeAvro     = "AVRO"
+	schemaTypeProtobuf = "PROTOBUF"
+)
+
+// Config configures the schema registry encoding of the Kafka output.
+type Config struct {
+	URL      string `config:"url" validate:"required"`
+	Username string `config:"username"`
+	Password string `config:"password"`
+
+	// Format is the encoding of the messages, avro or protobuf.
+	Format string `config:"format"`
+
+	// Subject is the subject the schema is registered under. It defaults to
+	// the topic of the event followed by -value.
+	Subject string `config:"subject"`
+
+	// Schema or SchemaFile hold the schema to look up or register. Without
+	// it, Avro messages use the latest schema of the subject.
+	Schema     string `config:"schema"`
+	SchemaFile string `config:"schema_file"`
+
+	// AutoRegister registers the schema when the subject doesn't have it
+	// yet.
+	AutoRegister bool `config:"auto_register"`
+
+	Protobuf ProtobufConfig `config:"protobuf"`
+
+	Transport httpcommon.HTTPTransportSettings `config:",inline"`
+}
+
+// ProtobufConfig selects the message type events are encoded as.
+type ProtobufConfig struct {
+	DescriptorFile string `config:"descriptor_file"`
+	Message        string `config:"message"`
+}
+
+// DefaultConfig returns the default schema registry settings.
+func DefaultConfig() Config {
+	return Config{
+		Format:       formatAvro,
+		AutoRegister: true,
+		Transport:    httpcommon.DefaultHTTPTransportSettings(),
+	}
+}
+
+func (c *Config) Validate() error {
+	if c.Schema != "" && c.SchemaFile != "" {
+		return errors.New("schema and schema_file can't be used together")
+	}
+	switch c.Format {
+	case formatAvro:
+	case formatProtobuf:
+		if c.Protobuf.DescriptorFile == "" || c.Protobuf.Message == "" {
+			return errors.New("the protobuf format requires protobuf.descriptor_file and protobuf.message")
+		}
+	default:
+		return fmt.Errorf("unsupported format %q, must be %q or %q", c.Format, formatAvro, formatProtobuf)
+	}
+	return nil
+}
+
+// schema returns the schema text configured with schema or schema_file.
+func (c *Config) schema() (string, error) {
+	if c.SchemaFile == "" {
+		return c.Schema, nil
+	}
+	data, err := os.ReadFile(c.SchemaFile)
+	if err != nil {
+		return "", fmt.Errorf("couldn't read schema_file: %w", err)
+	}
+	return string(data), nil
+}
+
+func (c *Config) schemaType() string {
+	if c.Format == formatProtobuf {
+		return schemaTypeProtobuf
+	}
+	return schemaTypeAvro
+}
@@ -0,0 +1,245 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package schemaregistry
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/transport/httpcommon"
+	"github.com/elastic/go-structform/gotype"
+)
+
+// magicByte starts every message in the schema registry wire format. It is
+// followed by the schema ID as a big endian uint32 and the encoded event.
+const magicByte = 0
+
+// Encoder encodes events against schemas of a schema registry. The schema
+// IDs are looked up, or registered, once per subject and cached.
+type Encoder struct {
+	log     *logp.Logger
+	config  Config
+	version string
+
+	// schema is the configured schema, empty to use the latest schema of
+	// each subject. avro is its parsed form for the Avro format.
+	schema   string
+	avro     *avroSchema
+	protobuf *protobufMessage
+
+	registry registryClient
+
+	mu       sync.Mutex
+	subjects map[string]*subjectSchema
+
+	// The event is converted to plain maps, slices and scalars, with the
+	// timestamps formatted like the json codec does, before being encoded.
+	convMu sync.Mutex
+	folder *gotype.Iterator
+	unfold *gotype.Unfolder
+}
+
+// subjectSchema is the schema used for the events of a subject.
+type subjectSchema struct {
+	id   int
+	avro *avroSchema
+}
+
+// NewEncoder creates an Encoder for the given settings. version is the
+// version of the Beat, reported in the @metadata of the events.
+func NewEncoder(log *logp.Logger, cfg Config, version string) (*Encoder, error) {
+	e := &Encoder{
+		log:      log.Named("schema_registry"),
+		config:   cfg,
+		version:  version,
+		subjects: map[string]*subjectSchema{},
+		registry: registryClient{
+			url:      cfg.URL,
+			username: cfg.Username,
+			password: cfg.Password,
+		},
+	}
+
+	var err error
+	if e.schema, err = cfg.schema(); err != nil {
+		return nil, err
+	}
+	switch cfg.Format {
+	case formatProtobuf:
+		if e.protobuf, err = loadProtobufMessage(cfg.Protobuf.DescriptorFile, cfg.Protobuf.Message); err != nil {
+			return nil, err
+		}
+	default:
+		if e.schema != "" {
+			if e.avro, err = parseAvroSchema(e.schema); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := e.resetConverter(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *Encoder) resetConverter() error {
+	var err error
+	e.unfold, err = gotype.NewUnfolder(nil)
+	if err != nil {
+		return err
+	}
+	e.folder, err = gotype.NewIterator(e.unfold,
+		gotype.Folders(
+			codec.MakeTimestampEncoder(),
+			codec.MakeBCTimestampEncoder(),
+		),
+	)
+	return err
+}
+
+// Connect sets up the HTTP client used to reach the schema registry.
+func (e *Encoder) Connect() error {
+	client, err := e.config.Transport.Client(httpcommon.WithLogger(e.log))
+	if err != nil {
+		return fmt.Errorf("failed to create the schema registry HTTP client: %w", err)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.registry.http = client
+	return nil
+}
+
+// Close releases the idle connections to the schema registry.
+func (e *Encoder) Close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.registry.http != nil {
+		e.registry.http.CloseIdleConnections()
+		e.registry.http = nil
+	}
+}
+
+// Encode encodes an event published to topic. Errors for which IsTemporary
+// is true may go away when the event is retried, other errors mean the
+// event doesn't conform to the schema.
+func (e *Encoder) Encode(ctx context.Context, topic, index string, event *beat.Event) ([]byte, error) {
+	subject := e.config.Subject
+	if subject == "" {
+		subject = topic + "-value"
+	}
+	schema, err := e.subjectSchema(ctx, subject)
+	if err != nil {
+		return nil, fmt.Errorf("schema of subject %s: %w", subject, err)
+	}
+
+	doc, err := e.document(index, event)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 5, 256)
+	buf[0] = magicByte
+	binary.BigEndian.PutUint32(buf[1:], uint32(schema.id)) //nolint:gosec // G115: schema IDs are positive int32 values
+	if e.protobuf != nil {
+		return e.protobuf.encode(buf, doc)
+	}
+	if buf, err = schema.avro.encode(buf, doc); err != nil {
+		return nil, fmt.Errorf("event doesn't conform to the schema of subject %s: %w", subject, err)
+	}
+	return buf, nil
+}
+
+// subjectSchema returns the schema of subject, looking it up in the
+// registry the first time.
+func (e *Encoder) subjectSchema(ctx context.Context, subject string) (*subjectSchema, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if s, ok := e.subjects[subject]; ok {
+		return s, nil
+	}
+	if e.registry.http == nil {
+		return nil, &RegistryError{Err: errors.New("not connected")}
+	}
+
+	s := &subjectSchema{avro: e.avro}
+	var err error
+	switch {
+	case e.schema != "" && e.config.AutoRegister:
+		s.id, err = e.registry.register(ctx, subject, e.config.schemaType(), e.schema)
+	case e.schema != "":
+		s.id, err = e.registry.lookup(ctx, subject, e.config.schemaType(), e.schema)
+	default:
+		s, err = e.latestSchema(ctx, subject)
+	}
+	if err != nil {
+		return nil, err
+	}
+	e.log.Infof("Using schema %d for subject %s", s.id, subject)
+	e.subjects[subject] = s
+	return s, nil
+}
+
+// latestSchema returns the latest schema registered under subject.
+func (e *Encoder) latestSchema(ctx context.Context, subject string) (*subjectSchema, error) {
+	latest, err := e.registry.latest(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+	schemaType := latest.SchemaType
+	if schemaType == "" {
+		schemaType = schemaTypeAvro
+	}
+	if schemaType != e.config.schemaType() {
+		return nil, fmt.Errorf("the latest schema is %s, the output encodes %s", schemaType, e.config.Format)
+	}
+
+	s := &subjectSchema{id: latest.ID}
+	if e.protobuf == nil {
+		if s.avro, err = parseAvroSchema(latest.Schema); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// document returns the event as the json codec would write it, with plain
+// Go values.
+func (e *Encoder) document(index string, event *beat.Event) (map[string]any, error) {
+	e.convMu.Lock()
+	defer e.convMu.Unlock()
+
+	var doc map[string]any
+	if err := e.unfold.SetTarget(&doc); err != nil {
+		return nil, err
+	}
+	defer e.unfold.Reset()
+	if err := e.folder.Fold(codec.MakeEvent(index, e.version, event)); err != nil {
+		if resetErr := e.resetConverter(); resetErr != nil {
+			return nil, errors.Join(err, resetErr)
+		}
+		return nil, fmt.Errorf("failed to convert event: %w", err)
+	}
+	return doc, nil
+}
@@ -0,0 +1,381 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package schemaregistry
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// mockRegistry is a minimal Confluent compatible schema registry.
+type mockRegistry struct {
+	mu       sync.Mutex
+	ids      map[string]int                // schema text to ID
+	subjects map[string][]registeredSchema // versions of each subject
+	requests int
+	status   int // when set, every request fails with it
+}
+
+func newMockRegistry(t *testing.T) (*mockRegistry, string) {
+	r := &mockRegistry{ids: map[string]int{}, subjects: map[string][]registeredSchema{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /subjects/{subject}/versions", r.handle(r.register))
+	mux.HandleFunc("POST /subjects/{subject}", r.handle(r.lookup))
+	mux.HandleFunc("GET /subjects/{subject}/versions/latest", r.handle(r.latest))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return r, srv.URL
+}
+
+func (r *mockRegistry) handle(fn func(subject string, req registeredSchema) (any, int)) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.requests++
+		w.Header().Set("Content-Type", registryContentType)
+		if r.status != 0 {
+			w.WriteHeader(r.status)
+			_ = json.NewEncoder(w).Encode(map[string]any{"message": "unavailable"})
+			return
+		}
+		var body registeredSchema
+		if req.Body != nil {
+			_ = json.NewDecoder(req.Body).Decode(&body)
+		}
+		resp, status := fn(req.PathValue("subject"), body)
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func (r *mockRegistry) add(subject string, schema registeredSchema) int {
+	id, ok := r.ids[schema.Schema]
+	if !ok {
+		id = len(r.ids) + 1
+		r.ids[schema.Schema] = id
+	}
+	for _, s := range r.subjects[subject] {
+		if s.ID == id {
+			return id
+		}
+	}
+	schema.ID = id
+	r.subjects[subject] = append(r.subjects[subject], schema)
+	return id
+}
+
+func (r *mockRegistry) register(subject string, req registeredSchema) (any, int) {
+	return map[string]int{"id": r.add(subject, req)}, http.StatusOK
+}
+
+func (r *mockRegistry) lookup(subject string, req registeredSchema) (any, int) {
+	for _, s := range r.subjects[subject] {
+		if s.Schema == req.Schema {
+			return s, http.StatusOK
+		}
+	}
+	return map[string]any{"error_code": 40403, "message": "Schema not found"}, http.StatusNotFound
+}
+
+func (r *mockRegistry) latest(subject string, _ registeredSchema) (any, int) {
+	versions := r.subjects[subject]
+	if len(versions) == 0 {
+		return map[string]any{"error_code": 40401, "message": "Subject not found"}, http.StatusNotFound
+	}
+	return versions[len(versions)-1], http.StatusOK
+}
+
+func (r *mockRegistry) versions(subject string) []registeredSchema {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.subjects[subject]
+}
+
+func (r *mockRegistry) requestCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests
+}
+
+func newTestEncoder(t *testing.T, cfg Config) *Encoder {
+	t.Helper()
+	require.NoError(t, cfg.Validate())
+	e, err := NewEncoder(logptest.NewTestingLogger(t, ""), cfg, "9.0.0")
+	require.NoError(t, err)
+	require.NoError(t, e.Connect())
+	t.Cleanup(e.Close)
+	return e
+}
+
+func testEvent(fields mapstr.M) *beat.Event {
+	return &beat.Event{
+		Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Fields:    fields,
+	}
+}
+
+// schemaID returns the schema ID of an encoded message.
+func schemaID(t *testing.T, msg []byte) int {
+	t.Helper()
+	require.GreaterOrEqual(t, len(msg), 5)
+	require.Equal(t, byte(magicByte), msg[0])
+	return int(binary.BigEndian.Uint32(msg[1:5]))
+}
+
+func TestEncoderRegistersSchemaOnce(t *testing.T) {
+	registry, url := newMockRegistry(t)
+	cfg := DefaultConfig()
+	cfg.URL = url
+	cfg.Schema = `{"type": "record", "name": "Log", "fields": [{"name": "message", "type": "string"}]}`
+	e := newTestEncoder(t, cfg)
+
+	for range 3 {
+		msg, err := e.Encode(context.Background(), "logs", "filebeat", testEvent(mapstr.M{"message": "hi"}))
+		require.NoError(t, err)
+		assert.Equal(t, 1, schemaID(t, msg))
+		assert.Equal(t, []byte{0x04, 'h', 'i'}, msg[5:])
+	}
+	assert.Equal(t, 1, registry.requestCount(), "the schema ID must be cached")
+	assert.Len(t, registry.versions("logs-value"), 1, "the subject defaults to the topic followed by -value")
+
+	// Another topic is another subject.
+	_, err := e.Encode(context.Background(), "metrics", "filebeat", testEvent(mapstr.M{"message": "hi"}))
+	require.NoError(t, err)
+	assert.Len(t, registry.versions("metrics-value"), 1)
+}
+
+func TestEncoderLookup(t *testing.T) {
+	registry, url := newMockRegistry(t)
+	schema := `{"type": "record", "name": "Log", "fields": [{"name": "message", "type": "string"}]}`
+	cfg := DefaultConfig()
+	cfg.URL = url
+	cfg.Subject = "logs"
+	cfg.Schema = schema
+	cfg.AutoRegister = false
+	e := newTestEncoder(t, cfg)
+
+	// The schema isn't registered, the event fails permanently.
+	_, err := e.Encode(context.Background(), "topic", "filebeat", testEvent(mapstr.M{"message": "hi"}))
+	assert.ErrorContains(t, err, "Schema not found")
+	assert.False(t, IsTemporary(err))
+
+	registry.mu.Lock()
+	registry.add("other", registeredSchema{Schema: `"string"`})
+	id := registry.add("logs", registeredSchema{Schema: schema})
+	registry.mu.Unlock()
+
+	msg, err := e.Encode(context.Background(), "topic", "filebeat", testEvent(mapstr.M{"message": "hi"}))
+	require.NoError(t, err)
+	assert.Equal(t, id, schemaID(t, msg))
+}
+
+func TestEncoderLatestSchema(t *testing.T) {
+	registry, url := newMockRegistry(t)
+	registry.add("logs-value", registeredSchema{
+		Schema: `{"type": "record", "name": "Log", "fields": [{"name": "timestamp", "type": "string"}, {"name": "beat", "type": "string"}]}`,
+	})
+	cfg := DefaultConfig()
+	cfg.URL = url
+	e := newTestEncoder(t, cfg)
+
+	msg, err := e.Encode(context.Background(), "logs", "filebeat", testEvent(mapstr.M{"beat": "metricbeat"}))
+	require.NoError(t, err)
+	assert.Equal(t, 1, schemaID(t, msg))
+	// The timestamp is read from @timestamp, formatted like the json codec
+	// does.
+	expected := append([]byte{0x30}, "2024-01-02T03:04:05.000Z"...)
+	expected = append(expected, 0x14)
+	expected = append(expected, "metricbeat"...)
+	assert.Equal(t, expected, msg[5:])
+}
+
+func TestEncoderNonConformingEvent(t *testing.T) {
+	_, url := newMockRegistry(t)
+	cfg := DefaultConfig()
+	cfg.URL = url
+	cfg.Schema = `{"type": "record", "name": "Log", "fields": [{"name": "count", "type": "long"}]}`
+	e := newTestEncoder(t, cfg)
+
+	_, err := e.Encode(context.Background(), "logs", "filebeat", testEvent(mapstr.M{"count": "many"}))
+	assert.ErrorContains(t, err, "event doesn't conform to the schema of subject logs-value")
+	assert.False(t, IsTemporary(err))
+}
+
+func TestEncoderRegistryUnavailable(t *testing.T) {
+	registry, url := newMockRegistry(t)
+	registry.status = http.StatusServiceUnavailable
+	cfg := DefaultConfig()
+	cfg.URL = url
+	cfg.Schema = `"string"`
+	e := newTestEncoder(t, cfg)
+
+	_, err := e.Encode(context.Background(), "logs", "filebeat", testEvent(mapstr.M{}))
+	assert.ErrorContains(t, err, "unavailable")
+	assert.True(t, IsTemporary(err))
+
+	// Failures aren't cached, the next event tries again.
+	registry.mu.Lock()
+	registry.status = 0
+	registry.mu.Unlock()
+	_, err = e.Encode(context.Background(), "logs", "filebeat", testEvent(mapstr.M{}))
+	assert.ErrorContains(t, err, "expected string", "the document of an event is a record")
+	assert.False(t, IsTemporary(err))
+}
+
+// writeDescriptorSet writes a descriptor set with the message
+// beats.test.Log { string message = 1; int64 count = 2; string timestamp = 3;
+// Nested nested = 4; message Nested { string name = 1; } }.
+func writeDescriptorSet(t *testing.T) string {
+	t.Helper()
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     typ.Enum(),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	set := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{{
+		Name:    proto.String("log.proto"),
+		Package: proto.String("beats.test"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Log"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("message", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+				field("count", 2, descriptorpb.FieldDescriptorProto_TYPE_INT64, ""),
+				field("timestamp", 3, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+				field("nested", 4, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".beats.test.Log.Nested"),
+			},
+			NestedType: []*descriptorpb.DescriptorProto{{
+				Name:  proto.String("Nested"),
+				Field: []*descriptorpb.FieldDescriptorProto{field("name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, "")},
+			}},
+		}},
+	}}}
+	data, err := proto.Marshal(set)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "log.desc")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func TestEncoderProtobuf(t *testing.T) {
+	registry, url := newMockRegistry(t)
+	cfg := DefaultConfig()
+	cfg.URL = url
+	cfg.Format = formatProtobuf
+	cfg.Schema = `syntax = "proto3"; package beats.test; message Log { string message = 1; }`
+	cfg.Protobuf = ProtobufConfig{DescriptorFile: writeDescriptorSet(t), Message: "beats.test.Log"}
+	e := newTestEncoder(t, cfg)
+
+	msg, err := e.Encode(context.Background(), "logs", "filebeat", testEvent(mapstr.M{
+		"message": "hi",
+		"count":   42,
+		"nested":  mapstr.M{"name": "inner"},
+		"host":    mapstr.M{"name": "not in the message"},
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, 1, schemaID(t, msg))
+	assert.Equal(t, schemaTypeProtobuf, registry.versions("logs-value")[0].SchemaType)
+	require.Equal(t, byte(0), msg[5], "the first message of the file has the index header 0")
+
+	decoded := dynamicpb.NewMessage(e.protobuf.desc)
+	require.NoError(t, proto.Unmarshal(msg[6:], decoded))
+	fields := e.protobuf.desc.Fields()
+	assert.Equal(t, "hi", decoded.Get(fields.ByName("message")).String())
+	assert.Equal(t, int64(42), decoded.Get(fields.ByName("count")).Int())
+	assert.Equal(t, "2024-01-02T03:04:05.000Z", decoded.Get(fields.ByName("timestamp")).String())
+
+	_, err = e.Encode(context.Background(), "logs", "filebeat", testEvent(mapstr.M{"count": "many"}))
+	assert.ErrorContains(t, err, "event doesn't conform to beats.test.Log")
+}
+
+func TestMessageIndexes(t *testing.T) {
+	path := writeDescriptorSet(t)
+	msg, err := loadProtobufMessage(path, "beats.test.Log")
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0}, msg.indexes)
+
+	nested, err := loadProtobufMessage(path, "beats.test.Log.Nested")
+	require.NoError(t, err)
+	// Two indexes, 0 then 0, as zigzag varints.
+	assert.Equal(t, []byte{0x04, 0x00, 0x00}, nested.indexes)
+
+	_, err = loadProtobufMessage(path, "beats.test.Missing")
+	assert.ErrorContains(t, err, "not found")
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := map[string]struct {
+		modify func(*Config)
+		err    string
+	}{
+		"defaults": {modify: func(*Config) {}},
+		"schema and schema file": {
+			modify: func(c *Config) { c.Schema, c.SchemaFile = `"string"`, "schema.avsc" },
+			err:    "can't be used together",
+		},
+		"unknown format": {
+			modify: func(c *Config) { c.Format = "thrift" },
+			err:    "unsupported format",
+		},
+		"protobuf without descriptor": {
+			modify: func(c *Config) { c.Format = formatProtobuf },
+			err:    "requires protobuf.descriptor_file and protobuf.message",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.URL = "http://localhost:8081"
+			tc.modify(&cfg)
+			err := cfg.Validate()
+			if tc.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tc.err)
+		})
+	}
+}
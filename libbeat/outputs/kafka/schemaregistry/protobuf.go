@@ -0,0 +1,123 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package schemaregistry
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// protobufMessage encodes events as one message type of a compiled
+// descriptor set.
+type protobufMessage struct {
+	desc protoreflect.MessageDescriptor
+
+	// indexes is the Confluent message index header of the message type,
+	// written between the schema ID and the message.
+	indexes []byte
+}
+
+// loadProtobufMessage reads a FileDescriptorSet, as written by
+// `protoc --include_imports --descriptor_set_out`, and returns the message
+// type with the given full name.
+func loadProtobufMessage(path, name string) (*protobufMessage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read descriptor file: %w", err)
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid descriptor file %s: %w", path, err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("invalid descriptor file %s: %w", path, err)
+	}
+	d, err := files.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, fmt.Errorf("message %s not found in %s: %w", name, path, err)
+	}
+	desc, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a message in %s", name, path)
+	}
+	return &protobufMessage{desc: desc, indexes: messageIndexes(desc)}, nil
+}
+
+// messageIndexes returns the message index header of a message type: the
+// position of the message in its file, followed by its position in each
+// enclosing message, as zigzag varints prefixed by their count. The common
+// case of the first message of the file is written as a single 0.
+func messageIndexes(desc protoreflect.MessageDescriptor) []byte {
+	var path []int
+	for d := protoreflect.Descriptor(desc); d != nil; d = d.Parent() {
+		if _, ok := d.(protoreflect.MessageDescriptor); !ok {
+			break
+		}
+		path = append([]int{d.Index()}, path...)
+	}
+	if len(path) == 1 && path[0] == 0 {
+		return []byte{0}
+	}
+	buf := binary.AppendVarint(nil, int64(len(path)))
+	for _, i := range path {
+		buf = binary.AppendVarint(buf, int64(i))
+	}
+	return buf
+}
+
+// encode appends the message index header and the binary encoding of doc
+// to buf. The document is mapped to the message with the protobuf JSON
+// mapping: fields of the event that aren't part of the message are left
+// out, and the event fails if a field has an incompatible type. The
+// @timestamp and @metadata fields of the event are read by the message
+// fields named timestamp and metadata.
+func (m *protobufMessage) encode(buf []byte, doc map[string]any) ([]byte, error) {
+	fields := m.desc.Fields()
+	projected := make(map[string]any, len(doc))
+	for k, v := range doc {
+		name := k
+		if len(k) > 1 && k[0] == '@' {
+			name = k[1:]
+		}
+		if fields.ByJSONName(name) != nil || fields.ByName(protoreflect.Name(name)) != nil {
+			projected[name] = v
+		}
+	}
+	data, err := json.Marshal(projected)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := dynamicpb.NewMessage(m.desc)
+	opts := protojson.UnmarshalOptions{DiscardUnknown: true}
+	if err := opts.Unmarshal(data, msg); err != nil {
+		return nil, fmt.Errorf("event doesn't conform to %s: %w", m.desc.FullName(), err)
+	}
+	buf = append(buf, m.indexes...)
+	return proto.MarshalOptions{}.MarshalAppend(buf, msg)
+}
@@ -0,0 +1,161 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package schemaregistry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const registryContentType = "application/vnd.schemaregistry.v1+json"
+
+// registryClient talks to a Confluent compatible schema registry.
+type registryClient struct {
+	url      string
+	username string
+	password string
+	http     *http.Client
+}
+
+// registeredSchema is a schema as returned by the registry.
+type registeredSchema struct {
+	ID         int    `json:"id"`
+	Schema     string `json:"schema"`
+	SchemaType string `json:"schemaType"`
+}
+
+// RegistryError is returned when the schema registry can't be reached or
+// answers with an error.
+type RegistryError struct {
+	// Status is the HTTP status of the response, 0 if there was none.
+	Status  int
+	Message string
+	Err     error
+}
+
+func (e *RegistryError) Error() string {
+	switch {
+	case e.Err != nil:
+		return fmt.Sprintf("schema registry: %v", e.Err)
+	case e.Message != "":
+		return fmt.Sprintf("schema registry returned %d: %s", e.Status, e.Message)
+	default:
+		return fmt.Sprintf("schema registry returned %d", e.Status)
+	}
+}
+
+func (e *RegistryError) Unwrap() error { return e.Err }
+
+// Temporary reports whether the request may succeed later: the registry
+// couldn't be reached, is overloaded or failed internally. Other errors,
+// like an unknown subject or an incompatible schema, are permanent.
+func (e *RegistryError) Temporary() bool {
+	return e.Status == 0 ||
+		e.Status == http.StatusRequestTimeout ||
+		e.Status == http.StatusTooManyRequests ||
+		e.Status >= 500
+}
+
+// register registers a schema under subject, or returns the ID of the
+// schema if it is already registered.
+func (c *registryClient) register(ctx context.Context, subject, schemaType, schema string) (int, error) {
+	var resp registeredSchema
+	err := c.do(ctx, http.MethodPost, "/subjects/"+url.PathEscape(subject)+"/versions", schemaRequest(schemaType, schema), &resp)
+	return resp.ID, err
+}
+
+// lookup returns the ID of a schema registered under subject.
+func (c *registryClient) lookup(ctx context.Context, subject, schemaType, schema string) (int, error) {
+	var resp registeredSchema
+	err := c.do(ctx, http.MethodPost, "/subjects/"+url.PathEscape(subject), schemaRequest(schemaType, schema), &resp)
+	return resp.ID, err
+}
+
+// latest returns the latest schema registered under subject.
+func (c *registryClient) latest(ctx context.Context, subject string) (registeredSchema, error) {
+	var resp registeredSchema
+	err := c.do(ctx, http.MethodGet, "/subjects/"+url.PathEscape(subject)+"/versions/latest", nil, &resp)
+	return resp, err
+}
+
+func schemaRequest(schemaType, schema string) any {
+	req := map[string]string{"schema": schema}
+	// AVRO is the default and older registries don't know the field.
+	if schemaType != schemaTypeAvro {
+		req["schemaType"] = schemaType
+	}
+	return req
+}
+
+func (c *registryClient) do(ctx context.Context, method, path string, body, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.url, "/")+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", registryContentType)
+	if body != nil {
+		req.Header.Set("Content-Type", registryContentType)
+	}
+	if c.username != "" || c.password != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return &RegistryError{Err: err}
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return &RegistryError{Err: err}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(data, &errResp)
+		return &RegistryError{Status: resp.StatusCode, Message: errResp.Message}
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return &RegistryError{Status: resp.StatusCode, Err: fmt.Errorf("invalid response: %w", err)}
+	}
+	return nil
+}
+
+// IsTemporary reports whether err is a schema registry error that may go
+// away when the event is retried.
+func IsTemporary(err error) bool {
+	var regErr *RegistryError
+	return errors.As(err, &regErr) && regErr.Temporary()
+}
@@ -0,0 +1,136 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+// errTooManyRequests is wrapped by the errors of exporters when the endpoint
+// asks to slow down.
+var errTooManyRequests = errors.New("endpoint is overloaded")
+
+// permanentError marks export errors that won't be solved by retrying the
+// same request, e.g. because the endpoint rejected the data.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// exporter sends OTLP export requests to an endpoint.
+type exporter interface {
+	connect(ctx context.Context) error
+	export(ctx context.Context, req plogotlp.ExportRequest) (plogotlp.ExportResponse, error)
+	close() error
+	String() string
+}
+
+type client struct {
+	log      *logp.Logger
+	observer outputs.Observer
+	encoder  *logsEncoder
+	exporter exporter
+}
+
+func newClient(log *logp.Logger, observer outputs.Observer, encoder *logsEncoder, exp exporter) *client {
+	return &client{
+		log:      log,
+		observer: observer,
+		encoder:  encoder,
+		exporter: exp,
+	}
+}
+
+func (c *client) Connect(ctx context.Context) error {
+	return c.exporter.connect(ctx)
+}
+
+func (c *client) Close() error {
+	return c.exporter.close()
+}
+
+func (c *client) Publish(ctx context.Context, batch publisher.Batch) error {
+	events := batch.Events()
+	c.observer.NewBatch(len(events))
+	if len(events) == 0 {
+		batch.ACK()
+		return nil
+	}
+
+	req := plogotlp.NewExportRequestFromLogs(c.encoder.encode(events, time.Now()))
+	begin := time.Now()
+	resp, err := c.exporter.export(ctx, req)
+	if err != nil {
+		if ctx.Err() != nil {
+			batch.Cancelled()
+			return ctx.Err()
+		}
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			c.log.Errorf("Dropping %d events rejected by the OTLP endpoint: %v", len(events), err)
+			outputs.DeadLetterWriterFrom(ctx).WriteDeadLetters(events, err)
+			c.observer.PermanentErrors(len(events))
+			batch.Drop()
+			return nil
+		}
+
+		if errors.Is(err, errTooManyRequests) {
+			c.observer.ErrTooMany(len(events))
+		}
+		c.observer.RetryableErrors(len(events))
+		batch.Retry()
+		return fmt.Errorf("failed to export logs to %v: %w", c.exporter, err)
+	}
+	c.observer.ReportLatency(time.Since(begin))
+
+	acked := len(events)
+	// A partial success only reports how many log records were rejected,
+	// not which ones. They can only be written to the dead letter file when
+	// every record of the batch was rejected.
+	partial := resp.PartialSuccess()
+	if rejected := int(partial.RejectedLogRecords()); rejected > 0 {
+		c.log.Warnf("The OTLP endpoint rejected %d of %d log records: %s",
+			rejected, len(events), partial.ErrorMessage())
+		rejected = min(rejected, len(events))
+		if rejected == len(events) {
+			outputs.DeadLetterWriterFrom(ctx).WriteDeadLetters(events,
+				fmt.Errorf("rejected by the OTLP endpoint: %s", partial.ErrorMessage()))
+		}
+		c.observer.PermanentErrors(rejected)
+		acked -= rejected
+	}
+	c.observer.AckedEvents(acked)
+	batch.ACK()
+	return nil
+}
+
+func (c *client) String() string {
+	return "otlp(" + c.exporter.String() + ")"
+}
@@ -0,0 +1,100 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/transport/httpcommon"
+)
+
+const (
+	protocolGRPC = "grpc"
+	protocolHTTP = "http"
+
+	compressionNone = "none"
+	compressionGzip = "gzip"
+
+	// mappingAttributes uses the message as log record body and stores the
+	// remaining fields as attributes.
+	mappingAttributes = "attributes"
+	// mappingBodyMap stores the whole event as a map in the log record body.
+	mappingBodyMap = "bodymap"
+)
+
+type otlpConfig struct {
+	Protocol    string                           `config:"protocol"`
+	Headers     map[string]string                `config:"headers"`
+	Compression string                           `config:"compression"`
+	Mapping     string                           `config:"mapping"`
+	LoadBalance bool                             `config:"loadbalance"`
+	BulkMaxSize int                              `config:"bulk_max_size"`
+	MaxRetries  int                              `config:"max_retries"`
+	Backoff     backoff                          `config:"backoff"`
+	Transport   httpcommon.HTTPTransportSettings `config:",inline"`
+	Queue       config.Namespace                 `config:"queue"`
+}
+
+type backoff struct {
+	Init time.Duration
+	Max  time.Duration
+}
+
+func defaultConfig() otlpConfig {
+	return otlpConfig{
+		Protocol:    protocolGRPC,
+		Compression: compressionGzip,
+		Mapping:     mappingAttributes,
+		LoadBalance: true,
+		BulkMaxSize: 1600,
+		MaxRetries:  3,
+		Backoff: backoff{
+			Init: 1 * time.Second,
+			Max:  60 * time.Second,
+		},
+		Transport: httpcommon.DefaultHTTPTransportSettings(),
+	}
+}
+
+func (c *otlpConfig) Validate() error {
+	switch c.Protocol {
+	case protocolGRPC, protocolHTTP:
+	default:
+		return fmt.Errorf("unsupported OTLP protocol %q, must be one of %q or %q", c.Protocol, protocolGRPC, protocolHTTP)
+	}
+
+	switch c.Compression {
+	case compressionNone, compressionGzip:
+	default:
+		return fmt.Errorf("unsupported compression %q, must be one of %q or %q", c.Compression, compressionNone, compressionGzip)
+	}
+
+	switch c.Mapping {
+	case mappingAttributes, mappingBodyMap:
+	default:
+		return fmt.Errorf("unsupported mapping %q, must be one of %q or %q", c.Mapping, mappingAttributes, mappingBodyMap)
+	}
+
+	if c.BulkMaxSize < 0 {
+		return errors.New("bulk_max_size must not be negative")
+	}
+	return nil
+}
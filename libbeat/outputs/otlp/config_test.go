@@ -0,0 +1,66 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package otlp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/elastic-agent-libs/config"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := map[string]struct {
+		settings map[string]any
+		err      string
+	}{
+		"defaults": {
+			settings: map[string]any{},
+		},
+		"http with gzip": {
+			settings: map[string]any{"protocol": "http", "compression": "gzip"},
+		},
+		"unknown protocol": {
+			settings: map[string]any{"protocol": "thrift"},
+			err:      "unsupported OTLP protocol",
+		},
+		"unknown compression": {
+			settings: map[string]any{"compression": "zstd"},
+			err:      "unsupported compression",
+		},
+		"unknown mapping": {
+			settings: map[string]any{"mapping": "flat"},
+			err:      "unsupported mapping",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := defaultConfig()
+			err := config.MustNewConfigFrom(tc.settings).Unpack(&c)
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
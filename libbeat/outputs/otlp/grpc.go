@@ -0,0 +1,142 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/elastic/elastic-agent-libs/transport/tlscommon"
+)
+
+// grpcExporter exports logs using OTLP over gRPC.
+type grpcExporter struct {
+	target    string
+	tlsConfig *tls.Config
+	userAgent string
+	headers   metadata.MD
+	compress  bool
+	timeout   time.Duration
+
+	conn   *grpc.ClientConn
+	client plogotlp.GRPCClient
+}
+
+func newGRPCExporter(target string, tlsConfig *tls.Config, userAgent string, c otlpConfig) *grpcExporter {
+	return &grpcExporter{
+		target:    target,
+		tlsConfig: tlsConfig,
+		userAgent: userAgent,
+		headers:   metadata.New(c.Headers),
+		compress:  c.Compression == compressionGzip,
+		timeout:   c.Transport.Timeout,
+	}
+}
+
+// buildTLS returns the client TLS configuration for target, or nil if the
+// connection isn't encrypted.
+func buildTLS(c *tlscommon.TLSConfig, target string) *tls.Config {
+	if c == nil {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(target)
+	if err != nil {
+		host = target
+	}
+	return c.BuildModuleClientConfig(host)
+}
+
+func (e *grpcExporter) connect(_ context.Context) error {
+	creds := insecure.NewCredentials()
+	if e.tlsConfig != nil {
+		creds = credentials.NewTLS(e.tlsConfig)
+	}
+
+	conn, err := grpc.NewClient(e.target,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithUserAgent(e.userAgent),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create gRPC connection to %s: %w", e.target, err)
+	}
+	e.conn = conn
+	e.client = plogotlp.NewGRPCClient(conn)
+	return nil
+}
+
+func (e *grpcExporter) export(ctx context.Context, req plogotlp.ExportRequest) (plogotlp.ExportResponse, error) {
+	if e.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.timeout)
+		defer cancel()
+	}
+	if len(e.headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, e.headers)
+	}
+
+	var opts []grpc.CallOption
+	if e.compress {
+		opts = append(opts, grpc.UseCompressor(gzip.Name))
+	}
+
+	resp, err := e.client.Export(ctx, req, opts...)
+	if err != nil {
+		return resp, classifyGRPCError(err)
+	}
+	return resp, nil
+}
+
+// classifyGRPCError maps gRPC status codes to retryable and permanent errors
+// following the OTLP specification.
+func classifyGRPCError(err error) error {
+	switch status.Code(err) {
+	case codes.ResourceExhausted:
+		return fmt.Errorf("%w: %w", errTooManyRequests, err)
+	case codes.Canceled, codes.DeadlineExceeded, codes.Aborted, codes.OutOfRange,
+		codes.Unavailable, codes.DataLoss:
+		return err
+	default:
+		return &permanentError{err: err}
+	}
+}
+
+func (e *grpcExporter) close() error {
+	if e.conn == nil {
+		return nil
+	}
+	err := e.conn.Close()
+	e.conn = nil
+	e.client = nil
+	return err
+}
+
+func (e *grpcExporter) String() string {
+	return "grpc://" + e.target
+}
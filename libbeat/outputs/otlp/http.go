@@ -0,0 +1,166 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/transport/httpcommon"
+)
+
+const (
+	protobufContentType = "application/x-protobuf"
+
+	// maxErrorBodySize limits how much of an error response is included in
+	// the error message.
+	maxErrorBodySize = 1024
+)
+
+// httpExporter exports logs using OTLP over HTTP with protobuf encoding.
+type httpExporter struct {
+	log       *logp.Logger
+	url       string
+	headers   map[string]string
+	userAgent string
+	compress  bool
+	transport httpcommon.HTTPTransportSettings
+	observer  outputs.Observer
+
+	client *http.Client
+}
+
+func newHTTPExporter(log *logp.Logger, url, userAgent string, observer outputs.Observer, c otlpConfig) *httpExporter {
+	return &httpExporter{
+		log:       log,
+		url:       url,
+		headers:   c.Headers,
+		userAgent: userAgent,
+		compress:  c.Compression == compressionGzip,
+		transport: c.Transport,
+		observer:  observer,
+	}
+}
+
+func (e *httpExporter) connect(_ context.Context) error {
+	client, err := e.transport.Client(
+		httpcommon.WithLogger(e.log),
+		httpcommon.WithIOStats(e.observer),
+		httpcommon.WithHeaderRoundTripper(map[string]string{"User-Agent": e.userAgent}),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP client: %w", err)
+	}
+	e.client = client
+	return nil
+}
+
+func (e *httpExporter) export(ctx context.Context, req plogotlp.ExportRequest) (plogotlp.ExportResponse, error) {
+	resp := plogotlp.NewExportResponse()
+
+	body, err := req.MarshalProto()
+	if err != nil {
+		return resp, &permanentError{err: fmt.Errorf("failed to encode request: %w", err)}
+	}
+	if e.compress {
+		if body, err = gzipBody(body); err != nil {
+			return resp, fmt.Errorf("failed to compress request: %w", err)
+		}
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return resp, &permanentError{err: err}
+	}
+	httpReq.Header.Set("Content-Type", protobufContentType)
+	if e.compress {
+		httpReq.Header.Set("Content-Encoding", "gzip")
+	}
+	for k, v := range e.headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	httpResp, err := e.client.Do(httpReq)
+	if err != nil {
+		return resp, err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode >= 200 && httpResp.StatusCode < 300 {
+		respBody, err := io.ReadAll(httpResp.Body)
+		if err != nil {
+			return resp, fmt.Errorf("failed to read response: %w", err)
+		}
+		// An empty body is a full success.
+		if len(respBody) > 0 && httpResp.Header.Get("Content-Type") == protobufContentType {
+			if err := resp.UnmarshalProto(respBody); err != nil {
+				e.log.Warnf("Failed to decode OTLP response: %v", err)
+			}
+		}
+		return resp, nil
+	}
+
+	respBody, _ := io.ReadAll(io.LimitReader(httpResp.Body, maxErrorBodySize))
+	return resp, classifyHTTPError(httpResp.StatusCode, respBody)
+}
+
+// classifyHTTPError maps HTTP status codes to retryable and permanent errors
+// following the OTLP specification.
+func classifyHTTPError(code int, body []byte) error {
+	err := fmt.Errorf("%d %s: %s", code, http.StatusText(code), bytes.TrimSpace(body))
+	switch code {
+	case http.StatusTooManyRequests:
+		return fmt.Errorf("%w: %w", errTooManyRequests, err)
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return err
+	default:
+		return &permanentError{err: err}
+	}
+}
+
+func gzipBody(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (e *httpExporter) close() error {
+	if e.client != nil {
+		e.client.CloseIdleConnections()
+		e.client = nil
+	}
+	return nil
+}
+
+func (e *httpExporter) String() string {
+	return e.url
+}
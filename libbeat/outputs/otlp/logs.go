@@ -0,0 +1,180 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/otel/otelmap"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const scopeName = "github.com/elastic/beats/v7/libbeat/outputs/otlp"
+
+// resourceFields are the top level event fields describing the entity that
+// produced the event. They are sent as resource attributes instead of being
+// repeated on every log record.
+var resourceFields = []string{"host", "agent"}
+
+var severities = map[string]plog.SeverityNumber{
+	"trace":    plog.SeverityNumberTrace,
+	"debug":    plog.SeverityNumberDebug,
+	"info":     plog.SeverityNumberInfo,
+	"notice":   plog.SeverityNumberInfo2,
+	"warn":     plog.SeverityNumberWarn,
+	"warning":  plog.SeverityNumberWarn,
+	"err":      plog.SeverityNumberError,
+	"error":    plog.SeverityNumberError,
+	"crit":     plog.SeverityNumberFatal,
+	"critical": plog.SeverityNumberFatal,
+	"alert":    plog.SeverityNumberFatal2,
+	"emerg":    plog.SeverityNumberFatal3,
+	"fatal":    plog.SeverityNumberFatal,
+}
+
+// logsEncoder converts batches of events to OTLP logs.
+type logsEncoder struct {
+	log     *logp.Logger
+	beat    beat.Info
+	mapping string
+}
+
+// encode converts events to OTLP logs. Events sharing the same resource
+// fields are grouped under a single resource.
+func (e *logsEncoder) encode(events []publisher.Event, observed time.Time) plog.Logs {
+	logs := plog.NewLogs()
+	scopes := map[string]plog.ScopeLogs{}
+
+	for i := range events {
+		content := &events[i].Content
+		fields := content.Fields
+		if fields == nil {
+			fields = mapstr.M{}
+		}
+
+		resource := resourceOf(fields)
+		key := resourceKey(resource)
+		scope, ok := scopes[key]
+		if !ok {
+			resourceLogs := logs.ResourceLogs().AppendEmpty()
+			e.fillResource(resourceLogs.Resource(), resource)
+			scope = resourceLogs.ScopeLogs().AppendEmpty()
+			scope.Scope().SetName(scopeName)
+			scope.Scope().SetVersion(e.beat.Version)
+			scopes[key] = scope
+		}
+
+		record := scope.LogRecords().AppendEmpty()
+		if err := e.fillRecord(record, content, fields, observed); err != nil {
+			e.log.Errorf("Error converting event to an OTLP log record, some fields might be missing: %v", err)
+		}
+	}
+	return logs
+}
+
+// resourceOf returns the flattened resource fields of an event.
+func resourceOf(fields mapstr.M) mapstr.M {
+	resource := mapstr.M{}
+	for _, name := range resourceFields {
+		switch v := fields[name].(type) {
+		case mapstr.M:
+			resource.DeepUpdate(mapstr.M{name: v}.Flatten())
+		case map[string]any:
+			resource.DeepUpdate(mapstr.M{name: mapstr.M(v)}.Flatten())
+		}
+	}
+	return resource
+}
+
+func resourceKey(resource mapstr.M) string {
+	keys := make([]string, 0, len(resource))
+	for k := range resource {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&sb, "%s=%v;", k, resource[k])
+	}
+	return sb.String()
+}
+
+func (e *logsEncoder) fillResource(res pcommon.Resource, resource mapstr.M) {
+	attrs := res.Attributes()
+	attrs.EnsureCapacity(len(resource) + 2)
+	for k, v := range resource {
+		if err := otelmap.FromValue(attrs.PutEmpty(k), v); err != nil {
+			e.log.Warnf("Error converting resource attribute %s: %v", k, err)
+			attrs.Remove(k)
+		}
+	}
+
+	// Follow the OpenTelemetry semantic conventions for the service
+	// producing the logs.
+	attrs.PutStr("service.name", e.beat.Beat)
+	if e.beat.Version != "" {
+		attrs.PutStr("service.version", e.beat.Version)
+	}
+}
+
+func (e *logsEncoder) fillRecord(record plog.LogRecord, content *beat.Event, fields mapstr.M, observed time.Time) error {
+	record.SetTimestamp(pcommon.NewTimestampFromTime(content.Timestamp))
+	record.SetObservedTimestamp(pcommon.NewTimestampFromTime(observed))
+
+	if level, err := fields.GetValue("log.level"); err == nil {
+		if s, ok := level.(string); ok {
+			record.SetSeverityText(s)
+			record.SetSeverityNumber(severities[strings.ToLower(s)])
+		}
+	}
+
+	// Copy the top level fields so the event itself is left untouched.
+	rest := make(mapstr.M, len(fields))
+	for k, v := range fields {
+		rest[k] = v
+	}
+	for _, name := range resourceFields {
+		delete(rest, name)
+	}
+
+	if e.mapping == mappingBodyMap {
+		body := record.Body().SetEmptyMap()
+		body.EnsureCapacity(len(rest) + 1)
+		if err := otelmap.FromMapstr(body, rest); err != nil {
+			return err
+		}
+		body.PutStr("@timestamp", otelmap.FormatTimestamp(content.Timestamp))
+		return nil
+	}
+
+	if msg, ok := rest["message"].(string); ok {
+		record.Body().SetStr(msg)
+		delete(rest, "message")
+	}
+	return otelmap.FromMapstr(record.Attributes(), rest)
+}
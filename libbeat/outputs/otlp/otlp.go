@@ -0,0 +1,138 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package otlp provides an output that sends events as OpenTelemetry log
+// records to an OTLP endpoint, typically an OpenTelemetry collector, using
+// either gRPC or HTTP with protobuf encoding.
+package otlp
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/transport/tlscommon"
+)
+
+const (
+	defaultGRPCPort = 4317
+	defaultHTTPPort = 4318
+	logsPath        = "/v1/logs"
+)
+
+func init() {
+	outputs.RegisterType("otlp", makeOTLP)
+}
+
+func makeOTLP(
+	_ outputs.IndexManager,
+	beat beat.Info,
+	observer outputs.Observer,
+	cfg *config.C,
+) (outputs.Group, error) {
+	log := beat.Logger.Named("otlp")
+
+	oConfig := defaultConfig()
+	if err := cfg.Unpack(&oConfig); err != nil {
+		return outputs.Fail(err)
+	}
+
+	hosts, err := outputs.ReadHostList(cfg)
+	if err != nil {
+		return outputs.Fail(err)
+	}
+	if len(hosts) == 0 {
+		return outputs.Fail(errors.New("the otlp output requires at least one host"))
+	}
+
+	tls, err := tlscommon.LoadTLSConfig(oConfig.Transport.TLS, beat.Logger)
+	if err != nil {
+		return outputs.Fail(err)
+	}
+
+	encoder := &logsEncoder{log: log, beat: beat, mapping: oConfig.Mapping}
+	clients := make([]outputs.NetworkClient, len(hosts))
+	for i, host := range hosts {
+		var exp exporter
+		switch oConfig.Protocol {
+		case protocolGRPC:
+			target, secure, err := grpcTarget(host, tls != nil)
+			if err != nil {
+				return outputs.Fail(err)
+			}
+			var tlsConfig *tlscommon.TLSConfig
+			if secure {
+				tlsConfig = tls
+				if tlsConfig == nil {
+					tlsConfig = &tlscommon.TLSConfig{}
+				}
+			}
+			exp = newGRPCExporter(target, buildTLS(tlsConfig, target), beat.UserAgent, oConfig)
+		case protocolHTTP:
+			scheme := "http"
+			if tls != nil {
+				scheme = "https"
+			}
+			endpoint, err := common.MakeURL(scheme, logsPath, host, defaultHTTPPort)
+			if err != nil {
+				return outputs.Fail(err)
+			}
+			exp = newHTTPExporter(log, endpoint, beat.UserAgent, observer, oConfig)
+		}
+
+		client := newClient(log, observer, encoder, exp)
+		clients[i] = outputs.WithBackoff(client, oConfig.Backoff.Init, oConfig.Backoff.Max)
+	}
+
+	return outputs.SuccessNet(oConfig.Queue,
+		oConfig.LoadBalance,
+		oConfig.BulkMaxSize,
+		oConfig.MaxRetries,
+		nil,
+		beat.Logger,
+		beat.Paths,
+		outputs.NumofWorker(cfg), clients)
+}
+
+// grpcTarget returns the host:port to dial for a gRPC host and whether TLS
+// must be used. An explicit http or https scheme overrides the ssl settings.
+func grpcTarget(host string, tlsEnabled bool) (string, bool, error) {
+	scheme := "http"
+	if tlsEnabled {
+		scheme = "https"
+	}
+	raw, err := common.MakeURL(scheme, "", host, defaultGRPCPort)
+	if err != nil {
+		return "", false, err
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false, err
+	}
+	switch u.Scheme {
+	case "http":
+		return u.Host, false, nil
+	case "https":
+		return u.Host, true, nil
+	default:
+		return "", false, fmt.Errorf("invalid scheme %q in otlp host %s", u.Scheme, host)
+	}
+}
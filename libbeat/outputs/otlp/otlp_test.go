@@ -0,0 +1,422 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package otlp
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/plog/plogotlp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/outputs/outest"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// testCollector is a minimal OTLP logs collector serving both gRPC and HTTP.
+type testCollector struct {
+	plogotlp.UnimplementedGRPCServer
+
+	mu       sync.Mutex
+	logs     []plog.Logs
+	headers  []map[string]string
+	err      error // returned by the gRPC endpoint
+	code     int   // returned by the HTTP endpoint
+	rejected int64
+}
+
+func (c *testCollector) Export(ctx context.Context, req plogotlp.ExportRequest) (plogotlp.ExportResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	headers := map[string]string{}
+	for k, v := range md {
+		headers[k] = v[0]
+	}
+	return c.record(req.Logs(), headers)
+}
+
+func (c *testCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body = zr
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req := plogotlp.NewExportRequest()
+	if err := req.UnmarshalProto(data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	headers := map[string]string{}
+	for k := range r.Header {
+		headers[http.CanonicalHeaderKey(k)] = r.Header.Get(k)
+	}
+	resp, _ := c.record(req.Logs(), headers)
+
+	c.mu.Lock()
+	code := c.code
+	c.mu.Unlock()
+	if code != 0 {
+		http.Error(w, "collector error", code)
+		return
+	}
+
+	out, err := resp.MarshalProto()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", protobufContentType)
+	_, _ = w.Write(out)
+}
+
+func (c *testCollector) record(logs plog.Logs, headers map[string]string) (plogotlp.ExportResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	resp := plogotlp.NewExportResponse()
+	if c.err != nil {
+		return resp, c.err
+	}
+	if c.code == 0 {
+		c.logs = append(c.logs, logs)
+		c.headers = append(c.headers, headers)
+	}
+	if c.rejected > 0 {
+		resp.PartialSuccess().SetRejectedLogRecords(c.rejected)
+		resp.PartialSuccess().SetErrorMessage("some records were rejected")
+	}
+	return resp, nil
+}
+
+func (c *testCollector) received() ([]plog.Logs, []map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.logs, c.headers
+}
+
+func startGRPCCollector(t *testing.T, c *testCollector) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	plogotlp.RegisterGRPCServer(srv, c)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+	return lis.Addr().String()
+}
+
+func startHTTPCollector(t *testing.T, c *testCollector) string {
+	t.Helper()
+	srv := httptest.NewServer(c)
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func startCollector(t *testing.T, protocol string, c *testCollector) string {
+	if protocol == protocolGRPC {
+		return startGRPCCollector(t, c)
+	}
+	return startHTTPCollector(t, c)
+}
+
+func newTestClient(t *testing.T, settings map[string]any) outputs.NetworkClient {
+	t.Helper()
+	info := beat.Info{
+		Beat:      "testbeat",
+		Version:   "9.9.9",
+		UserAgent: "testbeat/9.9.9",
+		Logger:    logptest.NewTestingLogger(t, ""),
+	}
+	group, err := makeOTLP(nil, info, outputs.NewNilObserver(), config.MustNewConfigFrom(settings))
+	require.NoError(t, err)
+	require.Len(t, group.Clients, 1)
+
+	client := group.Clients[0].(outputs.NetworkClient)
+	require.NoError(t, client.Connect(context.Background()))
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func testEvent(host, message string) beat.Event {
+	return beat.Event{
+		Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Fields: mapstr.M{
+			"message": message,
+			"host":    mapstr.M{"name": host, "ip": []string{"10.0.0.1"}},
+			"agent":   mapstr.M{"type": "testbeat", "version": "9.9.9"},
+			"log":     mapstr.M{"level": "warn"},
+			"event":   mapstr.M{"dataset": "test"},
+		},
+	}
+}
+
+func TestEncode(t *testing.T) {
+	encoder := &logsEncoder{
+		log:     logptest.NewTestingLogger(t, ""),
+		beat:    beat.Info{Beat: "testbeat", Version: "9.9.9"},
+		mapping: mappingAttributes,
+	}
+	observed := time.Date(2024, 1, 2, 3, 4, 6, 0, time.UTC)
+	batch := outest.NewBatch(
+		testEvent("host-a", "first"),
+		testEvent("host-b", "second"),
+		testEvent("host-a", "third"),
+	)
+	logs := encoder.encode(batch.Events(), observed)
+
+	// Events are grouped by resource.
+	require.Equal(t, 2, logs.ResourceLogs().Len())
+	assert.Equal(t, 3, logs.LogRecordCount())
+
+	resourceA := logs.ResourceLogs().At(0)
+	attrs := resourceA.Resource().Attributes().AsRaw()
+	assert.Equal(t, "host-a", attrs["host.name"])
+	assert.Equal(t, []any{"10.0.0.1"}, attrs["host.ip"])
+	assert.Equal(t, "testbeat", attrs["agent.type"])
+	assert.Equal(t, "testbeat", attrs["service.name"])
+	assert.Equal(t, "9.9.9", attrs["service.version"])
+
+	records := resourceA.ScopeLogs().At(0).LogRecords()
+	require.Equal(t, 2, records.Len())
+	record := records.At(0)
+	assert.Equal(t, "first", record.Body().Str())
+	assert.Equal(t, plog.SeverityNumberWarn, record.SeverityNumber())
+	assert.Equal(t, "warn", record.SeverityText())
+	assert.Equal(t, batch.Events()[0].Content.Timestamp, record.Timestamp().AsTime())
+	assert.Equal(t, observed, record.ObservedTimestamp().AsTime())
+	assert.Equal(t, map[string]any{
+		"log":   map[string]any{"level": "warn"},
+		"event": map[string]any{"dataset": "test"},
+	}, record.Attributes().AsRaw())
+
+	// The events themselves are left untouched.
+	assert.Contains(t, batch.Events()[0].Content.Fields, "host")
+	assert.Contains(t, batch.Events()[0].Content.Fields, "message")
+}
+
+func TestEncodeBodyMap(t *testing.T) {
+	encoder := &logsEncoder{
+		log:     logptest.NewTestingLogger(t, ""),
+		beat:    beat.Info{Beat: "testbeat"},
+		mapping: mappingBodyMap,
+	}
+	batch := outest.NewBatch(testEvent("host-a", "first"))
+	logs := encoder.encode(batch.Events(), time.Now())
+
+	record := logs.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0)
+	assert.Equal(t, 0, record.Attributes().Len())
+	assert.Equal(t, map[string]any{
+		"@timestamp": "2024-01-02T03:04:05.000Z",
+		"message":    "first",
+		"log":        map[string]any{"level": "warn"},
+		"event":      map[string]any{"dataset": "test"},
+	}, record.Body().Map().AsRaw())
+}
+
+func TestPublish(t *testing.T) {
+	for _, protocol := range []string{protocolGRPC, protocolHTTP} {
+		for _, compression := range []string{compressionNone, compressionGzip} {
+			t.Run(protocol+"/"+compression, func(t *testing.T) {
+				collector := &testCollector{}
+				client := newTestClient(t, map[string]any{
+					"hosts":       []string{startCollector(t, protocol, collector)},
+					"protocol":    protocol,
+					"compression": compression,
+					"headers":     map[string]string{"x-api-key": "secret"},
+				})
+
+				batch := outest.NewBatch(testEvent("host-a", "first"), testEvent("host-a", "second"))
+				require.NoError(t, client.Publish(context.Background(), batch))
+				require.Len(t, batch.Signals, 1)
+				assert.Equal(t, outest.BatchACK, batch.Signals[0].Tag)
+
+				logs, headers := collector.received()
+				require.Len(t, logs, 1)
+				assert.Equal(t, 2, logs[0].LogRecordCount())
+				if protocol == protocolGRPC {
+					assert.Equal(t, "secret", headers[0]["x-api-key"])
+				} else {
+					assert.Equal(t, "secret", headers[0]["X-Api-Key"])
+					assert.Equal(t, "testbeat/9.9.9", headers[0]["User-Agent"])
+				}
+			})
+		}
+	}
+}
+
+func TestPublishErrors(t *testing.T) {
+	tests := map[string]struct {
+		protocol string
+		err      error
+		code     int
+		signal   outest.BatchSignalTag
+		retry    bool
+	}{
+		"grpc unavailable": {
+			protocol: protocolGRPC,
+			err:      status.Error(codes.Unavailable, "try later"),
+			signal:   outest.BatchRetry,
+			retry:    true,
+		},
+		"grpc resource exhausted": {
+			protocol: protocolGRPC,
+			err:      status.Error(codes.ResourceExhausted, "slow down"),
+			signal:   outest.BatchRetry,
+			retry:    true,
+		},
+		"grpc invalid argument": {
+			protocol: protocolGRPC,
+			err:      status.Error(codes.InvalidArgument, "bad data"),
+			signal:   outest.BatchDrop,
+		},
+		"http service unavailable": {
+			protocol: protocolHTTP,
+			code:     http.StatusServiceUnavailable,
+			signal:   outest.BatchRetry,
+			retry:    true,
+		},
+		"http too many requests": {
+			protocol: protocolHTTP,
+			code:     http.StatusTooManyRequests,
+			signal:   outest.BatchRetry,
+			retry:    true,
+		},
+		"http bad request": {
+			protocol: protocolHTTP,
+			code:     http.StatusBadRequest,
+			signal:   outest.BatchDrop,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			collector := &testCollector{err: tc.err, code: tc.code}
+			client := newTestClient(t, map[string]any{
+				"hosts":    []string{startCollector(t, tc.protocol, collector)},
+				"protocol": tc.protocol,
+				"backoff":  map[string]any{"init": "1ms", "max": "1ms"},
+			})
+
+			batch := outest.NewBatch(testEvent("host-a", "first"))
+			err := client.Publish(context.Background(), batch)
+			if tc.retry {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			require.Len(t, batch.Signals, 1)
+			assert.Equal(t, tc.signal, batch.Signals[0].Tag)
+		})
+	}
+}
+
+func TestPublishPartialSuccess(t *testing.T) {
+	for _, protocol := range []string{protocolGRPC, protocolHTTP} {
+		t.Run(protocol, func(t *testing.T) {
+			collector := &testCollector{rejected: 1}
+			client := newTestClient(t, map[string]any{
+				"hosts":    []string{startCollector(t, protocol, collector)},
+				"protocol": protocol,
+			})
+
+			// The rejected record can't be identified, so nothing is
+			// written to the dead letter file.
+			deadLetters := &outest.DeadLetters{}
+			ctx := outputs.WithDeadLetterWriter(context.Background(), deadLetters)
+			batch := outest.NewBatch(testEvent("host-a", "first"), testEvent("host-a", "second"))
+			require.NoError(t, client.Publish(ctx, batch))
+			require.Len(t, batch.Signals, 1)
+			assert.Equal(t, outest.BatchACK, batch.Signals[0].Tag)
+			assert.Empty(t, deadLetters.Events())
+		})
+	}
+}
+
+func TestPublishAllRecordsRejected(t *testing.T) {
+	for _, protocol := range []string{protocolGRPC, protocolHTTP} {
+		t.Run(protocol, func(t *testing.T) {
+			collector := &testCollector{rejected: 2}
+			client := newTestClient(t, map[string]any{
+				"hosts":    []string{startCollector(t, protocol, collector)},
+				"protocol": protocol,
+			})
+
+			deadLetters := &outest.DeadLetters{}
+			ctx := outputs.WithDeadLetterWriter(context.Background(), deadLetters)
+			batch := outest.NewBatch(testEvent("host-a", "first"), testEvent("host-a", "second"))
+			require.NoError(t, client.Publish(ctx, batch))
+			require.Len(t, batch.Signals, 1)
+			assert.Equal(t, outest.BatchACK, batch.Signals[0].Tag)
+			assert.Len(t, deadLetters.Events(), 2)
+			for _, reason := range deadLetters.Reasons() {
+				assert.ErrorContains(t, reason, "some records were rejected")
+			}
+		})
+	}
+}
+
+func TestGRPCTarget(t *testing.T) {
+	tests := []struct {
+		host       string
+		tlsEnabled bool
+		target     string
+		secure     bool
+	}{
+		{host: "localhost", target: "localhost:4317"},
+		{host: "collector:1234", tlsEnabled: true, target: "collector:1234", secure: true},
+		{host: "https://collector", target: "collector:4317", secure: true},
+		{host: "http://collector:4317", tlsEnabled: true, target: "collector:4317"},
+	}
+	for _, tc := range tests {
+		target, secure, err := grpcTarget(tc.host, tc.tlsEnabled)
+		require.NoError(t, err, tc.host)
+		assert.Equal(t, tc.target, target, tc.host)
+		assert.Equal(t, tc.secure, secure, tc.host)
+	}
+
+	_, _, err := grpcTarget("ftp://collector", false)
+	assert.ErrorContains(t, err, "invalid scheme")
+}
@@ -0,0 +1,58 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package outest
+
+import (
+	"sync"
+
+	"github.com/elastic/beats/v7/libbeat/publisher"
+)
+
+// DeadLetters is a dead letter writer recording the events written to it.
+type DeadLetters struct {
+	mu      sync.Mutex
+	events  []publisher.Event
+	reasons []error
+}
+
+func (d *DeadLetters) WriteDeadLetters(events []publisher.Event, reason error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, event := range events {
+		d.events = append(d.events, event)
+		d.reasons = append(d.reasons, reason)
+	}
+}
+
+func (d *DeadLetters) Close() error {
+	return nil
+}
+
+// Events returns the events written so far.
+func (d *DeadLetters) Events() []publisher.Event {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]publisher.Event(nil), d.events...)
+}
+
+// Reasons returns the error written with each event.
+func (d *DeadLetters) Reasons() []error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]error(nil), d.reasons...)
+}
@@ -21,6 +21,7 @@ import (
 	"fmt"
 
 	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/outputs/deadletter"
 	"github.com/elastic/beats/v7/libbeat/publisher"
 	"github.com/elastic/beats/v7/libbeat/publisher/queue"
 	"github.com/elastic/elastic-agent-libs/config"
@@ -77,6 +78,12 @@ type Group struct {
 	//   and clear Content anyway. Metadata about the error should be saved in
 	//   EncodedEvent and reported when Publish is called.
 	EncoderFactory queue.EncoderFactory[publisher.Event]
+
+	// DeadLetter, if set, receives the events the clients fail to publish
+	// permanently. The pipeline passes it to the clients through the context
+	// of Publish, see DeadLetterWriterFrom, and closes it when the group is
+	// replaced.
+	DeadLetter DeadLetterWriter
 }
 
 // RegisterType registers a new output type.
@@ -108,5 +115,17 @@ func Load(
 	if stats == nil {
 		stats = NewNilObserver()
 	}
-	return factory(im, info, stats, config)
+	group, err := factory(im, info, stats, config)
+	if err != nil {
+		return group, err
+	}
+
+	dlConfig, err := deadletter.ConfigFromOutput(config)
+	if err != nil {
+		return Group{}, fmt.Errorf("invalid dead_letter settings: %w", err)
+	}
+	if dlConfig.Enabled {
+		group.DeadLetter = deadletter.NewWriter(info, name, dlConfig)
+	}
+	return group, nil
 }
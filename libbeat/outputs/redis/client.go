@@ -45,6 +45,7 @@ var (
 type publishFn func(
 	keys outil.Selector,
 	data []publisher.Event,
+	deadLetter outputs.DeadLetterWriter,
 ) ([]publisher.Event, error)
 
 type client struct {
@@ -137,7 +138,7 @@ func (c *client) Close() error {
 	return c.Client.Close()
 }
 
-func (c *client) Publish(_ context.Context, batch publisher.Batch) error {
+func (c *client) Publish(ctx context.Context, batch publisher.Batch) error {
 	if c == nil {
 		panic("no client")
 	}
@@ -147,7 +148,7 @@ func (c *client) Publish(_ context.Context, batch publisher.Batch) error {
 
 	events := batch.Events()
 	c.observer.NewBatch(len(events))
-	rest, err := c.publish(c.key, events)
+	rest, err := c.publish(c.key, events, outputs.DeadLetterWriterFrom(ctx))
 	if rest != nil {
 		c.observer.RetryableErrors(len(rest))
 		batch.RetryEvents(rest)
@@ -225,11 +226,11 @@ func (c *client) makePublishPUBLISH(conn redis.Conn) (publishFn, error) {
 func (c *client) publishEventsBulk(conn redis.Conn, command string) publishFn {
 	// XXX: requires key.IsConst() == true
 	dest, _ := c.key.Select(&beat.Event{Fields: mapstr.M{}})
-	return func(_ outil.Selector, data []publisher.Event) ([]publisher.Event, error) {
+	return func(_ outil.Selector, data []publisher.Event, deadLetter outputs.DeadLetterWriter) ([]publisher.Event, error) {
 		args := make([]any, 1, len(data)+1)
 		args[0] = dest
 
-		okEvents, args := serializeEvents(c.log, args, 1, data, c.index, c.codec)
+		okEvents, args := serializeEvents(c.log, args, 1, data, c.index, c.codec, deadLetter)
 		c.observer.PermanentErrors(len(data) - len(okEvents))
 		if (len(args) - 1) == 0 {
 			return nil, nil
@@ -252,10 +253,10 @@ func (c *client) publishEventsBulk(conn redis.Conn, command string) publishFn {
 }
 
 func (c *client) publishEventsPipeline(conn redis.Conn, command string) publishFn {
-	return func(key outil.Selector, data []publisher.Event) ([]publisher.Event, error) {
+	return func(key outil.Selector, data []publisher.Event, deadLetter outputs.DeadLetterWriter) ([]publisher.Event, error) {
 		var okEvents []publisher.Event
 		serialized := make([]any, 0, len(data))
-		okEvents, serialized = serializeEvents(c.log, serialized, 0, data, c.index, c.codec)
+		okEvents, serialized = serializeEvents(c.log, serialized, 0, data, c.index, c.codec, deadLetter)
 		c.observer.PermanentErrors(len(data) - len(okEvents))
 		if len(serialized) == 0 {
 			return nil, nil
@@ -267,6 +268,7 @@ func (c *client) publishEventsPipeline(conn redis.Conn, command string) publishF
 			eventKey, err := key.Select(&okEvents[i].Content)
 			if err != nil {
 				c.log.Errorf("Failed to set redis key: %+v", err)
+				deadLetter.WriteDeadLetters(okEvents[i:i+1], fmt.Errorf("failed to set redis key: %w", err))
 				dropped++
 				continue
 			}
@@ -315,6 +317,7 @@ func serializeEvents(
 	data []publisher.Event,
 	index string,
 	codec codec.Codec,
+	deadLetter outputs.DeadLetterWriter,
 ) ([]publisher.Event, []any) {
 
 	succeeded := data
@@ -323,6 +326,7 @@ func serializeEvents(
 		if err != nil {
 			log.Errorf("Encoding event failed with error: %+v. Check the event_data log (configured by logging.event_data.files.path) to view the event", err)
 			log.Errorw(fmt.Sprintf("Failed event: %v", d.Content), logp.TypeKey, logp.EventType)
+			deadLetter.WriteDeadLetters([]publisher.Event{d}, fmt.Errorf("encoding event failed: %w", err))
 			goto failLoop
 		}
 
@@ -341,6 +345,7 @@ failLoop:
 		if err != nil {
 			log.Errorf("Encoding event failed with error: %+v. Check the event_data log (configured by logging.event_data.files.path) to view the event", err)
 			log.Errorw(fmt.Sprintf("Failed event: %v", d.Content), logp.TypeKey, logp.EventType)
+			deadLetter.WriteDeadLetters([]publisher.Event{d}, fmt.Errorf("encoding event failed: %w", err))
 			i++
 			continue
 		}
@@ -18,13 +18,17 @@
 package redis
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/elastic/beats/v7/libbeat/beat"
 	"github.com/elastic/beats/v7/libbeat/outputs"
 	_ "github.com/elastic/beats/v7/libbeat/outputs/codec/json"
+	"github.com/elastic/beats/v7/libbeat/outputs/outest"
+	"github.com/elastic/beats/v7/libbeat/publisher"
 	"github.com/elastic/elastic-agent-libs/config"
 	"github.com/elastic/elastic-agent-libs/logp/logptest"
 	"github.com/elastic/elastic-agent-libs/mapstr"
@@ -183,3 +187,30 @@ func TestKeySelection(t *testing.T) {
 		})
 	}
 }
+
+// failingCodec fails to encode events whose message is "bad".
+type failingCodec struct{}
+
+func (failingCodec) Encode(_ string, event *beat.Event) ([]byte, error) {
+	msg, _ := event.Fields["message"].(string)
+	if msg == "bad" {
+		return nil, errors.New("cannot encode")
+	}
+	return []byte(msg), nil
+}
+
+func TestSerializeEventsDeadLetters(t *testing.T) {
+	deadLetters := &outest.DeadLetters{}
+	data := []publisher.Event{
+		{Content: beat.Event{Fields: mapstr.M{"message": "first"}}},
+		{Content: beat.Event{Fields: mapstr.M{"message": "bad"}}},
+		{Content: beat.Event{Fields: mapstr.M{"message": "second"}}},
+	}
+
+	_, serialized := serializeEvents(logptest.NewTestingLogger(t, ""), nil, 0, data, "test", failingCodec{}, deadLetters)
+	assert.Equal(t, []any{[]byte("first"), []byte("second")}, serialized)
+
+	require.Len(t, deadLetters.Events(), 1)
+	assert.Equal(t, "bad", deadLetters.Events()[0].Content.Fields["message"])
+	assert.ErrorContains(t, deadLetters.Reasons()[0], "cannot encode")
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/elastic/beats/v7/libbeat/publisher/queue"
 	"github.com/elastic/beats/v7/libbeat/publisher/queue/diskqueue"
 	"github.com/elastic/beats/v7/libbeat/publisher/queue/memqueue"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/spillqueue"
 	"github.com/elastic/elastic-agent-libs/config"
 	"github.com/elastic/elastic-agent-libs/logp"
 	"github.com/elastic/elastic-agent-libs/paths"
@@ -64,6 +65,12 @@ func Success(
 				return Group{}, fmt.Errorf("unable to get disk queue settings: %w", err)
 			}
 			q = diskqueue.FactoryForSettings(settings, beatPaths)
+		case spillqueue.QueueType:
+			settings, err := spillqueue.SettingsForUserConfig(cfg.Config())
+			if err != nil {
+				return Group{}, fmt.Errorf("unable to get spill queue settings: %w", err)
+			}
+			q = spillqueue.FactoryForSettings(settings, beatPaths)
 		default:
 			return Group{}, fmt.Errorf("unknown queue type: %s", cfg.Name())
 		}
@@ -0,0 +1,389 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package aggregate
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/monitoring"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+const (
+	procName = "aggregate"
+	logName  = "processor." + procName
+)
+
+// instanceID is used to assign each instance a unique monitoring namespace.
+var instanceID atomic.Uint32
+
+func init() {
+	// We cannot use this as a JS plugin as it is stateful and includes a Close method.
+	processors.RegisterPlugin(procName, New)
+}
+
+type metrics struct {
+	Rollups    *monitoring.Int
+	Overflowed *monitoring.Int
+}
+
+// rollup marks the events published by aggregate processors in their
+// Private field, so they are not aggregated again when they go through the
+// processors.
+type rollup struct{}
+
+type processor struct {
+	config
+	log     *logp.Logger
+	metrics metrics
+
+	mu          sync.Mutex
+	groups      map[string]*group
+	windowStart time.Time
+	paths       *paths.Path
+	removeHook  func()
+
+	// flushMu serializes the publications of rollups. client is connected on
+	// the first one, and disconnected is set once the pipeline is closing.
+	flushMu      sync.Mutex
+	client       beat.Client
+	disconnected bool
+
+	now       func() time.Time
+	done      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// group holds the statistics of the events having the same values of the
+// group_by fields in the current window.
+type group struct {
+	values  mapstr.M
+	count   int64
+	metrics map[string]*stats
+}
+
+type stats struct {
+	count    int64
+	sum      float64
+	min, max float64
+	sketch   *sketch
+}
+
+func (s *stats) add(v float64) {
+	s.count++
+	s.sum += v
+	s.min = math.Min(s.min, v)
+	s.max = math.Max(s.max, v)
+	s.sketch.add(v)
+}
+
+// New constructs a new aggregate processor.
+func New(cfg *conf.C, log *logp.Logger) (beat.Processor, error) {
+	c := defaultConfig()
+	if err := cfg.Unpack(&c); err != nil {
+		return nil, fmt.Errorf("fail to unpack the %v processor configuration: %w", procName, err)
+	}
+
+	p, err := newAggregate(c, log)
+	if err != nil {
+		return nil, err
+	}
+	p.start()
+	return p, nil
+}
+
+func newAggregate(c config, logger *logp.Logger) (*processor, error) {
+	if c.Percentiles == nil {
+		c.Percentiles = defaultPercentiles
+	}
+
+	var (
+		id  = int(instanceID.Add(1))
+		reg = monitoring.Default.GetOrCreateRegistry(logName+"."+strconv.Itoa(id), monitoring.DoNotReport)
+	)
+
+	log := logger.Named(logName)
+	if c.ID != "" {
+		log = log.With("instance_id", c.ID)
+	}
+	p := &processor{
+		config: c,
+		log:    log,
+		metrics: metrics{
+			Rollups:    monitoring.NewInt(reg, "rollups"),
+			Overflowed: monitoring.NewInt(reg, "overflowed"),
+		},
+		groups: map[string]*group{},
+		now:    time.Now,
+		done:   make(chan struct{}),
+	}
+	p.windowStart = p.now().Truncate(c.Window)
+	return p, nil
+}
+
+// start closes the windows in the background until the processor is closed.
+func (p *processor) start() {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		timer := time.NewTimer(p.untilWindowEnd())
+		defer timer.Stop()
+		for {
+			select {
+			case <-p.done:
+				return
+			case <-timer.C:
+				p.flush(p.now())
+				timer.Reset(p.untilWindowEnd())
+			}
+		}
+	}()
+}
+
+func (p *processor) untilWindowEnd() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.windowStart.Add(p.Window).Sub(p.now())
+}
+
+// SetPaths sets the paths of the beat, which the pipeline the rollup events
+// are published to is registered with.
+func (p *processor) SetPaths(paths *paths.Path) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.removeHook != nil {
+		p.removeHook()
+	}
+	p.paths = paths
+	p.removeHook = processors.OnPipelineClose(paths, p.pipelineClosing)
+	return nil
+}
+
+// pipelineClosing publishes the rollup events of the current window when the
+// pipeline is disconnected, while the processors still run. The rollups of the
+// events processed later are dropped.
+func (p *processor) pipelineClosing() {
+	p.flush(p.now())
+	p.flushMu.Lock()
+	p.disconnected = true
+	p.flushMu.Unlock()
+}
+
+func (p *processor) String() string {
+	return fmt.Sprintf("%v=[group_by=%v, metrics=%v, window=%v, percentiles=%v, drop_events=%v]",
+		procName, p.GroupBy, p.Metrics, p.Window, p.Percentiles, p.DropEvents)
+}
+
+// Run adds the event to the statistics of its group, and drops it if
+// drop_events is set. Rollup events are passed unchanged.
+func (p *processor) Run(event *beat.Event) (*beat.Event, error) {
+	if _, ok := event.Private.(rollup); ok {
+		return event, nil
+	}
+
+	p.mu.Lock()
+	p.add(event)
+	p.mu.Unlock()
+
+	if p.DropEvents {
+		return nil, nil
+	}
+	return event, nil
+}
+
+// add adds the event to the statistics of its group. Callers must hold p.mu.
+func (p *processor) add(event *beat.Event) {
+	var key strings.Builder
+	values := make([]any, len(p.GroupBy))
+	for i, field := range p.GroupBy {
+		// Events missing a field are grouped together.
+		values[i], _ = event.GetValue(field)
+		fmt.Fprintf(&key, "%T:%v\x00", values[i], values[i])
+	}
+
+	g, ok := p.groups[key.String()]
+	if !ok {
+		if len(p.groups) >= p.MaxGroups {
+			p.metrics.Overflowed.Inc()
+			return
+		}
+		g = &group{values: mapstr.M{}, metrics: map[string]*stats{}}
+		for i, field := range p.GroupBy {
+			if values[i] != nil {
+				_, _ = g.values.Put(field, values[i])
+			}
+		}
+		p.groups[key.String()] = g
+	}
+
+	g.count++
+	for _, field := range p.Metrics {
+		v, err := event.GetValue(field)
+		if err != nil {
+			continue
+		}
+		f, ok := toFloat(v)
+		if !ok {
+			continue
+		}
+		s, ok := g.metrics[field]
+		if !ok {
+			s = &stats{min: f, max: f, sketch: newSketch()}
+			g.metrics[field] = s
+		}
+		s.add(f)
+	}
+}
+
+// flush publishes the rollup events of the current window and starts a new
+// one.
+func (p *processor) flush(now time.Time) {
+	p.mu.Lock()
+	groups, start := p.groups, p.windowStart
+	p.groups = map[string]*group{}
+	p.windowStart = now.Truncate(p.Window)
+	paths := p.paths
+	p.mu.Unlock()
+
+	if len(groups) == 0 {
+		return
+	}
+
+	p.flushMu.Lock()
+	defer p.flushMu.Unlock()
+	if p.disconnected {
+		p.log.Warnf("Dropping %d rollup events: the pipeline is closed.", len(groups))
+		return
+	}
+
+	end := start.Add(p.Window)
+	if now.Before(end) {
+		end = now
+	}
+	events := make([]beat.Event, 0, len(groups))
+	for _, g := range groups {
+		events = append(events, p.rollup(g, start, end))
+	}
+
+	if p.client == nil {
+		pipeline := processors.LookupPipeline(paths)
+		if pipeline == nil {
+			p.log.Warnf("Dropping %d rollup events: the processor is not connected to a pipeline.", len(events))
+			return
+		}
+		// The rollups don't come from an input, so they are published without
+		// the settings of the input the processor may be configured in.
+		client, err := pipeline.ConnectWith(beat.ClientConfig{})
+		if err != nil {
+			p.log.Errorf("Dropping %d rollup events: failed to connect to the pipeline: %v", len(events), err)
+			return
+		}
+		p.client = client
+	}
+	p.client.PublishAll(events)
+	p.metrics.Rollups.Add(int64(len(events)))
+}
+
+// rollup returns the rollup event of a group for the window between start
+// and end.
+func (p *processor) rollup(g *group, start, end time.Time) beat.Event {
+	metrics := mapstr.M{}
+	for field, s := range g.metrics {
+		percentiles := mapstr.M{}
+		for _, pct := range p.Percentiles {
+			// The estimate can't be outside of the values seen.
+			v := math.Min(math.Max(s.sketch.quantile(pct/100), s.min), s.max)
+			percentiles[percentileKey(pct)] = v
+		}
+		_, _ = metrics.Put(field, mapstr.M{
+			"count":       s.count,
+			"sum":         s.sum,
+			"min":         s.min,
+			"max":         s.max,
+			"avg":         s.sum / float64(s.count),
+			"percentiles": percentiles,
+		})
+	}
+
+	fields := g.values.Clone()
+	_, _ = fields.Put("event.kind", "metric")
+	_, _ = fields.Put(p.TargetField, mapstr.M{
+		"window": mapstr.M{
+			"start": start,
+			"end":   end,
+		},
+		"count":   g.count,
+		"metrics": metrics,
+	})
+	return beat.Event{Timestamp: start, Fields: fields, Private: rollup{}}
+}
+
+// percentileKey returns the name of the field of a percentile, p50 for 50
+// and p99_9 for 99.9.
+func percentileKey(pct float64) string {
+	return "p" + strings.ReplaceAll(strconv.FormatFloat(pct, 'f', -1, 64), ".", "_")
+}
+
+func toFloat(v any) (float64, bool) {
+	if f, ok := common.TryToFloat64(v); ok {
+		return f, true
+	}
+	if i, ok := common.TryToInt(v); ok {
+		return float64(i), true
+	}
+	return 0, false
+}
+
+// Close stops closing the windows, publishes the rollup events of the current
+// window and disconnects from the pipeline. Global processors publish the last
+// window when the pipeline is closing instead, as their rollups would go
+// through the processors being closed.
+func (p *processor) Close() error {
+	var err error
+	p.closeOnce.Do(func() {
+		p.mu.Lock()
+		if p.removeHook != nil {
+			p.removeHook()
+		}
+		p.mu.Unlock()
+
+		close(p.done)
+		p.wg.Wait()
+		p.flush(p.now())
+
+		p.flushMu.Lock()
+		defer p.flushMu.Unlock()
+		if p.client != nil {
+			err = p.client.Close()
+		}
+	})
+	return err
+}
@@ -0,0 +1,236 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package aggregate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	pubtest "github.com/elastic/beats/v7/libbeat/publisher/testing"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+// connect registers a pipeline publishing to the returned client for the
+// paths of p.
+func connect(t *testing.T, p *processor) *pubtest.ChanClient {
+	t.Helper()
+	client := pubtest.NewChanClient(100)
+	beatPaths := &paths.Path{}
+	t.Cleanup(processors.RegisterPipeline(beatPaths, pubtest.PublisherWithClient(client)))
+	require.NoError(t, p.SetPaths(beatPaths))
+	return client
+}
+
+func received(client *pubtest.ChanClient) []beat.Event {
+	var events []beat.Event
+	for {
+		select {
+		case e := <-client.Channel:
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	proc, err := New(conf.MustNewConfigFrom(mapstr.M{
+		"group_by":    []string{"url.path", "http.response.status_code"},
+		"metrics":     []string{"event.duration"},
+		"percentiles": []float64{50, 99.9},
+	}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	p := proc.(*processor)
+	t.Cleanup(func() { assert.NoError(t, p.Close()) })
+	client := connect(t, p)
+	start := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	p.mu.Lock()
+	p.windowStart = start
+	p.mu.Unlock()
+
+	for _, d := range []int64{100, 200, 300, 400} {
+		event, err := p.Run(&beat.Event{Fields: mapstr.M{
+			"url":   mapstr.M{"path": "/"},
+			"http":  mapstr.M{"response": mapstr.M{"status_code": 200}},
+			"event": mapstr.M{"duration": d},
+		}})
+		require.NoError(t, err)
+		assert.NotNil(t, event, "raw events are passed by default")
+	}
+	_, err = p.Run(&beat.Event{Fields: mapstr.M{
+		"url":   mapstr.M{"path": "/"},
+		"http":  mapstr.M{"response": mapstr.M{"status_code": 500}},
+		"event": mapstr.M{"duration": "not a number"},
+	}})
+	require.NoError(t, err)
+
+	p.flush(start.Add(time.Minute))
+	events := received(client)
+	require.Len(t, events, 2)
+	if events[0].Fields["http"].(mapstr.M)["response"].(mapstr.M)["status_code"] != 200 {
+		events[0], events[1] = events[1], events[0]
+	}
+
+	ok := events[0]
+	assert.Equal(t, start, ok.Timestamp)
+	assert.Equal(t, rollup{}, ok.Private)
+	stats, err := ok.Fields.GetValue("aggregate.metrics.event.duration")
+	require.NoError(t, err)
+	percentiles := stats.(mapstr.M)["percentiles"].(mapstr.M)
+	assert.InEpsilon(t, 200.0, percentiles["p50"], relativeAccuracy)
+	assert.InEpsilon(t, 400.0, percentiles["p99_9"], relativeAccuracy)
+	delete(stats.(mapstr.M), "percentiles")
+	assert.Equal(t, mapstr.M{
+		"url":   mapstr.M{"path": "/"},
+		"http":  mapstr.M{"response": mapstr.M{"status_code": 200}},
+		"event": mapstr.M{"kind": "metric"},
+		"aggregate": mapstr.M{
+			"window": mapstr.M{"start": start, "end": start.Add(time.Minute)},
+			"count":  int64(4),
+			"metrics": mapstr.M{"event": mapstr.M{"duration": mapstr.M{
+				"count": int64(4),
+				"sum":   1000.0,
+				"min":   100.0,
+				"max":   400.0,
+				"avg":   250.0,
+			}}},
+		},
+	}, ok.Fields)
+
+	failed := events[1]
+	count, _ := failed.Fields.GetValue("aggregate.count")
+	assert.Equal(t, int64(1), count)
+	metrics, _ := failed.Fields.GetValue("aggregate.metrics")
+	assert.Empty(t, metrics, "non numeric values are not aggregated")
+
+	assert.Equal(t, start.Add(time.Minute), p.windowStart)
+	p.flush(start.Add(2 * time.Minute))
+	assert.Empty(t, received(client), "empty windows have no rollups")
+	assert.Equal(t, int64(2), p.metrics.Rollups.Get())
+}
+
+func TestAggregateDropEvents(t *testing.T) {
+	proc, err := New(conf.MustNewConfigFrom(mapstr.M{
+		"metrics":     []string{"bytes"},
+		"drop_events": true,
+	}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	p := proc.(*processor)
+	t.Cleanup(func() { assert.NoError(t, p.Close()) })
+	client := connect(t, p)
+
+	event, err := p.Run(&beat.Event{Fields: mapstr.M{"bytes": 10.5}})
+	require.NoError(t, err)
+	assert.Nil(t, event)
+
+	require.NoError(t, p.Close())
+	events := received(client)
+	require.Len(t, events, 1, "the last window is published on close")
+
+	// Rollups going through the processor again are passed unchanged.
+	event, err = p.Run(&events[0])
+	require.NoError(t, err)
+	require.NotNil(t, event)
+	assert.Equal(t, 10.5, mustGet(t, event.Fields, "aggregate.metrics.bytes.sum"))
+	assert.NoError(t, p.Close(), "close is idempotent")
+}
+
+func TestAggregateMaxGroups(t *testing.T) {
+	proc, err := New(conf.MustNewConfigFrom(mapstr.M{
+		"group_by":   []string{"host"},
+		"metrics":    []string{"bytes"},
+		"max_groups": 2,
+	}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	p := proc.(*processor)
+	t.Cleanup(func() { assert.NoError(t, p.Close()) })
+	client := connect(t, p)
+	for _, host := range []string{"a", "b", "c", "a"} {
+		_, err := p.Run(&beat.Event{Fields: mapstr.M{"host": host, "bytes": 1}})
+		require.NoError(t, err)
+	}
+	_, err = p.Run(&beat.Event{Fields: mapstr.M{"bytes": 1}})
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(2), p.metrics.Overflowed.Get())
+	p.flush(p.windowStart.Add(p.Window))
+	assert.Len(t, received(client), 2)
+}
+
+func TestAggregateWithoutPipeline(t *testing.T) {
+	p, err := New(conf.MustNewConfigFrom(mapstr.M{"metrics": []string{"bytes"}}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+
+	_, err = p.Run(&beat.Event{Fields: mapstr.M{"bytes": 1}})
+	require.NoError(t, err)
+	assert.NoError(t, processors.Close(p))
+	assert.Equal(t, int64(0), p.(*processor).metrics.Rollups.Get())
+}
+
+func TestAggregatePipelineClosing(t *testing.T) {
+	proc, err := New(conf.MustNewConfigFrom(mapstr.M{"metrics": []string{"bytes"}}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	p := proc.(*processor)
+
+	client := pubtest.NewChanClient(100)
+	beatPaths := &paths.Path{}
+	closing := processors.RegisterPipeline(beatPaths, pubtest.PublisherWithClient(client))
+	require.NoError(t, p.SetPaths(beatPaths))
+
+	_, err = p.Run(&beat.Event{Fields: mapstr.M{"bytes": 1}})
+	require.NoError(t, err)
+	closing()
+	assert.Len(t, received(client), 1, "the window is published when the pipeline is closing")
+	assert.Nil(t, processors.LookupPipeline(beatPaths))
+
+	_, err = p.Run(&beat.Event{Fields: mapstr.M{"bytes": 1}})
+	require.NoError(t, err)
+	require.NoError(t, p.Close())
+	assert.Empty(t, received(client), "rollups are dropped once the pipeline is closed")
+}
+
+func TestConfigValidate(t *testing.T) {
+	for name, cfg := range map[string]mapstr.M{
+		"no metrics":         {},
+		"invalid percentile": {"metrics": []string{"bytes"}, "percentiles": []float64{0}},
+		"empty target":       {"metrics": []string{"bytes"}, "target_field": ""},
+		"zero window":        {"metrics": []string{"bytes"}, "window": "0s"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(conf.MustNewConfigFrom(cfg), logptest.NewTestingLogger(t, ""))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func mustGet(t *testing.T, m mapstr.M, key string) any {
+	t.Helper()
+	v, err := m.GetValue(key)
+	require.NoError(t, err)
+	return v
+}
@@ -0,0 +1,58 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package aggregate
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+type config struct {
+	GroupBy     []string      `config:"group_by"`
+	Metrics     []string      `config:"metrics"     validate:"required"`
+	Window      time.Duration `config:"window"      validate:"nonzero,positive"`
+	Percentiles []float64     `config:"percentiles"`
+	DropEvents  bool          `config:"drop_events"`
+	MaxGroups   int           `config:"max_groups"  validate:"min=1"`
+	TargetField string        `config:"target_field"`
+	ID          string        `config:"id"`
+}
+
+// defaultPercentiles are computed when no percentiles are configured.
+var defaultPercentiles = []float64{50, 95, 99}
+
+func defaultConfig() config {
+	return config{
+		Window:      time.Minute,
+		MaxGroups:   10000,
+		TargetField: "aggregate",
+	}
+}
+
+func (c *config) Validate() error {
+	if c.TargetField == "" {
+		return errors.New("target_field cannot be empty")
+	}
+	for _, p := range c.Percentiles {
+		if p <= 0 || p > 100 {
+			return fmt.Errorf("invalid percentile %v, it must be greater than 0 and at most 100", p)
+		}
+	}
+	return nil
+}
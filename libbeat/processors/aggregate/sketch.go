@@ -0,0 +1,114 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package aggregate
+
+import (
+	"math"
+	"slices"
+)
+
+// relativeAccuracy is the maximum relative error of the quantiles returned
+// by a sketch.
+const relativeAccuracy = 0.01
+
+var (
+	gamma    = (1 + relativeAccuracy) / (1 - relativeAccuracy)
+	logGamma = math.Log(gamma)
+)
+
+// sketch estimates the quantiles of a stream of values. Values are counted
+// in buckets of exponentially growing width, so its size depends on the
+// range of the values rather than on their number.
+type sketch struct {
+	positive map[int]uint64
+	negative map[int]uint64
+	zero     uint64
+	count    uint64
+}
+
+func newSketch() *sketch {
+	return &sketch{positive: map[int]uint64{}, negative: map[int]uint64{}}
+}
+
+func (s *sketch) add(v float64) {
+	s.count++
+	switch {
+	case v > 0:
+		s.positive[bucket(v)]++
+	case v < 0:
+		s.negative[bucket(-v)]++
+	default:
+		s.zero++
+	}
+}
+
+// quantile returns the estimated value at quantile q, between 0 and 1, using
+// the nearest-rank method.
+func (s *sketch) quantile(q float64) float64 {
+	if s.count == 0 {
+		return math.NaN()
+	}
+	var rank uint64
+	if q > 0 {
+		rank = uint64(math.Ceil(q*float64(s.count))) - 1
+	}
+
+	// Walk the buckets in increasing order of value: negative buckets by
+	// decreasing index, then zero, then positive buckets by increasing index.
+	var seen uint64
+	negative := sortedKeys(s.negative)
+	for _, i := range slices.Backward(negative) {
+		seen += s.negative[i]
+		if seen > rank {
+			return -value(i)
+		}
+	}
+	seen += s.zero
+	if seen > rank {
+		return 0
+	}
+	positive := sortedKeys(s.positive)
+	for _, i := range positive {
+		seen += s.positive[i]
+		if seen > rank {
+			return value(i)
+		}
+	}
+	// Not reached: the buckets hold count values.
+	return math.NaN()
+}
+
+// bucket returns the index of the bucket holding v, greater than 0.
+func bucket(v float64) int {
+	return int(math.Ceil(math.Log(v) / logGamma))
+}
+
+// value returns the value representing the bucket i, within relativeAccuracy
+// of all the values the bucket holds.
+func value(i int) float64 {
+	return 2 * math.Pow(gamma, float64(i)) / (gamma + 1)
+}
+
+func sortedKeys(m map[int]uint64) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,63 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package aggregate
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSketchQuantiles(t *testing.T) {
+	s := newSketch()
+	values := make([]float64, 0, 10000)
+	r := rand.New(rand.NewPCG(1, 2))
+	for range 10000 {
+		v := r.ExpFloat64() * 100
+		values = append(values, v)
+		s.add(v)
+	}
+	slices.Sort(values)
+
+	for _, q := range []float64{0, 0.5, 0.9, 0.99, 1} {
+		want := values[max(0, int(math.Ceil(q*float64(len(values))))-1)]
+		got := s.quantile(q)
+		assert.InEpsilon(t, want, got, relativeAccuracy, "quantile %v", q)
+	}
+}
+
+func TestSketchSigns(t *testing.T) {
+	s := newSketch()
+	for _, v := range []float64{-100, -10, 0, 0, 10, 100} {
+		s.add(v)
+	}
+	assert.InEpsilon(t, -100, s.quantile(0), relativeAccuracy)
+	assert.InEpsilon(t, -10, s.quantile(0.3), relativeAccuracy)
+	assert.Equal(t, 0.0, s.quantile(0.5))
+	assert.InEpsilon(t, 10, s.quantile(0.7), relativeAccuracy)
+	assert.InEpsilon(t, 100, s.quantile(1), relativeAccuracy)
+}
+
+func TestSketchEmpty(t *testing.T) {
+	assert.True(t, math.IsNaN(newSketch().quantile(0.5)))
+}
@@ -102,6 +102,18 @@ func getStoreFor(cfg config, log *logp.Logger, path *paths.Path) (Store, context
 	}
 }
 
+// OpenStore returns the store of the backend configured by cfg, which has
+// the format of the backend setting of the cache processor, with entries
+// expiring after ttl. It lets other processors keep their state in the cache
+// stores. The returned context.CancelFunc releases the store.
+func OpenStore(cfg *conf.C, ttl time.Duration, log *logp.Logger, path *paths.Path) (Store, context.CancelFunc, error) {
+	var store storeConfig
+	if err := cfg.Unpack(&store); err != nil {
+		return nil, noop, fmt.Errorf("invalid cache backend configuration: %w", err)
+	}
+	return getStoreFor(config{Put: &putConfig{TTL: &ttl}, Store: &store}, log, path)
+}
+
 // noop is a no-op context.CancelFunc.
 func noop() {}
 
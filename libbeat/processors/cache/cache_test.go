@@ -21,6 +21,7 @@ import (
 	"errors"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -757,3 +758,44 @@ func TestSetPathsUninitialized(t *testing.T) {
 		t.Fatalf("expected error containing 'cache processor store not initialized', got: %v", err)
 	}
 }
+
+func TestOpenStore(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := &paths.Path{Home: tmpDir, Config: tmpDir, Data: tmpDir, Logs: tmpDir}
+	log := logptest.NewTestingLogger(t, "")
+
+	cfg := conf.MustNewConfigFrom(mapstr.M{
+		"file":     mapstr.M{"id": "open_store"},
+		"capacity": 10,
+	})
+	s, cancel, err := OpenStore(cfg, time.Hour, log, path)
+	if err != nil {
+		t.Fatalf("unexpected error from OpenStore: %v", err)
+	}
+	if got := s.String(); got != "file:open_store" {
+		t.Errorf("unexpected store: got:%s want:file:open_store", got)
+	}
+	if err := s.Put("key", "value"); err != nil {
+		t.Fatalf("unexpected error from Put: %v", err)
+	}
+	cancel()
+
+	// The state was written out when the store was released.
+	s, cancel, err = OpenStore(cfg, time.Hour, log, path)
+	if err != nil {
+		t.Fatalf("unexpected error from OpenStore: %v", err)
+	}
+	defer cancel()
+	got, err := s.Get("key")
+	if err != nil {
+		t.Fatalf("unexpected error from Get: %v", err)
+	}
+	if got != "value" {
+		t.Errorf("unexpected value: got:%v want:value", got)
+	}
+
+	_, _, err = OpenStore(conf.MustNewConfigFrom(mapstr.M{}), time.Hour, log, path)
+	if err == nil {
+		t.Error("expected error for a configuration without backend")
+	}
+}
@@ -100,11 +100,12 @@ func (r *WhenProcessor) Run(event *beat.Event) (*beat.Event, error) {
 }
 
 func (r *WhenProcessor) SetPaths(paths *paths.Path) error {
+	err := conditions.SetPaths(r.condition, paths)
 	pathSetter, ok := r.p.(PathSetter)
 	if ok {
-		return pathSetter.SetPaths(paths)
+		err = errors.Join(err, pathSetter.SetPaths(paths))
 	}
-	return nil
+	return err
 }
 
 func (r *WhenProcessor) String() string {
@@ -194,6 +195,12 @@ type IfThenElseProcessor struct {
 
 // NewIfElseThenProcessor construct a new IfThenElseProcessor.
 func NewIfElseThenProcessor(cfg *config.C, logger *logp.Logger) (beat.Processor, error) {
+	return newIfElseThenProcessor(cfg, DefaultOwner+".if", logger)
+}
+
+// newIfElseThenProcessor constructs an IfThenElseProcessor reporting the
+// metrics of its processors under owner, followed by then or else.
+func newIfElseThenProcessor(cfg *config.C, owner string, logger *logp.Logger) (beat.Processor, error) {
 	var c ifThenElseConfig
 	if err := cfg.Unpack(&c); err != nil {
 		return nil, err
@@ -204,26 +211,26 @@ func NewIfElseThenProcessor(cfg *config.C, logger *logp.Logger) (beat.Processor,
 		return nil, err
 	}
 
-	newProcessors := func(c *config.C) (*Processors, error) {
+	newProcessors := func(c *config.C, owner string) (*Processors, error) {
 		if c == nil {
 			return nil, nil
 		}
 		if !c.IsArray() {
-			return New([]*config.C{c}, logger)
+			return NewWithOwner([]*config.C{c}, owner, logger)
 		}
 
 		var pc PluginConfig
 		if err := c.Unpack(&pc); err != nil {
 			return nil, err
 		}
-		return New(pc, logger)
+		return NewWithOwner(pc, owner, logger)
 	}
 
 	var ifProcessors, elseProcessors *Processors
-	if ifProcessors, err = newProcessors(c.Then); err != nil {
+	if ifProcessors, err = newProcessors(c.Then, owner+".then"); err != nil {
 		return nil, err
 	}
-	if elseProcessors, err = newProcessors(c.Else); err != nil {
+	if elseProcessors, err = newProcessors(c.Else, owner+".else"); err != nil {
 		// The 'then' processors were already constructed and may hold
 		// resources (or references to shared instances): release them.
 		if ifProcessors != nil {
@@ -268,7 +275,7 @@ func (p *IfThenElseProcessor) Run(event *beat.Event) (*beat.Event, error) {
 }
 
 func (p *IfThenElseProcessor) SetPaths(paths *paths.Path) error {
-	var err error
+	err := conditions.SetPaths(p.cond, paths)
 	for _, proc := range p.then.List {
 		if procWithSet, ok := proc.(PathSetter); ok {
 			err = errors.Join(err, procWithSet.SetPaths(paths))
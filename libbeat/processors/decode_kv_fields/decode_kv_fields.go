@@ -0,0 +1,208 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package decode_kv_fields
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common/jsontransform"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	"github.com/elastic/beats/v7/libbeat/processors/checks"
+	jsprocessor "github.com/elastic/beats/v7/libbeat/processors/script/javascript/module/processor/registry"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const procName = "decode_kv_fields"
+
+type decodeKVFields struct {
+	kvConfig
+	parser  *parser
+	include map[string]struct{}
+	exclude map[string]struct{}
+	logger  *logp.Logger
+}
+
+type kvConfig struct {
+	Fields        []string `config:"fields"`
+	Target        *string  `config:"target"`
+	FieldSplit    string   `config:"field_split"`
+	ValueSplit    string   `config:"value_split"`
+	QuoteChars    string   `config:"quote_chars"`
+	EscapeChar    string   `config:"escape_char"`
+	IncludeKeys   []string `config:"include_keys"`
+	ExcludeKeys   []string `config:"exclude_keys"`
+	Prefix        string   `config:"prefix"`
+	OverwriteKeys bool     `config:"overwrite_keys"`
+	AddErrorKey   bool     `config:"add_error_key"`
+	IgnoreFailure bool     `config:"ignore_failure"`
+}
+
+var defaultKVConfig = kvConfig{
+	FieldSplit: " ",
+	ValueSplit: "=",
+	QuoteChars: `"'`,
+	EscapeChar: `\`,
+}
+
+func init() {
+	processors.RegisterPlugin(procName,
+		checks.ConfigChecked(NewDecodeKVFields,
+			checks.RequireFields("fields"),
+			checks.AllowedFields("fields", "target", "field_split", "value_split", "quote_chars", "escape_char",
+				"include_keys", "exclude_keys", "prefix", "overwrite_keys", "add_error_key", "ignore_failure", "when")))
+
+	jsprocessor.RegisterPlugin("DecodeKVFields", NewDecodeKVFields)
+}
+
+// NewDecodeKVFields constructs a new decode_kv_fields processor.
+func NewDecodeKVFields(c *config.C, log *logp.Logger) (beat.Processor, error) {
+	config := defaultKVConfig
+	if err := c.Unpack(&config); err != nil {
+		return nil, fmt.Errorf("fail to unpack the %v configuration: %w", procName, err)
+	}
+	if len(config.Fields) == 0 {
+		return nil, errors.New("no fields to decode configured")
+	}
+
+	p, err := newParser(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %v configuration: %w", procName, err)
+	}
+	return &decodeKVFields{
+		kvConfig: config,
+		parser:   p,
+		include:  keySet(config.IncludeKeys),
+		exclude:  keySet(config.ExcludeKeys),
+		logger:   log.Named(procName),
+	}, nil
+}
+
+func keySet(keys []string) map[string]struct{} {
+	if len(keys) == 0 {
+		return nil
+	}
+	set := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		set[k] = struct{}{}
+	}
+	return set
+}
+
+// Run applies the decode_kv_fields processor to an event.
+func (f *decodeKVFields) Run(event *beat.Event) (*beat.Event, error) {
+	var errs []string
+
+	for _, field := range f.Fields {
+		data, err := event.GetValue(field)
+		if err != nil && !errors.Is(err, mapstr.ErrKeyNotFound) {
+			errs = append(errs, err.Error())
+			continue
+		}
+
+		text, ok := data.(string)
+		if !ok {
+			// ignore missing and non string fields
+			continue
+		}
+
+		pairs, err := f.parser.parse(text)
+		if err != nil {
+			f.logger.Debugf("Error trying to parse key/value pairs from %s", text)
+			errs = append(errs, fmt.Sprintf("parsing field %s: %v", field, err))
+			f.setError(event, fmt.Sprintf("parsing input as key/value pairs: %v", err), text, field)
+			continue
+		}
+
+		output := f.collect(pairs)
+
+		target := field
+		if f.Target != nil {
+			target = *f.Target
+		}
+		if target == "" {
+			jsontransform.WriteJSONKeys(event, output, false, f.OverwriteKeys, f.AddErrorKey)
+			continue
+		}
+		if target != field && !f.OverwriteKeys {
+			if _, err := event.GetValue(target); err == nil {
+				errs = append(errs, fmt.Sprintf("target field %s already has a value. Set the overwrite_keys flag or drop/rename the field first", target))
+				continue
+			}
+		}
+		if _, err := event.PutValue(target, output); err != nil {
+			errs = append(errs, fmt.Sprintf("failed setting field %s: %v", target, err))
+		}
+	}
+
+	if len(errs) > 0 && !f.IgnoreFailure {
+		return event, errors.New(strings.Join(errs, ", "))
+	}
+	return event, nil
+}
+
+// collect turns the pairs into a map, applying the key filters and the
+// prefix. The values of repeated keys are combined into an array.
+func (f *decodeKVFields) collect(pairs []pair) mapstr.M {
+	output := make(mapstr.M, len(pairs))
+	for _, kv := range pairs {
+		if f.include != nil {
+			if _, ok := f.include[kv.key]; !ok {
+				continue
+			}
+		}
+		if _, ok := f.exclude[kv.key]; ok {
+			continue
+		}
+
+		key := f.Prefix + kv.key
+		switch prev := output[key].(type) {
+		case nil:
+			output[key] = kv.value
+		case string:
+			output[key] = []string{prev, kv.value}
+		case []string:
+			output[key] = append(prev, kv.value)
+		}
+	}
+	return output
+}
+
+// setError adds the error key to the event if add_error_key is enabled.
+func (f *decodeKVFields) setError(event *beat.Event, message, data, field string) {
+	if !f.AddErrorKey {
+		return
+	}
+	event.Fields[beat.ErrorFieldKey] = mapstr.M{
+		"message": message,
+		"type":    "kv",
+		"data":    data,
+		"field":   field,
+	}
+}
+
+// String returns a string representation of this processor.
+func (f *decodeKVFields) String() string {
+	json, _ := json.Marshal(f.kvConfig)
+	return procName + "=" + string(json)
+}
@@ -0,0 +1,170 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package decode_kv_fields
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	cfg "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestDecodeKVFields(t *testing.T) {
+	tests := map[string]struct {
+		config   mapstr.M
+		input    mapstr.M
+		expected mapstr.M
+		fail     bool
+	}{
+		"replace field": {
+			config: mapstr.M{"fields": []string{"message"}},
+			input:  mapstr.M{"message": "a=1 b=2"},
+			expected: mapstr.M{
+				"message": mapstr.M{"a": "1", "b": "2"},
+			},
+		},
+		"target field": {
+			config: mapstr.M{"fields": []string{"message"}, "target": "kv"},
+			input:  mapstr.M{"message": "a=1 b=2"},
+			expected: mapstr.M{
+				"message": "a=1 b=2",
+				"kv":      mapstr.M{"a": "1", "b": "2"},
+			},
+		},
+		"root target": {
+			config: mapstr.M{"fields": []string{"message"}, "target": ""},
+			input:  mapstr.M{"message": "a=1 b=2", "b": "old"},
+			expected: mapstr.M{
+				"message": "a=1 b=2",
+				"a":       "1",
+				"b":       "old",
+			},
+		},
+		"root target with overwrite_keys": {
+			config: mapstr.M{"fields": []string{"message"}, "target": "", "overwrite_keys": true},
+			input:  mapstr.M{"message": "a=1 b=2", "b": "old"},
+			expected: mapstr.M{
+				"message": "a=1 b=2",
+				"a":       "1",
+				"b":       "2",
+			},
+		},
+		"existing target": {
+			config: mapstr.M{"fields": []string{"message"}, "target": "kv"},
+			input:  mapstr.M{"message": "a=1", "kv": "old"},
+			expected: mapstr.M{
+				"message": "a=1",
+				"kv":      "old",
+			},
+			fail: true,
+		},
+		"existing target with overwrite_keys": {
+			config: mapstr.M{"fields": []string{"message"}, "target": "kv", "overwrite_keys": true},
+			input:  mapstr.M{"message": "a=1", "kv": "old"},
+			expected: mapstr.M{
+				"message": "a=1",
+				"kv":      mapstr.M{"a": "1"},
+			},
+		},
+		"include and exclude keys with prefix": {
+			config: mapstr.M{
+				"fields":       []string{"message"},
+				"target":       "kv",
+				"include_keys": []string{"a", "b", "c"},
+				"exclude_keys": []string{"b"},
+				"prefix":       "x_",
+			},
+			input: mapstr.M{"message": "a=1 b=2 c=3 d=4"},
+			expected: mapstr.M{
+				"message": "a=1 b=2 c=3 d=4",
+				"kv":      mapstr.M{"x_a": "1", "x_c": "3"},
+			},
+		},
+		"repeated keys": {
+			config: mapstr.M{"fields": []string{"message"}, "target": "kv"},
+			input:  mapstr.M{"message": "tag=a tag=b tag=c"},
+			expected: mapstr.M{
+				"message": "tag=a tag=b tag=c",
+				"kv":      mapstr.M{"tag": []string{"a", "b", "c"}},
+			},
+		},
+		"missing and non string fields": {
+			config:   mapstr.M{"fields": []string{"message", "count"}},
+			input:    mapstr.M{"count": 1},
+			expected: mapstr.M{"count": 1},
+		},
+		"parse error": {
+			config:   mapstr.M{"fields": []string{"message"}},
+			input:    mapstr.M{"message": `a="1`},
+			expected: mapstr.M{"message": `a="1`},
+			fail:     true,
+		},
+		"parse error with add_error_key": {
+			config: mapstr.M{"fields": []string{"message"}, "add_error_key": true},
+			input:  mapstr.M{"message": `a="1`},
+			expected: mapstr.M{
+				"message": `a="1`,
+				"error": mapstr.M{
+					"message": `parsing input as key/value pairs: invalid value of key a: missing closing quote "`,
+					"type":    "kv",
+					"data":    `a="1`,
+					"field":   "message",
+				},
+			},
+			fail: true,
+		},
+		"parse error with ignore_failure": {
+			config:   mapstr.M{"fields": []string{"message"}, "ignore_failure": true},
+			input:    mapstr.M{"message": `a="1`},
+			expected: mapstr.M{"message": `a="1`},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			p, err := NewDecodeKVFields(cfg.MustNewConfigFrom(tc.config), logptest.NewTestingLogger(t, ""))
+			require.NoError(t, err)
+
+			result, err := p.Run(&beat.Event{Fields: tc.input})
+			if tc.fail {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expected.Flatten(), result.Fields.Flatten())
+		})
+	}
+}
+
+func TestDecodeKVFieldsConfig(t *testing.T) {
+	for name, c := range map[string]mapstr.M{
+		"no fields":       {"fields": []string{}},
+		"same separators": {"fields": []string{"message"}, "field_split": "="},
+		"long escape":     {"fields": []string{"message"}, "escape_char": "\\\\"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewDecodeKVFields(cfg.MustNewConfigFrom(c), logptest.NewTestingLogger(t, ""))
+			assert.Error(t, err)
+		})
+	}
+}
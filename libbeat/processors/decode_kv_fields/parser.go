@@ -0,0 +1,128 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package decode_kv_fields
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// parser splits strings into key/value pairs.
+type parser struct {
+	fieldSplit string
+	valueSplit string
+	quoteChars string
+	escapeChar rune // 0 if values can't be escaped
+}
+
+type pair struct {
+	key, value string
+}
+
+func newParser(c kvConfig) (*parser, error) {
+	p := &parser{
+		fieldSplit: c.FieldSplit,
+		valueSplit: c.ValueSplit,
+		quoteChars: c.QuoteChars,
+	}
+	if p.fieldSplit == "" || p.valueSplit == "" {
+		return nil, errors.New("field_split and value_split can't be empty")
+	}
+	if p.fieldSplit == p.valueSplit {
+		return nil, errors.New("field_split and value_split must be different")
+	}
+	switch runes := []rune(c.EscapeChar); len(runes) {
+	case 0:
+	case 1:
+		p.escapeChar = runes[0]
+	default:
+		return nil, fmt.Errorf("escape_char must be a single character, got '%s'", c.EscapeChar)
+	}
+	return p, nil
+}
+
+// parse returns the key/value pairs of s in order. Tokens without a value
+// separator are skipped.
+func (p *parser) parse(s string) ([]pair, error) {
+	var pairs []pair
+	for s != "" {
+		if strings.HasPrefix(s, p.fieldSplit) {
+			s = s[len(p.fieldSplit):]
+			continue
+		}
+
+		key, rest, err := p.token(s, p.valueSplit)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(rest, p.valueSplit) {
+			// A token without value.
+			s = rest
+			continue
+		}
+
+		value, rest, err := p.token(rest[len(p.valueSplit):], "")
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of key %s: %w", key, err)
+		}
+		if key != "" {
+			pairs = append(pairs, pair{key: key, value: value})
+		}
+		s = rest
+	}
+	return pairs, nil
+}
+
+// token reads a key or a value from the start of s, up to the field
+// separator or stop. It returns the unquoted token and the rest of s.
+func (p *parser) token(s, stop string) (string, string, error) {
+	var b strings.Builder
+	var quote rune
+	escaped := false
+	for i, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+			b.WriteRune(r)
+		case p.escapeChar != 0 && r == p.escapeChar:
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				b.WriteRune(r)
+			}
+		case strings.ContainsRune(p.quoteChars, r):
+			quote = r
+		case strings.HasPrefix(s[i:], p.fieldSplit):
+			return b.String(), s[i:], nil
+		case stop != "" && strings.HasPrefix(s[i:], stop):
+			return b.String(), s[i:], nil
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if quote != 0 {
+		return "", "", fmt.Errorf("missing closing quote %c", quote)
+	}
+	if escaped {
+		b.WriteRune(p.escapeChar)
+	}
+	return b.String(), "", nil
+}
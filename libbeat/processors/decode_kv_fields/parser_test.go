@@ -0,0 +1,111 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package decode_kv_fields
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := map[string]struct {
+		config kvConfig
+		input  string
+		want   []pair
+		err    string
+	}{
+		"simple": {
+			input: "a=1 b=2  c=3",
+			want:  []pair{{"a", "1"}, {"b", "2"}, {"c", "3"}},
+		},
+		"quoted values": {
+			input: `msg="hello world" user='john doe' empty=""`,
+			want:  []pair{{"msg", "hello world"}, {"user", "john doe"}, {"empty", ""}},
+		},
+		"escaped characters": {
+			input: `msg="say \"hi\"" path=a\ b`,
+			want:  []pair{{"msg", `say "hi"`}, {"path", "a b"}},
+		},
+		"quoted keys": {
+			input: `"src ip"=10.0.0.1`,
+			want:  []pair{{"src ip", "10.0.0.1"}},
+		},
+		"tokens without value are skipped": {
+			input: "CEF: act=blocked flag =x",
+			want:  []pair{{"act", "blocked"}},
+		},
+		"value containing the value separator": {
+			input: "q=a=b",
+			want:  []pair{{"q", "a=b"}},
+		},
+		"custom separators": {
+			config: kvConfig{FieldSplit: "&", ValueSplit: ":", QuoteChars: `"`},
+			input:  "a:1&b:it's&&c:",
+			want:   []pair{{"a", "1"}, {"b", "it's"}, {"c", ""}},
+		},
+		"multi character separators": {
+			config: kvConfig{FieldSplit: ", ", ValueSplit: "=>"},
+			input:  "a=>1, b=>x,y",
+			want:   []pair{{"a", "1"}, {"b", "x,y"}},
+		},
+		"no escape character": {
+			config: kvConfig{FieldSplit: " ", ValueSplit: "=", QuoteChars: `"`},
+			input:  `path=C:\tmp`,
+			want:   []pair{{"path", `C:\tmp`}},
+		},
+		"unterminated quote": {
+			input: `a=1 msg="oops`,
+			err:   `invalid value of key msg: missing closing quote "`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := tc.config
+			if c.FieldSplit == "" {
+				c = defaultKVConfig
+			}
+			p, err := newParser(c)
+			require.NoError(t, err)
+
+			got, err := p.parse(tc.input)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestNewParserErrors(t *testing.T) {
+	for name, c := range map[string]kvConfig{
+		"empty field_split": {ValueSplit: "="},
+		"empty value_split": {FieldSplit: " "},
+		"same separators":   {FieldSplit: "=", ValueSplit: "="},
+		"long escape_char":  {FieldSplit: " ", ValueSplit: "=", EscapeChar: "ab"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := newParser(c)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,42 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dedup
+
+import (
+	"time"
+
+	conf "github.com/elastic/elastic-agent-libs/config"
+)
+
+type config struct {
+	Fields        []string      `config:"fields" validate:"required"`
+	TTL           time.Duration `config:"ttl"    validate:"nonzero,positive"`
+	TargetField   string        `config:"target_field"`
+	Backend       *conf.C       `config:"backend"`
+	MaxEntries    int           `config:"max_entries" validate:"min=1"`
+	IgnoreMissing bool          `config:"ignore_missing"`
+	ID            string        `config:"id"`
+}
+
+func defaultConfig() config {
+	return config{
+		TTL:         time.Minute,
+		TargetField: "dedup.previous_window_duplicates",
+		MaxEntries:  100000,
+	}
+}
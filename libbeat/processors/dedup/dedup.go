@@ -0,0 +1,445 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dedup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	"github.com/elastic/beats/v7/libbeat/processors/cache"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/monitoring"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+const (
+	procName = "dedup"
+	logName  = "processor." + procName
+)
+
+// instanceID is used to assign each instance a unique monitoring namespace
+// and memory store.
+var instanceID atomic.Uint32
+
+func init() {
+	// We cannot use this as a JS plugin as it is stateful and includes a Close method.
+	processors.RegisterPlugin(procName, New)
+}
+
+type metrics struct {
+	Dropped *monitoring.Int
+}
+
+// summary marks the events published by dedup processors in their Private
+// field, so they are not deduplicated against the events they count.
+type summary struct{}
+
+type processor struct {
+	config
+	log     *logp.Logger
+	id      int
+	metrics metrics
+
+	// mu makes the check and the update of an entry atomic.
+	mu     sync.Mutex
+	store  cache.Store // initialized in SetPaths
+	cancel context.CancelFunc
+	// pending are the windows with dropped duplicates, by key.
+	pending    map[string]*window
+	paths      *paths.Path
+	removeHook func()
+
+	// publishMu serializes the publications of the duplicate counts. client
+	// is connected on the first one, and disconnected is set once the
+	// pipeline is closing.
+	publishMu    sync.Mutex
+	client       beat.Client
+	disconnected bool
+
+	now       func() time.Time
+	done      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// window is a window in which duplicates were dropped, with the values of
+// the fields of its events.
+type window struct {
+	first  time.Time
+	values mapstr.M
+}
+
+// entry is the state of a key in the store. Stores persisted to files
+// return entries decoded from JSON, as map[string]any.
+type entry struct {
+	// First is the time of the event that opened the window.
+	First time.Time `json:"first"`
+	// Dropped is the number of duplicates dropped in the window.
+	Dropped int64 `json:"dropped"`
+}
+
+// New constructs a new dedup processor.
+func New(cfg *conf.C, log *logp.Logger) (beat.Processor, error) {
+	c := defaultConfig()
+	if err := cfg.Unpack(&c); err != nil {
+		return nil, fmt.Errorf("fail to unpack the %v processor configuration: %w", procName, err)
+	}
+
+	p, err := newDedup(c, log)
+	if err != nil {
+		return nil, err
+	}
+	if p.TargetField != "" {
+		p.start()
+	}
+	return p, nil
+}
+
+func newDedup(c config, logger *logp.Logger) (*processor, error) {
+	// Sort the fields so their order doesn't change the keys.
+	c.Fields = slices.Clone(c.Fields)
+	slices.Sort(c.Fields)
+
+	var (
+		id  = int(instanceID.Add(1))
+		reg = monitoring.Default.GetOrCreateRegistry(logName+"."+strconv.Itoa(id), monitoring.DoNotReport)
+	)
+
+	log := logger.Named(logName)
+	if c.ID != "" {
+		log = log.With("instance_id", c.ID)
+	}
+	return &processor{
+		config: c,
+		log:    log,
+		id:     id,
+		metrics: metrics{
+			Dropped: monitoring.NewInt(reg, "dropped"),
+		},
+		pending: map[string]*window{},
+		now:     time.Now,
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// start publishes the duplicate counts of the ended windows in the
+// background until the processor is closed.
+func (p *processor) start() {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(p.TTL)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.done:
+				return
+			case <-ticker.C:
+				p.flush(p.now(), false)
+			}
+		}
+	}()
+}
+
+// SetPaths opens the store with the provided paths configuration, and sets the
+// paths of the beat, which the pipeline the duplicate counts are published to
+// is registered with. This method must be called before the processor can be
+// used.
+func (p *processor) SetPaths(path *paths.Path) error {
+	settings := map[string]any{
+		"memory": map[string]any{"id": procName + "-" + strconv.Itoa(p.id)},
+	}
+	if p.Backend != nil {
+		settings = map[string]any{}
+		if err := p.Backend.Unpack(&settings); err != nil {
+			return fmt.Errorf("invalid %v processor backend: %w", procName, err)
+		}
+	}
+	// The capacity of the backend takes precedence over max_entries.
+	if _, ok := settings["capacity"]; !ok {
+		settings["capacity"] = p.MaxEntries
+	}
+	backend, err := conf.NewConfigFrom(settings)
+	if err != nil {
+		return fmt.Errorf("invalid %v processor backend: %w", procName, err)
+	}
+
+	// Entries outlive their window, so the number of duplicates of a
+	// window can be added to the first event of the next one.
+	store, cancel, err := cache.OpenStore(backend, 2*p.TTL, p.log, path)
+	if err != nil {
+		return fmt.Errorf("%v processor could not open its store: %w", procName, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.store = store
+	p.cancel = cancel
+	p.paths = path
+	p.removeHook = processors.OnPipelineClose(path, p.pipelineClosing)
+	return nil
+}
+
+// pipelineClosing publishes the duplicate counts of all the windows when the
+// pipeline is disconnected, while the processors still run. The counts of the
+// duplicates dropped later are left in the store.
+func (p *processor) pipelineClosing() {
+	p.flush(p.now(), true)
+	p.publishMu.Lock()
+	p.disconnected = true
+	p.publishMu.Unlock()
+}
+
+// Unshareable opts dedup out of process-wide processor sharing, so the
+// events of different owners are not deduplicated against each other.
+func (p *processor) Unshareable() {}
+
+func (p *processor) String() string {
+	return fmt.Sprintf("%v=[fields=%v, ttl=%v, target_field=%v, max_entries=%v, store=%v]", procName, p.Fields, p.TTL, p.TargetField, p.MaxEntries, p.store)
+}
+
+// Run drops the event if an event with the same values of the fields opened
+// a window less than ttl before. The event opening a window is annotated with
+// the number of duplicates dropped in the previous window. Duplicate count
+// events are passed unchanged.
+func (p *processor) Run(event *beat.Event) (*beat.Event, error) {
+	if _, ok := event.Private.(summary); ok {
+		return event, nil
+	}
+	if p.store == nil {
+		return event, fmt.Errorf("%v processor store not initialized", procName)
+	}
+
+	key, err := p.key(event)
+	if err != nil {
+		return event, err
+	}
+	if key == "" {
+		return event, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	prev, found := p.get(key)
+	if found && now.Sub(prev.First) < p.TTL {
+		prev.Dropped++
+		if err := p.store.Put(key, prev); err != nil {
+			return event, fmt.Errorf("failed to update the %v store: %w", procName, err)
+		}
+		if _, ok := p.pending[key]; !ok && p.TargetField != "" {
+			p.pending[key] = &window{first: prev.First, values: p.values(event)}
+		}
+		p.metrics.Dropped.Inc()
+		return nil, nil
+	}
+
+	if err := p.store.Put(key, entry{First: now}); err != nil {
+		return event, fmt.Errorf("failed to update the %v store: %w", procName, err)
+	}
+	delete(p.pending, key)
+	if found && prev.Dropped > 0 && p.TargetField != "" {
+		if _, err := event.PutValue(p.TargetField, prev.Dropped); err != nil {
+			return event, fmt.Errorf("failed to set the %v field: %w", p.TargetField, err)
+		}
+	}
+	return event, nil
+}
+
+// key returns the hash of the values of the fields in the event, or an empty
+// string if the event has none of the fields.
+func (p *processor) key(event *beat.Event) (string, error) {
+	h := sha256.New()
+	found := false
+	for _, field := range p.Fields {
+		v, err := event.GetValue(field)
+		if err != nil {
+			if errors.Is(err, mapstr.ErrKeyNotFound) && p.IgnoreMissing {
+				continue
+			}
+			return "", fmt.Errorf("failed to get the %v field: %w", field, err)
+		}
+		found = true
+		fmt.Fprintf(h, "|%s|%v", field, v)
+	}
+	if !found {
+		return "", nil
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// values returns the values of the fields in the event.
+func (p *processor) values(event *beat.Event) mapstr.M {
+	values := mapstr.M{}
+	for _, field := range p.Fields {
+		if v, err := event.GetValue(field); err == nil {
+			_, _ = values.Put(field, v)
+		}
+	}
+	return values
+}
+
+// flush publishes the duplicate counts of the windows which ended before now,
+// or of all the windows if all is set, in events of their own, and resets the
+// counts in the store. The counts are left in the store, to be added to the
+// next event kept, if the processor is not connected to a pipeline.
+func (p *processor) flush(now time.Time, all bool) {
+	p.publishMu.Lock()
+	defer p.publishMu.Unlock()
+
+	p.mu.Lock()
+	var keys []string
+	for key, w := range p.pending {
+		if all || now.Sub(w.first) >= p.TTL {
+			keys = append(keys, key)
+		}
+	}
+	paths := p.paths
+	p.mu.Unlock()
+	if len(keys) == 0 {
+		return
+	}
+
+	client := p.connect(paths)
+
+	p.mu.Lock()
+	var events []beat.Event
+	for _, key := range keys {
+		w, ok := p.pending[key]
+		if !ok {
+			// An event opened a new window in the meantime.
+			continue
+		}
+		delete(p.pending, key)
+		if client == nil {
+			continue
+		}
+		e, found := p.get(key)
+		if !found || !e.First.Equal(w.first) || e.Dropped == 0 {
+			continue
+		}
+		if err := p.store.Put(key, entry{First: e.First}); err != nil {
+			p.log.Errorf("Failed to reset the duplicate count in the %v store: %v", procName, err)
+			continue
+		}
+		fields := w.values
+		_, _ = fields.Put(p.TargetField, e.Dropped)
+		events = append(events, beat.Event{Timestamp: w.first, Fields: fields, Private: summary{}})
+	}
+	p.mu.Unlock()
+
+	if len(events) > 0 {
+		client.PublishAll(events)
+	}
+}
+
+// connect returns the client publishing the duplicate counts, connecting it
+// to the pipeline registered for paths on first use, or nil if the processor
+// can't publish. Callers must hold p.publishMu.
+func (p *processor) connect(paths *paths.Path) beat.Client {
+	if p.disconnected {
+		return nil
+	}
+	if p.client == nil {
+		pipeline := processors.LookupPipeline(paths)
+		if pipeline == nil {
+			p.log.Debug("The duplicate counts are left in the store: the processor is not connected to a pipeline.")
+			return nil
+		}
+		// The counts don't come from an input, so they are published
+		// without the settings of the input the processor may be
+		// configured in.
+		client, err := pipeline.ConnectWith(beat.ClientConfig{})
+		if err != nil {
+			p.log.Errorf("Failed to connect to the pipeline, the duplicate counts are left in the store: %v", err)
+			return nil
+		}
+		p.client = client
+	}
+	return p.client
+}
+
+// get returns the entry of key in the store.
+func (p *processor) get(key string) (entry, bool) {
+	v, err := p.store.Get(key)
+	if err != nil {
+		return entry{}, false
+	}
+	switch v := v.(type) {
+	case entry:
+		return v, true
+	case map[string]any:
+		// Entry read from a file.
+		var e entry
+		first, _ := v["first"].(string)
+		if e.First, err = time.Parse(time.RFC3339Nano, first); err != nil {
+			return entry{}, false
+		}
+		dropped, _ := v["dropped"].(float64)
+		e.Dropped = int64(dropped)
+		return e, true
+	default:
+		return entry{}, false
+	}
+}
+
+// Close publishes the duplicate counts of all the windows, disconnects from
+// the pipeline and releases the store. Global processors publish the counts
+// when the pipeline is closing instead, as the count events would go through
+// the processors being closed.
+func (p *processor) Close() error {
+	var err error
+	p.closeOnce.Do(func() {
+		p.mu.Lock()
+		if p.removeHook != nil {
+			p.removeHook()
+		}
+		p.mu.Unlock()
+
+		close(p.done)
+		p.wg.Wait()
+		p.flush(p.now(), true)
+
+		p.publishMu.Lock()
+		if p.client != nil {
+			err = p.client.Close()
+		}
+		p.publishMu.Unlock()
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.cancel != nil {
+			p.cancel()
+			p.cancel = nil
+		}
+	})
+	return err
+}
@@ -0,0 +1,250 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package dedup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	pubtest "github.com/elastic/beats/v7/libbeat/publisher/testing"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+func run(t *testing.T, p beat.Processor, fields mapstr.M) *beat.Event {
+	t.Helper()
+
+	event, err := p.Run(&beat.Event{Fields: fields})
+	require.NoError(t, err)
+	return event
+}
+
+func TestDedup(t *testing.T) {
+	p, err := New(conf.MustNewConfigFrom(mapstr.M{"fields": []string{"message", "host.name"}, "ttl": "1m"}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	require.NoError(t, p.(processors.PathSetter).SetPaths(&paths.Path{Data: t.TempDir()}))
+	t.Cleanup(func() { require.NoError(t, processors.Close(p)) })
+	now := time.Now()
+	p.(*processor).now = func() time.Time { return now }
+
+	line := mapstr.M{"message": "link down", "host": mapstr.M{"name": "sw1"}}
+	assert.NotNil(t, run(t, p, line.Clone()), "first event")
+	assert.Nil(t, run(t, p, line.Clone()), "duplicate")
+
+	now = now.Add(30 * time.Second)
+	assert.Nil(t, run(t, p, line.Clone()), "duplicate in the window")
+	assert.NotNil(t, run(t, p, mapstr.M{"message": "link down", "host": mapstr.M{"name": "sw2"}}), "other host")
+
+	now = now.Add(31 * time.Second)
+	event := run(t, p, line.Clone())
+	require.NotNil(t, event, "first event of the next window")
+	assert.Equal(t, mapstr.M{
+		"message": "link down",
+		"host":    mapstr.M{"name": "sw1"},
+		"dedup":   mapstr.M{"previous_window_duplicates": int64(2)},
+	}, event.Fields)
+
+	now = now.Add(2 * time.Minute)
+	event = run(t, p, line.Clone())
+	require.NotNil(t, event)
+	assert.NotContains(t, event.Fields, "dedup", "no duplicates in the previous window")
+
+	assert.Equal(t, int64(2), p.(*processor).metrics.Dropped.Get())
+}
+
+// connect registers a pipeline publishing to the returned client for the
+// paths of p.
+func connect(t *testing.T, p *processor) *pubtest.ChanClient {
+	t.Helper()
+	client := pubtest.NewChanClient(100)
+	beatPaths := &paths.Path{Data: t.TempDir()}
+	t.Cleanup(processors.RegisterPipeline(beatPaths, pubtest.PublisherWithClient(client)))
+	require.NoError(t, p.SetPaths(beatPaths))
+	return client
+}
+
+func received(client *pubtest.ChanClient) []beat.Event {
+	var events []beat.Event
+	for {
+		select {
+		case e := <-client.Channel:
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+func TestDedupPublishesCounts(t *testing.T) {
+	proc, err := New(conf.MustNewConfigFrom(mapstr.M{"fields": []string{"message"}, "ttl": "1m"}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	p := proc.(*processor)
+	client := connect(t, p)
+	start := time.Now()
+	now := start
+	p.now = func() time.Time { return now }
+
+	assert.NotNil(t, run(t, p, mapstr.M{"message": "link down", "log": "a"}))
+	assert.Nil(t, run(t, p, mapstr.M{"message": "link down", "log": "b"}))
+	assert.Nil(t, run(t, p, mapstr.M{"message": "link down", "log": "c"}))
+
+	p.flush(now.Add(30*time.Second), false)
+	assert.Empty(t, received(client), "the window has not ended")
+
+	now = now.Add(time.Minute)
+	p.flush(now, false)
+	events := received(client)
+	require.Len(t, events, 1, "no event opened the next window")
+	assert.Equal(t, start, events[0].Timestamp)
+	assert.Equal(t, mapstr.M{
+		"message": "link down",
+		"dedup":   mapstr.M{"previous_window_duplicates": int64(2)},
+	}, events[0].Fields)
+	out, err := p.Run(&events[0])
+	require.NoError(t, err)
+	assert.Same(t, &events[0], out, "count events are not deduplicated")
+
+	event := run(t, p, mapstr.M{"message": "link down"})
+	require.NotNil(t, event)
+	assert.NotContains(t, event.Fields, "dedup", "the count was published")
+
+	// The counts of the open windows are published on close.
+	assert.Nil(t, run(t, p, mapstr.M{"message": "link down"}))
+	require.NoError(t, p.Close())
+	events = received(client)
+	require.Len(t, events, 1)
+	assert.Equal(t, int64(1), events[0].Fields["dedup"].(mapstr.M)["previous_window_duplicates"])
+}
+
+func TestDedupPipelineClosing(t *testing.T) {
+	proc, err := New(conf.MustNewConfigFrom(mapstr.M{"fields": []string{"message"}}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	p := proc.(*processor)
+
+	client := pubtest.NewChanClient(100)
+	beatPaths := &paths.Path{Data: t.TempDir()}
+	closing := processors.RegisterPipeline(beatPaths, pubtest.PublisherWithClient(client))
+	require.NoError(t, p.SetPaths(beatPaths))
+
+	assert.NotNil(t, run(t, p, mapstr.M{"message": "hello"}))
+	assert.Nil(t, run(t, p, mapstr.M{"message": "hello"}))
+	closing()
+	assert.Len(t, received(client), 1, "the count is published when the pipeline is closing")
+
+	// Later counts are left in the store.
+	assert.Nil(t, run(t, p, mapstr.M{"message": "hello"}))
+	require.NoError(t, p.Close())
+	assert.Empty(t, received(client))
+}
+
+func TestDedupMissingFields(t *testing.T) {
+	p, err := New(conf.MustNewConfigFrom(mapstr.M{"fields": []string{"message", "host.name"}}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	require.NoError(t, p.(processors.PathSetter).SetPaths(&paths.Path{Data: t.TempDir()}))
+	t.Cleanup(func() { require.NoError(t, processors.Close(p)) })
+	_, err = p.Run(&beat.Event{Fields: mapstr.M{"message": "hello"}})
+	assert.Error(t, err)
+
+	ignoring, err := New(conf.MustNewConfigFrom(mapstr.M{"fields": []string{"message", "host.name"}, "ignore_missing": true}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	require.NoError(t, ignoring.(processors.PathSetter).SetPaths(&paths.Path{Data: t.TempDir()}))
+	t.Cleanup(func() { require.NoError(t, processors.Close(ignoring)) })
+	assert.NotNil(t, run(t, ignoring, mapstr.M{"message": "hello"}))
+	assert.Nil(t, run(t, ignoring, mapstr.M{"message": "hello"}))
+	assert.NotNil(t, run(t, ignoring, mapstr.M{"other": "x"}), "events without the fields are kept")
+	assert.NotNil(t, run(t, ignoring, mapstr.M{"other": "x"}), "events without the fields are kept")
+}
+
+func TestDedupFileBackend(t *testing.T) {
+	dataDir := t.TempDir()
+	cfg := mapstr.M{
+		"fields":  []string{"message"},
+		"ttl":     "1h",
+		"backend": mapstr.M{"file": mapstr.M{"id": "dedup_test"}},
+	}
+
+	p, err := New(conf.MustNewConfigFrom(cfg), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	require.NoError(t, p.(processors.PathSetter).SetPaths(&paths.Path{Data: dataDir}))
+	assert.NotNil(t, run(t, p, mapstr.M{"message": "hello"}))
+	assert.Nil(t, run(t, p, mapstr.M{"message": "hello"}))
+	require.NoError(t, processors.Close(p))
+
+	// The window survives a restart.
+	p, err = New(conf.MustNewConfigFrom(cfg), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	require.NoError(t, p.(processors.PathSetter).SetPaths(&paths.Path{Data: dataDir}))
+	t.Cleanup(func() { require.NoError(t, processors.Close(p)) })
+	assert.Nil(t, run(t, p, mapstr.M{"message": "hello"}))
+
+	p.(*processor).now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	event := run(t, p, mapstr.M{"message": "hello"})
+	require.NotNil(t, event)
+	duplicates, err := event.GetValue("dedup.previous_window_duplicates")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), duplicates)
+}
+
+func TestDedupMaxEntries(t *testing.T) {
+	p, err := New(conf.MustNewConfigFrom(mapstr.M{"fields": []string{"message"}, "max_entries": 2}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	require.NoError(t, p.(processors.PathSetter).SetPaths(&paths.Path{Data: t.TempDir()}))
+	t.Cleanup(func() { require.NoError(t, processors.Close(p)) })
+	for _, msg := range []string{"a", "b", "c"} {
+		assert.NotNil(t, run(t, p, mapstr.M{"message": msg}))
+	}
+	assert.NotNil(t, run(t, p, mapstr.M{"message": "a"}), "the oldest window was evicted")
+	assert.Nil(t, run(t, p, mapstr.M{"message": "c"}))
+}
+
+func TestDedupNotInitialized(t *testing.T) {
+	c := defaultConfig()
+	c.Fields = []string{"message"}
+	p, err := newDedup(c, logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+
+	_, err = p.Run(&beat.Event{Fields: mapstr.M{"message": "hello"}})
+	assert.ErrorContains(t, err, "store not initialized")
+}
+
+func TestDedupConfig(t *testing.T) {
+	for name, cfg := range map[string]mapstr.M{
+		"no fields":  {},
+		"zero ttl":   {"fields": []string{"message"}, "ttl": 0},
+		"no backend": {"fields": []string{"message"}, "backend": mapstr.M{"capacity": 10}},
+		"zero max":   {"fields": []string{"message"}, "max_entries": 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p, err := New(conf.MustNewConfigFrom(cfg), logptest.NewTestingLogger(t, ""))
+			if err == nil {
+				t.Cleanup(func() { assert.NoError(t, processors.Close(p)) })
+				err = p.(*processor).SetPaths(&paths.Path{Data: t.TempDir()})
+			}
+			assert.Error(t, err)
+		})
+	}
+}
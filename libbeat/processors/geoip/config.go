@@ -0,0 +1,61 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package geoip
+
+import (
+	"errors"
+	"time"
+)
+
+type config struct {
+	CityDatabase   string        `config:"city_database"`
+	ASNDatabase    string        `config:"asn_database"`
+	Fields         []fieldConfig `config:"fields"`
+	ReloadInterval time.Duration `config:"reload_interval" validate:"min=0"`
+	CacheSize      int           `config:"cache_size"      validate:"min=0"`
+	IgnoreFailure  bool          `config:"ignore_failure"`
+	ID             string        `config:"id"`
+}
+
+// fieldConfig enriches the IP address in From with the geo and as fields
+// under To.
+type fieldConfig struct {
+	From string `config:"from" validate:"required"`
+	To   string `config:"to"`
+}
+
+// defaultFields are used when no fields are configured. They aren't part of
+// the default config, the configured fields would be merged into them.
+var defaultFields = []fieldConfig{
+	{From: "source.ip", To: "source"},
+	{From: "destination.ip", To: "destination"},
+}
+
+func defaultConfig() config {
+	return config{
+		ReloadInterval: time.Minute,
+		CacheSize:      10000,
+	}
+}
+
+func (c *config) Validate() error {
+	if c.CityDatabase == "" && c.ASNDatabase == "" {
+		return errors.New("at least one of city_database and asn_database must be set")
+	}
+	return nil
+}
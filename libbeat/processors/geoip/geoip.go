@@ -0,0 +1,412 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package geoip
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/oschwald/maxminddb-golang"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const (
+	procName = "geoip"
+	logName  = "processor." + procName
+)
+
+func init() {
+	// We cannot use this as a JS plugin as it is stateful and includes a Close method.
+	processors.RegisterPlugin(procName, New)
+}
+
+type processor struct {
+	config
+	log *logp.Logger
+
+	// mu guards the databases, and keeps the cache consistent with them
+	// while they are reloaded.
+	mu    sync.RWMutex
+	city  *database
+	asn   *database
+	cache *lru.Cache[netip.Addr, location]
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// database is a MaxMind DB file and the state it was loaded in.
+type database struct {
+	path    string
+	reader  *maxminddb.Reader
+	modTime time.Time
+	size    int64
+}
+
+// location holds the geo and as fields of an IP address, nil when the
+// databases have no data for it.
+type location struct {
+	geo mapstr.M
+	as  mapstr.M
+}
+
+// New constructs a new geoip processor.
+func New(cfg *conf.C, log *logp.Logger) (beat.Processor, error) {
+	c := defaultConfig()
+	if err := cfg.Unpack(&c); err != nil {
+		return nil, fmt.Errorf("fail to unpack the %v processor configuration: %w", procName, err)
+	}
+
+	return newGeoIP(c, log)
+}
+
+func newGeoIP(c config, logger *logp.Logger) (*processor, error) {
+	log := logger.Named(logName)
+	if c.ID != "" {
+		log = log.With("instance_id", c.ID)
+	}
+
+	if len(c.Fields) == 0 {
+		c.Fields = defaultFields
+	}
+
+	p := &processor{config: c, log: log, done: make(chan struct{})}
+
+	var err error
+	if c.CityDatabase != "" {
+		if p.city, err = loadDatabase(c.CityDatabase); err != nil {
+			return nil, fmt.Errorf("failed to load the city database: %w", err)
+		}
+	}
+	if c.ASNDatabase != "" {
+		if p.asn, err = loadDatabase(c.ASNDatabase); err != nil {
+			return nil, fmt.Errorf("failed to load the ASN database: %w", err)
+		}
+	}
+	if c.CacheSize > 0 {
+		if p.cache, err = lru.New[netip.Addr, location](c.CacheSize); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.ReloadInterval > 0 {
+		p.wg.Add(1)
+		go p.reloadLoop()
+	}
+	return p, nil
+}
+
+func loadDatabase(path string) (*database, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	// The file is read in memory instead of being mapped, a database
+	// updated in place would crash the process while it is mapped.
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := maxminddb.FromBytes(buf)
+	if err != nil {
+		return nil, err
+	}
+	return &database{path: path, reader: reader, modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// lookupRecord returns the record of the network containing ip, nil if the
+// database doesn't have one.
+func lookupRecord(reader *maxminddb.Reader, ip netip.Addr) (map[string]any, error) {
+	var record map[string]any
+	if err := reader.Lookup(ip.AsSlice(), &record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// reloadLoop reloads the databases whose files changed every reload
+// interval.
+func (p *processor) reloadLoop() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+			p.reload()
+		}
+	}
+}
+
+// reload replaces the databases whose files changed since they were
+// loaded. A database that fails to load is kept until its file is fixed.
+func (p *processor) reload() {
+	city := p.reloadDatabase(p.city)
+	asn := p.reloadDatabase(p.asn)
+	if city == p.city && asn == p.asn {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if city != p.city {
+		p.city.close()
+	}
+	if asn != p.asn {
+		p.asn.close()
+	}
+	p.city, p.asn = city, asn
+	if p.cache != nil {
+		p.cache.Purge()
+	}
+}
+
+func (p *processor) reloadDatabase(db *database) *database {
+	if db == nil {
+		return nil
+	}
+	info, err := os.Stat(db.path)
+	if err != nil {
+		p.log.Warnf("Failed to check the GeoIP database %s: %v", db.path, err)
+		return db
+	}
+	if info.ModTime().Equal(db.modTime) && info.Size() == db.size {
+		return db
+	}
+
+	reloaded, err := loadDatabase(db.path)
+	if err != nil {
+		p.log.Warnf("Failed to reload the GeoIP database, the previous version is kept: %v", err)
+		return db
+	}
+	p.log.Infof("Reloaded the GeoIP database %s", db.path)
+	return reloaded
+}
+
+func (p *processor) String() string {
+	json, _ := json.Marshal(p.config)
+	return procName + "=" + string(json)
+}
+
+func (p *processor) Run(event *beat.Event) (*beat.Event, error) {
+	var errs []error
+	for _, f := range p.Fields {
+		if err := p.enrich(event, f); err != nil && !p.IgnoreFailure {
+			errs = append(errs, err)
+		}
+	}
+	return event, errors.Join(errs...)
+}
+
+// enrich adds the geo and as fields of the IP address in f.From. Events
+// without the field are left unchanged.
+func (p *processor) enrich(event *beat.Event, f fieldConfig) error {
+	v, err := event.GetValue(f.From)
+	if err != nil {
+		return nil
+	}
+
+	var ip netip.Addr
+	switch v := v.(type) {
+	case string:
+		ip, err = netip.ParseAddr(v)
+		if err != nil {
+			return fmt.Errorf("geoip field [%v] is not a valid IP address: %w", f.From, err)
+		}
+	case net.IP:
+		var ok bool
+		if ip, ok = netip.AddrFromSlice(v); !ok {
+			return fmt.Errorf("geoip field [%v] is not a valid IP address", f.From)
+		}
+	default:
+		return fmt.Errorf("geoip field [%v] has an unexpected type %T", f.From, v)
+	}
+
+	loc, err := p.lookup(ip.Unmap().WithZone(""))
+	if err != nil {
+		return fmt.Errorf("geoip lookup of [%v] failed: %w", f.From, err)
+	}
+
+	if loc.geo != nil {
+		if _, err := event.PutValue(targetField(f.To, "geo"), loc.geo.Clone()); err != nil {
+			return fmt.Errorf("failed to write geoip fields: %w", err)
+		}
+	}
+	if loc.as != nil {
+		if _, err := event.PutValue(targetField(f.To, "as"), loc.as.Clone()); err != nil {
+			return fmt.Errorf("failed to write geoip fields: %w", err)
+		}
+	}
+	return nil
+}
+
+func targetField(to, field string) string {
+	if to == "" {
+		return field
+	}
+	return to + "." + field
+}
+
+// lookup returns the location of ip from the cache or the databases.
+func (p *processor) lookup(ip netip.Addr) (location, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.cache != nil {
+		if loc, ok := p.cache.Get(ip); ok {
+			return loc, nil
+		}
+	}
+
+	var loc location
+	if p.city != nil {
+		record, err := lookupRecord(p.city.reader, ip)
+		if err != nil {
+			return location{}, err
+		}
+		loc.geo = cityFields(record)
+	}
+	if p.asn != nil {
+		record, err := lookupRecord(p.asn.reader, ip)
+		if err != nil {
+			return location{}, err
+		}
+		loc.as = asnFields(record)
+	}
+
+	if p.cache != nil {
+		p.cache.Add(ip, loc)
+	}
+	return loc, nil
+}
+
+// cityFields maps a record of a City or Country database to the ECS geo
+// fields.
+func cityFields(record map[string]any) mapstr.M {
+	if record == nil {
+		return nil
+	}
+
+	geo := mapstr.M{}
+	putString(geo, "city_name", record, "city", "names", "en")
+	putString(geo, "continent_code", record, "continent", "code")
+	putString(geo, "continent_name", record, "continent", "names", "en")
+	putString(geo, "country_iso_code", record, "country", "iso_code")
+	putString(geo, "country_name", record, "country", "names", "en")
+	putString(geo, "postal_code", record, "postal", "code")
+	putString(geo, "timezone", record, "location", "time_zone")
+
+	if subdivisions, ok := record["subdivisions"].([]any); ok && len(subdivisions) > 0 {
+		if region, ok := subdivisions[0].(map[string]any); ok {
+			putString(geo, "region_name", region, "names", "en")
+			country, _ := geo["country_iso_code"].(string)
+			if code, ok := lookupValue(region, "iso_code").(string); ok && country != "" {
+				geo["region_iso_code"] = country + "-" + code
+			}
+		}
+	}
+
+	lat, latOK := lookupValue(record, "location", "latitude").(float64)
+	lon, lonOK := lookupValue(record, "location", "longitude").(float64)
+	if latOK && lonOK {
+		geo["location"] = mapstr.M{"lat": lat, "lon": lon}
+	}
+
+	if len(geo) == 0 {
+		return nil
+	}
+	return geo
+}
+
+// asnFields maps a record of an ASN database to the ECS as fields.
+func asnFields(record map[string]any) mapstr.M {
+	if record == nil {
+		return nil
+	}
+
+	as := mapstr.M{}
+	if number, ok := record["autonomous_system_number"].(uint64); ok {
+		as["number"] = number
+	}
+	if org, ok := record["autonomous_system_organization"].(string); ok {
+		as["organization"] = mapstr.M{"name": org}
+	}
+
+	if len(as) == 0 {
+		return nil
+	}
+	return as
+}
+
+func putString(m mapstr.M, key string, record map[string]any, path ...string) {
+	if s, ok := lookupValue(record, path...).(string); ok && s != "" {
+		m[key] = s
+	}
+}
+
+func lookupValue(record map[string]any, path ...string) any {
+	var v any = record
+	for _, key := range path {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
+
+// Close stops the reloading of the databases and releases them.
+func (p *processor) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.done)
+	})
+	p.wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.city.close()
+	p.asn.close()
+	p.city, p.asn = nil, nil
+	if p.cache != nil {
+		p.cache.Purge()
+	}
+	return nil
+}
+
+func (db *database) close() {
+	if db != nil {
+		_ = db.reader.Close()
+	}
+}
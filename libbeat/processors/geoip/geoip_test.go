@@ -0,0 +1,182 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package geoip
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+var londonRecord = map[string]any{
+	"city":      map[string]any{"names": map[string]any{"en": "London"}},
+	"continent": map[string]any{"code": "EU", "names": map[string]any{"en": "Europe"}},
+	"country":   map[string]any{"iso_code": "GB", "names": map[string]any{"en": "United Kingdom"}},
+	"location": map[string]any{
+		"latitude":  51.5142,
+		"longitude": -0.0931,
+		"time_zone": "Europe/London",
+	},
+	"postal": map[string]any{"code": "EC2V"},
+	"subdivisions": []any{
+		map[string]any{"iso_code": "ENG", "names": map[string]any{"en": "England"}},
+	},
+}
+
+func TestGeoIP(t *testing.T) {
+	city := writeTestDB(t, "GeoLite2-City", testNetwork{prefix: "81.2.69.0/24", record: londonRecord})
+	asn := writeTestDB(t, "GeoLite2-ASN",
+		testNetwork{prefix: "81.2.69.0/24", record: map[string]any{
+			"autonomous_system_number":       uint32(20712),
+			"autonomous_system_organization": "Andrews & Arnold Ltd",
+		}},
+		testNetwork{prefix: "2001:db8::/32", record: map[string]any{
+			"autonomous_system_number": uint32(64496),
+		}},
+	)
+	p, err := New(conf.MustNewConfigFrom(mapstr.M{"city_database": city, "asn_database": asn}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, processors.Close(p)) })
+
+	event := &beat.Event{Fields: mapstr.M{
+		"source":      mapstr.M{"ip": "81.2.69.142"},
+		"destination": mapstr.M{"ip": "2001:db8::1"},
+	}}
+	event, err = p.Run(event)
+	require.NoError(t, err)
+
+	assert.Equal(t, mapstr.M{
+		"ip": "81.2.69.142",
+		"geo": mapstr.M{
+			"city_name":        "London",
+			"continent_code":   "EU",
+			"continent_name":   "Europe",
+			"country_iso_code": "GB",
+			"country_name":     "United Kingdom",
+			"location":         mapstr.M{"lat": 51.5142, "lon": -0.0931},
+			"postal_code":      "EC2V",
+			"region_iso_code":  "GB-ENG",
+			"region_name":      "England",
+			"timezone":         "Europe/London",
+		},
+		"as": mapstr.M{
+			"number":       uint64(20712),
+			"organization": mapstr.M{"name": "Andrews & Arnold Ltd"},
+		},
+	}, event.Fields["source"])
+	assert.Equal(t, mapstr.M{
+		"ip": "2001:db8::1",
+		"as": mapstr.M{"number": uint64(64496)},
+	}, event.Fields["destination"])
+
+	// The cached location isn't shared with the events.
+	_, err = event.PutValue("source.geo.city_name", "changed")
+	require.NoError(t, err)
+	event, err = p.Run(&beat.Event{Fields: mapstr.M{"source": mapstr.M{"ip": "81.2.69.142"}}})
+	require.NoError(t, err)
+	name, _ := event.GetValue("source.geo.city_name")
+	assert.Equal(t, "London", name)
+}
+
+func TestGeoIPNotFound(t *testing.T) {
+	city := writeTestDB(t, "GeoLite2-City", testNetwork{prefix: "81.2.69.0/24", record: londonRecord})
+	p, err := New(conf.MustNewConfigFrom(mapstr.M{"city_database": city}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, processors.Close(p)) })
+
+	fields := mapstr.M{"source": mapstr.M{"ip": "10.0.0.1"}, "message": "hello"}
+	event, err := p.Run(&beat.Event{Fields: fields.Clone()})
+	require.NoError(t, err)
+	assert.Equal(t, fields, event.Fields)
+}
+
+func TestGeoIPInvalidAddress(t *testing.T) {
+	city := writeTestDB(t, "GeoLite2-City", testNetwork{prefix: "81.2.69.0/24", record: londonRecord})
+
+	for _, ignoreFailure := range []bool{false, true} {
+		p, err := New(conf.MustNewConfigFrom(mapstr.M{"city_database": city, "ignore_failure": ignoreFailure}), logptest.NewTestingLogger(t, ""))
+		require.NoError(t, err)
+		t.Cleanup(func() { require.NoError(t, processors.Close(p)) })
+
+		_, err = p.Run(&beat.Event{Fields: mapstr.M{"source": mapstr.M{"ip": "not an ip"}}})
+		if ignoreFailure {
+			assert.NoError(t, err)
+		} else {
+			assert.ErrorContains(t, err, "source.ip")
+		}
+	}
+}
+
+func TestGeoIPReload(t *testing.T) {
+	city := writeTestDB(t, "GeoLite2-City", testNetwork{prefix: "81.2.69.0/24", record: londonRecord})
+	p, err := New(conf.MustNewConfigFrom(mapstr.M{"city_database": city, "reload_interval": 0}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, processors.Close(p)) })
+
+	cityName := func() any {
+		event, err := p.Run(&beat.Event{Fields: mapstr.M{"source": mapstr.M{"ip": "81.2.69.142"}}})
+		require.NoError(t, err)
+		name, _ := event.GetValue("source.geo.city_name")
+		return name
+	}
+	assert.Equal(t, "London", cityName())
+
+	paris := map[string]any{"city": map[string]any{"names": map[string]any{"en": "Paris"}}}
+	db := buildTestDB(t, "GeoLite2-City", 24, testNetwork{prefix: "81.2.69.0/24", record: paris})
+	require.NoError(t, os.WriteFile(city, db, 0o644))
+	modTime := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(city, modTime, modTime))
+
+	p.(*processor).reload()
+	assert.Equal(t, "Paris", cityName())
+
+	// A broken update keeps the database loaded before.
+	require.NoError(t, os.WriteFile(city, []byte("broken"), 0o644))
+	p.(*processor).reload()
+	assert.Equal(t, "Paris", cityName())
+}
+
+func TestConfigValidate(t *testing.T) {
+	c := defaultConfig()
+	assert.Error(t, conf.MustNewConfigFrom(mapstr.M{}).Unpack(&c))
+
+	c = defaultConfig()
+	assert.Error(t, conf.MustNewConfigFrom(mapstr.M{
+		"city_database": "city.mmdb",
+		"fields":        []mapstr.M{{"to": "source"}},
+	}).Unpack(&c))
+
+	// Configured fields replace the default ones.
+	c = defaultConfig()
+	require.NoError(t, conf.MustNewConfigFrom(mapstr.M{
+		"city_database": "city.mmdb",
+		"fields":        []mapstr.M{{"from": "client.ip", "to": "client"}},
+	}).Unpack(&c))
+	assert.Equal(t, []fieldConfig{{From: "client.ip", To: "client"}}, c.Fields)
+}
@@ -0,0 +1,249 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package geoip
+
+import (
+	"encoding/binary"
+	"math"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// metadataStart marks the start of the metadata section at the end of a
+// MaxMind DB file.
+var metadataStart = []byte("\xab\xcd\xefMaxMind.com")
+
+// dataSectionSeparator is the size of the zeroed separator between the
+// search tree and the data section.
+const dataSectionSeparator = 16
+
+// Data section types, see https://maxmind.github.io/MaxMind-DB/.
+const (
+	typeString = 2
+	typeDouble = 3
+	typeUint16 = 5
+	typeUint32 = 6
+	typeMap    = 7
+	typeArray  = 11
+)
+
+// testNetwork is a network of a test database and its record.
+type testNetwork struct {
+	prefix string
+	record map[string]any
+}
+
+// writeTestDB writes a MaxMind DB holding networks to a temporary file.
+func writeTestDB(t *testing.T, dbType string, networks ...testNetwork) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), dbType+".mmdb")
+	require.NoError(t, os.WriteFile(path, buildTestDB(t, dbType, 24, networks...), 0o644))
+	return path
+}
+
+// buildTestDB builds an IPv6 MaxMind DB holding networks, IPv4 networks
+// are stored in ::/96.
+func buildTestDB(t *testing.T, dbType string, recordSize int, networks ...testNetwork) []byte {
+	t.Helper()
+
+	// A record is a node index, a data section offset + 1, or 0 if empty.
+	type record struct {
+		node, data int
+	}
+	nodes := [][2]record{{}}
+
+	var data []byte
+	for _, n := range networks {
+		prefix := netip.MustParsePrefix(n.prefix)
+		addr := prefix.Addr().As16()
+		bits := prefix.Bits()
+		if prefix.Addr().Is4() {
+			addr = [16]byte{}
+			a4 := prefix.Addr().As4()
+			copy(addr[12:], a4[:])
+			bits += 96
+		}
+
+		node := 0
+		for i := range bits {
+			bit := (addr[i/8] >> (7 - i%8)) & 1
+			if i == bits-1 {
+				nodes[node][bit] = record{data: len(data) + 1}
+				break
+			}
+			if nodes[node][bit].node == 0 {
+				require.Zero(t, nodes[node][bit].data, "overlapping test networks")
+				nodes = append(nodes, [2]record{})
+				nodes[node][bit] = record{node: len(nodes) - 1}
+			}
+			node = nodes[node][bit].node
+		}
+		data = appendValue(data, n.record)
+	}
+
+	var tree []byte
+	nodeCount := len(nodes)
+	value := func(r record) uint32 {
+		switch {
+		case r.node != 0:
+			return uint32(r.node)
+		case r.data != 0:
+			return uint32(nodeCount + dataSectionSeparator + r.data - 1)
+		default:
+			return uint32(nodeCount)
+		}
+	}
+	for _, n := range nodes {
+		left, right := value(n[0]), value(n[1])
+		switch recordSize {
+		case 24:
+			tree = append(tree, byte(left>>16), byte(left>>8), byte(left),
+				byte(right>>16), byte(right>>8), byte(right))
+		case 28:
+			tree = append(tree, byte(left>>16), byte(left>>8), byte(left),
+				byte(left>>20)&0xf0|byte(right>>24)&0x0f,
+				byte(right>>16), byte(right>>8), byte(right))
+		case 32:
+			tree = binary.BigEndian.AppendUint32(tree, left)
+			tree = binary.BigEndian.AppendUint32(tree, right)
+		}
+	}
+
+	buf := append(tree, make([]byte, dataSectionSeparator)...)
+	buf = append(buf, data...)
+	buf = append(buf, metadataStart...)
+	return appendValue(buf, map[string]any{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"database_type":               dbType,
+		"ip_version":                  uint16(6),
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(recordSize),
+	})
+}
+
+// appendValue appends v in the MaxMind DB data section format.
+func appendValue(buf []byte, v any) []byte {
+	switch v := v.(type) {
+	case string:
+		return append(appendControl(buf, typeString, len(v)), v...)
+	case float64:
+		return binary.BigEndian.AppendUint64(appendControl(buf, typeDouble, 8), math.Float64bits(v))
+	case uint16:
+		return binary.BigEndian.AppendUint16(appendControl(buf, typeUint16, 2), v)
+	case uint32:
+		return binary.BigEndian.AppendUint32(appendControl(buf, typeUint32, 4), v)
+	case []any:
+		buf = appendControl(buf, typeArray, len(v))
+		for _, e := range v {
+			buf = appendValue(buf, e)
+		}
+		return buf
+	case map[string]any:
+		buf = appendControl(buf, typeMap, len(v))
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			buf = appendValue(buf, k)
+			buf = appendValue(buf, v[k])
+		}
+		return buf
+	default:
+		panic("unsupported test value")
+	}
+}
+
+func appendControl(buf []byte, typ, size int) []byte {
+	var ext []byte
+	switch {
+	case size >= 285:
+		ext = binary.BigEndian.AppendUint16(nil, uint16(size-285))
+		size = 30
+	case size >= 29:
+		ext = []byte{byte(size - 29)}
+		size = 29
+	}
+	if typ <= typeMap {
+		buf = append(buf, byte(typ<<5|size))
+	} else {
+		buf = append(buf, byte(size), byte(typ-7))
+	}
+	return append(buf, ext...)
+}
+
+func TestMMDBLookup(t *testing.T) {
+	networks := []testNetwork{
+		{prefix: "81.2.69.0/24", record: map[string]any{"name": "v4"}},
+		{prefix: "2001:db8::/32", record: map[string]any{"name": "v6"}},
+	}
+
+	for _, recordSize := range []int{24, 28, 32} {
+		r, err := maxminddb.FromBytes(buildTestDB(t, "Test", recordSize, networks...))
+		require.NoError(t, err, "record size %d", recordSize)
+		assert.Equal(t, "Test", r.Metadata.DatabaseType)
+
+		tests := map[string]any{
+			"81.2.69.142":          "v4",
+			"::ffff:81.2.69.1":     "v4",
+			"81.2.70.1":            nil,
+			"2001:db8:1234::1":     "v6",
+			"2001:db9::1":          nil,
+			"10.0.0.1":             nil,
+			"fe80::1ff:fe23:4567":  nil,
+			"2001:db8:ffff::ffff":  "v6",
+			"81.2.69.255":          "v4",
+			"81.2.68.255":          nil,
+			"0.0.0.0":              nil,
+			"::":                   nil,
+			"255.255.255.255":      nil,
+			"ffff:ffff:ffff::ffff": nil,
+		}
+		for ip, want := range tests {
+			record, err := lookupRecord(r, netip.MustParseAddr(ip))
+			require.NoError(t, err, "record size %d, ip %s", recordSize, ip)
+			if want == nil {
+				assert.Nil(t, record, "record size %d, ip %s", recordSize, ip)
+				continue
+			}
+			assert.Equal(t, want, record["name"], "record size %d, ip %s", recordSize, ip)
+		}
+	}
+}
+
+func TestMMDBInvalid(t *testing.T) {
+	_, err := loadDatabase(filepath.Join(t.TempDir(), "missing.mmdb"))
+	assert.Error(t, err)
+
+	path := filepath.Join(t.TempDir(), "broken.mmdb")
+	require.NoError(t, os.WriteFile(path, []byte("not a database"), 0o644))
+	_, err = loadDatabase(path)
+	assert.Error(t, err)
+}
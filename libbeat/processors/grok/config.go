@@ -0,0 +1,46 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grok
+
+import "errors"
+
+type config struct {
+	Field              string            `config:"field"`
+	Patterns           []string          `config:"patterns"`
+	PatternDefinitions map[string]string `config:"pattern_definitions"`
+	TargetPrefix       string            `config:"target_prefix"`
+	OverwriteKeys      bool              `config:"overwrite_keys"`
+	TagOnFailure       []string          `config:"tag_on_failure"`
+	IgnoreMissing      bool              `config:"ignore_missing"`
+	IgnoreFailure      bool              `config:"ignore_failure"`
+	ID                 string            `config:"id"`
+}
+
+func defaultConfig() config {
+	return config{
+		Field:        "message",
+		TagOnFailure: []string{"_grokparsefailure"},
+	}
+}
+
+func (c *config) Validate() error {
+	if len(c.Patterns) == 0 {
+		return errors.New("no patterns configured")
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grok
+
+import (
+	"bufio"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+//go:embed patterns
+var patternFiles embed.FS
+
+var (
+	// syntaxRE matches the %{SYNTAX}, %{SYNTAX:SEMANTIC} and
+	// %{SYNTAX:SEMANTIC:TYPE} references of a pattern.
+	syntaxRE = regexp.MustCompile(`%\{(\w+)(?::([^:}]+)(?::(\w+))?)?\}`)
+
+	// inlineCaptureRE matches the (?<field>...) named captures of a pattern.
+	inlineCaptureRE = regexp.MustCompile(`\(\?<([^>!=][^>]*)>`)
+
+	// fieldNameRE matches the [parent][child] field name syntax.
+	fieldNameRE = regexp.MustCompile(`^(?:\[[^\[\]]+\])+$`)
+
+	errNoMatch = errors.New("no grok pattern matched")
+)
+
+// defaultPatterns holds the standard pattern library.
+var defaultPatterns = mustLoadPatterns()
+
+func mustLoadPatterns() map[string]string {
+	patterns := map[string]string{}
+	err := fs.WalkDir(patternFiles, "patterns", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		f, err := patternFiles.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			name, definition, ok := strings.Cut(line, " ")
+			if !ok {
+				return fmt.Errorf("invalid pattern definition in %s: %s", path, line)
+			}
+			patterns[name] = strings.TrimSpace(definition)
+		}
+		return scanner.Err()
+	})
+	if err != nil {
+		panic(err)
+	}
+	return patterns
+}
+
+// converters convert a captured value to the type of a capture.
+var converters = map[string]func(string) (any, error){
+	"string": func(s string) (any, error) { return s, nil },
+	"int": func(s string) (any, error) {
+		i, err := strconv.ParseInt(s, 10, 32)
+		return int32(i), err
+	},
+	"long": func(s string) (any, error) {
+		return strconv.ParseInt(s, 10, 64)
+	},
+	"float": func(s string) (any, error) {
+		f, err := strconv.ParseFloat(s, 32)
+		return float32(f), err
+	},
+	"double": func(s string) (any, error) {
+		return strconv.ParseFloat(s, 64)
+	},
+	"boolean": func(s string) (any, error) {
+		return strconv.ParseBool(s)
+	},
+}
+
+func init() {
+	converters["integer"] = converters["int"]
+}
+
+// capture is a named capture of a compiled pattern.
+type capture struct {
+	index   int // of the regular expression sub-match
+	field   string
+	typ     string
+	convert func(string) (any, error)
+}
+
+// grok is a compiled grok pattern.
+type grok struct {
+	raw      string
+	re       *regexp.Regexp
+	captures []capture
+}
+
+// compile expands the references of pattern with the definitions and
+// compiles it.
+func compile(pattern string, definitions map[string]string) (*grok, error) {
+	c := &compiler{definitions: definitions, groups: map[string]capture{}}
+	expanded, err := c.expand(pattern, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid grok pattern %q: %w", pattern, err)
+	}
+	re, err := regexp.Compile(expanded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid grok pattern %q: %w", pattern, err)
+	}
+
+	g := &grok{raw: pattern, re: re}
+	for i, name := range re.SubexpNames() {
+		if capture, ok := c.groups[name]; ok {
+			capture.index = i
+			g.captures = append(g.captures, capture)
+		}
+	}
+	if len(g.captures) == 0 {
+		return nil, fmt.Errorf("grok pattern %q doesn't capture any field", pattern)
+	}
+	return g, nil
+}
+
+// match returns the fields captured from s, ok is false if s doesn't
+// match. A field captured more than once keeps its first value.
+func (g *grok) match(s string) (fields map[string]any, ok bool, err error) {
+	loc := g.re.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return nil, false, nil
+	}
+
+	fields = make(map[string]any, len(g.captures))
+	for _, capture := range g.captures {
+		start, end := loc[2*capture.index], loc[2*capture.index+1]
+		if start < 0 || start == end {
+			continue
+		}
+		if _, exists := fields[capture.field]; exists {
+			continue
+		}
+		v, err := capture.convert(s[start:end])
+		if err != nil {
+			return nil, true, fmt.Errorf("cannot convert field %s to %s: %w", capture.field, capture.typ, err)
+		}
+		fields[capture.field] = v
+	}
+	return fields, true, nil
+}
+
+type compiler struct {
+	definitions map[string]string
+	groups      map[string]capture
+}
+
+// expand replaces the references and named captures of pattern with
+// regular expression groups. stack holds the names of the definitions
+// being expanded, to detect recursive definitions.
+func (c *compiler) expand(pattern string, stack []string) (string, error) {
+	var err error
+	pattern = inlineCaptureRE.ReplaceAllStringFunc(pattern, func(m string) string {
+		field := inlineCaptureRE.FindStringSubmatch(m)[1]
+		return "(?P<" + c.group(field, "string") + ">"
+	})
+
+	expanded := syntaxRE.ReplaceAllStringFunc(pattern, func(m string) string {
+		if err != nil {
+			return ""
+		}
+		sub := syntaxRE.FindStringSubmatch(m)
+		name, field, typ := sub[1], sub[2], sub[3]
+
+		definition, ok := c.definitions[name]
+		if !ok {
+			err = fmt.Errorf("unknown pattern %s", name)
+			return ""
+		}
+		if slices.Contains(stack, name) {
+			err = fmt.Errorf("pattern %s references itself", name)
+			return ""
+		}
+		var inner string
+		inner, err = c.expand(definition, append(stack, name))
+		if err != nil {
+			return ""
+		}
+
+		if field == "" {
+			return "(?:" + inner + ")"
+		}
+		if typ == "" {
+			typ = "string"
+		}
+		if _, ok := converters[typ]; !ok {
+			err = fmt.Errorf("unsupported type %s of field %s", typ, field)
+			return ""
+		}
+		return "(?P<" + c.group(field, typ) + ">" + inner + ")"
+	})
+	return expanded, err
+}
+
+// group returns the name of a new regular expression group capturing
+// field.
+func (c *compiler) group(field, typ string) string {
+	name := "grok" + strconv.Itoa(len(c.groups))
+	c.groups[name] = capture{field: fieldName(field), typ: typ, convert: converters[typ]}
+	return name
+}
+
+// fieldName converts the [parent][child] field names to parent.child.
+func fieldName(name string) string {
+	if !fieldNameRE.MatchString(name) {
+		return name
+	}
+	return strings.ReplaceAll(strings.Trim(name, "[]"), "][", ".")
+}
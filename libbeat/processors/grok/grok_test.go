@@ -0,0 +1,160 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package grok
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultPatternsCompile(t *testing.T) {
+	for name := range defaultPatterns {
+		_, err := compile("%{"+name+":field}", defaultPatterns)
+		assert.NoError(t, err, name)
+	}
+}
+
+func TestCompile(t *testing.T) {
+	tests := map[string]struct {
+		pattern string
+		input   string
+		want    map[string]any
+		err     string
+	}{
+		"types": {
+			pattern: `%{WORD:verb} %{INT:status:int} %{NUMBER:bytes:long} %{NUMBER:ratio:float} %{NUMBER:duration:double} %{WORD:cached:boolean}`,
+			input:   "GET 200 1024 0.5 12.25 true",
+			want: map[string]any{
+				"verb":     "GET",
+				"status":   int32(200),
+				"bytes":    int64(1024),
+				"ratio":    float32(0.5),
+				"duration": 12.25,
+				"cached":   true,
+			},
+		},
+		"ecs field names": {
+			pattern: `%{IP:[source][ip]} %{POSINT:source.port:int}`,
+			input:   "10.0.0.1 5044",
+			want:    map[string]any{"source.ip": "10.0.0.1", "source.port": int32(5044)},
+		},
+		"inline capture": {
+			pattern: `user=(?<[user][name]>\w+)`,
+			input:   "login user=alice",
+			want:    map[string]any{"user.name": "alice"},
+		},
+		"unmatched optional capture": {
+			pattern: `%{WORD:a}(?: %{WORD:b})?`,
+			input:   "one",
+			want:    map[string]any{"a": "one"},
+		},
+		"ipv6": {
+			pattern: `%{IP:ip}`,
+			input:   "from 2001:db8::1",
+			want:    map[string]any{"ip": "2001:db8::1"},
+		},
+		"no match": {
+			pattern: `%{INT:n}`,
+			input:   "none",
+		},
+		"conversion failure": {
+			pattern: `%{WORD:n:int}`,
+			input:   "ten",
+			err:     "cannot convert field n to int",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			g, err := compile(tc.pattern, defaultPatterns)
+			require.NoError(t, err)
+
+			fields, matched, err := g.match(tc.input)
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want != nil, matched)
+			if tc.want != nil {
+				assert.Equal(t, tc.want, fields)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	definitions := map[string]string{
+		"LOOP":  "%{LOOP2}",
+		"LOOP2": "x%{LOOP}",
+		"WORD":  `\w+`,
+	}
+
+	tests := map[string]string{
+		"%{UNKNOWN:a}":     "unknown pattern UNKNOWN",
+		"%{LOOP:a}":        "references itself",
+		"%{WORD:a:number}": "unsupported type number",
+		"%{WORD}":          "doesn't capture any field",
+		"(%{WORD:a}":       "missing closing )",
+	}
+	for pattern, want := range tests {
+		_, err := compile(pattern, definitions)
+		assert.ErrorContains(t, err, want, pattern)
+	}
+}
+
+func TestHTTPDPatterns(t *testing.T) {
+	g, err := compile("%{HTTPD_COMBINEDLOG}", defaultPatterns)
+	require.NoError(t, err)
+
+	fields, matched, err := g.match(`203.0.113.7 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08 [en] (Win98; I ;Nav)"`)
+	require.NoError(t, err)
+	require.True(t, matched)
+	assert.Equal(t, map[string]any{
+		"source.address":            "203.0.113.7",
+		"user.name":                 "frank",
+		"timestamp":                 "10/Oct/2000:13:55:36 -0700",
+		"http.request.method":       "GET",
+		"url.original":              "/apache_pb.gif",
+		"http.version":              "1.0",
+		"http.response.status_code": int32(200),
+		"http.response.body.bytes":  int64(2326),
+		"http.request.referrer":     "http://www.example.com/start.html",
+		"user_agent.original":       "Mozilla/4.08 [en] (Win98; I ;Nav)",
+	}, fields)
+}
+
+func TestSyslogPatterns(t *testing.T) {
+	g, err := compile("%{SYSLOGLINE}", defaultPatterns)
+	require.NoError(t, err)
+
+	fields, matched, err := g.match(`Oct 11 22:14:15 mymachine su[230]: 'su root' failed for lonvick on /dev/pts/8`)
+	require.NoError(t, err)
+	require.True(t, matched)
+	assert.Equal(t, map[string]any{
+		"timestamp":     "Oct 11 22:14:15",
+		"host.hostname": "mymachine",
+		"process.name":  "su",
+		"process.pid":   int32(230),
+		"message":       "'su root' failed for lonvick on /dev/pts/8",
+	}, fields)
+}
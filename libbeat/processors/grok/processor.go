@@ -0,0 +1,159 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grok
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	jsprocessor "github.com/elastic/beats/v7/libbeat/processors/script/javascript/module/processor/registry"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const procName = "grok"
+
+func init() {
+	processors.RegisterPlugin(procName, New)
+	jsprocessor.RegisterPlugin("Grok", New)
+}
+
+type processor struct {
+	config
+	log      *logp.Logger
+	patterns []*grok
+}
+
+// New constructs a new grok processor.
+func New(cfg *conf.C, log *logp.Logger) (beat.Processor, error) {
+	c := defaultConfig()
+	if err := cfg.Unpack(&c); err != nil {
+		return nil, fmt.Errorf("fail to unpack the %v processor configuration: %w", procName, err)
+	}
+
+	return newGrok(c, log)
+}
+
+func newGrok(c config, logger *logp.Logger) (*processor, error) {
+	log := logger.Named(procName)
+	if c.ID != "" {
+		log = log.With("instance_id", c.ID)
+	}
+
+	// Custom definitions override the standard patterns of the same name.
+	definitions := maps.Clone(defaultPatterns)
+	maps.Copy(definitions, c.PatternDefinitions)
+
+	p := &processor{config: c, log: log}
+	for _, pattern := range c.Patterns {
+		g, err := compile(pattern, definitions)
+		if err != nil {
+			return nil, err
+		}
+		p.patterns = append(p.patterns, g)
+	}
+	return p, nil
+}
+
+func (p *processor) String() string {
+	return procName + "=[field=" + p.Field +
+		", patterns=[" + strings.Join(p.Patterns, ", ") +
+		"], target_prefix=" + p.TargetPrefix + "]"
+}
+
+// Run extracts the fields of the first pattern matching the field.
+func (p *processor) Run(event *beat.Event) (*beat.Event, error) {
+	v, err := event.GetValue(p.Field)
+	if err != nil {
+		if p.IgnoreMissing && errors.Is(err, mapstr.ErrKeyNotFound) {
+			return event, nil
+		}
+		return p.fail(event, fmt.Errorf("grok field [%v] not found: %w", p.Field, err))
+	}
+	s, ok := v.(string)
+	if !ok {
+		return p.fail(event, fmt.Errorf("grok field [%v] is not a string", p.Field))
+	}
+
+	for _, g := range p.patterns {
+		fields, matched, err := g.match(s)
+		if err != nil {
+			return p.fail(event, err)
+		}
+		if matched {
+			if err := p.put(event, fields); err != nil {
+				return p.fail(event, err)
+			}
+			return event, nil
+		}
+	}
+	return p.fail(event, fmt.Errorf("%w field [%v]", errNoMatch, p.Field))
+}
+
+// put writes the captured fields to the event, without changing it if a
+// field exists and overwrite_keys isn't set.
+func (p *processor) put(event *beat.Event, fields map[string]any) error {
+	keys := make(map[string]any, len(fields))
+	for field, v := range fields {
+		key := field
+		if p.TargetPrefix != "" {
+			key = p.TargetPrefix + "." + field
+		}
+		keys[key] = v
+	}
+
+	if !p.OverwriteKeys {
+		for key := range keys {
+			// The field matched is usually captured again, as message.
+			if key == p.Field {
+				continue
+			}
+			found, err := event.HasKey(key)
+			if found {
+				return fmt.Errorf("cannot override existing key with `%s`", key)
+			}
+			if err != nil && !errors.Is(err, mapstr.ErrKeyNotFound) {
+				return fmt.Errorf("cannot override existing key with `%s`: %w", key, err)
+			}
+		}
+	}
+	for key, v := range keys {
+		if _, err := event.PutValue(key, v); err != nil {
+			return fmt.Errorf("failed to put field `%s`: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// fail tags the event that couldn't be parsed.
+func (p *processor) fail(event *beat.Event, err error) (*beat.Event, error) {
+	if len(p.TagOnFailure) > 0 {
+		if err := mapstr.AddTags(event.Fields, p.TagOnFailure); err != nil {
+			return event, fmt.Errorf("cannot add tags to the event: %w", err)
+		}
+	}
+	if p.IgnoreFailure {
+		return event, nil
+	}
+	return event, err
+}
@@ -0,0 +1,152 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package grok
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestProcessor(t *testing.T) {
+	p, err := New(conf.MustNewConfigFrom(mapstr.M{
+		"patterns": []string{
+			`%{IP:[source][ip]} %{WORD:[http][request][method]} %{URIPATHPARAM:[url][original]} %{NUMBER:[http][response][body][bytes]:long}`,
+			`%{IP:[source][ip]} %{FIREWALL_ACTION:[event][action]}`,
+		},
+		"pattern_definitions": map[string]string{
+			"FIREWALL_ACTION": "(?:allow|deny)",
+		},
+	}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		message string
+		want    mapstr.M
+	}{
+		"first pattern": {
+			message: "55.3.244.1 GET /index.html 15824",
+			want: mapstr.M{
+				"source": mapstr.M{"ip": "55.3.244.1"},
+				"http": mapstr.M{
+					"request":  mapstr.M{"method": "GET"},
+					"response": mapstr.M{"body": mapstr.M{"bytes": int64(15824)}},
+				},
+				"url": mapstr.M{"original": "/index.html"},
+			},
+		},
+		"second pattern": {
+			message: "55.3.244.1 deny",
+			want: mapstr.M{
+				"source": mapstr.M{"ip": "55.3.244.1"},
+				"event":  mapstr.M{"action": "deny"},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			event, err := p.Run(&beat.Event{Fields: mapstr.M{"message": tc.message}})
+			require.NoError(t, err)
+
+			tc.want["message"] = tc.message
+			assert.Equal(t, tc.want, event.Fields)
+		})
+	}
+}
+
+func TestProcessorNoMatch(t *testing.T) {
+	p, err := New(conf.MustNewConfigFrom(mapstr.M{"patterns": []string{"%{IP:[source][ip]}"}}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+
+	event, err := p.Run(&beat.Event{Fields: mapstr.M{"message": "no address"}})
+	assert.ErrorIs(t, err, errNoMatch)
+	assert.Equal(t, mapstr.M{
+		"message": "no address",
+		"tags":    []string{"_grokparsefailure"},
+	}, event.Fields)
+
+	p, err = New(conf.MustNewConfigFrom(mapstr.M{
+		"patterns":       []string{"%{IP:[source][ip]}"},
+		"tag_on_failure": []string{"unparsed"},
+		"ignore_failure": true,
+	}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	event, err = p.Run(&beat.Event{Fields: mapstr.M{"message": "no address"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"unparsed"}, event.Fields["tags"])
+}
+
+func TestProcessorMissingField(t *testing.T) {
+	p, err := New(conf.MustNewConfigFrom(mapstr.M{"patterns": []string{"%{IP:[source][ip]}"}}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	_, err = p.Run(&beat.Event{Fields: mapstr.M{}})
+	assert.ErrorContains(t, err, "not found")
+
+	p, err = New(conf.MustNewConfigFrom(mapstr.M{"patterns": []string{"%{IP:[source][ip]}"}, "ignore_missing": true}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	event, err := p.Run(&beat.Event{Fields: mapstr.M{}})
+	assert.NoError(t, err)
+	assert.Equal(t, mapstr.M{}, event.Fields)
+}
+
+func TestProcessorExistingKeys(t *testing.T) {
+	pattern := "%{IP:[source][ip]} %{GREEDYDATA:message}"
+
+	p, err := New(conf.MustNewConfigFrom(mapstr.M{"patterns": []string{pattern}}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	fields := mapstr.M{"message": "10.0.0.1 hello", "source": mapstr.M{"ip": "127.0.0.1"}}
+	event, err := p.Run(&beat.Event{Fields: fields.Clone()})
+	assert.ErrorContains(t, err, "cannot override existing key")
+	assert.Equal(t, "127.0.0.1", event.Fields["source"].(mapstr.M)["ip"])
+
+	// The field parsed is replaced even without overwrite_keys.
+	event, err = p.Run(&beat.Event{Fields: mapstr.M{"message": "10.0.0.1 hello"}})
+	require.NoError(t, err)
+	assert.Equal(t, "hello", event.Fields["message"])
+
+	p, err = New(conf.MustNewConfigFrom(mapstr.M{"patterns": []string{pattern}, "overwrite_keys": true}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	event, err = p.Run(&beat.Event{Fields: fields.Clone()})
+	require.NoError(t, err)
+	assert.Equal(t, mapstr.M{"message": "hello", "source": mapstr.M{"ip": "10.0.0.1"}}, event.Fields)
+
+	p, err = New(conf.MustNewConfigFrom(mapstr.M{"patterns": []string{pattern}, "target_prefix": "grok"}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	event, err = p.Run(&beat.Event{Fields: fields.Clone()})
+	require.NoError(t, err)
+	assert.Equal(t, mapstr.M{"message": "hello", "source": mapstr.M{"ip": "10.0.0.1"}}, event.Fields["grok"])
+}
+
+func TestProcessorConfig(t *testing.T) {
+	c := defaultConfig()
+	assert.Error(t, conf.MustNewConfigFrom(mapstr.M{}).Unpack(&c))
+
+	c = defaultConfig()
+	require.NoError(t, conf.MustNewConfigFrom(mapstr.M{"patterns": []string{"%{NOPE:a}"}}).Unpack(&c))
+	_, err := newGrok(c, logptest.NewTestingLogger(t, ""))
+	assert.ErrorContains(t, err, "unknown pattern NOPE")
+}
@@ -0,0 +1,74 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package lookup
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+
+	matchExact    = "exact"
+	matchCIDR     = "cidr"
+	matchWildcard = "wildcard"
+)
+
+type config struct {
+	File           string        `config:"file"            validate:"required"`
+	Format         string        `config:"format"`
+	Separator      string        `config:"separator"`
+	Key            string        `config:"key"             validate:"required"`
+	Field          string        `config:"field"           validate:"required"`
+	TargetField    string        `config:"target_field"`
+	Match          string        `config:"match"`
+	ReloadInterval time.Duration `config:"reload_interval" validate:"min=0"`
+	IgnoreMissing  bool          `config:"ignore_missing"`
+	IgnoreFailure  bool          `config:"ignore_failure"`
+	ID             string        `config:"id"`
+}
+
+func defaultConfig() config {
+	return config{
+		Separator:      ",",
+		TargetField:    "lookup",
+		Match:          matchExact,
+		ReloadInterval: time.Minute,
+	}
+}
+
+func (c *config) Validate() error {
+	switch c.Format {
+	case "", formatCSV, formatNDJSON:
+	default:
+		return fmt.Errorf("invalid format [%s], it must be %s or %s", c.Format, formatCSV, formatNDJSON)
+	}
+	switch c.Match {
+	case matchExact, matchCIDR, matchWildcard:
+	default:
+		return fmt.Errorf("invalid match [%s], it must be one of %s, %s or %s", c.Match, matchExact, matchCIDR, matchWildcard)
+	}
+	if utf8.RuneCountInString(c.Separator) != 1 {
+		return errors.New("separator must be a single character")
+	}
+	return nil
+}
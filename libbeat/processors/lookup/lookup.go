@@ -0,0 +1,211 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package lookup
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+const procName = "lookup"
+
+func init() {
+	// We cannot use this as a JS plugin as it is stateful and includes a Close method.
+	processors.RegisterPlugin(procName, New)
+}
+
+type processor struct {
+	config
+	log *logp.Logger
+
+	path  string // file resolved in SetPaths
+	table atomic.Pointer[table]
+
+	done      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// New constructs a new lookup processor.
+func New(cfg *conf.C, log *logp.Logger) (beat.Processor, error) {
+	c := defaultConfig()
+	if err := cfg.Unpack(&c); err != nil {
+		return nil, fmt.Errorf("fail to unpack the %v processor configuration: %w", procName, err)
+	}
+
+	return newLookup(c, log)
+}
+
+func newLookup(c config, logger *logp.Logger) (*processor, error) {
+	log := logger.Named(procName)
+	if c.ID != "" {
+		log = log.With("instance_id", c.ID)
+	}
+	return &processor{config: c, log: log, done: make(chan struct{})}, nil
+}
+
+// SetPaths loads the table, resolving its file relative to the configuration
+// path, and starts watching the file for changes. This method must be called
+// before the processor can be used.
+func (p *processor) SetPaths(path *paths.Path) error {
+	p.path = path.Resolve(paths.Config, p.File)
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return fmt.Errorf("%v processor could not read its table: %w", procName, err)
+	}
+	if err := p.load(); err != nil {
+		return err
+	}
+
+	if p.ReloadInterval > 0 {
+		p.wg.Add(1)
+		go p.watch(info)
+	}
+	return nil
+}
+
+// load reads the table and replaces the one in use.
+func (p *processor) load() error {
+	separator := []rune(p.Separator)[0]
+	rows, err := readTable(p.path, p.Format, separator, p.Key)
+	if err != nil {
+		return fmt.Errorf("%v processor failed to read the table %s: %w", procName, p.path, err)
+	}
+	t, err := newTable(rows, p.Match)
+	if err != nil {
+		return fmt.Errorf("%v processor failed to load the table %s: %w", procName, p.path, err)
+	}
+	p.table.Store(&t)
+	p.log.Infof("Loaded %d rows from %s", len(rows), p.path)
+	return nil
+}
+
+// watch reloads the table when the modification time or the size of its file
+// change, until the processor is closed. The table in use is kept when the
+// file can't be loaded.
+func (p *processor) watch(info os.FileInfo) {
+	defer p.wg.Done()
+	ticker := time.NewTicker(p.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+
+		current, err := os.Stat(p.path)
+		if err != nil {
+			p.log.Warnf("Failed to check the table %s for changes: %v", p.path, err)
+			continue
+		}
+		if current.ModTime().Equal(info.ModTime()) && current.Size() == info.Size() {
+			continue
+		}
+		info = current
+		if err := p.load(); err != nil {
+			p.log.Errorf("Keeping the previous table: %v", err)
+		}
+	}
+}
+
+func (p *processor) String() string {
+	return fmt.Sprintf("%v=[file=%v, key=%v, field=%v, target_field=%v, match=%v]", procName, p.File, p.Key, p.Field, p.TargetField, p.Match)
+}
+
+// Run merges the fields of the row matching the value of the field into the
+// target field. When the field holds a list, the first value with a match is
+// used.
+func (p *processor) Run(event *beat.Event) (*beat.Event, error) {
+	err := p.enrich(event)
+	if err != nil && !p.IgnoreFailure {
+		return event, err
+	}
+	return event, nil
+}
+
+func (p *processor) enrich(event *beat.Event) error {
+	t := p.table.Load()
+	if t == nil {
+		return fmt.Errorf("%v processor table not loaded", procName)
+	}
+
+	v, err := event.GetValue(p.Field)
+	if err != nil {
+		if p.IgnoreMissing && errors.Is(err, mapstr.ErrKeyNotFound) {
+			return nil
+		}
+		return fmt.Errorf("could not fetch value for field %s: %w", p.Field, err)
+	}
+
+	for _, value := range values(v) {
+		fields, ok := (*t).lookup(value)
+		if !ok {
+			continue
+		}
+		if p.TargetField == "" {
+			event.DeepUpdate(fields.Clone())
+			return nil
+		}
+		update := mapstr.M{}
+		if _, err := update.Put(p.TargetField, fields.Clone()); err != nil {
+			return fmt.Errorf("failed to write lookup fields: %w", err)
+		}
+		event.DeepUpdate(update)
+		return nil
+	}
+	return nil
+}
+
+// values returns the values of a field as strings.
+func values(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []any:
+		s := make([]string, len(v))
+		for i, e := range v {
+			s[i] = fmt.Sprint(e)
+		}
+		return s
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
+
+// Close stops watching the file of the table.
+func (p *processor) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.done)
+		p.wg.Wait()
+	})
+	return nil
+}
@@ -0,0 +1,191 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package lookup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+const assets = `ip,owner,env
+10.0.0.1,alice,prod
+10.0.0.2,bob,dev
+`
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+func TestLookup(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "assets.csv"), assets)
+	p, err := New(conf.MustNewConfigFrom(mapstr.M{
+		"file":         "assets.csv",
+		"key":          "ip",
+		"field":        "host.ip",
+		"target_field": "asset",
+	}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	require.NoError(t, p.(processors.PathSetter).SetPaths(&paths.Path{Config: dir, Data: dir}))
+	t.Cleanup(func() { require.NoError(t, processors.Close(p)) })
+
+	for name, tc := range map[string]struct {
+		fields mapstr.M
+		want   mapstr.M
+	}{
+		"single value": {
+			fields: mapstr.M{"host": mapstr.M{"ip": "10.0.0.2"}},
+			want:   mapstr.M{"host": mapstr.M{"ip": "10.0.0.2"}, "asset": mapstr.M{"ip": "10.0.0.2", "owner": "bob", "env": "dev"}},
+		},
+		"first match of a list": {
+			fields: mapstr.M{"host": mapstr.M{"ip": []any{"fe80::1", "10.0.0.1", "10.0.0.2"}}},
+			want:   mapstr.M{"host": mapstr.M{"ip": []any{"fe80::1", "10.0.0.1", "10.0.0.2"}}, "asset": mapstr.M{"ip": "10.0.0.1", "owner": "alice", "env": "prod"}},
+		},
+		"merged into the target": {
+			fields: mapstr.M{"host": mapstr.M{"ip": "10.0.0.1"}, "asset": mapstr.M{"id": "a1", "env": "test"}},
+			want:   mapstr.M{"host": mapstr.M{"ip": "10.0.0.1"}, "asset": mapstr.M{"id": "a1", "ip": "10.0.0.1", "owner": "alice", "env": "prod"}},
+		},
+		"no match": {
+			fields: mapstr.M{"host": mapstr.M{"ip": "10.0.0.3"}},
+			want:   mapstr.M{"host": mapstr.M{"ip": "10.0.0.3"}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			event, err := p.Run(&beat.Event{Fields: tc.fields})
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, event.Fields)
+		})
+	}
+
+	// Events don't share the fields of the table.
+	event, err := p.Run(&beat.Event{Fields: mapstr.M{"host": mapstr.M{"ip": "10.0.0.1"}}})
+	require.NoError(t, err)
+	_, _ = event.PutValue("asset.owner", "mallory")
+	event, err = p.Run(&beat.Event{Fields: mapstr.M{"host": mapstr.M{"ip": "10.0.0.1"}}})
+	require.NoError(t, err)
+	owner, _ := event.GetValue("asset.owner")
+	assert.Equal(t, "alice", owner)
+}
+
+func TestLookupRoot(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "hosts.ndjson"), `{"host":{"name":"web-*","role":"frontend"}}
+{"host":{"name":"db-*","role":"database"}}
+`)
+	p, err := New(conf.MustNewConfigFrom(mapstr.M{
+		"file":         "hosts.ndjson",
+		"key":          "host.name",
+		"field":        "host.name",
+		"target_field": "",
+		"match":        "wildcard",
+	}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	require.NoError(t, p.(processors.PathSetter).SetPaths(&paths.Path{Config: dir, Data: dir}))
+	t.Cleanup(func() { require.NoError(t, processors.Close(p)) })
+
+	event, err := p.Run(&beat.Event{Fields: mapstr.M{"host": mapstr.M{"name": "db-3", "id": "x"}}})
+	require.NoError(t, err)
+	assert.Equal(t, mapstr.M{"host": mapstr.M{"name": "db-*", "id": "x", "role": "database"}}, event.Fields)
+}
+
+func TestLookupMissing(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "assets.csv"), assets)
+	cfg := mapstr.M{"file": "assets.csv", "key": "ip", "field": "host.ip"}
+
+	p, err := New(conf.MustNewConfigFrom(cfg), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	require.NoError(t, p.(processors.PathSetter).SetPaths(&paths.Path{Config: dir, Data: dir}))
+	t.Cleanup(func() { require.NoError(t, processors.Close(p)) })
+	_, err = p.Run(&beat.Event{Fields: mapstr.M{}})
+	assert.ErrorContains(t, err, "could not fetch value for field host.ip")
+
+	cfg["ignore_missing"] = true
+	ignoring, err := New(conf.MustNewConfigFrom(cfg), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	require.NoError(t, ignoring.(processors.PathSetter).SetPaths(&paths.Path{Config: dir, Data: dir}))
+	t.Cleanup(func() { require.NoError(t, processors.Close(ignoring)) })
+	event, err := ignoring.Run(&beat.Event{Fields: mapstr.M{}})
+	assert.NoError(t, err)
+	assert.Equal(t, mapstr.M{}, event.Fields)
+}
+
+func TestLookupReload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "assets.csv")
+	writeFile(t, path, assets)
+	p, err := New(conf.MustNewConfigFrom(mapstr.M{
+		"file":            path,
+		"key":             "ip",
+		"field":           "ip",
+		"reload_interval": "10ms",
+	}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	require.NoError(t, p.(processors.PathSetter).SetPaths(&paths.Path{Config: t.TempDir(), Data: t.TempDir()}))
+	t.Cleanup(func() { require.NoError(t, processors.Close(p)) })
+
+	owner := func() any {
+		event, err := p.Run(&beat.Event{Fields: mapstr.M{"ip": "10.0.0.1"}})
+		require.NoError(t, err)
+		v, _ := event.GetValue("lookup.owner")
+		return v
+	}
+	assert.Equal(t, "alice", owner())
+
+	writeFile(t, path, "ip,owner\n10.0.0.1,carol\n")
+	assert.Eventually(t, func() bool { return owner() == "carol" }, 5*time.Second, 10*time.Millisecond)
+
+	// A broken table doesn't replace the one in use.
+	writeFile(t, path, "owner\ndave\n")
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, "carol", owner())
+}
+
+func TestLookupLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "cidrs.csv"), "net,zone\nnot-a-cidr/8,dmz\n")
+
+	for name, cfg := range map[string]mapstr.M{
+		"missing file": {"file": "missing.csv", "key": "ip", "field": "ip"},
+		"invalid cidr": {"file": "cidrs.csv", "key": "net", "field": "ip", "match": "cidr"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := defaultConfig()
+			require.NoError(t, conf.MustNewConfigFrom(cfg).Unpack(&c))
+			p, err := newLookup(c, logptest.NewTestingLogger(t, ""))
+			require.NoError(t, err)
+			assert.Error(t, p.SetPaths(&paths.Path{Home: dir, Config: dir, Data: dir, Logs: dir}))
+			assert.NoError(t, p.Close())
+		})
+	}
+}
@@ -0,0 +1,283 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package lookup
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// row is an entry of a table: the value of its key, and the fields merged
+// into the matching events.
+type row struct {
+	key    string
+	fields mapstr.M
+}
+
+// readTable reads the rows of a CSV or NDJSON file. When format is empty, it
+// is deduced from the extension of the file.
+func readTable(path, format string, separator rune, key string) ([]row, error) {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".ndjson", ".jsonl", ".json":
+			format = formatNDJSON
+		default:
+			format = formatCSV
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if format == formatNDJSON {
+		return readNDJSON(f, key)
+	}
+	return readCSV(f, separator, key)
+}
+
+// readCSV reads the rows of a CSV table whose first line holds the names of
+// the columns. Empty values are left out.
+func readCSV(r io.Reader, separator rune, key string) ([]row, error) {
+	cr := csv.NewReader(r)
+	cr.Comma = separator
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("missing header line")
+		}
+		return nil, err
+	}
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	keyColumn := slices.Index(header, key)
+	if keyColumn < 0 {
+		return nil, fmt.Errorf("key column %s not found in the header", key)
+	}
+
+	var rows []row
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if record[keyColumn] == "" {
+			continue
+		}
+		fields := mapstr.M{}
+		for i, v := range record {
+			if v != "" {
+				if _, err := fields.Put(header[i], v); err != nil {
+					return nil, fmt.Errorf("invalid column %s: %w", header[i], err)
+				}
+			}
+		}
+		rows = append(rows, row{key: record[keyColumn], fields: fields})
+	}
+}
+
+// readNDJSON reads the rows of a table with a JSON object per line.
+func readNDJSON(r io.Reader, key string) ([]row, error) {
+	var rows []row
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		b := bytes.TrimSpace(scanner.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+		var fields mapstr.M
+		if err := json.Unmarshal(b, &fields); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		v, err := fields.GetValue(key)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: missing key %s", line, key)
+		}
+		rows = append(rows, row{key: fmt.Sprint(v), fields: fields})
+	}
+	return rows, scanner.Err()
+}
+
+// table finds the fields of the row matching a value.
+type table interface {
+	lookup(value string) (mapstr.M, bool)
+}
+
+// newTable indexes the rows for the match mode. When several rows have the
+// same key, the first one is kept.
+func newTable(rows []row, match string) (table, error) {
+	switch match {
+	case matchCIDR:
+		return newCIDRTable(rows)
+	case matchWildcard:
+		return newWildcardTable(rows), nil
+	default:
+		t := exactTable{}
+		for _, r := range rows {
+			if _, ok := t[r.key]; !ok {
+				t[r.key] = r.fields
+			}
+		}
+		return t, nil
+	}
+}
+
+type exactTable map[string]mapstr.M
+
+func (t exactTable) lookup(value string) (mapstr.M, bool) {
+	fields, ok := t[value]
+	return fields, ok
+}
+
+// cidrTable matches IP addresses with the longest prefix containing them.
+// Keys are CIDRs or single addresses.
+type cidrTable struct {
+	// bits are the lengths of the prefixes, in decreasing order.
+	bits     []int
+	prefixes map[netip.Prefix]mapstr.M
+}
+
+func newCIDRTable(rows []row) (*cidrTable, error) {
+	t := &cidrTable{prefixes: map[netip.Prefix]mapstr.M{}}
+	for _, r := range rows {
+		var prefix netip.Prefix
+		if strings.Contains(r.key, "/") {
+			p, err := netip.ParsePrefix(r.key)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q: %w", r.key, err)
+			}
+			if p.Addr().Is4In6() && p.Bits() >= 96 {
+				p = netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96)
+			}
+			prefix = p.Masked()
+		} else {
+			addr, err := netip.ParseAddr(r.key)
+			if err != nil {
+				return nil, fmt.Errorf("invalid IP address %q: %w", r.key, err)
+			}
+			addr = addr.Unmap()
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		if _, ok := t.prefixes[prefix]; ok {
+			continue
+		}
+		t.prefixes[prefix] = r.fields
+		if !slices.Contains(t.bits, prefix.Bits()) {
+			t.bits = append(t.bits, prefix.Bits())
+		}
+	}
+	slices.Sort(t.bits)
+	slices.Reverse(t.bits)
+	return t, nil
+}
+
+func (t *cidrTable) lookup(value string) (mapstr.M, bool) {
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return nil, false
+	}
+	addr = addr.Unmap().WithZone("")
+	for _, bits := range t.bits {
+		prefix, err := addr.Prefix(bits)
+		if err != nil {
+			continue
+		}
+		if fields, ok := t.prefixes[prefix]; ok {
+			return fields, true
+		}
+	}
+	return nil, false
+}
+
+// wildcardTable matches values with keys where * matches any sequence of
+// characters and ? any single character. Keys without wildcards are tried
+// first, then the patterns in the order of the table.
+type wildcardTable struct {
+	exact    exactTable
+	patterns []row
+}
+
+func newWildcardTable(rows []row) *wildcardTable {
+	t := &wildcardTable{exact: exactTable{}}
+	for _, r := range rows {
+		if strings.ContainsAny(r.key, "*?") {
+			t.patterns = append(t.patterns, r)
+		} else if _, ok := t.exact[r.key]; !ok {
+			t.exact[r.key] = r.fields
+		}
+	}
+	return t
+}
+
+func (t *wildcardTable) lookup(value string) (mapstr.M, bool) {
+	if fields, ok := t.exact[value]; ok {
+		return fields, true
+	}
+	for _, r := range t.patterns {
+		if wildcardMatch(r.key, value) {
+			return r.fields, true
+		}
+	}
+	return nil, false
+}
+
+// wildcardMatch reports whether s matches pattern, where * matches any
+// sequence of characters and ? any single character.
+func wildcardMatch(pattern, s string) bool {
+	p, v := []rune(pattern), []rune(s)
+	var i, j int
+	star, next := -1, 0
+	for j < len(v) {
+		switch {
+		case i < len(p) && (p[i] == '?' || p[i] == v[j]):
+			i++
+			j++
+		case i < len(p) && p[i] == '*':
+			// Try to match nothing first, and one more character of s
+			// each time the rest of the pattern fails.
+			star, next = i, j
+			i++
+		case star >= 0:
+			next++
+			i, j = star+1, next
+		default:
+			return false
+		}
+	}
+	for i < len(p) && p[i] == '*' {
+		i++
+	}
+	return i == len(p)
+}
@@ -0,0 +1,136 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package lookup
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestReadCSV(t *testing.T) {
+	rows, err := readCSV(strings.NewReader("\ufeffip,owner.name,env\n10.0.0.1,alice,prod\n10.0.0.2,,dev\n,bob,dev\n"), ',', "ip")
+	require.NoError(t, err)
+	assert.Equal(t, []row{
+		{key: "10.0.0.1", fields: mapstr.M{"ip": "10.0.0.1", "owner": mapstr.M{"name": "alice"}, "env": "prod"}},
+		{key: "10.0.0.2", fields: mapstr.M{"ip": "10.0.0.2", "env": "dev"}},
+	}, rows)
+
+	_, err = readCSV(strings.NewReader("host,env\n"), ',', "ip")
+	assert.ErrorContains(t, err, "key column ip not found")
+	_, err = readCSV(strings.NewReader(""), ',', "ip")
+	assert.ErrorContains(t, err, "missing header")
+	_, err = readCSV(strings.NewReader("ip,env\n10.0.0.1\n"), ',', "ip")
+	assert.Error(t, err, "wrong number of fields")
+}
+
+func TestReadNDJSON(t *testing.T) {
+	rows, err := readNDJSON(strings.NewReader(`{"host":{"name":"web-1"},"id":7}
+
+{"host":{"name":"web-2"},"tags":["a"]}
+`), "host.name")
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "web-1", rows[0].key)
+	assert.Equal(t, float64(7), rows[0].fields["id"])
+	assert.Equal(t, "web-2", rows[1].key)
+
+	_, err = readNDJSON(strings.NewReader(`{"id":7}`), "host.name")
+	assert.ErrorContains(t, err, "line 1: missing key host.name")
+	_, err = readNDJSON(strings.NewReader("{}\n{"), "id")
+	assert.ErrorContains(t, err, "line 1")
+}
+
+func testRows(keys ...string) []row {
+	rows := make([]row, len(keys))
+	for i, k := range keys {
+		rows[i] = row{key: k, fields: mapstr.M{"row": i}}
+	}
+	return rows
+}
+
+func assertLookup(t *testing.T, tbl table, value string, want int) {
+	t.Helper()
+	fields, ok := tbl.lookup(value)
+	if want < 0 {
+		assert.False(t, ok, "no match for %s", value)
+		return
+	}
+	require.True(t, ok, "match for %s", value)
+	assert.Equal(t, want, fields["row"], "match for %s", value)
+}
+
+func TestExactTable(t *testing.T) {
+	tbl, err := newTable(testRows("a", "b", "a"), matchExact)
+	require.NoError(t, err)
+	assertLookup(t, tbl, "a", 0)
+	assertLookup(t, tbl, "b", 1)
+	assertLookup(t, tbl, "A", -1)
+}
+
+func TestCIDRTable(t *testing.T) {
+	tbl, err := newTable(testRows("10.0.0.0/8", "10.1.0.0/16", "10.1.2.3", "2001:db8::/32", "::ffff:192.168.0.0/112"), matchCIDR)
+	require.NoError(t, err)
+	assertLookup(t, tbl, "10.2.3.4", 0)
+	assertLookup(t, tbl, "10.1.3.4", 1)
+	assertLookup(t, tbl, "10.1.2.3", 2)
+	assertLookup(t, tbl, "::ffff:10.1.2.3", 2)
+	assertLookup(t, tbl, "2001:db8::1", 3)
+	assertLookup(t, tbl, "192.168.1.1", 4)
+	assertLookup(t, tbl, "11.0.0.1", -1)
+	assertLookup(t, tbl, "not an ip", -1)
+
+	_, err = newTable(testRows("10.0.0.0/33"), matchCIDR)
+	assert.ErrorContains(t, err, "invalid CIDR")
+	_, err = newTable(testRows("web-1"), matchCIDR)
+	assert.ErrorContains(t, err, "invalid IP address")
+}
+
+func TestWildcardTable(t *testing.T) {
+	tbl, err := newTable(testRows("*.example.com", "db-?", "api.example.com", "*"), matchWildcard)
+	require.NoError(t, err)
+	assertLookup(t, tbl, "api.example.com", 2)
+	assertLookup(t, tbl, "www.example.com", 0)
+	assertLookup(t, tbl, "db-1", 1)
+	assertLookup(t, tbl, "db-10", 3)
+}
+
+func TestWildcardMatch(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"", "", true},
+		{"*", "", true},
+		{"a*b*c", "aXbYc", true},
+		{"a*b*c", "abcbc", true},
+		{"a*c", "abcd", false},
+		{"?é*", "xé", true},
+		{"??", "é", false},
+		{"**x", "abx", true},
+		{"abc", "abd", false},
+	} {
+		assert.Equal(t, tc.want, wildcardMatch(tc.pattern, tc.s), "%q %q", tc.pattern, tc.s)
+	}
+}
@@ -0,0 +1,268 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package processors
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/monitoring"
+	"github.com/elastic/elastic-agent-libs/monitoring/adapter"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+// TraceMetaKey is the @metadata key holding the processor trace of a sampled
+// event. Events carrying the key get an entry appended by every processor
+// they pass through.
+const TraceMetaKey = "processor_trace"
+
+// metricsNamespace is the registry processor metrics are reported under,
+// relative to the registry registered with RegisterMetrics.
+const metricsNamespace = "processors"
+
+const (
+	// GlobalOwner owns the global processors of a beat.
+	GlobalOwner = "global"
+	// DefaultOwner owns the lists of processors created with New, such as
+	// the processors of inputs.
+	DefaultOwner = "input"
+)
+
+var _ PdataProcessor = (*monitoredPdataProcessor)(nil)
+
+// processorMetrics counts the events passing through the processors sharing
+// one metrics key. Every event is counted as in and then as either out or
+// dropped. Errors are counted independently, as a failed processor may still
+// return the event.
+type processorMetrics struct {
+	in      *monitoring.Int
+	out     *monitoring.Int
+	dropped *monitoring.Int
+	errors  *monitoring.Int
+	latency metrics.Sample
+}
+
+var (
+	processorMetricsMu sync.Mutex
+	// metricsRegistries are the registries registered by beat paths.
+	metricsRegistries = map[*paths.Path]*monitoring.Registry{}
+	// processorMetricsByKey are the metrics of each registry by key.
+	processorMetricsByKey = map[*monitoring.Registry]map[string]*processorMetrics{}
+)
+
+// RegisterMetrics registers the registry the processors given the paths in
+// SetPaths report their metrics under. The first registration for the paths
+// is kept, so the metrics of a beat go to its main publisher pipeline. The
+// returned function removes the registration and the metrics.
+func RegisterMetrics(p *paths.Path, reg *monitoring.Registry) (unregister func()) {
+	if p == nil || reg == nil {
+		return func() {}
+	}
+	processorMetricsMu.Lock()
+	defer processorMetricsMu.Unlock()
+	if _, ok := metricsRegistries[p]; ok {
+		return func() {}
+	}
+	metricsRegistries[p] = reg
+
+	return func() {
+		processorMetricsMu.Lock()
+		defer processorMetricsMu.Unlock()
+		delete(metricsRegistries, p)
+		if _, ok := processorMetricsByKey[reg]; ok {
+			delete(processorMetricsByKey, reg)
+			reg.Remove(metricsNamespace)
+		}
+	}
+}
+
+// getProcessorMetrics returns the metrics for key in the registry registered
+// for p, registering them on first use, or nil if there is no registry.
+// Metrics are kept as long as the registry is registered, so counters stay
+// cumulative across configuration reloads.
+func getProcessorMetrics(p *paths.Path, key string, logger *logp.Logger) *processorMetrics {
+	processorMetricsMu.Lock()
+	defer processorMetricsMu.Unlock()
+
+	parent, ok := metricsRegistries[p]
+	if !ok {
+		return nil
+	}
+	byKey := processorMetricsByKey[parent]
+	if m, ok := byKey[key]; ok {
+		return m
+	}
+
+	reg := parent.GetOrCreateRegistry(metricsNamespace+"."+key, monitoring.DoNotReport)
+	if reg == nil {
+		// The key collides with an existing variable; keep counting
+		// without exposing the metrics.
+		logger.Warnf("Cannot register metrics for processor %q", key)
+		reg = monitoring.NewRegistry()
+	}
+	m := &processorMetrics{
+		in:      monitoring.NewInt(reg, "events.in"),
+		out:     monitoring.NewInt(reg, "events.out"),
+		dropped: monitoring.NewInt(reg, "events.dropped"),
+		errors:  monitoring.NewInt(reg, "events.errors"),
+		latency: metrics.NewUniformSample(2048),
+	}
+	_ = adapter.NewGoMetrics(reg, "histogram", logger, adapter.Accept).
+		Register("process_time", metrics.NewHistogram(m.latency))
+	if byKey == nil {
+		byKey = map[string]*processorMetrics{}
+		processorMetricsByKey[parent] = byKey
+	}
+	byKey[key] = m
+	return m
+}
+
+// record updates the metrics for one processor run and returns the result
+// reported in traces. Nil metrics record nothing.
+func (m *processorMetrics) record(took time.Duration, dropped bool, err error) string {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	} else if dropped {
+		result = "dropped"
+	}
+	if m == nil {
+		return result
+	}
+
+	m.in.Inc()
+	m.latency.Update(took.Nanoseconds())
+	if err != nil {
+		m.errors.Inc()
+	}
+	if dropped {
+		m.dropped.Inc()
+	} else {
+		m.out.Inc()
+	}
+	return result
+}
+
+// monitoredProcessor reports events in, out, dropped and errored and the
+// processing time of the wrapped processor, and annotates sampled events with
+// a trace entry. The optional interfaces are forwarded to the wrapped
+// processor, so wrapping does not change how it is set up or closed.
+//
+// The metrics are bound in SetPaths to the registry registered for the paths
+// of the beat, as a beat receiver reports to a registry of its own.
+type monitoredProcessor struct {
+	beat.Processor
+
+	key     string
+	log     *logp.Logger
+	metrics atomic.Pointer[processorMetrics]
+}
+
+// monitoredPdataProcessor extends monitoredProcessor with the pdata fast path.
+// It is only created when the wrapped processor implements PdataProcessor.
+type monitoredPdataProcessor struct {
+	*monitoredProcessor
+	pdataProc PdataProcessor
+}
+
+// newMonitoredProcessor wraps p to report its metrics under key. Processors
+// using the same key share their metrics.
+func newMonitoredProcessor(p beat.Processor, key string, logger *logp.Logger) beat.Processor {
+	mp := &monitoredProcessor{
+		Processor: p,
+		key:       key,
+		log:       logger,
+	}
+	if pdataProc, ok := p.(PdataProcessor); ok {
+		return &monitoredPdataProcessor{monitoredProcessor: mp, pdataProc: pdataProc}
+	}
+	return mp
+}
+
+func (p *monitoredProcessor) Run(event *beat.Event) (*beat.Event, error) {
+	start := time.Now()
+	out, err := p.Processor.Run(event)
+	took := time.Since(start)
+
+	result := p.metrics.Load().record(took, out == nil, err)
+	if out != nil {
+		p.trace(out, took, result, err)
+	}
+	return out, err
+}
+
+// trace appends an entry to the processor trace of out if the event has been
+// sampled for tracing.
+func (p *monitoredProcessor) trace(out *beat.Event, took time.Duration, result string, err error) {
+	if out.Meta == nil {
+		return
+	}
+	entries, ok := out.Meta[TraceMetaKey].([]mapstr.M)
+	if !ok {
+		return
+	}
+	entry := mapstr.M{
+		"processor": p.key,
+		"took":      took.String(),
+		"result":    result,
+	}
+	if err != nil {
+		entry["error"] = err.Error()
+	}
+	out.Meta[TraceMetaKey] = append(entries, entry)
+}
+
+func (p *monitoredProcessor) String() string {
+	return p.Processor.String()
+}
+
+// Close closes the wrapped processor if it implements Closer.
+func (p *monitoredProcessor) Close() error {
+	return Close(p.Processor)
+}
+
+// SetPaths binds the metrics to the registry registered for paths, unless
+// they are already bound, and delegates to the wrapped processor if it
+// implements PathSetter.
+func (p *monitoredProcessor) SetPaths(paths *paths.Path) error {
+	if p.metrics.Load() == nil {
+		if m := getProcessorMetrics(paths, p.key, p.log); m != nil {
+			p.metrics.CompareAndSwap(nil, m)
+		}
+	}
+	if setter, ok := p.Processor.(PathSetter); ok {
+		return setter.SetPaths(paths)
+	}
+	return nil
+}
+
+// RunPdata delegates to the wrapped processor's RunPdata. Events handled on
+// the pdata path are counted but never traced, as they carry no @metadata.
+func (p *monitoredPdataProcessor) RunPdata(body pcommon.Map) (bool, error) {
+	start := time.Now()
+	drop, err := p.pdataProc.RunPdata(body)
+	p.metrics.Load().record(time.Since(start), drop, err)
+	return drop, err
+}
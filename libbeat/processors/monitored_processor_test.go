@@ -0,0 +1,221 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package processors
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/monitoring"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+type funcProcessor func(*beat.Event) (*beat.Event, error)
+
+func (f funcProcessor) Run(event *beat.Event) (*beat.Event, error) { return f(event) }
+func (f funcProcessor) String() string                             { return "func-processor" }
+
+// registerTestMetrics registers a new registry for new paths and returns both.
+func registerTestMetrics(t *testing.T) (*paths.Path, *monitoring.Registry) {
+	t.Helper()
+	p, reg := paths.New(), monitoring.NewRegistry()
+	t.Cleanup(RegisterMetrics(p, reg))
+	return p, reg
+}
+
+func processorMetricsSnapshot(t *testing.T, reg *monitoring.Registry, key string) map[string]int64 {
+	t.Helper()
+	sub := reg.GetRegistry(metricsNamespace + "." + key)
+	require.NotNil(t, sub, "metrics for %q are not registered", key)
+	return monitoring.CollectFlatSnapshot(sub, monitoring.Full, false).Ints
+}
+
+func TestMonitoredProcessorMetrics(t *testing.T) {
+	logger := logptest.NewTestingLogger(t, "")
+	errFailed := errors.New("failed")
+
+	var calls int
+	p := newMonitoredProcessor(funcProcessor(func(event *beat.Event) (*beat.Event, error) {
+		calls++
+		switch calls {
+		case 1:
+			return event, nil
+		case 2:
+			return nil, nil
+		case 3:
+			return event, errFailed
+		default:
+			return nil, errFailed
+		}
+	}), "global.0.func", logger)
+	beatPaths, reg := registerTestMetrics(t)
+	require.NoError(t, p.(PathSetter).SetPaths(beatPaths))
+
+	for range 4 {
+		_, _ = p.Run(&beat.Event{Fields: mapstr.M{}})
+	}
+
+	ints := processorMetricsSnapshot(t, reg, "global.0.func")
+	assert.Equal(t, int64(4), ints["events.in"])
+	assert.Equal(t, int64(2), ints["events.out"])
+	assert.Equal(t, int64(2), ints["events.dropped"])
+	assert.Equal(t, int64(2), ints["events.errors"])
+	assert.Equal(t, int64(4), ints["histogram.process_time.count"])
+}
+
+func TestMonitoredProcessorSharedKey(t *testing.T) {
+	logger := logptest.NewTestingLogger(t, "")
+	beatPaths, reg := registerTestMetrics(t)
+	otherPaths, otherReg := registerTestMetrics(t)
+
+	// A reloaded processor keeps counting where the previous one stopped,
+	// the processor of another beat counts in its own registry.
+	a := newMonitoredProcessor(&mockProcessor{}, "input.0.mock", logger)
+	b := newMonitoredProcessor(&mockProcessor{}, "input.0.mock", logger)
+	c := newMonitoredProcessor(&mockProcessor{}, "input.0.mock", logger)
+	require.NoError(t, a.(PathSetter).SetPaths(beatPaths))
+	require.NoError(t, b.(PathSetter).SetPaths(beatPaths))
+	require.NoError(t, c.(PathSetter).SetPaths(otherPaths))
+
+	_, _ = a.Run(&beat.Event{})
+	_, _ = b.Run(&beat.Event{})
+	_, _ = c.Run(&beat.Event{})
+
+	assert.Equal(t, int64(2), processorMetricsSnapshot(t, reg, "input.0.mock")["events.in"])
+	assert.Equal(t, int64(1), processorMetricsSnapshot(t, otherReg, "input.0.mock")["events.in"])
+}
+
+func TestMonitoredProcessorUnregistered(t *testing.T) {
+	logger := logptest.NewTestingLogger(t, "")
+	p := newMonitoredProcessor(&mockProcessor{}, "input.0.mock", logger)
+	require.NoError(t, p.(PathSetter).SetPaths(paths.New()))
+
+	out, err := p.Run(&beat.Event{})
+	require.NoError(t, err)
+	assert.NotNil(t, out)
+}
+
+func TestRegisterMetrics(t *testing.T) {
+	logger := logptest.NewTestingLogger(t, "")
+	beatPaths, reg := registerTestMetrics(t)
+
+	// The first registration for the paths is kept.
+	other := monitoring.NewRegistry()
+	RegisterMetrics(beatPaths, other)()
+
+	p := newMonitoredProcessor(&mockProcessor{}, "global.0.mock", logger)
+	require.NoError(t, p.(PathSetter).SetPaths(beatPaths))
+	_, _ = p.Run(&beat.Event{})
+	assert.Equal(t, int64(1), processorMetricsSnapshot(t, reg, "global.0.mock")["events.in"])
+	assert.Nil(t, other.GetRegistry(metricsNamespace))
+}
+
+func TestMonitoredProcessorTrace(t *testing.T) {
+	logger := logptest.NewTestingLogger(t, "")
+	p := newMonitoredProcessor(funcProcessor(func(event *beat.Event) (*beat.Event, error) {
+		return event, errors.New("failed")
+	}), "traced", logger)
+
+	t.Run("sampled", func(t *testing.T) {
+		event := &beat.Event{Meta: mapstr.M{TraceMetaKey: []mapstr.M{}}}
+		out, err := p.Run(event)
+		require.Error(t, err)
+
+		trace, ok := out.Meta[TraceMetaKey].([]mapstr.M)
+		require.True(t, ok)
+		require.Len(t, trace, 1)
+		assert.Equal(t, "traced", trace[0]["processor"])
+		assert.Equal(t, "error", trace[0]["result"])
+		assert.Equal(t, "failed", trace[0]["error"])
+		assert.NotEmpty(t, trace[0]["took"])
+	})
+
+	t.Run("not sampled", func(t *testing.T) {
+		out, err := p.Run(&beat.Event{})
+		require.Error(t, err)
+		assert.Nil(t, out.Meta)
+	})
+}
+
+func TestMonitoredProcessorForwardsInterfaces(t *testing.T) {
+	logger := logptest.NewTestingLogger(t, "")
+
+	t.Run("plain", func(t *testing.T) {
+		p := newMonitoredProcessor(&mockProcessor{}, t.Name(), logger)
+		_, ok := p.(PdataProcessor)
+		assert.False(t, ok, "processor must not gain RunPdata")
+		assert.Equal(t, "mock-processor", p.String())
+		assert.NoError(t, Close(p))
+	})
+
+	t.Run("path setter and closer", func(t *testing.T) {
+		inner := &mockPathSetterCloserProcessor{}
+		p := newMonitoredProcessor(inner, t.Name(), logger)
+		require.NoError(t, p.(PathSetter).SetPaths(paths.New()))
+		require.NoError(t, Close(p))
+		assert.Equal(t, 1, inner.setPathsCount)
+		assert.Equal(t, 1, inner.closeCount)
+	})
+
+	t.Run("pdata", func(t *testing.T) {
+		inner := &mockPdataProcessor{}
+		p := newMonitoredProcessor(inner, "global.0.pdata", logger)
+		beatPaths, reg := registerTestMetrics(t)
+		require.NoError(t, p.(PathSetter).SetPaths(beatPaths))
+		pp, ok := p.(PdataProcessor)
+		require.True(t, ok)
+		drop, err := pp.RunPdata(pcommon.NewMap())
+		require.NoError(t, err)
+		assert.False(t, drop)
+		assert.Equal(t, 1, inner.pdataCount)
+		assert.Equal(t, int64(1), processorMetricsSnapshot(t, reg, "global.0.pdata")["events.out"])
+	})
+}
+
+func TestMetricsKey(t *testing.T) {
+	logger := logptest.NewTestingLogger(t, "")
+	RegisterPlugin("test-metrics-key", func(*config.C, *logp.Logger) (beat.Processor, error) {
+		return &mockProcessor{}, nil
+	})
+
+	procs, err := NewWithOwner(PluginConfig{
+		config.MustNewConfigFrom(map[string]any{"test-metrics-key": map[string]any{}}),
+		config.MustNewConfigFrom(map[string]any{
+			"if":   map[string]any{"has_fields": []string{"a"}},
+			"then": []any{map[string]any{"test-metrics-key": map[string]any{}}},
+		}),
+		config.MustNewConfigFrom(map[string]any{"test-metrics-key": map[string]any{}}),
+	}, GlobalOwner, logger)
+	require.NoError(t, err)
+	require.Len(t, procs.List, 3)
+
+	assert.Equal(t, "global.0.test-metrics-key", procs.List[0].(*monitoredProcessor).key)
+	assert.Equal(t, "global.2.test-metrics-key", procs.List[2].(*monitoredProcessor).key)
+	ifThen, ok := procs.List[1].(*ClosingIfThenElseProcessor)
+	require.True(t, ok)
+	assert.Equal(t, "global.1.if.then.0.test-metrics-key", ifThen.then.List[0].(*monitoredProcessor).key)
+}
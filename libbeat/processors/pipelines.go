@@ -0,0 +1,110 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package processors
+
+import (
+	"sync"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+// Processors publishing events of their own, such as periodic rollups, find
+// the publisher pipeline of their beat by the paths they are given in
+// SetPaths.
+var (
+	pipelinesMu sync.Mutex
+	pipelines   = map[*paths.Path]beat.PipelineConnector{}
+	closeHooks  = map[*paths.Path]map[*closeHook]struct{}{}
+)
+
+type closeHook struct {
+	fn func()
+}
+
+// RegisterPipeline registers the publisher pipeline of the beat using the
+// given paths. The pipeline calls the returned function when it is
+// disconnected, before its queue is drained: it runs the functions added with
+// OnPipelineClose for the paths, then removes the registration.
+//
+// The first registration for the paths is kept, so pipelines created later
+// by the beat, such as the pipeline of the monitoring reporter, do not take
+// over the events of the processors.
+func RegisterPipeline(p *paths.Path, pipeline beat.PipelineConnector) (closing func()) {
+	if p == nil {
+		return func() {}
+	}
+	pipelinesMu.Lock()
+	if _, ok := pipelines[p]; ok {
+		pipelinesMu.Unlock()
+		return func() {}
+	}
+	pipelines[p] = pipeline
+	pipelinesMu.Unlock()
+
+	return func() {
+		pipelinesMu.Lock()
+		hooks := make([]*closeHook, 0, len(closeHooks[p]))
+		for hook := range closeHooks[p] {
+			hooks = append(hooks, hook)
+		}
+		pipelinesMu.Unlock()
+
+		// The hooks may publish events, so the pipeline is still registered.
+		for _, hook := range hooks {
+			hook.fn()
+		}
+
+		pipelinesMu.Lock()
+		delete(pipelines, p)
+		pipelinesMu.Unlock()
+	}
+}
+
+// LookupPipeline returns the publisher pipeline registered for the given
+// paths, or nil if there is none.
+func LookupPipeline(p *paths.Path) beat.PipelineConnector {
+	pipelinesMu.Lock()
+	defer pipelinesMu.Unlock()
+	return pipelines[p]
+}
+
+// OnPipelineClose adds a function run when the pipeline registered for the
+// given paths is disconnected, while the processors still run. Processors use
+// it to publish their pending events. The returned function removes it.
+func OnPipelineClose(p *paths.Path, fn func()) (remove func()) {
+	if p == nil {
+		return func() {}
+	}
+	hook := &closeHook{fn: fn}
+	pipelinesMu.Lock()
+	if closeHooks[p] == nil {
+		closeHooks[p] = map[*closeHook]struct{}{}
+	}
+	closeHooks[p][hook] = struct{}{}
+	pipelinesMu.Unlock()
+
+	return func() {
+		pipelinesMu.Lock()
+		defer pipelinesMu.Unlock()
+		delete(closeHooks[p], hook)
+		if len(closeHooks[p]) == 0 {
+			delete(closeHooks, p)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package processors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	pubtest "github.com/elastic/beats/v7/libbeat/publisher/testing"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+func TestRegisterPipeline(t *testing.T) {
+	beatPaths := paths.New()
+	main := pubtest.PublisherWithClient(pubtest.NewChanClient(0))
+	reporter := pubtest.PublisherWithClient(pubtest.NewChanClient(0))
+
+	closing := RegisterPipeline(beatPaths, main)
+	var closed int
+	remove := OnPipelineClose(beatPaths, func() { closed++ })
+	defer remove()
+
+	// A pipeline registered later for the same paths is ignored.
+	RegisterPipeline(beatPaths, reporter)()
+	assert.Same(t, main, LookupPipeline(beatPaths))
+	assert.Zero(t, closed)
+
+	closing()
+	assert.Equal(t, 1, closed)
+	assert.Nil(t, LookupPipeline(beatPaths))
+}
@@ -20,6 +20,7 @@ package processors
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"go.opentelemetry.io/collector/pdata/pcommon"
@@ -84,8 +85,16 @@ func NewList(log *logp.Logger) *Processors {
 }
 
 // New creates a list of processors from a list of free user configurations.
-// The logger argument cannot be nil.
+// The logger argument cannot be nil. The metrics of the processors are
+// reported under DefaultOwner.
 func New(config PluginConfig, logger *logp.Logger) (*Processors, error) {
+	return NewWithOwner(config, DefaultOwner, logger)
+}
+
+// NewWithOwner creates a list of processors like New, reporting the metrics
+// of each processor under the owner of the list, followed by the position and
+// the name of the processor, such as `global.2.drop_fields`.
+func NewWithOwner(config PluginConfig, owner string, logger *logp.Logger) (*Processors, error) {
 	procs := NewList(logger)
 
 	// abort closes the processors constructed so far, so a failed list does
@@ -97,10 +106,10 @@ func New(config PluginConfig, logger *logp.Logger) (*Processors, error) {
 		return nil, err
 	}
 
-	for _, procConfig := range config {
+	for i, procConfig := range config {
 		// Handle if/then/else processor which has multiple top-level keys.
 		if procConfig.HasField("if") {
-			p, err := NewIfElseThenProcessor(procConfig, logger)
+			p, err := newIfElseThenProcessor(procConfig, metricsKey(owner, i, "if"), logger)
 			if err != nil {
 				return abort(fmt.Errorf("failed to make if/then/else processor: %w", err))
 			}
@@ -138,7 +147,7 @@ func New(config PluginConfig, logger *logp.Logger) (*Processors, error) {
 			return abort(err)
 		}
 
-		procs.AddProcessor(plugin)
+		procs.AddProcessor(newMonitoredProcessor(plugin, metricsKey(owner, i, actionName), logger))
 	}
 
 	if len(procs.List) > 0 {
@@ -147,6 +156,12 @@ func New(config PluginConfig, logger *logp.Logger) (*Processors, error) {
 	return procs, nil
 }
 
+// metricsKey returns the key the metrics of the processor at position i of
+// the list of owner are reported under.
+func metricsKey(owner string, i int, name string) string {
+	return owner + "." + strconv.Itoa(i) + "." + name
+}
+
 // AddProcessor adds a single Processor to Processors
 func (procs *Processors) AddProcessor(p beat.Processor) {
 	procs.List = append(procs.List, p)
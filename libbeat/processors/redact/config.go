@@ -0,0 +1,77 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package redact
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	modeMask   = "mask"
+	modeHash   = "hash"
+	modeRemove = "remove"
+)
+
+type config struct {
+	Fields        []string        `config:"fields" validate:"required"`
+	Detectors     []string        `config:"detectors"`
+	Patterns      []patternConfig `config:"patterns"`
+	Mode          string          `config:"mode"`
+	Mask          string          `config:"mask"`
+	Hash          hashConfig      `config:"hash"`
+	IgnoreMissing bool            `config:"ignore_missing"`
+	IgnoreFailure bool            `config:"ignore_failure"`
+	ID            string          `config:"id"`
+}
+
+// patternConfig is a custom detector.
+type patternConfig struct {
+	Name  string `config:"name"  validate:"required"`
+	Regex string `config:"regex" validate:"required"`
+}
+
+type hashConfig struct {
+	Method   namedHashMethod     `config:"method"`
+	Encoding namedEncodingMethod `config:"encoding"`
+	Salt     string              `config:"salt"`
+}
+
+func defaultConfig() config {
+	return config{
+		Mode: modeMask,
+		Mask: "[REDACTED]",
+		Hash: hashConfig{
+			Method:   hashes["sha256"],
+			Encoding: encodings["hex"],
+		},
+	}
+}
+
+func (c *config) Validate() error {
+	switch c.Mode {
+	case modeMask, modeHash, modeRemove:
+	default:
+		return fmt.Errorf("invalid mode [%s], it must be one of %s, %s or %s", c.Mode, modeMask, modeHash, modeRemove)
+	}
+	// When detectors isn't set, all the built-in detectors are used.
+	if c.Detectors != nil && len(c.Detectors) == 0 && len(c.Patterns) == 0 {
+		return errors.New("no detectors or patterns configured")
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package redact
+
+import (
+	"net/netip"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// detector finds sensitive values in text. If its regular expression has a
+// capture group, only the text of the first group is redacted.
+type detector struct {
+	name   string
+	re     *regexp.Regexp
+	valid  func(string) bool     // optional check of the matched text
+	locate func(string) [][2]int // optional, the parts of the matched text to redact
+}
+
+// builtinDetectors are tried in order, a value matched by a detector isn't
+// matched by the next ones.
+var builtinDetectors = []detector{
+	{
+		name: "api_token",
+		re: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b` + // AWS access key ID
+			`|\bgh[pousr]_[A-Za-z0-9]{36,255}\b` + // GitHub
+			`|\bxox[abprs]-[A-Za-z0-9-]{10,}` + // Slack
+			`|\b[rs]k_(?:live|test)_[A-Za-z0-9]{16,}` + // Stripe
+			`|\bAIza[0-9A-Za-z_-]{35}` + // Google API key
+			`|\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`), // JWT
+	},
+	{
+		name:   "credit_card",
+		re:     regexp.MustCompile(`\b\d(?:[ -]?\d){12,}\b`),
+		locate: cardNumbers,
+	},
+	{
+		name:  "iban",
+		re:    regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,3})?\b`),
+		valid: validIBAN,
+	},
+	{
+		name: "email",
+		re:   regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`),
+	},
+	{
+		name:  "ip",
+		re:    regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}\b|(?:[0-9A-Fa-f]{0,4}:){2,7}(?:[0-9A-Fa-f]{1,4}|\d{1,3}(?:\.\d{1,3}){3})?`),
+		valid: validIP,
+	},
+}
+
+// match is the position of a value to redact.
+type match struct {
+	start, end int
+	detector   string
+}
+
+// findMatches returns the non overlapping matches of the detectors in s,
+// sorted by position. When matches overlap, the one of the first detector
+// wins.
+func findMatches(detectors []detector, s string) []match {
+	var matches []match
+	for _, d := range detectors {
+		for _, loc := range d.re.FindAllStringSubmatchIndex(s, -1) {
+			start, end := loc[0], loc[1]
+			if len(loc) > 2 {
+				if loc[2] < 0 {
+					continue
+				}
+				start, end = loc[2], loc[3]
+			}
+			if start == end || (d.valid != nil && !d.valid(s[start:end])) {
+				continue
+			}
+			spans := [][2]int{{0, end - start}}
+			if d.locate != nil {
+				spans = d.locate(s[start:end])
+			}
+			for _, span := range spans {
+				if overlaps(matches, start+span[0], start+span[1]) {
+					continue
+				}
+				matches = append(matches, match{start: start + span[0], end: start + span[1], detector: d.name})
+			}
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].start < matches[j].start })
+	return matches
+}
+
+func overlaps(matches []match, start, end int) bool {
+	for _, m := range matches {
+		if start < m.end && m.start < end {
+			return true
+		}
+	}
+	return false
+}
+
+// cardNumbers returns the parts of s, digits separated by single spaces or
+// dashes, that are laid out like payment card numbers and pass the Luhn
+// checksum. The longest number starting at each group of digits wins, so
+// that numbers next to the card number are left out of it.
+func cardNumbers(s string) [][2]int {
+	var groups [][2]int
+	for i := 0; i < len(s); {
+		j := i
+		for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+			j++
+		}
+		groups = append(groups, [2]int{i, j})
+		i = j + 1
+	}
+
+	var spans [][2]int
+	for i := 0; i < len(groups); {
+		last, digits := i, 0
+		for last < len(groups) && digits+groups[last][1]-groups[last][0] <= 19 {
+			digits += groups[last][1] - groups[last][0]
+			last++
+		}
+
+		next := i + 1
+		for j := last - 1; j >= i; j-- {
+			span := [2]int{groups[i][0], groups[j][1]}
+			if cardLayout(groups[i:j+1]) && luhn(s[span[0]:span[1]]) {
+				spans = append(spans, span)
+				next = j + 1
+				break
+			}
+		}
+		i = next
+	}
+	return spans
+}
+
+// cardLayout reports whether groups of digits are laid out like a card
+// number: a single group, groups of 4 digits and a shorter last group, or
+// the 4-6-5 and 4-6-4 layouts of American Express and Diners Club.
+func cardLayout(groups [][2]int) bool {
+	sizes := make([]int, len(groups))
+	total := 0
+	for i, g := range groups {
+		sizes[i] = g[1] - g[0]
+		total += sizes[i]
+	}
+	switch {
+	case total < 13 || total > 19:
+		return false
+	case len(sizes) == 1:
+		return true
+	case len(sizes) == 3 && sizes[0] == 4 && sizes[1] == 6 && (sizes[2] == 5 || sizes[2] == 4):
+		return true
+	}
+	for _, size := range sizes[:len(sizes)-1] {
+		if size != 4 {
+			return false
+		}
+	}
+	return sizes[len(sizes)-1] <= 4
+}
+
+// luhn reports whether the digits of s, ignoring separators, pass the Luhn
+// checksum of payment card numbers.
+func luhn(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && n <= 19 && sum%10 == 0
+}
+
+// validIBAN reports whether s, ignoring spaces, is an IBAN with a valid
+// ISO 13616 checksum.
+func validIBAN(s string) bool {
+	s = strings.ReplaceAll(s, " ", "")
+	if len(s) < 15 || len(s) > 34 {
+		return false
+	}
+	rem := 0
+	for _, c := range s[4:] + s[:4] {
+		switch {
+		case c >= '0' && c <= '9':
+			rem = (rem*10 + int(c-'0')) % 97
+		case c >= 'A' && c <= 'Z':
+			rem = (rem*100 + int(c-'A') + 10) % 97
+		default:
+			return false
+		}
+	}
+	return rem == 1
+}
+
+func validIP(s string) bool {
+	_, err := netip.ParseAddr(s)
+	return err == nil
+}
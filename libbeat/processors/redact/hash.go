@@ -0,0 +1,74 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package redact
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// The hash methods and encodings have the names of the fingerprint
+// processor ones. Only FIPS approved methods are supported.
+
+type namedHashMethod struct {
+	Name string
+	Hash func() hash.Hash
+}
+
+var hashes = map[string]namedHashMethod{
+	"sha256": {Name: "sha256", Hash: sha256.New},
+	"sha384": {Name: "sha384", Hash: sha512.New384},
+	"sha512": {Name: "sha512", Hash: sha512.New},
+}
+
+// Unpack creates the hash method from the given string.
+func (f *namedHashMethod) Unpack(str string) error {
+	m, found := hashes[strings.ToLower(str)]
+	if !found {
+		return fmt.Errorf("invalid hash method [%s]", str)
+	}
+	*f = m
+	return nil
+}
+
+type namedEncodingMethod struct {
+	Name   string
+	Encode func([]byte) string
+}
+
+var encodings = map[string]namedEncodingMethod{
+	"hex":    {Name: "hex", Encode: hex.EncodeToString},
+	"base32": {Name: "base32", Encode: base32.StdEncoding.EncodeToString},
+	"base64": {Name: "base64", Encode: base64.StdEncoding.EncodeToString},
+}
+
+// Unpack creates the encoding method from the given string.
+func (e *namedEncodingMethod) Unpack(str string) error {
+	m, found := encodings[strings.ToLower(str)]
+	if !found {
+		return fmt.Errorf("invalid encoding [%s]", str)
+	}
+	*e = m
+	return nil
+}
@@ -0,0 +1,191 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package redact
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/monitoring"
+)
+
+const (
+	procName = "redact"
+	logName  = "processor." + procName
+)
+
+// instanceID is used to assign each instance a unique monitoring namespace.
+var instanceID atomic.Uint32
+
+func init() {
+	processors.RegisterPlugin(procName, New)
+}
+
+type metrics struct {
+	Redactions *monitoring.Int
+}
+
+type processor struct {
+	config
+	log       *logp.Logger
+	detectors []detector
+	metrics   metrics
+}
+
+// New constructs a new redact processor.
+func New(cfg *conf.C, log *logp.Logger) (beat.Processor, error) {
+	c := defaultConfig()
+	if err := cfg.Unpack(&c); err != nil {
+		return nil, fmt.Errorf("fail to unpack the %v processor configuration: %w", procName, err)
+	}
+
+	return newRedact(c, log)
+}
+
+func newRedact(c config, logger *logp.Logger) (*processor, error) {
+	builtins := make(map[string]detector, len(builtinDetectors))
+	for _, d := range builtinDetectors {
+		builtins[d.name] = d
+	}
+
+	names := c.Detectors
+	if names == nil {
+		for _, d := range builtinDetectors {
+			names = append(names, d.name)
+		}
+	}
+
+	var detectors []detector
+	for _, name := range names {
+		d, found := builtins[name]
+		if !found {
+			return nil, fmt.Errorf("unknown detector [%s]", name)
+		}
+		detectors = append(detectors, d)
+	}
+	for _, p := range c.Patterns {
+		re, err := regexp.Compile(p.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex of pattern %s: %w", p.Name, err)
+		}
+		detectors = append(detectors, detector{name: p.Name, re: re})
+	}
+
+	var (
+		id  = int(instanceID.Add(1))
+		reg = monitoring.Default.GetOrCreateRegistry(logName+"."+strconv.Itoa(id), monitoring.DoNotReport)
+	)
+
+	log := logger.Named(logName)
+	if c.ID != "" {
+		log = log.With("instance_id", c.ID)
+	}
+	return &processor{
+		config:    c,
+		log:       log,
+		detectors: detectors,
+		metrics: metrics{
+			Redactions: monitoring.NewInt(reg, "redactions"),
+		},
+	}, nil
+}
+
+func (p *processor) String() string {
+	names := make([]string, len(p.detectors))
+	for i, d := range p.detectors {
+		names[i] = d.name
+	}
+	return fmt.Sprintf("%v=[fields=%v, detectors=%v, mode=%v]", procName, p.Fields, names, p.Mode)
+}
+
+func (p *processor) Run(event *beat.Event) (*beat.Event, error) {
+	var errs []error
+	for _, field := range p.Fields {
+		if err := p.redactField(event, field); err != nil && !p.IgnoreFailure {
+			errs = append(errs, err)
+		}
+	}
+	return event, errors.Join(errs...)
+}
+
+func (p *processor) redactField(event *beat.Event, field string) error {
+	v, err := event.GetValue(field)
+	if err != nil {
+		if p.IgnoreMissing && errors.Is(err, mapstr.ErrKeyNotFound) {
+			return nil
+		}
+		return fmt.Errorf("could not fetch value for field %s: %w", field, err)
+	}
+	s, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("field %s is not of string type", field)
+	}
+
+	redacted, n := p.redact(s)
+	if n == 0 {
+		return nil
+	}
+	if _, err := event.PutValue(field, redacted); err != nil {
+		return fmt.Errorf("failed setting field %s: %w", field, err)
+	}
+	p.log.Debugf("Redacted %d values in field %s", n, field)
+	p.metrics.Redactions.Add(int64(n))
+	return nil
+}
+
+// redact returns s with the values found by the detectors replaced, and the
+// number of replaced values.
+func (p *processor) redact(s string) (string, int) {
+	matches := findMatches(p.detectors, s)
+	if len(matches) == 0 {
+		return s, 0
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(s[last:m.start])
+		b.WriteString(p.replacement(s[m.start:m.end]))
+		last = m.end
+	}
+	b.WriteString(s[last:])
+	return b.String(), len(matches)
+}
+
+func (p *processor) replacement(value string) string {
+	switch p.Mode {
+	case modeHash:
+		h := p.Hash.Method.Hash()
+		h.Write([]byte(p.Hash.Salt))
+		h.Write([]byte(value))
+		return p.Hash.Encoding.Encode(h.Sum(nil))
+	case modeRemove:
+		return ""
+	default:
+		return p.Mask
+	}
+}
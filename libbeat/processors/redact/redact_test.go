@@ -0,0 +1,165 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package redact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestRedactModes(t *testing.T) {
+	const message = "login of alice@example.com from 10.0.0.1"
+
+	sum := sha256.Sum256([]byte("s3cr3t" + "alice@example.com"))
+	hashedEmail := hex.EncodeToString(sum[:])
+	sum = sha256.Sum256([]byte("s3cr3t" + "10.0.0.1"))
+	hashedIP := hex.EncodeToString(sum[:])
+
+	tests := map[string]struct {
+		config     mapstr.M
+		want       string
+		redactions int64
+	}{
+		"mask": {
+			config:     mapstr.M{},
+			want:       "login of [REDACTED] from [REDACTED]",
+			redactions: 2,
+		},
+		"custom mask": {
+			config:     mapstr.M{"mask": "***"},
+			want:       "login of *** from ***",
+			redactions: 2,
+		},
+		"hash": {
+			config:     mapstr.M{"mode": "hash", "hash": mapstr.M{"salt": "s3cr3t"}},
+			want:       "login of " + hashedEmail + " from " + hashedIP,
+			redactions: 2,
+		},
+		"remove": {
+			config:     mapstr.M{"mode": "remove"},
+			want:       "login of  from ",
+			redactions: 2,
+		},
+		"selected detectors": {
+			config:     mapstr.M{"detectors": []string{"ip"}},
+			want:       "login of alice@example.com from [REDACTED]",
+			redactions: 1,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			tc.config["fields"] = []string{"message"}
+			p, err := New(conf.MustNewConfigFrom(tc.config), logptest.NewTestingLogger(t, ""))
+			require.NoError(t, err)
+
+			event, err := p.Run(&beat.Event{Fields: mapstr.M{"message": message}})
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, event.Fields["message"])
+			assert.Equal(t, tc.redactions, p.(*processor).metrics.Redactions.Get())
+		})
+	}
+}
+
+func TestRedactPatterns(t *testing.T) {
+	p, err := New(conf.MustNewConfigFrom(mapstr.M{
+		"fields":    []string{"message", "user.note"},
+		"detectors": []string{"email"},
+		"patterns": []mapstr.M{
+			{"name": "employee_id", "regex": `EMP-\d{6}`},
+			{"name": "password", "regex": `password=(\S+)`},
+		},
+	}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+
+	event, err := p.Run(&beat.Event{Fields: mapstr.M{
+		"message": "EMP-123456 changed password=hunter2 for bob@example.com",
+		"user":    mapstr.M{"note": "no secrets here"},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, mapstr.M{
+		"message": "[REDACTED] changed password=[REDACTED] for [REDACTED]",
+		"user":    mapstr.M{"note": "no secrets here"},
+	}, event.Fields)
+	assert.Equal(t, int64(3), p.(*processor).metrics.Redactions.Get())
+}
+
+func TestRedactFieldErrors(t *testing.T) {
+	p, err := New(conf.MustNewConfigFrom(mapstr.M{"fields": []string{"message"}}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	_, err = p.Run(&beat.Event{Fields: mapstr.M{}})
+	assert.Error(t, err)
+	_, err = p.Run(&beat.Event{Fields: mapstr.M{"message": 1}})
+	assert.Error(t, err)
+
+	p, err = New(conf.MustNewConfigFrom(mapstr.M{"fields": []string{"message"}, "ignore_missing": true}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	_, err = p.Run(&beat.Event{Fields: mapstr.M{}})
+	assert.NoError(t, err)
+
+	p, err = New(conf.MustNewConfigFrom(mapstr.M{"fields": []string{"message"}, "ignore_failure": true}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	_, err = p.Run(&beat.Event{Fields: mapstr.M{"message": 1}})
+	assert.NoError(t, err)
+}
+
+func TestRedactConfig(t *testing.T) {
+	tests := map[string]struct {
+		config mapstr.M
+		err    string
+	}{
+		"no fields": {
+			config: mapstr.M{},
+			err:    "missing required field",
+		},
+		"invalid mode": {
+			config: mapstr.M{"fields": []string{"message"}, "mode": "shred"},
+			err:    "invalid mode [shred]",
+		},
+		"invalid hash method": {
+			config: mapstr.M{"fields": []string{"message"}, "hash": mapstr.M{"method": "md5"}},
+			err:    "invalid hash method [md5]",
+		},
+		"unknown detector": {
+			config: mapstr.M{"fields": []string{"message"}, "detectors": []string{"ssn"}},
+			err:    "unknown detector [ssn]",
+		},
+		"invalid pattern": {
+			config: mapstr.M{"fields": []string{"message"}, "patterns": []mapstr.M{{"name": "x", "regex": "("}}},
+			err:    "invalid regex of pattern x",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(conf.MustNewConfigFrom(tc.config), logptest.NewTestingLogger(t, ""))
+			assert.ErrorContains(t, err, tc.err)
+		})
+	}
+}
@@ -0,0 +1,70 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package route
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/elastic/beats/v7/libbeat/common/fmtstr"
+	"github.com/elastic/beats/v7/libbeat/conditions"
+)
+
+type config struct {
+	Routes  []routeConfig `config:"routes"  validate:"required"`
+	Default *targetConfig `config:"default"`
+	ID      string        `config:"id"`
+}
+
+type routeConfig struct {
+	When   *conditions.Config `config:"when" validate:"required"`
+	Target targetConfig       `config:",inline"`
+}
+
+// targetConfig holds the routing keys set on the events. All of them are
+// format strings.
+type targetConfig struct {
+	Index      *fmtstr.EventFormatString `config:"index"`
+	Topic      *fmtstr.EventFormatString `config:"topic"`
+	Key        *fmtstr.EventFormatString `config:"key"`
+	Pipeline   *fmtstr.EventFormatString `config:"pipeline"`
+	DataStream *dataStreamConfig         `config:"data_stream"`
+}
+
+type dataStreamConfig struct {
+	Type      *fmtstr.EventFormatString `config:"type"`
+	Dataset   *fmtstr.EventFormatString `config:"dataset"`
+	Namespace *fmtstr.EventFormatString `config:"namespace"`
+}
+
+func (c *config) Validate() error {
+	for i, r := range c.Routes {
+		if r.Target.empty() {
+			return fmt.Errorf("route %d sets no routing key", i)
+		}
+	}
+	if c.Default != nil && c.Default.empty() {
+		return errors.New("default sets no routing key")
+	}
+	return nil
+}
+
+func (t *targetConfig) empty() bool {
+	return t.Index == nil && t.Topic == nil && t.Key == nil && t.Pipeline == nil &&
+		(t.DataStream == nil || (t.DataStream.Type == nil && t.DataStream.Dataset == nil && t.DataStream.Namespace == nil))
+}
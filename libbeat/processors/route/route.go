@@ -0,0 +1,204 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package route
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/beat/events"
+	"github.com/elastic/beats/v7/libbeat/common/fmtstr"
+	"github.com/elastic/beats/v7/libbeat/conditions"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	jsprocessor "github.com/elastic/beats/v7/libbeat/processors/script/javascript/module/processor/registry"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+const procName = "route"
+
+// Metadata keys read by the outputs configured with the format strings
+// %{[@metadata.topic]} and %{[@metadata.key]}.
+const (
+	metaTopic = "topic"
+	metaKey   = "key"
+)
+
+func init() {
+	processors.RegisterPlugin(procName, New)
+	jsprocessor.RegisterPlugin("Route", New)
+}
+
+type processor struct {
+	config
+	log    *logp.Logger
+	routes []route
+}
+
+type route struct {
+	cond   conditions.Condition
+	target *targetConfig
+}
+
+// New constructs a new route processor.
+func New(cfg *conf.C, log *logp.Logger) (beat.Processor, error) {
+	var c config
+	if err := cfg.Unpack(&c); err != nil {
+		return nil, fmt.Errorf("fail to unpack the %v processor configuration: %w", procName, err)
+	}
+
+	return newRoute(c, log)
+}
+
+func newRoute(c config, logger *logp.Logger) (*processor, error) {
+	log := logger.Named(procName)
+	if c.ID != "" {
+		log = log.With("instance_id", c.ID)
+	}
+
+	p := &processor{config: c, log: log}
+	for i := range c.Routes {
+		cond, err := conditions.NewCondition(c.Routes[i].When, log)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the condition of route %d: %w", i, err)
+		}
+		p.routes = append(p.routes, route{cond: cond, target: &c.Routes[i].Target})
+	}
+	return p, nil
+}
+
+// SetPaths sets the paths of the route conditions, which resolve the relative
+// paths of the files they read against the configuration path.
+func (p *processor) SetPaths(path *paths.Path) error {
+	var errs []error
+	for _, r := range p.routes {
+		errs = append(errs, conditions.SetPaths(r.cond, path))
+	}
+	return errors.Join(errs...)
+}
+
+func (p *processor) String() string {
+	conds := make([]string, len(p.routes))
+	for i, r := range p.routes {
+		conds[i] = r.cond.String()
+	}
+	return fmt.Sprintf("%v=[routes=[%v], default=%v]", procName, strings.Join(conds, ", "), p.Default != nil)
+}
+
+// Run sets the routing keys of the first route whose condition matches the
+// event, or of the default if none does.
+func (p *processor) Run(event *beat.Event) (*beat.Event, error) {
+	target := p.Default
+	for _, r := range p.routes {
+		if r.cond.Check(event) {
+			target = r.target
+			break
+		}
+	}
+	if target == nil {
+		return event, nil
+	}
+	return event, p.apply(target, event)
+}
+
+func (p *processor) apply(t *targetConfig, event *beat.Event) error {
+	if ds := t.DataStream; ds != nil {
+		for _, f := range []struct {
+			field string
+			fs    *fmtstr.EventFormatString
+		}{
+			{"data_stream.type", ds.Type},
+			{"data_stream.dataset", ds.Dataset},
+			{"data_stream.namespace", ds.Namespace},
+		} {
+			if err := setField(event, f.field, f.fs); err != nil {
+				return err
+			}
+		}
+		if t.Index == nil {
+			// Send the event to the data stream named after its fields, like
+			// Elasticsearch does with the data_stream fields.
+			if index, ok := dataStreamName(event); ok {
+				setMeta(event, events.FieldMetaIndex, index)
+			}
+		}
+	}
+
+	for _, m := range []struct {
+		key string
+		fs  *fmtstr.EventFormatString
+	}{
+		{events.FieldMetaIndex, t.Index},
+		{metaTopic, t.Topic},
+		{metaKey, t.Key},
+		{events.FieldMetaPipeline, t.Pipeline},
+	} {
+		if m.fs == nil {
+			continue
+		}
+		v, err := m.fs.Run(event)
+		if err != nil {
+			return fmt.Errorf("failed to format the %v routing key: %w", m.key, err)
+		}
+		setMeta(event, m.key, v)
+	}
+	return nil
+}
+
+func setField(event *beat.Event, field string, fs *fmtstr.EventFormatString) error {
+	if fs == nil {
+		return nil
+	}
+	v, err := fs.Run(event)
+	if err != nil {
+		return fmt.Errorf("failed to format the %v field: %w", field, err)
+	}
+	if _, err := event.PutValue(field, v); err != nil {
+		return fmt.Errorf("failed to set the %v field: %w", field, err)
+	}
+	return nil
+}
+
+func setMeta(event *beat.Event, key, value string) {
+	if event.Meta == nil {
+		event.Meta = mapstr.M{}
+	}
+	event.Meta[key] = value
+}
+
+// dataStreamName returns the name of the data stream of the event,
+// {type}-{dataset}-{namespace}, if it has the three data_stream fields.
+func dataStreamName(event *beat.Event) (string, bool) {
+	parts := make([]string, 0, 3)
+	for _, field := range []string{"data_stream.type", "data_stream.dataset", "data_stream.namespace"} {
+		v, err := event.GetValue(field)
+		if err != nil {
+			return "", false
+		}
+		s, ok := v.(string)
+		if !ok || s == "" {
+			return "", false
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, "-"), true
+}
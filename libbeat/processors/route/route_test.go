@@ -0,0 +1,144 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package route
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestRoute(t *testing.T) {
+	p, err := New(conf.MustNewConfigFrom(mapstr.M{
+		"routes": []mapstr.M{
+			{
+				"when":  mapstr.M{"equals": mapstr.M{"event.dataset": "nginx.access"}},
+				"topic": "nginx",
+				"data_stream": mapstr.M{
+					"type":      "logs",
+					"dataset":   "%{[event.dataset]}",
+					"namespace": "prod",
+				},
+			},
+			{
+				"when":     mapstr.M{"has_fields": []string{"event.dataset"}},
+				"index":    "logs-%{[event.dataset]}-default",
+				"topic":    "%{[event.dataset]}",
+				"key":      "%{[event.dataset]}",
+				"pipeline": "%{[event.dataset]}-pipeline",
+			},
+			{
+				"when":  mapstr.M{"has_fields": []string{"message"}},
+				"topic": "never",
+			},
+		},
+		"default": mapstr.M{"topic": "other"},
+	}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		fields     mapstr.M
+		wantMeta   mapstr.M
+		wantFields mapstr.M
+	}{
+		"first matching route": {
+			fields:   mapstr.M{"event": mapstr.M{"dataset": "nginx.access"}, "message": "GET /"},
+			wantMeta: mapstr.M{"topic": "nginx", "index": "logs-nginx.access-prod"},
+			wantFields: mapstr.M{
+				"event":       mapstr.M{"dataset": "nginx.access"},
+				"message":     "GET /",
+				"data_stream": mapstr.M{"type": "logs", "dataset": "nginx.access", "namespace": "prod"},
+			},
+		},
+		"format strings": {
+			fields: mapstr.M{"event": mapstr.M{"dataset": "system.auth"}},
+			wantMeta: mapstr.M{
+				"index":    "logs-system.auth-default",
+				"topic":    "system.auth",
+				"key":      "system.auth",
+				"pipeline": "system.auth-pipeline",
+			},
+			wantFields: mapstr.M{"event": mapstr.M{"dataset": "system.auth"}},
+		},
+		"default": {
+			fields:     mapstr.M{"host": mapstr.M{"name": "web-1"}},
+			wantMeta:   mapstr.M{"topic": "other"},
+			wantFields: mapstr.M{"host": mapstr.M{"name": "web-1"}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			event, err := p.Run(&beat.Event{Fields: tc.fields})
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantMeta, event.Meta)
+			assert.Equal(t, tc.wantFields, event.Fields)
+		})
+	}
+}
+
+func TestRouteNoDefault(t *testing.T) {
+	p, err := New(conf.MustNewConfigFrom(mapstr.M{
+		"routes": []mapstr.M{{
+			"when":  mapstr.M{"equals": mapstr.M{"log.level": "error"}},
+			"index": "errors",
+		}},
+	}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+
+	event, err := p.Run(&beat.Event{Fields: mapstr.M{"log": mapstr.M{"level": "info"}}, Meta: mapstr.M{"index": "logs"}})
+	require.NoError(t, err)
+	assert.Equal(t, mapstr.M{"index": "logs"}, event.Meta, "unrouted events are unchanged")
+
+	event, err = p.Run(&beat.Event{Fields: mapstr.M{"log": mapstr.M{"level": "error"}}, Meta: mapstr.M{"index": "logs"}})
+	require.NoError(t, err)
+	assert.Equal(t, mapstr.M{"index": "errors"}, event.Meta)
+}
+
+func TestRouteFormatError(t *testing.T) {
+	p, err := New(conf.MustNewConfigFrom(mapstr.M{
+		"routes":  []mapstr.M{{"when": mapstr.M{"has_fields": []string{"x"}}, "index": "x"}},
+		"default": mapstr.M{"index": "logs-%{[event.dataset]}"},
+	}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+
+	_, err = p.Run(&beat.Event{Fields: mapstr.M{}})
+	assert.ErrorContains(t, err, "failed to format the index routing key")
+}
+
+func TestRouteConfig(t *testing.T) {
+	for name, cfg := range map[string]mapstr.M{
+		"no routes":      {"default": mapstr.M{"index": "logs"}},
+		"no condition":   {"routes": []mapstr.M{{"index": "logs"}}},
+		"no routing key": {"routes": []mapstr.M{{"when": mapstr.M{"has_fields": []string{"x"}}}}},
+		"empty default":  {"routes": []mapstr.M{{"when": mapstr.M{"has_fields": []string{"x"}}, "index": "x"}}, "default": mapstr.M{"data_stream": mapstr.M{}}},
+		"bad condition":  {"routes": []mapstr.M{{"when": mapstr.M{"unknown": mapstr.M{"x": 1}}, "index": "x"}}},
+		"bad format":     {"routes": []mapstr.M{{"when": mapstr.M{"has_fields": []string{"x"}}, "index": "%{[x"}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(conf.MustNewConfigFrom(cfg), logptest.NewTestingLogger(t, ""))
+			assert.Error(t, err)
+		})
+	}
+}
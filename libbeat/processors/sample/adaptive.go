@@ -0,0 +1,70 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package sample
+
+import (
+	"sync"
+	"time"
+)
+
+// adaptive computes per key sample rates, so each key keeps about
+// eventsPerKey events per interval. Keys with fewer events are kept at 100%.
+type adaptive struct {
+	interval     time.Duration
+	eventsPerKey float64
+	now          func() time.Time
+
+	mu       sync.Mutex
+	start    time.Time         // start of the current interval
+	previous map[uint64]uint64 // event counts of the previous interval
+	current  map[uint64]uint64 // event counts of the current interval
+}
+
+func newAdaptive(interval time.Duration, eventsPerKey int, now func() time.Time) *adaptive {
+	return &adaptive{
+		interval:     interval,
+		eventsPerKey: float64(eventsPerKey),
+		now:          now,
+		start:        now(),
+		previous:     map[uint64]uint64{},
+		current:      map[uint64]uint64{},
+	}
+}
+
+// rate counts an event of key and returns the sample rate of the key: the
+// count of its events, in the previous interval or so far in the current one,
+// divided by eventsPerKey, and at least 1.
+func (a *adaptive) rate(key uint64) float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if now := a.now(); now.Sub(a.start) >= a.interval {
+		if now.Sub(a.start) >= 2*a.interval {
+			// No events in the last interval.
+			a.previous = map[uint64]uint64{}
+		} else {
+			a.previous = a.current
+		}
+		a.current = make(map[uint64]uint64, len(a.previous))
+		a.start = now
+	}
+
+	a.current[key]++
+	count := max(a.previous[key], a.current[key])
+	return max(1, float64(count)/a.eventsPerKey)
+}
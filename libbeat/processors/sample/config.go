@@ -0,0 +1,65 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package sample
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	modeFixed    = "fixed"
+	modeHash     = "hash"
+	modeAdaptive = "adaptive"
+)
+
+type config struct {
+	Mode         string        `config:"mode"`
+	Percentage   float64       `config:"percentage"`
+	Fields       []string      `config:"fields"`
+	Interval     time.Duration `config:"interval"       validate:"nonzero,positive"`
+	EventsPerKey int           `config:"events_per_key" validate:"min=1"`
+	TargetField  string        `config:"target_field"`
+	ID           string        `config:"id"`
+}
+
+func defaultConfig() config {
+	return config{
+		Mode:         modeFixed,
+		Interval:     time.Minute,
+		EventsPerKey: 100,
+		TargetField:  "sample.rate",
+	}
+}
+
+func (c *config) Validate() error {
+	switch c.Mode {
+	case modeFixed, modeHash:
+		if c.Percentage <= 0 || c.Percentage > 100 {
+			return fmt.Errorf("percentage must be greater than 0 and at most 100 in %s mode", c.Mode)
+		}
+	case modeAdaptive:
+	default:
+		return fmt.Errorf("invalid mode [%s], it must be one of %s, %s or %s", c.Mode, modeFixed, modeHash, modeAdaptive)
+	}
+	if c.Mode != modeFixed && len(c.Fields) == 0 {
+		return errors.New("fields are required in " + c.Mode + " mode")
+	}
+	return nil
+}
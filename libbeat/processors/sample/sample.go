@@ -0,0 +1,151 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package sample
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const procName = "sample"
+
+func init() {
+	processors.RegisterPlugin(procName, New)
+}
+
+type processor struct {
+	config
+	log *logp.Logger
+
+	// threshold is the highest key hash kept in hash mode.
+	threshold uint64
+	adaptive  *adaptive
+	random    func() float64
+}
+
+// New constructs a new sample processor.
+func New(cfg *conf.C, log *logp.Logger) (beat.Processor, error) {
+	c := defaultConfig()
+	if err := cfg.Unpack(&c); err != nil {
+		return nil, fmt.Errorf("fail to unpack the %v processor configuration: %w", procName, err)
+	}
+
+	return newSample(c, log)
+}
+
+func newSample(c config, logger *logp.Logger) (*processor, error) {
+	log := logger.Named(procName)
+	if c.ID != "" {
+		log = log.With("instance_id", c.ID)
+	}
+
+	// Sort the fields so their order doesn't change the keys.
+	c.Fields = slices.Clone(c.Fields)
+	slices.Sort(c.Fields)
+
+	p := &processor{config: c, log: log, random: rand.Float64}
+	switch c.Mode {
+	case modeHash:
+		p.threshold = math.MaxUint64
+		if c.Percentage < 100 {
+			p.threshold = uint64(c.Percentage / 100 * (1 << 64))
+		}
+	case modeAdaptive:
+		p.adaptive = newAdaptive(c.Interval, c.EventsPerKey, time.Now)
+	}
+	return p, nil
+}
+
+// Unshareable opts sample out of process-wide processor sharing: in adaptive
+// mode, sharing one instance across owners would count the keys of all the
+// owners together.
+func (p *processor) Unshareable() {}
+
+func (p *processor) String() string {
+	switch p.Mode {
+	case modeAdaptive:
+		return fmt.Sprintf("%v=[mode=%v, fields=%v, interval=%v, events_per_key=%v]", procName, p.Mode, p.Fields, p.Interval, p.EventsPerKey)
+	default:
+		return fmt.Sprintf("%v=[mode=%v, percentage=%v, fields=%v]", procName, p.Mode, p.Percentage, p.Fields)
+	}
+}
+
+// Run keeps the event or drops it, depending on the mode. Kept events get
+// the sample rate, the number of events each of them represents.
+func (p *processor) Run(event *beat.Event) (*beat.Event, error) {
+	var keep bool
+	var rate float64
+	switch p.Mode {
+	case modeHash:
+		rate = 100 / p.Percentage
+		if key, ok := p.key(event); ok {
+			keep = key <= p.threshold
+		} else {
+			// Events without key are sampled randomly.
+			keep = p.random()*100 < p.Percentage
+		}
+	case modeAdaptive:
+		key, _ := p.key(event)
+		rate = p.adaptive.rate(key)
+		keep = rate == 1 || p.random()*rate < 1
+	default:
+		rate = 100 / p.Percentage
+		keep = p.random()*100 < p.Percentage
+	}
+
+	if !keep {
+		return nil, nil
+	}
+	if p.TargetField != "" {
+		if _, err := event.PutValue(p.TargetField, rate); err != nil {
+			return event, fmt.Errorf("failed to set the %v field: %w", p.TargetField, err)
+		}
+	}
+	return event, nil
+}
+
+// key returns the hash of the values of the fields in the event, and false if
+// the event has none of them.
+func (p *processor) key(event *beat.Event) (uint64, bool) {
+	h := xxhash.New()
+	found := false
+	for _, field := range p.Fields {
+		v, err := event.GetValue(field)
+		if err != nil {
+			if !errors.Is(err, mapstr.ErrKeyNotFound) {
+				p.log.Debugf("Failed to get the %v field: %v", field, err)
+			}
+			continue
+		}
+		found = true
+		fmt.Fprintf(h, "|%s|%v", field, v)
+	}
+	return h.Sum64(), found
+}
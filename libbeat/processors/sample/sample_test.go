@@ -0,0 +1,168 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package sample
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestFixed(t *testing.T) {
+	proc, err := New(conf.MustNewConfigFrom(mapstr.M{"percentage": 25}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	p := proc.(*processor)
+
+	p.random = func() float64 { return 0.1 }
+	event, err := p.Run(&beat.Event{Fields: mapstr.M{"message": "kept"}})
+	require.NoError(t, err)
+	require.NotNil(t, event)
+	assert.Equal(t, mapstr.M{"message": "kept", "sample": mapstr.M{"rate": 4.0}}, event.Fields)
+
+	p.random = func() float64 { return 0.3 }
+	event, err = p.Run(&beat.Event{Fields: mapstr.M{"message": "dropped"}})
+	require.NoError(t, err)
+	assert.Nil(t, event)
+}
+
+func TestHash(t *testing.T) {
+	proc, err := New(conf.MustNewConfigFrom(mapstr.M{"mode": "hash", "percentage": 10, "fields": []string{"trace.id"}, "target_field": "rate"}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	p := proc.(*processor)
+	p.random = func() float64 { panic("events with a key must not be sampled randomly") }
+
+	kept := 0
+	for i := 0; i < 10000; i++ {
+		trace := mapstr.M{"trace": mapstr.M{"id": fmt.Sprintf("trace-%d", i)}}
+		event, err := p.Run(&beat.Event{Fields: trace.Clone()})
+		require.NoError(t, err)
+
+		// The events of a trace are all kept or all dropped.
+		again, err := p.Run(&beat.Event{Fields: trace.Clone()})
+		require.NoError(t, err)
+		assert.Equal(t, event == nil, again == nil)
+
+		if event != nil {
+			kept++
+			assert.Equal(t, 10.0, event.Fields["rate"])
+		}
+	}
+	assert.InDelta(t, 1000, kept, 150)
+
+	proc, err = New(conf.MustNewConfigFrom(mapstr.M{"mode": "hash", "percentage": 100, "fields": []string{"trace.id"}}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	p = proc.(*processor)
+	event, err := p.Run(&beat.Event{Fields: mapstr.M{"trace": mapstr.M{"id": "x"}}})
+	require.NoError(t, err)
+	assert.NotNil(t, event)
+}
+
+func TestHashWithoutKey(t *testing.T) {
+	proc, err := New(conf.MustNewConfigFrom(mapstr.M{"mode": "hash", "percentage": 50, "fields": []string{"trace.id"}}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	p := proc.(*processor)
+
+	p.random = func() float64 { return 0.4 }
+	event, err := p.Run(&beat.Event{Fields: mapstr.M{}})
+	require.NoError(t, err)
+	assert.NotNil(t, event)
+
+	p.random = func() float64 { return 0.6 }
+	event, err = p.Run(&beat.Event{Fields: mapstr.M{}})
+	require.NoError(t, err)
+	assert.Nil(t, event)
+}
+
+func TestAdaptive(t *testing.T) {
+	proc, err := New(conf.MustNewConfigFrom(mapstr.M{"mode": "adaptive", "fields": []string{"log.level"}, "events_per_key": 10, "interval": "1m"}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	p := proc.(*processor)
+	now := time.Now()
+	p.adaptive = newAdaptive(time.Minute, 10, func() time.Time { return now })
+	p.random = func() float64 { return 0.5 }
+
+	run := func(level string) *beat.Event {
+		t.Helper()
+		event, err := p.Run(&beat.Event{Fields: mapstr.M{"log": mapstr.M{"level": level}}})
+		require.NoError(t, err)
+		return event
+	}
+	rate := func(event *beat.Event) any {
+		t.Helper()
+		v, err := event.GetValue("sample.rate")
+		require.NoError(t, err)
+		return v
+	}
+
+	kept := 0
+	for i := 0; i < 100; i++ {
+		if run("info") != nil {
+			kept++
+		}
+	}
+	// The first 10 events are kept, then the events up to the 20th as the
+	// rate stays under 2.
+	assert.Equal(t, 19, kept)
+
+	// Rare keys are kept at 100%.
+	for i := 0; i < 5; i++ {
+		event := run("error")
+		require.NotNil(t, event)
+		assert.Equal(t, 1.0, rate(event))
+	}
+
+	// In the next interval, the rates start from the previous counts.
+	now = now.Add(time.Minute)
+	assert.Nil(t, run("info"))
+	p.random = func() float64 { return 0.05 }
+	event := run("info")
+	require.NotNil(t, event)
+	assert.Equal(t, 10.0, rate(event))
+	assert.Equal(t, 1.0, rate(run("error")))
+
+	// After an interval without events, the counts are forgotten.
+	now = now.Add(2 * time.Minute)
+	p.random = func() float64 { return 0.99 }
+	assert.Equal(t, 1.0, rate(run("info")))
+}
+
+func TestConfig(t *testing.T) {
+	for name, cfg := range map[string]mapstr.M{
+		"no percentage":             {},
+		"percentage above 100":      {"percentage": 120},
+		"invalid mode":              {"mode": "random", "percentage": 10},
+		"hash without fields":       {"mode": "hash", "percentage": 10},
+		"adaptive without fields":   {"mode": "adaptive"},
+		"adaptive without interval": {"mode": "adaptive", "fields": []string{"a"}, "interval": 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(conf.MustNewConfigFrom(cfg), logptest.NewTestingLogger(t, ""))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,333 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package cel implements the CEL runtime of the script processor. The
+// configured expression is compiled and type-checked once when the processor
+// is created and evaluated for each event. Unlike JavaScript, a compiled CEL
+// program is safe for concurrent use, so no sessions are needed.
+package cel
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"time"
+
+	"github.com/elastic/mito/lib"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	libcommon "github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+const (
+	logName = "processor.cel"
+
+	// eventVar and paramsVar are the names under which the event and the
+	// configured params are available to the expression.
+	eventVar  = "event"
+	paramsVar = "params"
+
+	// interruptCheckFrequency is the number of comprehension iterations
+	// between checks of the timeout.
+	interruptCheckFrequency = 100
+)
+
+type celProcessor struct {
+	Config
+	prg        cel.Program
+	sourceFile string
+	log        *logp.Logger
+}
+
+// New constructs a new CEL processor.
+func New(c *config.C, log *logp.Logger) (beat.Processor, error) {
+	conf := defaultConfig()
+	if err := c.Unpack(&conf); err != nil {
+		return nil, err
+	}
+
+	return NewFromConfig(conf, log)
+}
+
+// NewFromConfig constructs a new CEL processor from the given config object.
+// Inline sources are compiled immediately, file-based sources when SetPaths
+// is called.
+func NewFromConfig(c Config, logger *logp.Logger) (beat.Processor, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	p := &celProcessor{
+		Config: c,
+		log:    logger.Named(logName),
+	}
+	if c.Tag != "" {
+		p.log = p.log.With("instance_id", c.Tag)
+	}
+
+	if c.Source != "" {
+		const inlineSourceFile = "inline.cel"
+		if err := p.compile(inlineSourceFile, c.Source); err != nil {
+			return nil, annotateError(c.Tag, err)
+		}
+	}
+	return p, nil
+}
+
+// SetPaths loads and compiles the source file, resolved relative to the
+// config directory.
+func (p *celProcessor) SetPaths(path *paths.Path) error {
+	if p.Source != "" {
+		return nil // inline source already compiled
+	}
+
+	sourceFile := path.Resolve(paths.Config, p.File)
+	if libcommon.IsStrictPerms() {
+		if err := libcommon.OwnerHasExclusiveWritePerms(sourceFile); err != nil {
+			return annotateError(p.Tag, err)
+		}
+	}
+	source, err := os.ReadFile(sourceFile)
+	if err != nil {
+		return annotateError(p.Tag, fmt.Errorf("failed to read file %v: %w", sourceFile, err))
+	}
+	return annotateError(p.Tag, p.compile(sourceFile, string(source)))
+}
+
+func annotateError(id string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if id != "" {
+		return fmt.Errorf("failed in processor.cel with id=%v: %w", id, err)
+	}
+	return fmt.Errorf("failed in processor.cel: %w", err)
+}
+
+// NewEnv returns the environment CEL expressions over events are compiled
+// in: the event and params variables and the mito libraries. The expression
+// condition compiles its expressions in it too.
+func NewEnv() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.Variable(eventVar, cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable(paramsVar, cel.MapType(cel.StringType, cel.DynType)),
+		cel.OptionalTypes(cel.OptionalTypesVersion(lib.OptionalTypesVersion)),
+		lib.Collections(),
+		lib.JSON(nil),
+		lib.Printf(),
+		lib.Strings(),
+		lib.Time(),
+		lib.Try(),
+	)
+}
+
+// EventFields returns the fields of the event with its @timestamp and
+// @metadata, the value of the event variable of NewEnv.
+func EventFields(event *beat.Event) map[string]any {
+	fields := make(map[string]any, len(event.Fields)+2)
+	maps.Copy(fields, event.Fields)
+	fields["@timestamp"] = event.Timestamp
+	if event.Meta != nil {
+		fields["@metadata"] = event.Meta
+	}
+	return fields
+}
+
+// Activation returns the variables an expression compiled in the environment
+// of NewEnv is evaluated with.
+func Activation(fields, params map[string]any) map[string]any {
+	return map[string]any{
+		eventVar:  fields,
+		paramsVar: params,
+		// Shadow the now global of the time library, which is fixed when the
+		// program is created.
+		"now": time.Now(),
+	}
+}
+
+// compile parses and type-checks the expression. Errors report the position
+// of the offending code in the source.
+func (p *celProcessor) compile(sourceFile, source string) error {
+	env, err := NewEnv()
+	if err != nil {
+		return fmt.Errorf("failed to create env: %w", err)
+	}
+
+	ast, iss := env.CompileSource(common.NewStringSource(source, sourceFile))
+	if iss.Err() != nil {
+		return fmt.Errorf("failed compilation: %w", iss.Err())
+	}
+	switch t := ast.OutputType(); t.Kind() {
+	case types.MapKind, types.BoolKind, types.NullTypeKind, types.DynKind:
+	default:
+		return fmt.Errorf("failed compilation: the expression must evaluate to a map, a bool or null, not %v", t)
+	}
+
+	prg, err := env.Program(ast, cel.InterruptCheckFrequency(interruptCheckFrequency))
+	if err != nil {
+		return fmt.Errorf("failed program instantiation: %w", err)
+	}
+
+	p.prg = prg
+	p.sourceFile = sourceFile
+	return nil
+}
+
+// Run evaluates the expression with the event. A map result replaces the
+// fields of the event, and its @timestamp and @metadata if present. A false
+// or null result drops the event and true keeps it unchanged.
+func (p *celProcessor) Run(event *beat.Event) (*beat.Event, error) {
+	if p.prg == nil {
+		return event, fmt.Errorf("cel processor not initialized: SetPaths must be called for file-based sources")
+	}
+
+	ctx := context.Background()
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+	out, _, err := p.prg.ContextEval(ctx, Activation(EventFields(event), p.Params))
+	if err == nil {
+		var drop bool
+		drop, err = apply(event, out)
+		if err == nil {
+			if drop {
+				return nil, nil
+			}
+			return event, nil
+		}
+	}
+
+	if p.TagOnException != "" {
+		_ = mapstr.AddTags(event.Fields, []string{p.TagOnException})
+	}
+	_, _ = event.PutValue("error.message", err.Error())
+	return event, annotateError(p.Tag, fmt.Errorf("failed eval: %w", err))
+}
+
+// apply updates the event from the result of the expression and reports
+// whether the event is to be dropped.
+func apply(event *beat.Event, out ref.Val) (drop bool, err error) {
+	switch v := out.(type) {
+	case types.Bool:
+		return !bool(v), nil
+	case types.Null:
+		return true, nil
+	case traits.Mapper:
+		m, err := toNative(v)
+		if err != nil {
+			return false, err
+		}
+		fields, _ := m.(mapstr.M)
+
+		if ts, found := fields["@timestamp"]; found {
+			t, ok := ts.(time.Time)
+			if !ok {
+				return false, fmt.Errorf("@timestamp must be a timestamp, not %T", ts)
+			}
+			event.Timestamp = t
+			delete(fields, "@timestamp")
+		}
+		if meta, found := fields["@metadata"]; found {
+			m, ok := meta.(mapstr.M)
+			if !ok {
+				return false, fmt.Errorf("@metadata must be a map, not %T", meta)
+			}
+			event.Meta = m
+			delete(fields, "@metadata")
+		}
+		event.Fields = fields
+		return false, nil
+	default:
+		return false, fmt.Errorf("the expression must evaluate to a map, a bool or null, not %v", out.Type())
+	}
+}
+
+// toNative converts a CEL value to the Go types used in events. Maps are
+// converted to mapstr.M and lists to []any.
+func toNative(val ref.Val) (any, error) {
+	switch v := val.(type) {
+	case types.Null:
+		return nil, nil
+	case types.Bool:
+		return bool(v), nil
+	case types.Int:
+		return int64(v), nil
+	case types.Uint:
+		return uint64(v), nil
+	case types.Double:
+		return float64(v), nil
+	case types.String:
+		return string(v), nil
+	case types.Bytes:
+		return []byte(v), nil
+	case types.Timestamp:
+		return v.Time, nil
+	case types.Duration:
+		return v.Duration, nil
+	case traits.Mapper:
+		m := make(mapstr.M)
+		it := v.Iterator()
+		for it.HasNext() == types.True {
+			k := it.Next()
+			key, ok := k.(types.String)
+			if !ok {
+				return nil, fmt.Errorf("map keys must be strings, not %v", k.Type())
+			}
+			elem, err := toNative(v.Get(k))
+			if err != nil {
+				return nil, fmt.Errorf("%v: %w", key, err)
+			}
+			m[string(key)] = elem
+		}
+		return m, nil
+	case traits.Lister:
+		size, ok := v.Size().(types.Int)
+		if !ok {
+			return nil, fmt.Errorf("invalid list size %v", v.Size())
+		}
+		list := make([]any, size)
+		for i := range list {
+			elem, err := toNative(v.Get(types.Int(i)))
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			list[i] = elem
+		}
+		return list, nil
+	default:
+		if types.IsError(val) {
+			return nil, fmt.Errorf("%v", val)
+		}
+		return nil, fmt.Errorf("unsupported value of type %v", val.Type())
+	}
+}
+
+func (p *celProcessor) String() string {
+	return "script=[type=cel, id=" + p.Tag + ", sources=" + p.sourceFile + "]"
+}
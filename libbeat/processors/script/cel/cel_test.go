@@ -0,0 +1,198 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cel
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("with tag", func(t *testing.T) {
+		p, err := New(config.MustNewConfigFrom(map[string]any{"source": "event", "tag": "my-processor"}), logptest.NewTestingLogger(t, ""))
+		require.NoError(t, err)
+		assert.Contains(t, p.String(), "id=my-processor")
+	})
+
+	t.Run("with invalid config", func(t *testing.T) {
+		cfg, err := config.NewConfigFrom(map[string]any{})
+		require.NoError(t, err)
+
+		_, err = New(cfg, logptest.NewTestingLogger(t, ""))
+		require.ErrorContains(t, err, "cel expression must be defined")
+	})
+
+	t.Run("with syntax error", func(t *testing.T) {
+		cfg, err := config.NewConfigFrom(map[string]any{"source": `{"a": event.message`})
+		require.NoError(t, err)
+
+		_, err = New(cfg, logptest.NewTestingLogger(t, ""))
+		require.ErrorContains(t, err, "inline.cel:1:")
+	})
+
+	t.Run("with undeclared reference", func(t *testing.T) {
+		cfg, err := config.NewConfigFrom(map[string]any{"source": `evnt.with({})`})
+		require.NoError(t, err)
+
+		_, err = New(cfg, logptest.NewTestingLogger(t, ""))
+		require.ErrorContains(t, err, "inline.cel:1:1: undeclared reference to 'evnt'")
+	})
+
+	t.Run("with invalid result type", func(t *testing.T) {
+		cfg, err := config.NewConfigFrom(map[string]any{"source": `1 + 2`})
+		require.NoError(t, err)
+
+		_, err = New(cfg, logptest.NewTestingLogger(t, ""))
+		require.ErrorContains(t, err, "must evaluate to a map, a bool or null")
+	})
+}
+
+func TestRun(t *testing.T) {
+	ts := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+
+	tests := map[string]struct {
+		source   string
+		params   map[string]any
+		expected *beat.Event
+	}{
+		"keep": {
+			source: `event.message != "drop me"`,
+			expected: &beat.Event{
+				Timestamp: ts,
+				Meta:      mapstr.M{"index": "logs"},
+				Fields:    mapstr.M{"message": "hello", "count": 3},
+			},
+		},
+		"drop": {
+			source: `event.message == "drop me"`,
+		},
+		"replace fields": {
+			source: `{"message": event.message, "length": size(event.message), "tags": ["a", "b"]}`,
+			expected: &beat.Event{
+				Timestamp: ts,
+				Meta:      mapstr.M{"index": "logs"},
+				Fields:    mapstr.M{"message": "hello", "length": int64(5), "tags": []any{"a", "b"}},
+			},
+		},
+		"set timestamp and metadata": {
+			source: `{"@timestamp": timestamp("2020-01-02T03:04:05Z"), "@metadata": {"index": "other"}, "message": event.message}`,
+			expected: &beat.Event{
+				Timestamp: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+				Meta:      mapstr.M{"index": "other"},
+				Fields:    mapstr.M{"message": "hello"},
+			},
+		},
+		"nested fields": {
+			source: `{"message": event.message, "event": {"count": event.count * 2}}`,
+			expected: &beat.Event{
+				Timestamp: ts,
+				Meta:      mapstr.M{"index": "logs"},
+				Fields:    mapstr.M{"message": "hello", "event": mapstr.M{"count": int64(6)}},
+			},
+		},
+		"params": {
+			source: `{"message": params.prefix + event.message}`,
+			params: map[string]any{"prefix": "> "},
+			expected: &beat.Event{
+				Timestamp: ts,
+				Meta:      mapstr.M{"index": "logs"},
+				Fields:    mapstr.M{"message": "> hello"},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			settings := map[string]any{"source": tc.source}
+			if tc.params != nil {
+				settings["params"] = tc.params
+			}
+			p, err := New(config.MustNewConfigFrom(settings), logptest.NewTestingLogger(t, ""))
+			require.NoError(t, err)
+
+			out, err := p.Run(&beat.Event{
+				Timestamp: ts,
+				Meta:      mapstr.M{"index": "logs"},
+				Fields:    mapstr.M{"message": "hello", "count": 3},
+			})
+			require.NoError(t, err)
+			if tc.expected == nil {
+				assert.Nil(t, out)
+				return
+			}
+			require.NotNil(t, out)
+			assert.Equal(t, tc.expected.Timestamp, out.Timestamp.UTC())
+			assert.Equal(t, tc.expected.Meta, out.Meta)
+			assert.Equal(t, tc.expected.Fields, out.Fields)
+		})
+	}
+}
+
+func TestRunError(t *testing.T) {
+	p, err := New(config.MustNewConfigFrom(map[string]any{"source": `{"message": event.missing}`}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+
+	out, err := p.Run(&beat.Event{Fields: mapstr.M{"message": "hello"}})
+	require.Error(t, err)
+	require.NotNil(t, out)
+	assert.Equal(t, "hello", out.Fields["message"])
+
+	tags, _ := out.GetValue("tags")
+	assert.Equal(t, []string{"_cel_exception"}, tags)
+	msg, _ := out.GetValue("error.message")
+	assert.Contains(t, msg, "missing")
+}
+
+func TestSetPaths(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "filter.cel"), []byte(`event.level != "debug"`), 0o600))
+
+	p, err := New(config.MustNewConfigFrom(map[string]any{"file": "filter.cel"}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+
+	_, err = p.Run(&beat.Event{Fields: mapstr.M{"level": "debug"}})
+	require.ErrorContains(t, err, "not initialized")
+
+	setter, ok := p.(interface{ SetPaths(*paths.Path) error })
+	require.True(t, ok)
+	require.NoError(t, setter.SetPaths(&paths.Path{Config: dir}))
+
+	out, err := p.Run(&beat.Event{Fields: mapstr.M{"level": "debug"}})
+	require.NoError(t, err)
+	assert.Nil(t, out)
+
+	out, err = p.Run(&beat.Event{Fields: mapstr.M{"level": "error"}})
+	require.NoError(t, err)
+	assert.NotNil(t, out)
+
+	p, err = New(config.MustNewConfigFrom(map[string]any{"file": "missing.cel"}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	setter, _ = p.(interface{ SetPaths(*paths.Path) error })
+	assert.Error(t, setter.SetPaths(&paths.Path{Config: dir}))
+}
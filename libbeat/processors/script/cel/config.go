@@ -0,0 +1,50 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cel
+
+import (
+	"errors"
+	"time"
+)
+
+// Config defines the CEL expression to use for the processor.
+type Config struct {
+	Tag            string         `config:"tag"`                      // Processor ID for debug.
+	Source         string         `config:"source"`                   // Inline expression to evaluate.
+	File           string         `config:"file"`                     // Source file.
+	Params         map[string]any `config:"params"`                   // Parameters to pass to the expression.
+	Timeout        time.Duration  `config:"timeout" validate:"min=0"` // Evaluation timeout.
+	TagOnException string         `config:"tag_on_exception"`         // Tag to add to events when an evaluation fails.
+}
+
+// Validate returns an error if one (and only one) option is not set.
+func (c Config) Validate() error {
+	switch {
+	case c.Source == "" && c.File == "":
+		return errors.New("cel expression must be defined via 'file' or inline as 'source'")
+	case c.Source != "" && c.File != "":
+		return errors.New("cel expression can be defined in only one of 'file' or inline as 'source'")
+	}
+	return nil
+}
+
+func defaultConfig() Config {
+	return Config{
+		TagOnException: "_cel_exception",
+	}
+}
@@ -0,0 +1,215 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package user_agent
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+//go:embed regexes.yaml
+var embeddedRegexes []byte
+
+// database holds the compiled parsers of a regexes file in the uap-core
+// format.
+type database struct {
+	agents  []agentParser
+	oses    []osParser
+	devices []deviceParser
+}
+
+type agentParser struct {
+	re                          *regexp.Regexp
+	family, major, minor, patch string
+}
+
+type osParser struct {
+	re                      *regexp.Regexp
+	os, major, minor, patch string
+}
+
+type deviceParser struct {
+	re     *regexp.Regexp
+	device string
+}
+
+// regexesFile is the layout of a regexes file.
+type regexesFile struct {
+	UserAgentParsers []struct {
+		Regex             string `yaml:"regex"`
+		FamilyReplacement string `yaml:"family_replacement"`
+		V1Replacement     string `yaml:"v1_replacement"`
+		V2Replacement     string `yaml:"v2_replacement"`
+		V3Replacement     string `yaml:"v3_replacement"`
+	} `yaml:"user_agent_parsers"`
+	OSParsers []struct {
+		Regex           string `yaml:"regex"`
+		OSReplacement   string `yaml:"os_replacement"`
+		OSV1Replacement string `yaml:"os_v1_replacement"`
+		OSV2Replacement string `yaml:"os_v2_replacement"`
+		OSV3Replacement string `yaml:"os_v3_replacement"`
+	} `yaml:"os_parsers"`
+	DeviceParsers []struct {
+		Regex             string `yaml:"regex"`
+		RegexFlag         string `yaml:"regex_flag"`
+		DeviceReplacement string `yaml:"device_replacement"`
+	} `yaml:"device_parsers"`
+}
+
+// loadDatabase compiles the regexes file at path, or the embedded one if
+// path is empty.
+func loadDatabase(path string) (*database, error) {
+	data := embeddedRegexes
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	return parseDatabase(data)
+}
+
+func parseDatabase(data []byte) (*database, error) {
+	var file regexesFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse the regexes: %w", err)
+	}
+	if len(file.UserAgentParsers)+len(file.OSParsers)+len(file.DeviceParsers) == 0 {
+		return nil, errors.New("no parsers found")
+	}
+
+	db := &database{}
+	for i, p := range file.UserAgentParsers {
+		re, err := regexp.Compile(p.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("user_agent_parsers[%d]: %w", i, err)
+		}
+		db.agents = append(db.agents, agentParser{
+			re:     re,
+			family: p.FamilyReplacement,
+			major:  p.V1Replacement,
+			minor:  p.V2Replacement,
+			patch:  p.V3Replacement,
+		})
+	}
+	for i, p := range file.OSParsers {
+		re, err := regexp.Compile(p.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("os_parsers[%d]: %w", i, err)
+		}
+		db.oses = append(db.oses, osParser{
+			re:    re,
+			os:    p.OSReplacement,
+			major: p.OSV1Replacement,
+			minor: p.OSV2Replacement,
+			patch: p.OSV3Replacement,
+		})
+	}
+	for i, p := range file.DeviceParsers {
+		expr := p.Regex
+		if p.RegexFlag == "i" {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("device_parsers[%d]: %w", i, err)
+		}
+		db.devices = append(db.devices, deviceParser{re: re, device: p.DeviceReplacement})
+	}
+	return db, nil
+}
+
+// userAgent is the result of parsing a user agent string. Empty fields are
+// unknown.
+type userAgent struct {
+	name, version     string
+	osName, osVersion string
+	device            string
+}
+
+func (db *database) parse(s string) userAgent {
+	var ua userAgent
+	for _, p := range db.agents {
+		if m := p.re.FindStringSubmatch(s); m != nil {
+			ua.name = replace(p.family, m, 1)
+			ua.version = version(replace(p.major, m, 2), replace(p.minor, m, 3), replace(p.patch, m, 4))
+			break
+		}
+	}
+	for _, p := range db.oses {
+		if m := p.re.FindStringSubmatch(s); m != nil {
+			ua.osName = replace(p.os, m, 1)
+			ua.osVersion = version(replace(p.major, m, 2), replace(p.minor, m, 3), replace(p.patch, m, 4))
+			break
+		}
+	}
+	for _, p := range db.devices {
+		if m := p.re.FindStringSubmatch(s); m != nil {
+			ua.device = replace(p.device, m, 1)
+			break
+		}
+	}
+	return ua
+}
+
+// replace returns the replacement with its $1 to $9 references expanded, or
+// the group of the match if there is no replacement.
+func replace(replacement string, match []string, group int) string {
+	if replacement == "" {
+		if group < len(match) {
+			return strings.TrimSpace(match[group])
+		}
+		return ""
+	}
+	if !strings.Contains(replacement, "$") {
+		return replacement
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(replacement); i++ {
+		c := replacement[i]
+		if c == '$' && i+1 < len(replacement) && replacement[i+1] >= '1' && replacement[i+1] <= '9' {
+			if n, _ := strconv.Atoi(replacement[i+1 : i+2]); n < len(match) {
+				b.WriteString(match[n])
+			}
+			i++
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// version joins the non empty leading version parts.
+func version(parts ...string) string {
+	var v []string
+	for _, p := range parts {
+		if p == "" {
+			break
+		}
+		v = append(v, p)
+	}
+	return strings.Join(v, ".")
+}
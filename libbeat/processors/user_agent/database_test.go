@@ -0,0 +1,108 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package user_agent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedDatabase(t *testing.T) {
+	db, err := loadDatabase("")
+	require.NoError(t, err)
+
+	tests := map[string]userAgent{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.130 Safari/537.36": {
+			name: "Chrome", version: "120.0.6099", osName: "Windows", osVersion: "10",
+		},
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.91": {
+			name: "Edge", version: "120.0.2210", osName: "Windows", osVersion: "10",
+		},
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Safari/605.1.15": {
+			name: "Safari", version: "17.2", osName: "Mac OS X", osVersion: "10.15.7", device: "Mac",
+		},
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_2_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1": {
+			name: "Mobile Safari", version: "17.2", osName: "iOS", osVersion: "17.2.1", device: "iPhone",
+		},
+		"Mozilla/5.0 (Linux; Android 13; SM-S911B) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.144 Mobile Safari/537.36": {
+			name: "Chrome Mobile", version: "120.0.6099", osName: "Android", osVersion: "13", device: "SM-S911B",
+		},
+		"Mozilla/5.0 (Android 13; Mobile; rv:121.0) Gecko/121.0 Firefox/121.0": {
+			name: "Firefox Mobile", version: "121.0", osName: "Android", osVersion: "13", device: "Generic Smartphone",
+		},
+		"Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0": {
+			name: "Firefox", version: "121.0", osName: "Ubuntu",
+		},
+		"Mozilla/5.0 (Windows NT 6.1; WOW64; Trident/7.0; rv:11.0) like Gecko": {
+			name: "IE", version: "11.0", osName: "Windows", osVersion: "7",
+		},
+		"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)": {
+			name: "Googlebot", version: "2.1", device: "Spider",
+		},
+		"curl/8.4.0": {
+			name: "curl", version: "8.4.0",
+		},
+		"unknown": {},
+	}
+	for s, want := range tests {
+		assert.Equal(t, want, db.parse(s), s)
+	}
+}
+
+func TestParseDatabase(t *testing.T) {
+	db, err := parseDatabase([]byte(`
+user_agent_parsers:
+  - regex: '(MyApp)/(\d+)\.(\d+)'
+  - regex: 'Other/(\d+)'
+    family_replacement: 'Other App $1'
+    v1_replacement: '$1'
+    v2_replacement: '0'
+os_parsers:
+  - regex: 'MyOS (\d+)'
+    os_replacement: 'My OS'
+    os_v1_replacement: '$1'
+device_parsers:
+  - regex: 'DEVICE-(\w+)'
+    regex_flag: 'i'
+    device_replacement: 'Device $1'
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		userAgent{name: "MyApp", version: "1.2", osName: "My OS", osVersion: "7", device: "Device x1"},
+		db.parse("MyApp/1.2 (MyOS 7; device-x1)"))
+	assert.Equal(t, userAgent{name: "Other App 3", version: "3.0"}, db.parse("Other/3"))
+}
+
+func TestParseDatabaseErrors(t *testing.T) {
+	tests := map[string]string{
+		"invalid yaml":  "user_agent_parsers: [",
+		"no parsers":    "foo: bar",
+		"invalid regex": "os_parsers:\n  - regex: '(?<=x)'",
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseDatabase([]byte(data))
+			assert.Error(t, err)
+		})
+	}
+}
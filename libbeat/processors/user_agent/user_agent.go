@@ -0,0 +1,165 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package user_agent
+
+import (
+	"errors"
+	"fmt"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	jsprocessor "github.com/elastic/beats/v7/libbeat/processors/script/javascript/module/processor/registry"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const procName = "user_agent"
+
+// other is the name of the user agents and devices that aren't in the
+// database, like in the Elasticsearch user_agent ingest processor.
+const other = "Other"
+
+func init() {
+	processors.RegisterPlugin(procName, New)
+	jsprocessor.RegisterPlugin("UserAgent", New)
+}
+
+type processor struct {
+	config
+	log   *logp.Logger
+	db    *database
+	cache *lru.Cache[string, userAgent]
+}
+
+// New constructs a new user_agent processor.
+func New(cfg *conf.C, log *logp.Logger) (beat.Processor, error) {
+	c := defaultConfig()
+	if err := cfg.Unpack(&c); err != nil {
+		return nil, fmt.Errorf("fail to unpack the %v processor configuration: %w", procName, err)
+	}
+
+	return newUserAgent(c, log)
+}
+
+func newUserAgent(c config, logger *logp.Logger) (*processor, error) {
+	log := logger.Named(procName)
+	if c.ID != "" {
+		log = log.With("instance_id", c.ID)
+	}
+
+	db, err := loadDatabase(c.RegexFile)
+	if err != nil {
+		if c.RegexFile != "" {
+			return nil, fmt.Errorf("failed to load the regex file %s: %w", c.RegexFile, err)
+		}
+		return nil, fmt.Errorf("failed to load the embedded regexes: %w", err)
+	}
+
+	p := &processor{config: c, log: log, db: db}
+	if c.CacheSize > 0 {
+		if p.cache, err = lru.New[string, userAgent](c.CacheSize); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func (p *processor) String() string {
+	return fmt.Sprintf("%v=[field=%v, target_field=%v, regex_file=%v]", procName, p.Field, p.TargetField, p.RegexFile)
+}
+
+func (p *processor) Run(event *beat.Event) (*beat.Event, error) {
+	err := p.parse(event)
+	if err != nil && !p.IgnoreFailure {
+		return event, err
+	}
+	return event, nil
+}
+
+func (p *processor) parse(event *beat.Event) error {
+	v, err := event.GetValue(p.Field)
+	if err != nil {
+		if p.IgnoreMissing && errors.Is(err, mapstr.ErrKeyNotFound) {
+			return nil
+		}
+		return fmt.Errorf("could not fetch value for field %s: %w", p.Field, err)
+	}
+	s, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("field %s is not of string type", p.Field)
+	}
+
+	ua := p.lookup(s)
+	for key, value := range ua.fields() {
+		if _, err := event.PutValue(p.target(key), value); err != nil {
+			return fmt.Errorf("failed to write user agent fields: %w", err)
+		}
+	}
+	return nil
+}
+
+// lookup parses s, or returns it from the cache.
+func (p *processor) lookup(s string) userAgent {
+	if p.cache != nil {
+		if ua, ok := p.cache.Get(s); ok {
+			return ua
+		}
+	}
+	ua := p.db.parse(s)
+	if p.cache != nil {
+		p.cache.Add(s, ua)
+	}
+	return ua
+}
+
+func (p *processor) target(key string) string {
+	if p.TargetField == "" {
+		return key
+	}
+	return p.TargetField + "." + key
+}
+
+// fields returns the ECS user_agent fields of ua, with their keys relative to
+// the target field.
+func (ua userAgent) fields() map[string]string {
+	fields := map[string]string{
+		"name":        other,
+		"device.name": other,
+	}
+	if ua.name != "" {
+		fields["name"] = ua.name
+	}
+	if ua.version != "" {
+		fields["version"] = ua.version
+	}
+	if ua.device != "" {
+		fields["device.name"] = ua.device
+	}
+	if ua.osName != "" {
+		fields["os.name"] = ua.osName
+		fields["os.full"] = ua.osName
+		if ua.osVersion != "" {
+			fields["os.version"] = ua.osVersion
+			fields["os.full"] = ua.osName + " " + ua.osVersion
+		}
+	}
+	return fields
+}
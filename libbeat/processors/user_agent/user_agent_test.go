@@ -0,0 +1,124 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package user_agent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const chromeWindows = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.130 Safari/537.36"
+
+func TestProcessor(t *testing.T) {
+	p, err := New(conf.MustNewConfigFrom(mapstr.M{}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ { // the second run is served by the cache
+		event, err := p.Run(&beat.Event{Fields: mapstr.M{
+			"user_agent": mapstr.M{"original": chromeWindows},
+		}})
+		require.NoError(t, err)
+		assert.Equal(t, mapstr.M{
+			"user_agent": mapstr.M{
+				"original": chromeWindows,
+				"name":     "Chrome",
+				"version":  "120.0.6099",
+				"os": mapstr.M{
+					"name":    "Windows",
+					"version": "10",
+					"full":    "Windows 10",
+				},
+				"device": mapstr.M{"name": "Other"},
+			},
+		}, event.Fields)
+	}
+	assert.Equal(t, 1, p.(*processor).cache.Len())
+}
+
+func TestProcessorUnknownUserAgent(t *testing.T) {
+	p, err := New(conf.MustNewConfigFrom(mapstr.M{"field": "message", "target_field": "ua"}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+
+	event, err := p.Run(&beat.Event{Fields: mapstr.M{"message": "unknown"}})
+	require.NoError(t, err)
+	assert.Equal(t, mapstr.M{
+		"message": "unknown",
+		"ua": mapstr.M{
+			"name":   "Other",
+			"device": mapstr.M{"name": "Other"},
+		},
+	}, event.Fields)
+}
+
+func TestProcessorMissingField(t *testing.T) {
+	p, err := New(conf.MustNewConfigFrom(mapstr.M{}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	_, err = p.Run(&beat.Event{Fields: mapstr.M{}})
+	assert.Error(t, err)
+
+	_, err = p.Run(&beat.Event{Fields: mapstr.M{"user_agent": mapstr.M{"original": 1}}})
+	assert.Error(t, err)
+
+	p, err = New(conf.MustNewConfigFrom(mapstr.M{"ignore_missing": true}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	_, err = p.Run(&beat.Event{Fields: mapstr.M{}})
+	assert.NoError(t, err)
+
+	p, err = New(conf.MustNewConfigFrom(mapstr.M{"ignore_failure": true}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	_, err = p.Run(&beat.Event{Fields: mapstr.M{"user_agent": mapstr.M{"original": 1}}})
+	assert.NoError(t, err)
+}
+
+func TestProcessorRegexFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "regexes.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+user_agent_parsers:
+  - regex: '(Chrome)/(\d+)'
+    family_replacement: 'Custom Chrome'
+`), 0o644))
+
+	p, err := New(conf.MustNewConfigFrom(mapstr.M{"regex_file": path, "cache_size": 0}), logptest.NewTestingLogger(t, ""))
+	require.NoError(t, err)
+	assert.Nil(t, p.(*processor).cache)
+
+	event, err := p.Run(&beat.Event{Fields: mapstr.M{
+		"user_agent": mapstr.M{"original": chromeWindows},
+	}})
+	require.NoError(t, err)
+	name, _ := event.GetValue("user_agent.name")
+	assert.Equal(t, "Custom Chrome", name)
+	_, err = event.GetValue("user_agent.os")
+	assert.Error(t, err, "the embedded OS parsers must not be used")
+
+	c := defaultConfig()
+	c.RegexFile = filepath.Join(t.TempDir(), "missing.yaml")
+	_, err = newUserAgent(c, logptest.NewTestingLogger(t, ""))
+	assert.ErrorContains(t, err, "failed to load the regex file")
+}
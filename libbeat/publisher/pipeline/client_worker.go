@@ -49,8 +49,10 @@ type netClientWorker struct {
 	tracer *apm.Tracer
 }
 
-func makeClientWorker(qu chan publisher.Batch, client outputs.Client, logger logger, tracer *apm.Tracer) outputWorker {
-	ctx, cancel := context.WithCancel(context.Background())
+func makeClientWorker(qu chan publisher.Batch, client outputs.Client, deadLetter outputs.DeadLetterWriter, logger logger, tracer *apm.Tracer) outputWorker {
+	// The context carries the dead letter writer of the output group to the
+	// client's Publish calls.
+	ctx, cancel := context.WithCancel(outputs.WithDeadLetterWriter(context.Background(), deadLetter))
 	w := worker{
 		qu:     qu,
 		cancel: cancel,
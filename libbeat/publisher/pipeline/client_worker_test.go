@@ -64,7 +64,7 @@ func TestMakeClientWorker(t *testing.T) {
 
 				client := ctor(publishFn)
 
-				worker := makeClientWorker(workQueue, client, logger, nil)
+				worker := makeClientWorker(workQueue, client, nil, logger, nil)
 				defer worker.Close()
 
 				for range numBatches {
@@ -156,7 +156,7 @@ func TestReplaceClientWorker(t *testing.T) {
 				}
 
 				client := ctor(blockingPublishFn)
-				worker := makeClientWorker(workQueue, client, logger, nil)
+				worker := makeClientWorker(workQueue, client, nil, logger, nil)
 
 				// Allow the worker to make *some* progress before we close it
 				timeout := 10 * time.Second
@@ -183,7 +183,7 @@ func TestReplaceClientWorker(t *testing.T) {
 				}
 
 				client = ctor(countingPublishFn)
-				makeClientWorker(workQueue, client, logger, nil)
+				makeClientWorker(workQueue, client, nil, logger, nil)
 				wg.Wait()
 
 				// Make sure that all events have eventually been published
@@ -229,7 +229,7 @@ func TestMakeClientTracer(t *testing.T) {
 	recorder := apmtest.NewRecordingTracer()
 	defer recorder.Close()
 
-	worker := makeClientWorker(workQueue, client, logger, recorder.Tracer)
+	worker := makeClientWorker(workQueue, client, nil, logger, recorder.Tracer)
 	defer worker.Close()
 
 	for range numBatches {
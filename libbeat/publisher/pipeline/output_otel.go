@@ -54,7 +54,7 @@ type otelOutputController struct {
 	queue queue.Queue[publisher.Event]
 
 	// pool is non-nil whenever the receiver uses the slabqueue pool (i.e.
-	// queue.mem); it is nil when the receiver was configured with queue.disk
+	// queue.mem or queue.slab); it is nil when the receiver was configured with queue.disk
 	// and owns its queue outright via queueFactory. It therefore also marks
 	// whether this controller joined a shared pool that must be released.
 	pool *slabqueue.Pool[publisher.Event]
@@ -129,13 +129,21 @@ func newOTelOutputController(
 	)
 
 	// The default receiver path uses the slabqueue pool: when queueConfig
-	// is a memqueue.Settings (the default; also any explicit queue.mem),
-	// we go through acquireOTelPool. Anything else (in practice
-	// diskqueue.Settings from an explicit queue.disk) opts out and builds
-	// its queue from the user-supplied queueFactory.
-	if settings, isMem := queueConfig.(memqueue.Settings); isMem {
+	// is a memqueue.Settings (the default; also any explicit queue.mem) or
+	// a slabqueue.Settings (queue.slab, which can also reserve pool slots
+	// and set a weight), we go through acquireOTelPool. Anything else (in
+	// practice diskqueue.Settings from an explicit queue.disk) opts out and
+	// builds its queue from the user-supplied queueFactory.
+	var slabSettings *slabqueue.Settings
+	switch settings := queueConfig.(type) {
+	case memqueue.Settings:
+		slabSettings = &slabqueue.Settings{Events: settings.Events}
+	case slabqueue.Settings:
+		slabSettings = &settings
+	}
+	if slabSettings != nil {
 		var sharedQueue *slabqueue.Queue[publisher.Event]
-		pool, sharedQueue = acquireOTelPool(settings, monitors)
+		pool, sharedQueue = acquireOTelPool(*slabSettings, monitors)
 		pipelineQueue = sharedQueue
 	} else {
 		// Non-memory queue (e.g. queue.disk): each receiver writes to its own
@@ -205,8 +213,9 @@ func closePipelineQueue(q queue.Queue[publisher.Event]) {
 // in turn sizes the shared pool to the largest cap among connected queues
 // (Queue.SetTarget drives the pool). A smaller receiver therefore cannot exceed
 // its own size even though the shared pool is larger, and the pool grows and
-// shrinks as receivers join and leave.
-func acquireOTelPool(settings memqueue.Settings, monitors Monitors) (*slabqueue.Pool[publisher.Event], *slabqueue.Queue[publisher.Event]) {
+// shrinks as receivers join and leave. The connection's queue also gets the
+// reservation and weight from settings, reported with the queue metrics.
+func acquireOTelPool(settings slabqueue.Settings, monitors Monitors) (*slabqueue.Pool[publisher.Event], *slabqueue.Queue[publisher.Event]) {
 	otelSharedPool.Lock()
 	defer otelSharedPool.Unlock()
 	if otelSharedPool.pool == nil {
@@ -214,11 +223,18 @@ func acquireOTelPool(settings memqueue.Settings, monitors Monitors) (*slabqueue.
 		monitors.Logger.Debugf("newOTelOutputController: created shared slabqueue pool")
 	}
 	otelSharedPool.refs++
-	q := otelSharedPool.pool.Connect()
+	q := otelSharedPool.pool.ConnectWithShare(settings.Share())
 	// Cap this connection's own queue at its requested budget. This also resizes
 	// the shared pool to the largest cap among connected queues, so the pool
 	// always tracks the queues and the two cannot drift.
 	q.SetTarget(settings.Events)
+	// Every receiver has its own metrics registry, so each connection reports
+	// its own share of the pool.
+	if monitors.Metrics != nil {
+		if err := q.RegisterMetrics(monitors.Metrics.GetOrCreateRegistry("pipeline").GetOrCreateRegistry("queue")); err != nil {
+			monitors.Logger.Warnf("newOTelOutputController: slab queue metrics can't be registered: %v", err)
+		}
+	}
 	monitors.Logger.Debugf("newOTelOutputController: joined shared pool (%v connections, pool budget %v)", otelSharedPool.refs, otelSharedPool.pool.Target())
 	return otelSharedPool.pool, q
 }
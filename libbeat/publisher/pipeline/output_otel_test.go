@@ -34,6 +34,7 @@ import (
 	"github.com/elastic/beats/v7/libbeat/publisher"
 	"github.com/elastic/beats/v7/libbeat/publisher/queue"
 	"github.com/elastic/beats/v7/libbeat/publisher/queue/memqueue"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/slabqueue"
 	conf "github.com/elastic/elastic-agent-libs/config"
 	"github.com/elastic/elastic-agent-libs/logp"
 	"github.com/elastic/elastic-agent-libs/monitoring"
@@ -109,6 +110,44 @@ func TestOTelQueueMetrics(t *testing.T) {
 	assert.Equal(t, uint64(1000), value.Get(), "pipeline.queue.max_events should match the events configuration key")
 }
 
+// TestOTelSlabMetricsPerReceiver verifies that receivers sharing the pool
+// each report their own share of it, a receiver joining doesn't replace the
+// metrics of the receivers already connected.
+func TestOTelSlabMetricsPerReceiver(t *testing.T) {
+	reg1, reg2 := monitoring.NewRegistry(), monitoring.NewRegistry()
+
+	c1, err := newOTelOutputController(
+		beatInfoForTest(t),
+		Monitors{Logger: logp.NewNopLogger(), Metrics: reg1},
+		nilObserver,
+		nil, // queueFactory unused on the slabqueue pool path
+		slabqueue.Settings{Events: 64, Reserved: 8, Weight: 3},
+	)
+	require.NoError(t, err)
+	defer c1.waitClose(cancelledContext(), false)
+
+	c2, err := newOTelOutputController(
+		beatInfoForTest(t),
+		Monitors{Logger: logp.NewNopLogger(), Metrics: reg2},
+		nilObserver,
+		nil, // queueFactory unused on the slabqueue pool path
+		slabqueue.Settings{Events: 64, Weight: 1},
+	)
+	require.NoError(t, err)
+	defer c2.waitClose(cancelledContext(), false)
+
+	slabMetrics := func(reg *monitoring.Registry) map[string]int64 {
+		slab := reg.GetRegistry("pipeline").GetRegistry("queue").GetRegistry("slab")
+		require.NotNil(t, slab, "pipeline.queue.slab must exist")
+		return monitoring.CollectFlatSnapshot(slab, monitoring.Full, false).Ints
+	}
+	m1, m2 := slabMetrics(reg1), slabMetrics(reg2)
+	assert.Equal(t, int64(8), m1["reserved_events"])
+	assert.Equal(t, int64(3), m1["weight"])
+	assert.Equal(t, int64(0), m2["reserved_events"])
+	assert.Equal(t, int64(1), m2["weight"])
+}
+
 // TestReceiversShareGlobalPool verifies that two receivers share the single
 // process-global pool, matching what a production deployment sees.
 func TestReceiversShareGlobalPool(t *testing.T) {
@@ -80,6 +80,10 @@ type processOutputController struct {
 	workers    []outputWorker
 	workerChan chan publisher.Batch
 
+	// deadLetter is the dead letter writer of the current output group, it
+	// is closed once the group's workers are closed.
+	deadLetter outputs.DeadLetterWriter
+
 	// The InputQueueSize can be set when the Beat is started, in
 	// libbeat/cmd/instance/Settings we need to preserve that
 	// value and pass it into the queue factory.  The queue
@@ -135,6 +139,7 @@ func (c *processOutputController) waitClose(ctx context.Context, force bool) err
 	for _, out := range c.workers {
 		out.Close()
 	}
+	c.closeDeadLetter()
 
 	return nil
 }
@@ -150,13 +155,15 @@ func (c *processOutputController) Set(outGrp outputs.Group) {
 	for _, w := range c.workers {
 		w.Close()
 	}
+	c.closeDeadLetter()
 
 	// create new output group with the shared work queue
 	clients := outGrp.Clients
 	c.workers = make([]outputWorker, len(clients))
+	c.deadLetter = outGrp.DeadLetter
 	logger := c.beat.Logger.Named("publisher_pipeline_output")
 	for i, client := range clients {
-		c.workers[i] = makeClientWorker(c.workerChan, client, logger, c.monitors.Tracer)
+		c.workers[i] = makeClientWorker(c.workerChan, client, c.deadLetter, logger, c.monitors.Tracer)
 	}
 
 	targetChan := c.workerChan
@@ -180,6 +187,16 @@ func (c *processOutputController) Set(outGrp outputs.Group) {
 		})
 }
 
+func (c *processOutputController) closeDeadLetter() {
+	if c.deadLetter == nil {
+		return
+	}
+	if err := c.deadLetter.Close(); err != nil {
+		c.logger.Errorf("Failed to close the dead letter writer: %v", err)
+	}
+	c.deadLetter = nil
+}
+
 // Reload the output
 func (c *processOutputController) Reload(
 	cfg *reload.ConfigWithMeta,
@@ -309,6 +326,14 @@ func (c *processOutputController) createQueueIfNeeded(outGrp outputs.Group) {
 	}
 	c.queue = queue
 
+	// Queues with metrics of their own, like slab queues and their share of
+	// the pool, report them next to the queue observer metrics.
+	if mq, ok := queue.(metricsQueue); ok && pipelineMetrics != nil {
+		if err := mq.RegisterMetrics(pipelineMetrics.GetOrCreateRegistry("queue")); err != nil {
+			logger.Warnf("queue metrics can't be registered: %v", err)
+		}
+	}
+
 	if c.monitors.Telemetry != nil {
 		queueReg := c.monitors.Telemetry.GetOrCreateRegistry("queue")
 		monitoring.NewString(queueReg, "name").Set(c.queue.QueueType())
@@ -322,6 +347,12 @@ func (c *processOutputController) createQueueIfNeeded(outGrp outputs.Group) {
 	c.pendingRequests = nil
 }
 
+// metricsQueue is implemented by queues that report metrics besides the
+// ones of their queue.Observer.
+type metricsQueue interface {
+	RegisterMetrics(reg *monitoring.Registry) error
+}
+
 // emptyProducer is a placeholder queue producer that is used only when
 // publishDisabled is set, so beats don't block forever waiting for
 // a producer for a nonexistent queue.
@@ -31,6 +31,7 @@ import (
 	"github.com/elastic/beats/v7/libbeat/publisher"
 	"github.com/elastic/beats/v7/libbeat/publisher/queue"
 	"github.com/elastic/beats/v7/libbeat/publisher/queue/memqueue"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/slabqueue"
 	conf "github.com/elastic/elastic-agent-libs/config"
 	"github.com/elastic/elastic-agent-libs/logp"
 	"github.com/elastic/elastic-agent-libs/logp/logptest"
@@ -291,3 +292,28 @@ func TestQueueMetrics(t *testing.T) {
 	require.True(t, ok, "pipeline.queue.max_events must be a *monitoring.Uint")
 	assert.Equal(t, uint64(1000), value.Get(), "pipeline.queue.max_events should match the events configuration key")
 }
+
+func TestSlabQueueMetrics(t *testing.T) {
+	reg := monitoring.NewRegistry()
+	logger := logptest.NewTestingLogger(t, "")
+	controller := processOutputController{
+		queueFactory: slabqueue.FactoryForSettings[publisher.Event](
+			slabqueue.Settings{Events: 1000, Reserved: 100, Weight: 2}),
+		consumer: &eventConsumer{
+			targetChan:    make(chan consumerTarget, 4),
+			retryObserver: nilObserver,
+		},
+		monitors: Monitors{Metrics: reg},
+		beat: beat.Info{
+			Logger: logger,
+		},
+	}
+	controller.Set(outputs.Group{
+		Clients: []outputs.Client{newMockClient(nil)},
+	})
+	defer controller.queue.Close(true)
+
+	snapshot := monitoring.CollectFlatSnapshot(reg, monitoring.Full, false)
+	assert.Equal(t, int64(100), snapshot.Ints["pipeline.queue.slab.reserved_events"])
+	assert.Equal(t, int64(2), snapshot.Ints["pipeline.queue.slab.weight"])
+}
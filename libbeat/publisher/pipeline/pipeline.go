@@ -30,12 +30,14 @@ import (
 	"github.com/elastic/beats/v7/libbeat/common/acker"
 	"github.com/elastic/beats/v7/libbeat/common/reload"
 	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/processors"
 	"github.com/elastic/beats/v7/libbeat/publisher"
 	"github.com/elastic/beats/v7/libbeat/publisher/processing"
 	"github.com/elastic/beats/v7/libbeat/publisher/queue"
 	"github.com/elastic/beats/v7/libbeat/publisher/queue/diskqueue"
 	"github.com/elastic/beats/v7/libbeat/publisher/queue/memqueue"
 	"github.com/elastic/beats/v7/libbeat/publisher/queue/slabqueue"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/spillqueue"
 	conf "github.com/elastic/elastic-agent-libs/config"
 	"github.com/elastic/elastic-agent-libs/paths"
 )
@@ -84,6 +86,11 @@ type Pipeline struct {
 
 	processors processing.Supporter
 
+	// closing lets the processors publishing events of their own publish
+	// their pending events, and unregisters the pipeline they publish to and
+	// the registry they report their metrics to.
+	closing func()
+
 	// clients is the set of connected clients. The Pipeline finalizes each of
 	// them (stage two of client shutdown, client.disconnect) when it is
 	// disconnected. Clients register on ConnectWith and remove themselves when
@@ -211,6 +218,7 @@ func New(
 	outputController.Set(out)
 	p.outputController = outputController
 
+	p.closing = p.registerProcessors(beat.Paths)
 	p.startReaper()
 	return p, nil
 }
@@ -252,10 +260,23 @@ func NewForReceiver(
 		return nil, err
 	}
 
+	p.closing = p.registerProcessors(beatInfo.Paths)
 	p.startReaper()
 	return p, nil
 }
 
+// registerProcessors registers the pipeline and its metrics registry for the
+// processors of the beat using beatPaths. Processor metrics are reported
+// under `processors` in the registry. The returned function unregisters both.
+func (p *Pipeline) registerProcessors(beatPaths *paths.Path) func() {
+	unregisterMetrics := processors.RegisterMetrics(beatPaths, p.monitors.Metrics)
+	closing := processors.RegisterPipeline(beatPaths, p)
+	return func() {
+		closing()
+		unregisterMetrics()
+	}
+}
+
 // Disconnect stops the pipeline, outputs and queue.
 // If WaitClose with WaitOnPipelineClose mode is configured, Disconnect will block
 // for a duration of WaitClose, if there are still active events in the pipeline.
@@ -265,12 +286,17 @@ func NewForReceiver(
 // The Beater is expected to close its clients (stage one) before disconnecting
 // the pipeline; Disconnect then performs stage two for any still-registered
 // client — see issues #50104 and #49794.
+//
+// Before the queue is drained, processors publishing events of their own
+// (e.g. the aggregate processor) publish their pending events.
 func (p *Pipeline) Disconnect(ctx context.Context) error {
 	p.closeOnce.Do(func() {
 		log := p.monitors.Logger
 
 		log.Debug("close pipeline")
 
+		p.closing()
+
 		// The Beater determines how long to wait before full disconnection by
 		// supplying a context with a deadline (issue #49794). If the caller did
 		// not set one, fall back to the pipeline's configured waitCloseTimeout.
@@ -537,6 +563,12 @@ func queueFactoryForUserConfig(queueType string, userConfig *conf.C, paths *path
 			return nil, nil, err
 		}
 		return diskqueue.FactoryForSettings(settings, paths), settings, nil
+	case spillqueue.QueueType:
+		settings, err := spillqueue.SettingsForUserConfig(userConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+		return spillqueue.FactoryForSettings(settings, paths), settings, nil
 	default:
 		return nil, nil, fmt.Errorf("unrecognized queue type '%v'", queueType)
 	}
@@ -18,19 +18,27 @@
 package pipeline
 
 import (
+	"context"
 	"runtime"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	_ "github.com/elastic/beats/v7/libbeat/processors/aggregate"
 	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/beats/v7/libbeat/publisher/processing"
 	"github.com/elastic/beats/v7/libbeat/publisher/queue"
 	"github.com/elastic/beats/v7/libbeat/tests/resources"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
 	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/paths"
 )
 
 func TestPipelineAcceptsAnyNumberOfClients(t *testing.T) {
@@ -78,6 +86,67 @@ func TestPipelineAcceptsAnyNumberOfClients(t *testing.T) {
 	}
 }
 
+func TestPipelineDisconnectPublishesPendingProcessorEvents(t *testing.T) {
+	logger := logptest.NewTestingLogger(t, "")
+	info := beat.Info{Logger: logger, Paths: paths.New()}
+
+	support, err := processing.MakeDefaultSupport(true, nil)(info, logger, conf.MustNewConfigFrom(map[string]any{
+		"processors": []map[string]any{
+			{"aggregate": map[string]any{"metrics": []string{"bytes"}, "window": "1h"}},
+		},
+	}))
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, support.Close()) })
+
+	var (
+		mu        sync.Mutex
+		published []beat.Event
+	)
+	pipeline, err := Load(info, Monitors{Logger: logger}, Config{}, support,
+		func(outputs.Observer) (string, outputs.Group, error) {
+			return "test", outputs.Group{Clients: []outputs.Client{
+				newMockClient(func(batch publisher.Batch) error {
+					mu.Lock()
+					defer mu.Unlock()
+					for _, event := range batch.Events() {
+						published = append(published, event.Content)
+					}
+					batch.ACK()
+					return nil
+				}),
+			}}, nil
+		},
+	)
+	require.NoError(t, err)
+
+	client, err := pipeline.ConnectWith(beat.ClientConfig{})
+	require.NoError(t, err)
+	for _, bytes := range []int{1, 2, 3} {
+		client.Publish(beat.Event{Timestamp: time.Now(), Fields: mapstr.M{"bytes": bytes}})
+	}
+	require.NoError(t, client.Close())
+
+	// The window of the aggregate processor is still open: its rollup is
+	// published when the pipeline is disconnected.
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, pipeline.Disconnect(ctx))
+
+	mu.Lock()
+	defer mu.Unlock()
+	var rollups []beat.Event
+	for _, event := range published {
+		if ok, _ := event.Fields.HasKey("aggregate"); ok {
+			rollups = append(rollups, event)
+		}
+	}
+	require.Len(t, rollups, 1, "events published: %v", published)
+	sum, err := rollups[0].Fields.GetValue("aggregate.metrics.bytes.sum")
+	require.NoError(t, err)
+	assert.Equal(t, 6.0, sum)
+	assert.Len(t, published, 4)
+}
+
 func TestPipelineWaitCloseThenForce(t *testing.T) {
 	closed := make(chan struct{})
 	forceClosed := make(chan struct{})
@@ -56,6 +56,10 @@ type builder struct {
 	timeSeries       bool
 	timeseriesFields mapping.Fields
 
+	// fraction of events annotated with a processor trace in @metadata
+	// (disabled by default)
+	traceSampleRate float64
+
 	// global pipeline processors
 	processors *group
 
@@ -106,6 +110,7 @@ func MakeDefaultSupport(
 			mapstr.EventMetadata `config:",inline"`      // Fields and tags to add to each event.
 			Processors           processors.PluginConfig `config:"processors"`
 			TimeSeries           bool                    `config:"timeseries.enabled"`
+			TraceSampleRate      float64                 `config:"processor_trace.sample_rate" validate:"min=0,max=1"`
 		}{}
 		if err := beatCfg.Unpack(&cfg); err != nil {
 			return nil, err
@@ -123,12 +128,17 @@ func MakeDefaultSupport(
 			rawProcessors = cfg.Processors
 		}
 
-		processors, err := processors.New(rawProcessors, log)
+		processors, err := processors.NewWithOwner(rawProcessors, processors.GlobalOwner, log)
 		if err != nil {
 			return nil, fmt.Errorf("error initializing processors: %w", err)
 		}
 
-		return newBuilder(info, log, processors, cfg.EventMetadata, modifiers, !normalize, cfg.TimeSeries)
+		b, err := newBuilder(info, log, processors, cfg.EventMetadata, modifiers, !normalize, cfg.TimeSeries)
+		if err != nil {
+			return nil, err
+		}
+		b.traceSampleRate = cfg.TraceSampleRate
+		return b, nil
 	}
 }
 
@@ -281,6 +291,7 @@ func (b *builder) Processors() []string {
 // Processing order (C=client, P=pipeline)
 //  1. (P) generalize/normalize event
 //  2. (C) add Meta from client Config to event.Meta
+//     (P) (if processor_trace enabled) sample event for tracing
 //  3. (C) add Fields from client config to event.Fields
 //  4. (P) add pipeline fields + tags
 //  5. (C) add client fields + tags
@@ -339,6 +350,11 @@ func (b *builder) Create(cfg beat.ProcessingConfig, drop bool) (beat.Processor,
 		processors.add(clientEventMeta(m, needsCopy))
 	}
 
+	// setup 2: sample event for a processor trace (P)
+	if b.traceSampleRate > 0 && (localProcessors != nil || b.processors != nil) {
+		processors.add(traceSampleProcessor(b.traceSampleRate))
+	}
+
 	// setup 4, 5: pipeline tags + client tags
 	var tags []string
 	tags = append(tags, b.tags...)
@@ -36,6 +36,7 @@ import (
 	"github.com/elastic/elastic-agent-libs/mapstr"
 	"github.com/elastic/elastic-agent-libs/paths"
 
+	_ "github.com/elastic/beats/v7/libbeat/processors/actions"
 	_ "github.com/elastic/beats/v7/libbeat/processors/add_cloud_metadata"
 	_ "github.com/elastic/beats/v7/libbeat/processors/add_docker_metadata"
 	_ "github.com/elastic/beats/v7/libbeat/processors/add_host_metadata"
@@ -449,6 +450,46 @@ func TestDynamicFields(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestProcessorTrace(t *testing.T) {
+	const global = `processors: [{add_fields: {target: "", fields: {env: prod}}}]`
+
+	run := func(t *testing.T, cfgYAML string) *beat.Event {
+		t.Helper()
+		cfg, err := config.NewConfigWithYAML([]byte(cfgYAML), "test")
+		require.NoError(t, err)
+		factory, err := MakeDefaultSupport(true, nil)(beat.Info{Paths: tmpPaths(t)}, logp.L(), cfg)
+		require.NoError(t, err)
+		t.Cleanup(func() { require.NoError(t, factory.Close()) })
+
+		prog, err := factory.Create(beat.ProcessingConfig{}, false)
+		require.NoError(t, err)
+		event, err := prog.Run(&beat.Event{Fields: mapstr.M{"hello": "world"}})
+		require.NoError(t, err)
+		return event
+	}
+
+	t.Run("sampled", func(t *testing.T) {
+		event := run(t, global+"\nprocessor_trace.sample_rate: 1")
+		trace, ok := event.Meta[processors.TraceMetaKey].([]mapstr.M)
+		require.True(t, ok, "event has no processor trace: %v", event.Meta)
+		require.Len(t, trace, 1)
+		assert.Equal(t, "global.0.add_fields", trace[0]["processor"])
+		assert.Equal(t, "ok", trace[0]["result"])
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		event := run(t, global)
+		assert.NotContains(t, event.Meta, processors.TraceMetaKey)
+	})
+
+	t.Run("invalid sample rate", func(t *testing.T) {
+		cfg, err := config.NewConfigWithYAML([]byte("processor_trace.sample_rate: 2"), "test")
+		require.NoError(t, err)
+		_, err = MakeDefaultSupport(true, nil)(beat.Info{Paths: tmpPaths(t)}, logp.L(), cfg)
+		assert.Error(t, err)
+	})
+}
+
 func TestProcessingClose(t *testing.T) {
 	factory, err := MakeDefaultSupport(true, nil)(beat.Info{Paths: tmpPaths(t)}, logp.L(), config.NewConfig())
 	require.NoError(t, err)
@@ -20,6 +20,7 @@ package processing
 import (
 	"errors"
 	"fmt"
+	"math/rand/v2"
 	"strings"
 	"sync"
 	"time"
@@ -184,6 +185,21 @@ func addMeta(event *beat.Event, meta mapstr.M) {
 	}
 }
 
+// traceSampleProcessor starts a processor trace in @metadata for the given
+// fraction of events. Processors then append an entry to the trace of every
+// event carrying one.
+func traceSampleProcessor(rate float64) *processorFn {
+	return newAnnotateProcessor("sampleTrace", func(event *beat.Event) {
+		if rand.Float64() >= rate {
+			return
+		}
+		if event.Meta == nil {
+			event.Meta = mapstr.M{}
+		}
+		event.Meta[processors.TraceMetaKey] = []mapstr.M{}
+	})
+}
+
 func makeAddDynMetaProcessor(
 	name string,
 	meta *mapstr.Pointer,
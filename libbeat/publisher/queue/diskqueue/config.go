@@ -64,8 +64,18 @@ type Settings struct {
 	RetryInterval    time.Duration
 	MaxRetryInterval time.Duration
 
-	// UseCompression enables or disables LZ4 compression
+	// UseCompression enables or disables LZ4 compression. It can't be
+	// combined with EncryptionKey.
 	UseCompression bool
+
+	// EncryptionKey enables AES-GCM encryption of the data frames written
+	// to new segments. It must be 16, 24 or 32 bytes long, and it can't be
+	// combined with UseCompression.
+	EncryptionKey []byte
+
+	// PreviousEncryptionKeys are only used to decrypt segments that were
+	// written before the encryption key was rotated.
+	PreviousEncryptionKeys [][]byte
 }
 
 // userConfig holds the parameters for a disk queue that are configurable
@@ -80,6 +90,8 @@ type userConfig struct {
 
 	RetryInterval    *time.Duration `config:"retry_interval" validate:"positive"`
 	MaxRetryInterval *time.Duration `config:"max_retry_interval" validate:"positive"`
+
+	Encryption *encryptionConfig `config:"encryption"`
 }
 
 func (c *userConfig) Validate() error {
@@ -164,6 +176,15 @@ func SettingsForUserConfig(config *config.C) (Settings, error) {
 		settings.MaxRetryInterval = *userConfig.MaxRetryInterval
 	}
 
+	if userConfig.Encryption != nil {
+		key, previousKeys, err := userConfig.Encryption.keys()
+		if err != nil {
+			return Settings{}, fmt.Errorf("disk queue encryption: %w", err)
+		}
+		settings.EncryptionKey = key
+		settings.PreviousEncryptionKeys = previousKeys
+	}
+
 	return settings, nil
 }
 
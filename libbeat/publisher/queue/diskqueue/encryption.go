@@ -0,0 +1,206 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package diskqueue
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// keyIDSize is the size of the key identifier stored in front of every
+// encrypted frame. It is derived from the key itself, so the reader can pick
+// the right key when segments were written before a key rotation.
+const keyIDSize = 8
+
+type keyID [keyIDSize]byte
+
+func (id keyID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// errUnknownKey is returned when a frame was encrypted with a key that
+// isn't part of the queue settings.
+var errUnknownKey = errors.New("frame was encrypted with an unknown key")
+
+// frameCipher encrypts and decrypts the serialized data of frames with
+// AES-GCM. New frames are always encrypted with the current key, frames
+// written with a previous key can still be decrypted as long as that key
+// is configured. frameCipher is safe for concurrent use.
+type frameCipher struct {
+	currentID keyID
+	current   cipher.AEAD
+	keys      map[keyID]cipher.AEAD
+}
+
+// newFrameCipher creates a frameCipher that encrypts with key and decrypts
+// with key or any of previousKeys. Keys must be 16, 24 or 32 bytes long to
+// select AES-128, AES-192 or AES-256.
+func newFrameCipher(key []byte, previousKeys [][]byte) (*frameCipher, error) {
+	fc := &frameCipher{keys: make(map[keyID]cipher.AEAD)}
+
+	for i, k := range append([][]byte{key}, previousKeys...) {
+		aead, err := newAEAD(k)
+		if err != nil {
+			if i == 0 {
+				return nil, fmt.Errorf("invalid encryption key: %w", err)
+			}
+			return nil, fmt.Errorf("invalid previous encryption key %d: %w", i-1, err)
+		}
+		id := keyIDFor(k)
+		if i == 0 {
+			fc.currentID = id
+			fc.current = aead
+		}
+		fc.keys[id] = aead
+	}
+	return fc, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func keyIDFor(key []byte) keyID {
+	var id keyID
+	sum := sha256.Sum256(key)
+	copy(id[:], sum[:])
+	return id
+}
+
+// encrypt returns the encrypted form of data, made of the current key ID,
+// a random nonce and the sealed data.
+func (fc *frameCipher) encrypt(data []byte) ([]byte, error) {
+	nonceSize := fc.current.NonceSize()
+	out := make([]byte, keyIDSize+nonceSize, keyIDSize+nonceSize+len(data)+fc.current.Overhead())
+	copy(out, fc.currentID[:])
+	if _, err := rand.Read(out[keyIDSize:]); err != nil {
+		return nil, fmt.Errorf("couldn't generate nonce: %w", err)
+	}
+	return fc.current.Seal(out, out[keyIDSize:], data, nil), nil
+}
+
+// decrypt reverses encrypt, appending the decrypted data to dst.
+func (fc *frameCipher) decrypt(dst, data []byte) ([]byte, error) {
+	if len(data) < keyIDSize {
+		return nil, fmt.Errorf("encrypted frame too short (%d bytes)", len(data))
+	}
+	var id keyID
+	copy(id[:], data)
+
+	aead, ok := fc.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w (key id %v), add the key it was written "+
+			"with to encryption.previous_keys", errUnknownKey, id)
+	}
+
+	data = data[keyIDSize:]
+	nonceSize := aead.NonceSize()
+	if len(data) < nonceSize+aead.Overhead() {
+		return nil, fmt.Errorf("encrypted frame too short (%d bytes)", len(data)+keyIDSize)
+	}
+	plaintext, err := aead.Open(dst, data[:nonceSize], data[nonceSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't decrypt frame with key id %v: %w", id, err)
+	}
+	return plaintext, nil
+}
+
+// encryptionConfig holds the user configuration of the segment encryption.
+// Keys are base64 encoded, either given inline (typically as a reference
+// to a keystore entry) or read from a file.
+type encryptionConfig struct {
+	Key              string   `config:"key"`
+	KeyFile          string   `config:"key_file"`
+	PreviousKeys     []string `config:"previous_keys"`
+	PreviousKeyFiles []string `config:"previous_key_files"`
+}
+
+func (c *encryptionConfig) Validate() error {
+	if (c.Key == "") == (c.KeyFile == "") {
+		return errors.New("disk queue encryption requires exactly one of key or key_file")
+	}
+	return nil
+}
+
+// keys loads and decodes the current and previous keys.
+func (c *encryptionConfig) keys() ([]byte, [][]byte, error) {
+	var key []byte
+	var err error
+	if c.KeyFile != "" {
+		key, err = readKeyFile(c.KeyFile)
+	} else {
+		key, err = decodeKey(c.Key)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't load encryption key: %w", err)
+	}
+
+	previous := make([][]byte, 0, len(c.PreviousKeys)+len(c.PreviousKeyFiles))
+	for i, encoded := range c.PreviousKeys {
+		k, err := decodeKey(encoded)
+		if err != nil {
+			return nil, nil, fmt.Errorf("couldn't load previous_keys[%d]: %w", i, err)
+		}
+		previous = append(previous, k)
+	}
+	for _, path := range c.PreviousKeyFiles {
+		k, err := readKeyFile(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("couldn't load previous key: %w", err)
+		}
+		previous = append(previous, k)
+	}
+	return key, previous, nil
+}
+
+func readKeyFile(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read key file: %w", err)
+	}
+	key, err := decodeKey(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("invalid key in file %s: %w", path, err)
+	}
+	return key, nil
+}
+
+func decodeKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("key is not valid base64: %w", err)
+	}
+	switch len(key) {
+	case 16, 24, 32:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("key must be 16, 24 or 32 bytes long, got %d", len(key))
+	}
+}
@@ -0,0 +1,204 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package diskqueue
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/publisher/queue"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+var (
+	testKeyA = bytes.Repeat([]byte{0xa}, 32)
+	testKeyB = bytes.Repeat([]byte{0xb}, 16)
+)
+
+func TestFrameCipherRoundTrip(t *testing.T) {
+	fc, err := newFrameCipher(testKeyA, nil)
+	require.NoError(t, err)
+
+	plaintext := []byte("some serialized event")
+	encrypted, err := fc.encrypt(plaintext)
+	require.NoError(t, err)
+	assert.NotContains(t, string(encrypted), string(plaintext))
+
+	decrypted, err := fc.decrypt(nil, encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, decrypted)
+
+	// Every frame gets its own nonce.
+	other, err := fc.encrypt(plaintext)
+	require.NoError(t, err)
+	assert.NotEqual(t, encrypted, other)
+}
+
+func TestFrameCipherKeyRotation(t *testing.T) {
+	before, err := newFrameCipher(testKeyA, nil)
+	require.NoError(t, err)
+	encrypted, err := before.encrypt([]byte("written before rotation"))
+	require.NoError(t, err)
+
+	after, err := newFrameCipher(testKeyB, [][]byte{testKeyA})
+	require.NoError(t, err)
+	decrypted, err := after.decrypt(nil, encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "written before rotation", string(decrypted))
+
+	withoutPrevious, err := newFrameCipher(testKeyB, nil)
+	require.NoError(t, err)
+	_, err = withoutPrevious.decrypt(nil, encrypted)
+	assert.ErrorIs(t, err, errUnknownKey)
+	assert.ErrorContains(t, err, keyIDFor(testKeyA).String())
+}
+
+func TestFrameCipherTamperedFrame(t *testing.T) {
+	fc, err := newFrameCipher(testKeyA, nil)
+	require.NoError(t, err)
+	encrypted, err := fc.encrypt([]byte("some serialized event"))
+	require.NoError(t, err)
+
+	encrypted[len(encrypted)-1] ^= 0xff
+	_, err = fc.decrypt(nil, encrypted)
+	assert.ErrorContains(t, err, "couldn't decrypt frame")
+
+	_, err = fc.decrypt(nil, encrypted[:keyIDSize+4])
+	assert.ErrorContains(t, err, "too short")
+}
+
+func TestEncryptionConfig(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "queue.key")
+	require.NoError(t, os.WriteFile(keyFile,
+		[]byte(base64.StdEncoding.EncodeToString(testKeyB)+"\n"), 0600))
+
+	tests := map[string]struct {
+		config       map[string]any
+		key          []byte
+		previousKeys [][]byte
+		err          string
+	}{
+		"inline key": {
+			config: map[string]any{
+				"key": base64.StdEncoding.EncodeToString(testKeyA),
+			},
+			key:          testKeyA,
+			previousKeys: [][]byte{},
+		},
+		"key file with previous key": {
+			config: map[string]any{
+				"key_file":      keyFile,
+				"previous_keys": []string{base64.StdEncoding.EncodeToString(testKeyA)},
+			},
+			key:          testKeyB,
+			previousKeys: [][]byte{testKeyA},
+		},
+		"key and key file": {
+			config: map[string]any{
+				"key":      base64.StdEncoding.EncodeToString(testKeyA),
+				"key_file": keyFile,
+			},
+			err: "exactly one of key or key_file",
+		},
+		"invalid key size": {
+			config: map[string]any{
+				"key": base64.StdEncoding.EncodeToString([]byte("short")),
+			},
+			err: "key must be 16, 24 or 32 bytes long",
+		},
+		"missing key file": {
+			config: map[string]any{
+				"key_file": filepath.Join(t.TempDir(), "missing.key"),
+			},
+			err: "couldn't read key file",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := config.MustNewConfigFrom(map[string]any{
+				"max_size":   "100MB",
+				"encryption": tc.config,
+			})
+			settings, err := SettingsForUserConfig(cfg)
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.key, settings.EncryptionKey)
+			assert.Equal(t, tc.previousKeys, settings.PreviousEncryptionKeys)
+		})
+	}
+}
+
+func TestEncryptedQueueAcrossKeyRotation(t *testing.T) {
+	settings := DefaultSettings()
+	settings.Path = t.TempDir()
+	settings.EncryptionKey = testKeyA
+	logger := logptest.NewTestingLogger(t, "")
+
+	// Run 1: publish an event with the first key and close the queue
+	// without reading it.
+	run1Queue, err := NewQueue(logger, nil, settings, nil, &paths.Path{})
+	require.NoError(t, err)
+	producer := run1Queue.Producer(queue.ProducerConfig{})
+	_, ok := producer.Publish(makeDiskQueueTestEvent("secret-message"))
+	require.True(t, ok)
+	producer.Close()
+	closeQueueAndWait(t, run1Queue)
+
+	segments, err := filepath.Glob(filepath.Join(settings.Path, "*.seg"))
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+	content, err := os.ReadFile(segments[0])
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "secret-message")
+
+	// Run 2: rotate the key, the pending event is still readable through
+	// the previous key.
+	settings.EncryptionKey = testKeyB
+	settings.PreviousEncryptionKeys = [][]byte{testKeyA}
+	run2Queue, err := NewQueue(logger, nil, settings, nil, &paths.Path{})
+	require.NoError(t, err)
+	batch := readBatch(t, run2Queue, 3*time.Second)
+	require.NotNil(t, batch)
+	require.Equal(t, 1, batch.Count())
+	assertEventMessage(t, batch.Entry(0), "secret-message")
+	batch.Done()
+	closeQueueAndWait(t, run2Queue)
+}
+
+func TestEncryptedQueueRejectsCompression(t *testing.T) {
+	settings := DefaultSettings()
+	settings.Path = t.TempDir()
+	settings.EncryptionKey = testKeyA
+	settings.UseCompression = true
+
+	_, err := NewQueue(logptest.NewTestingLogger(t, ""), nil, settings, nil, &paths.Path{})
+	assert.ErrorContains(t, err, "compression can't be combined with encryption")
+}
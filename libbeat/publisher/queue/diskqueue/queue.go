@@ -43,6 +43,10 @@ type diskQueue struct {
 	// Metadata related to the segment files.
 	segments diskQueueSegments
 
+	// If encryption is enabled, cipher encrypts the frames created by
+	// producers and decrypts the frames read from disk.
+	cipher *frameCipher
+
 	// Metadata related to consumer acks / positions of the oldest remaining
 	// frame.
 	acks *diskQueueACKs
@@ -135,6 +139,12 @@ func NewQueue(
 				"twice the segment size (%v)",
 			settings.MaxBufferSize, settings.MaxSegmentSize)
 	}
+	// Encrypted frames are indistinguishable from random data, so compressing
+	// the segment after encryption would only cost CPU.
+	if settings.UseCompression && settings.EncryptionKey != nil {
+		return nil, errors.New(
+			"disk queue compression can't be combined with encryption")
+	}
 	observer.MaxBytes(int(settings.MaxBufferSize)) //nolint:gosec // G115 Conversion from uint64 to int is safe here.
 
 	// Create the given directory path if it doesn't exist.
@@ -222,11 +232,20 @@ func NewQueue(
 		encoder = encoderFactory()
 	}
 
+	var cipher *frameCipher
+	if settings.EncryptionKey != nil {
+		cipher, err = newFrameCipher(settings.EncryptionKey, settings.PreviousEncryptionKeys)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't set up disk queue encryption: %w", err)
+		}
+	}
+
 	queue := &diskQueue{
 		logger:   logger,
 		observer: observer,
 		settings: settings,
 		paths:    paths,
+		cipher:   cipher,
 
 		segments: diskQueueSegments{
 			reading:          initialSegments,
@@ -238,7 +257,7 @@ func NewQueue(
 
 		acks: newDiskQueueACKs(logger, nextReadPosition, positionFile),
 
-		readerLoop:  newReaderLoop(settings, encoder, paths),
+		readerLoop:  newReaderLoop(settings, encoder, cipher, paths),
 		writerLoop:  newWriterLoop(logger, settings, paths),
 		deleterLoop: newDeleterLoop(settings, paths),
 
@@ -77,12 +77,25 @@ type readerLoop struct {
 	// them from disk, to convert them to their final output serialization
 	// format.
 	outputEncoder queue.Encoder[publisher.Event]
+
+	// The cipher used to decrypt frames from encrypted segments, nil if
+	// encryption is not configured.
+	cipher *frameCipher
+
+	// Scratch buffer holding the decrypted data of the current frame.
+	decrypted []byte
 }
 
-func newReaderLoop(settings Settings, outputEncoder queue.Encoder[publisher.Event], paths *paths.Path) *readerLoop {
+func newReaderLoop(
+	settings Settings,
+	outputEncoder queue.Encoder[publisher.Event],
+	cipher *frameCipher,
+	paths *paths.Path,
+) *readerLoop {
 	return &readerLoop{
 		settings: settings,
 		paths:    paths,
+		cipher:   cipher,
 
 		requestChan:   make(chan readerLoopRequest, 1),
 		responseChan:  make(chan readerLoopResponse),
@@ -116,6 +129,11 @@ func (rl *readerLoop) processRequest(request readerLoopRequest) readerLoopRespon
 		return readerLoopResponse{err: err}
 	}
 	defer handle.Close()
+	if handle.encrypted && rl.cipher == nil {
+		return readerLoopResponse{err: fmt.Errorf(
+			"segment %d is encrypted but disk queue encryption is not configured",
+			request.segment.id)}
+	}
 	rl.decoder.serializationFormat = handle.serializationFormat
 
 	_, err = handle.Seek(int64(request.startPosition), io.SeekStart)
@@ -248,6 +266,14 @@ func (rl *readerLoop) nextFrame(handle *segmentReader, maxLength uint64) (*readF
 			frameLength, duplicateLength)
 	}
 
+	if handle.encrypted {
+		rl.decrypted, err = rl.cipher.decrypt(rl.decrypted[:0], bytes)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't decrypt data frame: %w", err)
+		}
+		copy(rl.decoder.Buffer(len(rl.decrypted)), rl.decrypted)
+	}
+
 	event, err := rl.decoder.Decode()
 	if err != nil {
 		// Unlike errors in the segment or frame metadata, this is entirely
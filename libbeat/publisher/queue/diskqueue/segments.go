@@ -151,9 +151,12 @@ const currentSegmentVersion = 2
 const segmentHeaderSize = 12
 
 const (
-	_                  uint32 = 1 << iota // 0x1
+	// legacyEncryption was used by an older, incompatible encryption format.
+	// Segments with this flag set can not be read.
+	legacyEncryption   uint32 = 1 << iota // 0x1
 	ENABLE_COMPRESSION                    // 0x2
 	ENABLE_PROTOBUF                       // 0x4
+	ENABLE_ENCRYPTION                     // 0x8
 )
 
 // Sort order: we store loaded segments in ascending order by their id.
@@ -243,6 +246,12 @@ func (segment *queueSegment) getReader(queueSettings Settings, paths *paths.Path
 			"couldn't read header for segment %d: %w", segment.id, err)
 	}
 
+	if (header.options & legacyEncryption) == legacyEncryption {
+		file.Close()
+		return nil, fmt.Errorf(
+			"segment %d uses an unsupported legacy encryption format", segment.id)
+	}
+
 	sr := &segmentReader{}
 	sr.src = file
 
@@ -256,6 +265,10 @@ func (segment *queueSegment) getReader(queueSettings Settings, paths *paths.Path
 		sr.serializationFormat = SerializationCBOR
 	}
 
+	if (header.options & ENABLE_ENCRYPTION) == ENABLE_ENCRYPTION {
+		sr.encrypted = true
+	}
+
 	if (header.options & ENABLE_COMPRESSION) == ENABLE_COMPRESSION {
 		sr.cr = NewCompressionReader(sr.src)
 	}
@@ -273,6 +286,10 @@ func (segment *queueSegment) getWriter(queueSettings Settings, paths *paths.Path
 		return nil, err
 	}
 
+	if queueSettings.EncryptionKey != nil {
+		options = options | ENABLE_ENCRYPTION
+	}
+
 	if queueSettings.UseCompression {
 		options = options | ENABLE_COMPRESSION
 	}
@@ -446,6 +463,10 @@ type segmentReader struct {
 	src                 io.ReadSeekCloser
 	cr                  *CompressionReader
 	serializationFormat SerializationFormat
+
+	// encrypted is set when the data of every frame in the segment is
+	// encrypted, see frameCipher.
+	encrypted bool
 }
 
 func (r *segmentReader) Read(p []byte) (int, error) {
@@ -19,9 +19,11 @@ package diskqueue
 
 import (
 	"io"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSegmentsRoundTrip(t *testing.T) {
@@ -175,3 +177,44 @@ func TestSegmentReaderSeekLocations(t *testing.T) {
 		assert.Error(t, err, name)
 	}
 }
+
+func TestSegmentEncryptionFlag(t *testing.T) {
+	settings := DefaultSettings()
+	settings.Path = t.TempDir()
+	settings.EncryptionKey = testKeyA
+
+	qs := &queueSegment{id: 0}
+	sw, err := qs.getWriter(settings, nil)
+	require.NoError(t, err)
+	require.NoError(t, sw.Close())
+
+	f, err := os.Open(settings.segmentPath(qs.id, nil))
+	require.NoError(t, err)
+	header, err := readSegmentHeader(f)
+	f.Close()
+	require.NoError(t, err)
+	assert.Equal(t, ENABLE_ENCRYPTION, header.options, "encryption must not reuse the legacy flag")
+
+	sr, err := qs.getReader(settings, nil)
+	require.NoError(t, err)
+	assert.True(t, sr.encrypted)
+	require.NoError(t, sr.Close())
+}
+
+func TestSegmentLegacyEncryptionFlag(t *testing.T) {
+	settings := DefaultSettings()
+	settings.Path = t.TempDir()
+
+	// Write a segment with the flag of the legacy encryption format.
+	qs := &queueSegment{id: 1}
+	f, err := os.Create(settings.segmentPath(qs.id, nil))
+	require.NoError(t, err)
+	sw := &segmentWriter{dst: f}
+	require.NoError(t, sw.WriteHeader(legacyEncryption))
+	_, err = sw.Write([]byte("legacy encrypted frames"))
+	require.NoError(t, err)
+	require.NoError(t, sw.Close())
+
+	_, err = qs.getReader(settings, nil)
+	assert.ErrorContains(t, err, "unsupported legacy encryption format")
+}
@@ -72,6 +72,22 @@ type Pool[T any] struct {
 	capacity atomic.Int64
 	target   atomic.Int64
 
+	// Reservations and weights of the connected queues; see share.go.
+	//
+	//   reservedTotal: sum of the connected queues' reservations, updated
+	//                  under mu by Connect and disconnect.
+	//   sharedLive:    units of the shared budget held by live events.
+	//   waitingWeight: sum of the weights of the queues that have producers
+	//                  waiting for the shared budget.
+	//   shareWaiters/shareMu/shareCond: park point for those producers,
+	//                  touched only on the blocking slow path.
+	reservedTotal atomic.Int64
+	sharedLive    atomic.Int64
+	waitingWeight atomic.Int64
+	shareWaiters  atomic.Int64
+	shareMu       sync.Mutex
+	shareCond     *sync.Cond
+
 	// homeCounter hands each producer a stable home shard for the free list,
 	// assigned once at producer creation. It keeps ticking as producers
 	// (receivers) come and go; the shard count never changes.
@@ -121,6 +137,7 @@ func NewPool[T any](settings Settings, observer queue.Observer) *Pool[T] {
 		queues:   make(map[*Queue[T]]struct{}),
 	}
 	p.batchPool.New = func() any { return &batch[T]{} }
+	p.shareCond = sync.NewCond(&p.shareMu)
 	p.dir.Store(newDirectory[T](settings.Events))
 	for i := 0; i < settings.Events; i++ {
 		p.free.pushNoSignal(i)
@@ -161,6 +178,10 @@ func (p *Pool[T]) setTarget(n int) {
 	case int64(n) < p.capacity.Load():
 		p.shrinkLocked()
 	}
+	// A larger target also means a larger shared budget.
+	if int64(n) > prev {
+		p.wakeShareWaiters()
+	}
 }
 
 // setChunkCount swaps in a directory holding exactly n chunks, allocating fresh
@@ -350,10 +371,21 @@ func (p *Pool[T]) putBatch(b *batch[T]) {
 // connected pipeline must call (*Queue).Close when it is finished; the pool is
 // only safe to call Shutdown on once every connected queue is closed.
 func (p *Pool[T]) Connect() *Queue[T] {
-	q := newQueue(p)
+	return p.ConnectWithShare(QueueShare{})
+}
+
+// ConnectWithShare is like Connect, with the given reservation and weight for
+// the new queue. If the pool's capacity can't hold the reservations of all
+// its queues, it grows to their sum.
+func (p *Pool[T]) ConnectWithShare(share QueueShare) *Queue[T] {
+	q := newQueue(p, share)
 	p.mu.Lock()
 	p.queues[q] = struct{}{}
+	p.reservedTotal.Add(q.reserved)
 	p.mu.Unlock()
+	if q.reserved > 0 {
+		p.syncTargetToQueues()
+	}
 	return q
 }
 
@@ -370,6 +402,7 @@ func (p *Pool[T]) Shutdown() {
 		// Wake any producers parked on a full pool so they observe the closed
 		// state and return instead of blocking forever.
 		p.free.wakeAll()
+		p.wakeShareWaiters()
 		p.mu.Lock()
 		queues := make([]*Queue[T], 0, len(p.queues))
 		for q := range p.queues {
@@ -407,19 +440,27 @@ func (p *Pool[T]) ConnectedQueues() int {
 // disconnect is called by Queue.Close to unregister itself from the pool.
 func (p *Pool[T]) disconnect(q *Queue[T]) {
 	p.mu.Lock()
-	delete(p.queues, q)
+	if _, ok := p.queues[q]; ok {
+		delete(p.queues, q)
+		p.reservedTotal.Add(-q.reserved)
+	}
 	p.mu.Unlock()
 	// A departing queue may have held the largest per-queue cap; resize the pool
 	// to the new maximum so it tracks the connected queues.
 	p.syncTargetToQueues()
+	// Its reservation, if any, is now part of the shared budget.
+	if q.reserved > 0 {
+		p.wakeShareWaiters()
+	}
 }
 
 // syncTargetToQueues sizes the pool to the largest per-queue cap among the
 // connected queues, so the shared pool always tracks the queues rather than
 // being set independently (which could drift). It is the single place pool
-// capacity is derived: Queue.SetTarget and disconnect call it. Queues with no
-// per-queue cap (limit 0) do not contribute, so a pool of only uncapped queues
-// keeps whatever capacity it was created with.
+// capacity is derived: Queue.SetTarget, ConnectWithShare and disconnect call
+// it. Queues with no per-queue cap (limit 0) do not contribute, so a pool of
+// only uncapped queues keeps whatever capacity it was created with. In every
+// case the pool is at least as large as the sum of the queues' reservations.
 func (p *Pool[T]) syncTargetToQueues() {
 	if p.isClosed() {
 		return
@@ -433,7 +474,11 @@ func (p *Pool[T]) syncTargetToQueues() {
 	for q := range p.queues {
 		largest = max(largest, int(q.limit.Load()))
 	}
-	if largest > 0 {
-		p.setTarget(largest)
+	reserved := int(p.reservedTotal.Load())
+	switch {
+	case largest > 0:
+		p.setTarget(max(largest, reserved))
+	case reserved > p.Target():
+		p.setTarget(reserved)
 	}
 }
@@ -52,8 +52,9 @@ type producer[T any] struct {
 	ackOnce   sync.Once
 }
 
-// Publish adds an entry, blocking until both this queue is under its per-queue
-// cap and the shared pool has a free slot, or the queue/pool is closed.
+// Publish adds an entry, blocking until this queue is under its per-queue cap,
+// has a reserved or shared unit of the pool's budget, and the shared pool has a
+// free slot, or the queue/pool is closed.
 //
 // The per-queue cap is reserved first, before acquiring a pool slot: a queue at
 // its cap must not consume pool slots it cannot keep, which would starve other
@@ -79,8 +80,9 @@ func (p *producer[T]) Publish(entry T) (queue.EntryID, bool) {
 	return p.fill(entry, slotIdx)
 }
 
-// TryPublish adds an entry only if this queue is under its per-queue cap and a
-// pool slot is immediately available; it never blocks.
+// TryPublish adds an entry only if this queue is under its per-queue cap, has a
+// unit of the pool's budget and a pool slot is immediately available; it never
+// blocks.
 func (p *producer[T]) TryPublish(entry T) (queue.EntryID, bool) {
 	if p.closed.Load() {
 		return 0, false
@@ -89,7 +91,7 @@ func (p *producer[T]) TryPublish(entry T) (queue.EntryID, bool) {
 	// finished >= published and close ackWait while it's still in flight. Each
 	// failure path below calls unpublish to undo this.
 	p.published.Add(1)
-	if !p.queue.tryReserve() {
+	if p.queue.tryReserve() != reserveOK {
 		p.unpublish()
 		return 0, false
 	}
@@ -24,6 +24,7 @@ import (
 	"time"
 
 	"github.com/elastic/beats/v7/libbeat/publisher/queue"
+	"github.com/elastic/elastic-agent-libs/monitoring"
 )
 
 // DefaultGetDebounce is the coalescing window a newly connected queue starts
@@ -93,6 +94,22 @@ type Queue[T any] struct {
 	limMu      sync.Mutex
 	limCond    *sync.Cond
 
+	// This queue's share of the pool (see share.go), fixed at Connect.
+	//
+	//   reserved:     pool slots guaranteed to this queue.
+	//   weight:       this queue's weight in the shared budget.
+	//   shareWaiters: producers of this queue waiting for the shared budget.
+	//   shareBlocked: number of times a producer had to wait for it.
+	reserved     int64
+	weight       int64
+	shareWaiters atomic.Int64
+	shareBlocked atomic.Uint64
+
+	// metrics is the registry created by RegisterMetrics under
+	// metricsParent, removed on Close. Both are guarded by metricsMu.
+	metricsParent *monitoring.Registry
+	metrics       *monitoring.Registry
+
 	closeOnce sync.Once
 	closeCh   chan struct{} // closed on Close
 	doneOnce  sync.Once
@@ -102,9 +119,14 @@ type Queue[T any] struct {
 	debounce time.Duration // coalescing window for Get
 }
 
-func newQueue[T any](pool *Pool[T]) *Queue[T] {
+func newQueue[T any](pool *Pool[T], share QueueShare) *Queue[T] {
+	if share.Weight < 1 {
+		share.Weight = DefaultWeight
+	}
 	q := &Queue[T]{
 		pool:      pool,
+		reserved:  int64(max(0, share.Reserved)),
+		weight:    int64(share.Weight),
 		head:      -1,
 		tail:      -1,
 		notify:    make(chan struct{}, 1),
@@ -141,65 +163,75 @@ func (q *Queue[T]) SetTarget(n int) {
 }
 
 // tryReserve takes one unit of this queue's live-event budget if it is under the
-// cap, without blocking. It is the lock-free fast path: a CAS on the live
-// counter, with limit==0 meaning unlimited.
-func (q *Queue[T]) tryReserve() bool {
+// cap and, past its reservation, the pool's shared budget has a unit for it,
+// without blocking. It is the lock-free fast path: a CAS on the live counter,
+// with limit==0 meaning unlimited, plus a CAS on the pool's shared budget for
+// events beyond the reservation.
+func (q *Queue[T]) tryReserve() reserveResult {
 	for {
-		lim := q.limit.Load()
-		if lim <= 0 {
-			q.live.Add(1)
-			return true
-		}
 		cur := q.live.Load()
-		if cur >= lim {
-			return false
+		if lim := q.limit.Load(); lim > 0 && cur >= lim {
+			return reserveCapped
+		}
+		shared := cur >= q.reserved
+		if shared && !q.pool.tryTakeShared(q, cur) {
+			return reserveNoShare
 		}
 		if q.live.CompareAndSwap(cur, cur+1) {
-			return true
+			return reserveOK
+		}
+		// Lost the race against another producer or a release of this
+		// queue; the unit taken for position cur may not be needed anymore.
+		if shared {
+			q.pool.releaseShared(1)
 		}
 	}
 }
 
 // reserve takes one unit of this queue's live-event budget, blocking until the
-// queue is under its cap or the queue is closed. It returns false only when the
-// queue is closing. Like the pool's acquire, it re-checks after registering as a
-// waiter so a release between the failed fast path and the park is not lost.
+// queue is under its cap and has a slot in the pool's shared budget, or the
+// queue is closed. It returns false only when the queue or the pool is closing.
 func (q *Queue[T]) reserve() bool {
-	if q.tryReserve() {
-		return true
-	}
 	for {
-		q.limMu.Lock()
-		q.limWaiters.Add(1)
-		if q.tryReserve() {
-			q.limWaiters.Add(-1)
-			q.limMu.Unlock()
+		switch q.tryReserve() {
+		case reserveOK:
 			return true
+		case reserveCapped:
+			if !q.waitForLimit() {
+				return false
+			}
+		case reserveNoShare:
+			if !q.pool.waitForShare(q) {
+				return false
+			}
 		}
-		if q.isClosing() {
-			q.limWaiters.Add(-1)
-			q.limMu.Unlock()
-			return false
-		}
+	}
+}
+
+// waitForLimit parks a producer until this queue may be under its cap. It
+// returns false if the queue is closing. Like the pool's acquire, it re-checks
+// after registering as a waiter so a release between the failed fast path and
+// the park is not lost.
+func (q *Queue[T]) waitForLimit() bool {
+	q.limMu.Lock()
+	q.limWaiters.Add(1)
+	if lim := q.limit.Load(); lim > 0 && q.live.Load() >= lim && !q.isClosing() {
 		q.limCond.Wait()
-		q.limWaiters.Add(-1)
-		q.limMu.Unlock()
-		if q.isClosing() {
-			return false
-		}
-		if q.tryReserve() {
-			return true
-		}
 	}
+	q.limWaiters.Add(-1)
+	q.limMu.Unlock()
+	return !q.isClosing()
 }
 
 // releaseLive returns n units to this queue's live-event budget (called when
-// slots are released back to the pool) and wakes a producer parked on the cap.
+// slots are released back to the pool), along with the shared budget units
+// they held, and wakes the producers parked on either.
 func (q *Queue[T]) releaseLive(n int) {
 	if n <= 0 {
 		return
 	}
-	q.live.Add(int64(-n))
+	live := q.live.Add(int64(-n))
+	q.pool.releaseShared(sharedUnits(live+int64(n), q.reserved) - sharedUnits(live, q.reserved))
 	q.wakeLimitWaiters()
 }
 
@@ -393,9 +425,11 @@ func (q *Queue[T]) Close(force bool) error {
 	q.closeOnce.Do(func() {
 		close(q.closeCh)
 		q.pool.free.wakeAll()
-		// Wake producers parked on this queue's per-queue cap so they observe
-		// closeCh and return.
+		// Wake producers parked on this queue's per-queue cap or on the pool's
+		// shared budget so they observe closeCh and return.
 		q.wakeLimitWaiters()
+		q.pool.wakeShareWaiters()
+		q.unregisterMetrics()
 	})
 	q.signal()
 
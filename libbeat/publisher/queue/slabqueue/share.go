@@ -0,0 +1,233 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package slabqueue
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/elastic/elastic-agent-libs/monitoring"
+)
+
+// A pool shared by several queues is split in two parts: the slots reserved
+// by the queues (the sum of their QueueShare.Reserved) and the shared budget
+// made of the rest of the pool's target capacity. A queue's first Reserved
+// live events always count against its reservation, so no other queue can
+// take them; every live event beyond that takes one unit of the shared
+// budget.
+//
+// Accounting is positional: the event that brings a queue from n to n+1 live
+// events takes a shared unit if n >= Reserved, and a release from n+k to n
+// returns max(0, n+k-Reserved) - max(0, n-Reserved) units. Every change of a
+// queue's live count is a single atomic operation, so the number of shared
+// units a queue holds is always max(0, live-Reserved) no matter how its
+// producers and consumers interleave.
+//
+// Weights only matter under contention. While no other queue is waiting for
+// the shared budget a queue can use all of it; as soon as others are waiting,
+// a queue can only take shared units while it holds less than its weighted
+// share of the budget, so the units released by a noisy queue go to the
+// queues waiting behind it.
+
+// DefaultWeight is the weight of a queue connected without an explicit
+// weight.
+const DefaultWeight = 1
+
+// QueueShare configures how a queue shares its pool with the other queues
+// connected to it.
+type QueueShare struct {
+	// Reserved is the number of pool slots guaranteed to the queue: other
+	// queues can't use them even while the queue is idle. The pool grows to
+	// at least the sum of the reservations of its queues.
+	Reserved int
+
+	// Weight is the relative share of the pool's unreserved slots the queue
+	// gets when several queues are waiting for them. Values below 1 are
+	// treated as DefaultWeight.
+	Weight int
+}
+
+// reserveResult tells whether tryReserve took a budget unit, and if not what
+// the producer has to wait for.
+type reserveResult int
+
+const (
+	reserveOK reserveResult = iota
+	// reserveCapped means the queue is at its per-queue cap.
+	reserveCapped
+	// reserveNoShare means the queue is past its reservation and the shared
+	// budget has no unit it may take.
+	reserveNoShare
+)
+
+// sharedBudget returns the number of units in the shared budget.
+func (p *Pool[T]) sharedBudget() int64 {
+	return max(0, p.target.Load()-p.reservedTotal.Load())
+}
+
+// canTakeShared reports whether q, which holds live events, may take a unit
+// of the shared budget: the budget must have a free unit and, when other
+// queues are waiting for one, q must hold less than its weighted share.
+func (p *Pool[T]) canTakeShared(q *Queue[T], live int64) bool {
+	budget := p.sharedBudget()
+	if p.sharedLive.Load() >= budget {
+		return false
+	}
+	others := p.waitingWeight.Load()
+	if q.shareWaiters.Load() > 0 {
+		others -= q.weight
+	}
+	if others <= 0 {
+		return true
+	}
+	// Round the share up so the shares of the waiting queues always cover
+	// the whole budget.
+	share := (budget*q.weight + q.weight + others - 1) / (q.weight + others)
+	return live-q.reserved < share
+}
+
+// tryTakeShared takes a unit of the shared budget for q if canTakeShared
+// allows it.
+func (p *Pool[T]) tryTakeShared(q *Queue[T], live int64) bool {
+	for {
+		if !p.canTakeShared(q, live) {
+			return false
+		}
+		used := p.sharedLive.Load()
+		if p.sharedLive.CompareAndSwap(used, used+1) {
+			return true
+		}
+	}
+}
+
+// releaseShared returns n units to the shared budget and wakes the producers
+// waiting for one.
+func (p *Pool[T]) releaseShared(n int64) {
+	if n <= 0 {
+		return
+	}
+	p.sharedLive.Add(-n)
+	p.wakeShareWaiters()
+}
+
+// waitForShare parks a producer of q until the shared budget may have a unit
+// for it. It returns false if q or the pool was closed meanwhile. Like the
+// free list, it registers as a waiter before re-checking the budget so a
+// release between the failed attempt and the park is not lost. While parked,
+// the producer's queue counts as waiting, which limits the other queues to
+// their weighted share.
+func (p *Pool[T]) waitForShare(q *Queue[T]) bool {
+	q.shareBlocked.Add(1)
+	p.shareMu.Lock()
+	p.shareWaiters.Add(1)
+	if q.shareWaiters.Add(1) == 1 {
+		p.waitingWeight.Add(q.weight)
+	}
+	if !p.canTakeShared(q, q.live.Load()) && !p.isClosed() && !q.isClosing() {
+		p.shareCond.Wait()
+	}
+	if q.shareWaiters.Add(-1) == 0 {
+		p.waitingWeight.Add(-q.weight)
+	}
+	// Other producers may have been held back by this queue's weight.
+	othersWaiting := p.shareWaiters.Add(-1) > 0
+	if othersWaiting {
+		p.shareCond.Broadcast()
+	}
+	p.shareMu.Unlock()
+	return !p.isClosed() && !q.isClosing()
+}
+
+// wakeShareWaiters wakes the producers waiting for the shared budget, but
+// only if one might be waiting.
+func (p *Pool[T]) wakeShareWaiters() {
+	if p.shareWaiters.Load() == 0 {
+		return
+	}
+	p.shareMu.Lock()
+	p.shareCond.Broadcast()
+	p.shareMu.Unlock()
+}
+
+// sharedUnits returns how many shared units a queue with the given
+// reservation holds at live events.
+func sharedUnits(live, reserved int64) int64 {
+	return max(0, live-reserved)
+}
+
+// Reserved returns the number of pool slots reserved for this queue.
+func (q *Queue[T]) Reserved() int { return int(q.reserved) }
+
+// Weight returns this queue's weight in the pool's shared budget.
+func (q *Queue[T]) Weight() int { return int(q.weight) }
+
+// metricsMu serializes RegisterMetrics and unregisterMetrics, so two queues
+// registering with the same registry can't both take it.
+var metricsMu sync.Mutex
+
+// RegisterMetrics reports this queue's share of the pool under the "slab"
+// namespace of reg, typically the queue's "pipeline.queue" registry:
+//
+//   - reserved_events, weight: the queue's QueueShare.
+//   - live_events: events published to the queue and not yet acknowledged.
+//   - shared_events: how many of them use the pool's unreserved slots.
+//   - share_waits: how many times a producer had to wait for an unreserved
+//     slot.
+//
+// The values are read when the registry is reported, so registering them
+// adds no cost to publishing. Each queue needs its own registry: it fails if
+// reg already holds the metrics of another queue. The metrics are removed
+// when the queue is closed.
+func (q *Queue[T]) RegisterMetrics(reg *monitoring.Registry) error {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	if q.metrics != nil {
+		return errors.New("slab queue metrics are already registered")
+	}
+	if reg.GetRegistry("slab") != nil {
+		return errors.New("the registry already holds the metrics of another slab queue")
+	}
+	slab := reg.GetOrCreateRegistry("slab")
+
+	gauge := func(name string, value func() int64) {
+		monitoring.NewFunc(slab, name, func(_ monitoring.Mode, v monitoring.Visitor) {
+			v.OnInt(value())
+		}, monitoring.Report)
+	}
+	gauge("reserved_events", func() int64 { return q.reserved })
+	gauge("weight", func() int64 { return q.weight })
+	gauge("live_events", func() int64 { return q.live.Load() })
+	gauge("shared_events", func() int64 { return sharedUnits(q.live.Load(), q.reserved) })
+	gauge("share_waits", func() int64 { return int64(q.shareBlocked.Load()) }) //nolint:gosec // G115: a wait counter never reaches MaxInt64
+	q.metricsParent, q.metrics = reg, slab
+	return nil
+}
+
+// unregisterMetrics removes the metrics added by RegisterMetrics, unless the
+// registry was cleared and reused by another queue since.
+func (q *Queue[T]) unregisterMetrics() {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	if q.metrics == nil {
+		return
+	}
+	if q.metricsParent.GetRegistry("slab") == q.metrics {
+		q.metricsParent.Remove("slab")
+	}
+	q.metricsParent, q.metrics = nil, nil
+}
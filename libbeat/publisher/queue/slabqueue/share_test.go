@@ -0,0 +1,247 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package slabqueue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/publisher/queue"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/monitoring"
+)
+
+// TestReservedSlotsAreNotUsedByOtherQueues verifies that a noisy queue can't
+// take the slots reserved by another queue, even while that queue is idle.
+func TestReservedSlotsAreNotUsedByOtherQueues(t *testing.T) {
+	pool := NewPool[int](Settings{Events: 4}, nil)
+	defer pool.Shutdown()
+	critical := pool.ConnectWithShare(QueueShare{Reserved: 2})
+	noisy := pool.Connect()
+
+	pNoisy := noisy.Producer(queue.ProducerConfig{})
+	for i := range 2 {
+		_, ok := pNoisy.TryPublish(i)
+		require.True(t, ok)
+	}
+	_, ok := pNoisy.TryPublish(2)
+	assert.False(t, ok, "the noisy queue must not use the reserved slots")
+	assert.Equal(t, 2, pool.Available())
+
+	pCritical := critical.Producer(queue.ProducerConfig{})
+	for i := range 2 {
+		_, ok := pCritical.TryPublish(i)
+		require.True(t, ok, "the critical queue must get its reserved slots")
+	}
+	_, ok = pCritical.TryPublish(2)
+	assert.False(t, ok, "the shared budget is used by the noisy queue")
+
+	// Once the noisy queue drains, the critical queue can also use the shared
+	// budget.
+	b, err := noisy.Get(0)
+	require.NoError(t, err)
+	b.Done()
+	_, ok = pCritical.TryPublish(2)
+	assert.True(t, ok)
+}
+
+// TestReservationsGrowThePool verifies that the pool always holds the
+// reservations of its queues, and gives the slots back when they leave.
+func TestReservationsGrowThePool(t *testing.T) {
+	pool := NewPool[int](Settings{Events: 4}, nil)
+	defer pool.Shutdown()
+
+	qA := pool.ConnectWithShare(QueueShare{Reserved: 3})
+	assert.Equal(t, 4, pool.Target())
+	qB := pool.ConnectWithShare(QueueShare{Reserved: 3})
+	assert.Equal(t, 6, pool.Target())
+	assert.Equal(t, int64(0), pool.sharedBudget())
+
+	// Capped queues size the pool, but never below the reservations.
+	qA.SetTarget(5)
+	assert.Equal(t, 6, pool.Target())
+	qB.SetTarget(8)
+	assert.Equal(t, 8, pool.Target())
+	assert.Equal(t, int64(2), pool.sharedBudget())
+
+	require.NoError(t, qB.Close(true))
+	assert.Equal(t, 5, pool.Target())
+	assert.Equal(t, int64(2), pool.sharedBudget())
+	require.NoError(t, qA.Close(true))
+}
+
+// TestWeightedShare verifies that a queue can use the whole shared budget
+// while nobody else wants it, but only its weighted share while other queues
+// are waiting.
+func TestWeightedShare(t *testing.T) {
+	pool := NewPool[int](Settings{Events: 8}, nil)
+	defer pool.Shutdown()
+	heavy := pool.ConnectWithShare(QueueShare{Weight: 3})
+	light := pool.ConnectWithShare(QueueShare{Weight: 1})
+
+	assert.True(t, pool.canTakeShared(light, 6))
+
+	// The heavy queue waits: the light queue's share is 8 * 1/4 = 2.
+	heavy.shareWaiters.Store(1)
+	pool.waitingWeight.Store(heavy.weight)
+	pool.sharedLive.Store(2)
+	assert.False(t, pool.canTakeShared(light, 2))
+	assert.True(t, pool.canTakeShared(light, 1))
+	assert.True(t, pool.canTakeShared(heavy, 0))
+
+	// The light queue waits: the heavy queue's share is 8 * 3/4 = 6.
+	heavy.shareWaiters.Store(0)
+	light.shareWaiters.Store(1)
+	pool.waitingWeight.Store(light.weight)
+	pool.sharedLive.Store(6)
+	assert.False(t, pool.canTakeShared(heavy, 6))
+	assert.True(t, pool.canTakeShared(light, 0))
+
+	// A full budget refuses everyone.
+	pool.sharedLive.Store(8)
+	assert.False(t, pool.canTakeShared(light, 0))
+}
+
+// TestPublishWaitsForShare verifies that a producer blocked on the shared
+// budget is woken when another queue releases its events.
+func TestPublishWaitsForShare(t *testing.T) {
+	pool := NewPool[int](Settings{Events: 2}, nil)
+	defer pool.Shutdown()
+	qA := pool.Connect()
+	qB := pool.ConnectWithShare(QueueShare{Weight: 2})
+
+	pA := qA.Producer(queue.ProducerConfig{})
+	for i := range 2 {
+		_, ok := pA.Publish(i)
+		require.True(t, ok)
+	}
+
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		pB := qB.Producer(queue.ProducerConfig{})
+		pB.Publish(99)
+	}()
+	require.Eventually(t, func() bool { return qB.shareBlocked.Load() > 0 },
+		time.Second, time.Millisecond, "qB should wait for the shared budget")
+
+	// Releasing one of qA's events wakes qB.
+	b, err := qA.Get(1)
+	require.NoError(t, err)
+	b.Done()
+
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("Publish should have unblocked after qA released an event")
+	}
+	b, err = qB.Get(0)
+	require.NoError(t, err)
+	assert.Equal(t, 99, b.Entry(0))
+	b.Done()
+}
+
+// TestCloseWakesShareWaiters verifies that closing a queue unblocks its
+// producers waiting for the shared budget.
+func TestCloseWakesShareWaiters(t *testing.T) {
+	pool := NewPool[int](Settings{Events: 1}, nil)
+	defer pool.Shutdown()
+	qA := pool.Connect()
+	qB := pool.Connect()
+
+	_, ok := qA.Producer(queue.ProducerConfig{}).Publish(1)
+	require.True(t, ok)
+
+	result := make(chan bool)
+	go func() {
+		_, ok := qB.Producer(queue.ProducerConfig{}).Publish(2)
+		result <- ok
+	}()
+	require.Eventually(t, func() bool { return qB.shareBlocked.Load() > 0 },
+		time.Second, time.Millisecond)
+
+	require.NoError(t, qB.Close(false))
+	select {
+	case ok := <-result:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("Close should have unblocked the producer")
+	}
+}
+
+func TestShareSettings(t *testing.T) {
+	settings, err := SettingsForUserConfig(config.MustNewConfigFrom(map[string]any{
+		"events":          1024,
+		"reserved_events": 256,
+		"weight":          4,
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, QueueShare{Reserved: 256, Weight: 4}, settings.Share())
+
+	settings, err = SettingsForUserConfig(nil)
+	require.NoError(t, err)
+	assert.Equal(t, QueueShare{Reserved: 0, Weight: DefaultWeight}, settings.Share())
+
+	_, err = SettingsForUserConfig(config.MustNewConfigFrom(map[string]any{
+		"events":          1024,
+		"reserved_events": 2048,
+	}))
+	assert.ErrorContains(t, err, "can't be more than events")
+}
+
+func TestShareMetrics(t *testing.T) {
+	pool := NewPool[int](Settings{Events: 8}, nil)
+	defer pool.Shutdown()
+	q := pool.ConnectWithShare(QueueShare{Reserved: 2, Weight: 5})
+
+	reg := monitoring.NewRegistry()
+	require.NoError(t, q.RegisterMetrics(reg))
+
+	p := q.Producer(queue.ProducerConfig{})
+	for i := range 3 {
+		_, ok := p.Publish(i)
+		require.True(t, ok)
+	}
+
+	snapshot := monitoring.CollectFlatSnapshot(reg, monitoring.Full, false)
+	assert.Equal(t, map[string]int64{
+		"slab.reserved_events": 2,
+		"slab.weight":          5,
+		"slab.live_events":     3,
+		"slab.shared_events":   1,
+		"slab.share_waits":     0,
+	}, snapshot.Ints)
+
+	// Another live queue can't take over the registry, the pool may be
+	// shared by several pipelines.
+	other := pool.Connect()
+	assert.ErrorContains(t, other.RegisterMetrics(reg), "another slab queue")
+	assert.Equal(t, int64(5), monitoring.CollectFlatSnapshot(reg, monitoring.Full, false).Ints["slab.weight"])
+
+	// Once the queue is closed, e.g. when its pipeline restarts, the
+	// registry can be used by a new queue.
+	p.Close()
+	require.NoError(t, q.Close(true))
+	assert.Nil(t, reg.GetRegistry("slab"))
+	require.NoError(t, other.RegisterMetrics(reg))
+	snapshot = monitoring.CollectFlatSnapshot(reg, monitoring.Full, false)
+	assert.Equal(t, int64(DefaultWeight), snapshot.Ints["slab.weight"])
+}
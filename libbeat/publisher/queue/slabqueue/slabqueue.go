@@ -37,6 +37,11 @@
 //     never exceed 4096 live events even when the pool has room. A queue with
 //     no per-queue cap is bounded only by the pool, so a single busy pipeline
 //     can still use the whole budget while others are quiet.
+//   - Each Queue may reserve a number of pool slots and has a weight
+//     (QueueShare, see share.go). Reserved slots can't be used by other
+//     queues, so a noisy pipeline can't starve a critical one of its minimum
+//     budget, and the weights split the unreserved slots between the queues
+//     competing for them.
 //   - Each connected pipeline gets its own Queue (implementing
 //     queue.Queue[T]) with its own FIFO over the shared array. A slow or
 //     stalled consumer on one pipeline only holds its own in-flight slots;
@@ -68,7 +73,8 @@ import (
 // from a pipeline config (queue.slab) just like the other implementations.
 const QueueType = "slab"
 
-// Settings configures a queue's initial event budget.
+// Settings configures a queue's initial event budget and its share of the
+// pool.
 type Settings struct {
 	// Events is the pool's initial slot count: the starting bound on events
 	// live (published but not yet ack'd) across every pipeline connected to the
@@ -76,17 +82,32 @@ type Settings struct {
 	// by the connected queues' caps (see Queue.SetTarget), and its storage grows
 	// and shrinks in chunks rather than being a single backing array.
 	Events int
+
+	// Reserved and Weight are the queue's QueueShare. They only matter when
+	// several queues share one pool, like Beat receivers do.
+	Reserved int
+	Weight   int
 }
 
 // userConfig is the YAML-facing shape of slabqueue settings. Kept separate
 // from Settings so we can attach struct tags without exposing them as part
 // of the public Settings type.
 type userConfig struct {
-	Events int `config:"events" validate:"min=32"`
+	Events   int `config:"events" validate:"min=32"`
+	Reserved int `config:"reserved_events" validate:"min=0"`
+	Weight   int `config:"weight" validate:"min=1"`
 }
 
 var defaultUserConfig = userConfig{
 	Events: 3200, // matches memqueue's DefaultEvents
+	Weight: DefaultWeight,
+}
+
+func (c *userConfig) Validate() error {
+	if c.Reserved > c.Events {
+		return fmt.Errorf("slabqueue reserved_events (%d) can't be more than events (%d)", c.Reserved, c.Events)
+	}
+	return nil
 }
 
 // SettingsForUserConfig unpacks a ucfg config from a Beats queue
@@ -101,6 +122,11 @@ func SettingsForUserConfig(cfg *c.C) (Settings, error) {
 	return Settings(parsed), nil
 }
 
+// Share returns the QueueShare of a queue configured with these settings.
+func (s Settings) Share() QueueShare {
+	return QueueShare{Reserved: s.Reserved, Weight: s.Weight}
+}
+
 // FactoryForSettings returns a queue.QueueFactory[T] that gives each
 // pipeline its own private slabqueue.Pool sized to settings.Events. The
 // returned Queue is wired so closing it also shuts down the underlying
@@ -119,7 +145,7 @@ func FactoryForSettings[T any](settings Settings) queue.QueueFactory[T] {
 		_ queue.EncoderFactory[T],
 	) (queue.Queue[T], error) {
 		pool := NewPool[T](settings, observer)
-		return &slabBackedQueue[T]{Queue: pool.Connect(), pool: pool}, nil
+		return &slabBackedQueue[T]{Queue: pool.ConnectWithShare(settings.Share()), pool: pool}, nil
 	}
 }
 
@@ -0,0 +1,208 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package spillqueue
+
+import (
+	"sync"
+
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue"
+)
+
+// producer publishes events to the memory queue while it has room and to
+// the disk queue otherwise, and merges the acknowledgments of both queues
+// back into publish order.
+type producer struct {
+	queue *spillQueue
+	ack   func(count int)
+
+	mem  queue.Producer[publisher.Event]
+	disk queue.Producer[publisher.Event]
+
+	// runs holds, oldest first, the sequences of consecutive events that were
+	// published to the same queue and aren't fully acknowledged yet. It is
+	// only used when the producer has an ACK callback.
+	runsMu sync.Mutex
+	runs   []run
+
+	closeOnce sync.Once
+	ackWait   chan struct{}
+}
+
+// run is a sequence of consecutive events published to the same queue.
+// Events are released to the producer callback once acknowledged and all
+// the events before them are released, so only the oldest run can have
+// released events.
+type run struct {
+	spilled  bool
+	count    int
+	acked    int
+	released int
+}
+
+func newProducer(q *spillQueue, cfg queue.ProducerConfig) *producer {
+	p := &producer{
+		queue:   q,
+		ack:     cfg.ACK,
+		ackWait: make(chan struct{}),
+	}
+
+	// The memory producer always gets an ACK callback, the queue needs it to
+	// know whether the output is making progress.
+	p.mem = q.mem.Producer(queue.ProducerConfig{
+		ACK: func(count int) {
+			q.memACKed(count)
+			p.acked(false, count)
+		},
+	})
+
+	var diskCfg queue.ProducerConfig
+	if p.ack != nil {
+		diskCfg.ACK = func(count int) { p.acked(true, count) }
+	}
+	p.disk = q.disk.Producer(diskCfg)
+	return p
+}
+
+func (p *producer) Publish(event publisher.Event) (queue.EntryID, bool) {
+	return p.publish(event, true)
+}
+
+func (p *producer) TryPublish(event publisher.Event) (queue.EntryID, bool) {
+	return p.publish(event, false)
+}
+
+func (p *producer) publish(event publisher.Event, shouldBlock bool) (queue.EntryID, bool) {
+	if !p.queue.shouldSpill() {
+		// Register the event before publishing it, its ACK may come before
+		// TryPublish returns.
+		p.published(false)
+		if id, ok := p.mem.TryPublish(event); ok {
+			p.queue.memPublished()
+			return id, true
+		}
+		p.cancelled(false)
+	}
+
+	p.published(true)
+	var ok bool
+	if shouldBlock {
+		_, ok = p.disk.Publish(event)
+	} else {
+		_, ok = p.disk.TryPublish(event)
+	}
+	if !ok {
+		p.cancelled(true)
+		return 0, false
+	}
+	p.queue.diskPublished()
+	// The disk queue doesn't assign entry IDs.
+	return 0, true
+}
+
+func (p *producer) Close() {
+	p.closeOnce.Do(func() {
+		p.mem.Close()
+		p.disk.Close()
+		if p.ack == nil {
+			close(p.ackWait)
+			return
+		}
+		go func() {
+			<-p.mem.ACKWaitChan()
+			<-p.disk.ACKWaitChan()
+			close(p.ackWait)
+		}()
+	})
+}
+
+func (p *producer) ACKWaitChan() <-chan struct{} {
+	return p.ackWait
+}
+
+// published registers an event about to be published to the disk queue if
+// spilled is true or to the memory queue otherwise.
+func (p *producer) published(spilled bool) {
+	if p.ack == nil {
+		return
+	}
+	p.runsMu.Lock()
+	defer p.runsMu.Unlock()
+	if n := len(p.runs); n > 0 && p.runs[n-1].spilled == spilled {
+		p.runs[n-1].count++
+		return
+	}
+	p.runs = append(p.runs, run{spilled: spilled, count: 1})
+}
+
+// cancelled unregisters the latest event registered by published, when the
+// queue didn't accept it.
+func (p *producer) cancelled(spilled bool) {
+	if p.ack == nil {
+		return
+	}
+	p.runsMu.Lock()
+	defer p.runsMu.Unlock()
+	n := len(p.runs)
+	if n == 0 || p.runs[n-1].spilled != spilled {
+		return
+	}
+	p.runs[n-1].count--
+	if p.runs[n-1].count == 0 {
+		p.runs = p.runs[:n-1]
+	}
+}
+
+// acked applies count acknowledgments from one of the queues to the oldest
+// runs of that queue, and reports to the producer callback every event
+// whose predecessors are all acknowledged.
+func (p *producer) acked(spilled bool, count int) {
+	if p.ack == nil {
+		return
+	}
+
+	p.runsMu.Lock()
+	for i := range p.runs {
+		if count == 0 {
+			break
+		}
+		r := &p.runs[i]
+		if r.spilled != spilled {
+			continue
+		}
+		n := min(count, r.count-r.acked)
+		r.acked += n
+		count -= n
+	}
+
+	released := 0
+	for len(p.runs) > 0 {
+		r := &p.runs[0]
+		released += r.acked - r.released
+		r.released = r.acked
+		if r.acked < r.count {
+			break
+		}
+		p.runs = p.runs[1:]
+	}
+	p.runsMu.Unlock()
+
+	if released > 0 {
+		p.ack(released)
+	}
+}
@@ -0,0 +1,232 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package spillqueue
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/diskqueue"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/memqueue"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+// errQueueClosed is returned by Get once both underlying queues are closed.
+var errQueueClosed = errors.New("spill queue is closed")
+
+type spillQueue struct {
+	logger   *logp.Logger
+	settings Settings
+
+	mem  queue.Queue[publisher.Event]
+	disk queue.Queue[publisher.Event]
+
+	// memPending is the number of events published to the memory queue that
+	// haven't been acknowledged yet. lastProgress is the time, in unix
+	// nanoseconds, of the last memory queue acknowledgment, or of the
+	// moment memPending went up from zero if that is more recent.
+	memPending   atomic.Int64
+	lastProgress atomic.Int64
+
+	// spilling is true while new events are written to the disk queue. Once
+	// spilling, events keep going to disk until the memory queue holds at
+	// most half of SpillThreshold events, so a busy memory queue doesn't flip
+	// between the two queues on every event.
+	spilling atomic.Bool
+
+	// Batches are read from the underlying queues by one goroutine each,
+	// started by the first call to Get. batchSize holds the event count of
+	// the latest Get call, which is used for the following reads.
+	startReaders sync.Once
+	batchSize    atomic.Int64
+	memBatches   chan queue.Batch[publisher.Event]
+	diskBatches  chan queue.Batch[publisher.Event]
+
+	// done is closed once both underlying queues are done.
+	done chan struct{}
+}
+
+// NewQueue creates a spill queue made of a memory queue and a disk queue
+// configured with the given settings. Both queues report to observer.
+func NewQueue(
+	logger *logp.Logger,
+	observer queue.Observer,
+	settings Settings,
+	inputQueueSize int,
+	encoderFactory queue.EncoderFactory[publisher.Event],
+	paths *paths.Path,
+) (queue.Queue[publisher.Event], error) {
+	if logger == nil {
+		logger = logp.NewLogger("spillqueue") //nolint:forbidigo // fallback logger when the caller does not provide one.
+	} else {
+		logger = logger.Named("spillqueue")
+	}
+	if observer == nil {
+		observer = queue.NewQueueObserver(nil)
+	}
+
+	disk, err := diskqueue.NewQueue(logger, observer, settings.Disk, encoderFactory, paths)
+	if err != nil {
+		return nil, err
+	}
+	mem := memqueue.NewQueue(logger, observer, settings.Mem, inputQueueSize, encoderFactory)
+
+	q := &spillQueue{
+		logger:      logger,
+		settings:    settings,
+		mem:         mem,
+		disk:        disk,
+		memBatches:  make(chan queue.Batch[publisher.Event]),
+		diskBatches: make(chan queue.Batch[publisher.Event]),
+		done:        make(chan struct{}),
+	}
+	go func() {
+		<-mem.Done()
+		<-disk.Done()
+		close(q.done)
+	}()
+	return q, nil
+}
+
+func (q *spillQueue) Close(force bool) error {
+	return errors.Join(q.mem.Close(force), q.disk.Close(force))
+}
+
+func (q *spillQueue) Done() <-chan struct{} {
+	return q.done
+}
+
+func (q *spillQueue) QueueType() string {
+	return QueueType
+}
+
+func (q *spillQueue) BufferConfig() queue.BufferConfig {
+	// The disk queue has no fixed event limit, so neither has the spill
+	// queue.
+	return queue.BufferConfig{MaxEvents: 0}
+}
+
+func (q *spillQueue) Producer(cfg queue.ProducerConfig) queue.Producer[publisher.Event] {
+	return newProducer(q, cfg)
+}
+
+// Get returns the next batch of events, preferring the memory queue and
+// falling back on the disk queue when memory has no batch ready.
+func (q *spillQueue) Get(eventCount int) (queue.Batch[publisher.Event], error) {
+	q.batchSize.Store(int64(eventCount))
+	q.startReaders.Do(func() {
+		go q.readBatches(q.mem, q.memBatches)
+		go q.readBatches(q.disk, q.diskBatches)
+	})
+
+	memBatches, diskBatches := q.memBatches, q.diskBatches
+	for memBatches != nil || diskBatches != nil {
+		select {
+		case batch, ok := <-memBatches:
+			if ok {
+				return batch, nil
+			}
+			memBatches = nil
+			continue
+		default:
+		}
+
+		select {
+		case batch, ok := <-memBatches:
+			if ok {
+				return batch, nil
+			}
+			memBatches = nil
+		case batch, ok := <-diskBatches:
+			if ok {
+				return batch, nil
+			}
+			diskBatches = nil
+		}
+	}
+	return nil, errQueueClosed
+}
+
+// readBatches forwards the batches of an underlying queue to out until that
+// queue is closed. A batch that can't be delivered because the queue shut
+// down is released.
+func (q *spillQueue) readBatches(
+	source queue.Queue[publisher.Event],
+	out chan<- queue.Batch[publisher.Event],
+) {
+	defer close(out)
+	for {
+		batch, err := source.Get(int(q.batchSize.Load()))
+		if err != nil {
+			return
+		}
+		select {
+		case out <- batch:
+		case <-source.Done():
+			batch.Release()
+			return
+		}
+	}
+}
+
+// shouldSpill reports whether new events should skip the memory queue,
+// either because it holds too many events or because the output hasn't
+// acknowledged anything for OutputTimeout.
+func (q *spillQueue) shouldSpill() bool {
+	pending := q.memPending.Load()
+	threshold := int64(q.settings.SpillThreshold)
+	if threshold > 0 && pending >= threshold {
+		return true
+	}
+	if q.spilling.Load() && pending > threshold/2 {
+		return true
+	}
+	if q.settings.OutputTimeout <= 0 || pending == 0 {
+		return false
+	}
+	since := time.Since(time.Unix(0, q.lastProgress.Load()))
+	return since > q.settings.OutputTimeout
+}
+
+// memPublished records an event added to the memory queue.
+func (q *spillQueue) memPublished() {
+	if q.memPending.Add(1) == 1 {
+		q.lastProgress.Store(time.Now().UnixNano())
+	}
+	if q.spilling.CompareAndSwap(true, false) {
+		q.logger.Info("Memory queue accepts events again, no longer spilling to disk")
+	}
+}
+
+// memACKed records events acknowledged by the memory queue.
+func (q *spillQueue) memACKed(count int) {
+	q.lastProgress.Store(time.Now().UnixNano())
+	q.memPending.Add(-int64(count))
+}
+
+// diskPublished records an event added to the disk queue.
+func (q *spillQueue) diskPublished() {
+	if q.spilling.CompareAndSwap(false, true) {
+		q.logger.Info("Memory queue is full or the output is unavailable, spilling events to disk")
+	}
+}
@@ -0,0 +1,135 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package spillqueue implements a hybrid queue that serves events from
+// memory and spills them to disk when memory is not enough.
+//
+// A spill queue owns a memory queue (memqueue) and a disk queue (diskqueue).
+// Producers publish to the memory queue as long as it has room, so in the
+// normal case events flow with the latency of the memory queue. New events
+// are written to the disk queue instead when the memory queue holds
+// Settings.SpillThreshold events or is full, or when the output has not
+// acknowledged any event for Settings.OutputTimeout while events are
+// pending, so a long output outage doesn't lose the events that arrive
+// during it.
+//
+// Consumers read from the memory queue first and from the disk queue when
+// memory has nothing to offer. Events are therefore not delivered in strict
+// publish order once the queue has spilled, but producer ACK callbacks still
+// fire in publish order: every producer tracks which queue each of its
+// events went to and only reports an acknowledgment once every earlier event
+// has been acknowledged by its own queue. The disk queue acknowledges events
+// as soon as they are written to disk.
+//
+// Events held by the memory queue are lost if the Beat stops, like with the
+// memory queue alone; events spilled to disk are kept across restarts.
+package spillqueue
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/diskqueue"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/memqueue"
+	c "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+// QueueType is the string used to select this queue in beats configurations.
+const QueueType = "spill"
+
+// Settings contains the configuration of the memory and disk queues
+// backing a spill queue.
+type Settings struct {
+	Mem  memqueue.Settings
+	Disk diskqueue.Settings
+
+	// SpillThreshold is the number of events pending in the memory queue
+	// above which new events are written to the disk queue. It can't be
+	// more than Mem.Events.
+	SpillThreshold int
+
+	// OutputTimeout is how long the output may go without acknowledging
+	// any event, while events are pending in memory, before new events are
+	// spilled to disk even though memory has room. Zero disables it, in
+	// which case events are only spilled when the memory queue is full.
+	OutputTimeout time.Duration
+}
+
+type config struct {
+	Mem            *c.C          `config:"mem"`
+	Disk           *c.C          `config:"disk" validate:"required"`
+	SpillThreshold int           `config:"spill_threshold" validate:"min=0"`
+	OutputTimeout  time.Duration `config:"output_timeout" validate:"min=0"`
+}
+
+var defaultConfig = config{
+	OutputTimeout: 30 * time.Second,
+}
+
+// SettingsForUserConfig unpacks a ucfg config from a Beats queue
+// configuration and returns the equivalent spillqueue.Settings object.
+func SettingsForUserConfig(cfg *c.C) (Settings, error) {
+	config := defaultConfig
+	if err := cfg.Unpack(&config); err != nil {
+		return Settings{}, fmt.Errorf("couldn't unpack spill queue config: %w", err)
+	}
+
+	memSettings, err := memqueue.SettingsForUserConfig(config.Mem)
+	if err != nil {
+		return Settings{}, fmt.Errorf("spill queue mem settings: %w", err)
+	}
+	diskSettings, err := diskqueue.SettingsForUserConfig(config.Disk)
+	if err != nil {
+		return Settings{}, fmt.Errorf("spill queue disk settings: %w", err)
+	}
+
+	// By default events are spilled once the memory queue is full.
+	spillThreshold := config.SpillThreshold
+	if spillThreshold == 0 {
+		spillThreshold = memSettings.Events
+	}
+	if spillThreshold > memSettings.Events {
+		return Settings{}, fmt.Errorf(
+			"spill queue spill_threshold (%d) can't be more than mem.events (%d)",
+			spillThreshold, memSettings.Events)
+	}
+
+	return Settings{
+		Mem:            memSettings,
+		Disk:           diskSettings,
+		SpillThreshold: spillThreshold,
+		OutputTimeout:  config.OutputTimeout,
+	}, nil
+}
+
+// FactoryForSettings is a simple wrapper around NewQueue so a concrete
+// Settings object can be wrapped in a queue-agnostic interface for
+// later use by the pipeline.
+func FactoryForSettings(settings Settings, paths *paths.Path) queue.QueueFactory[publisher.Event] {
+	return func(
+		logger *logp.Logger,
+		observer queue.Observer,
+		inputQueueSize int,
+		encoderFactory queue.EncoderFactory[publisher.Event],
+	) (queue.Queue[publisher.Event], error) {
+		return NewQueue(logger, observer, settings, inputQueueSize, encoderFactory, paths)
+	}
+}
@@ -0,0 +1,270 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package spillqueue
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/diskqueue"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/memqueue"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/queuetest"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+func testSettings(t *testing.T, memEvents int) Settings {
+	diskSettings := diskqueue.DefaultSettings()
+	diskSettings.Path = t.TempDir()
+	return Settings{
+		Mem: memqueue.Settings{
+			Events:        memEvents,
+			MaxGetRequest: memEvents,
+			FlushTimeout:  0,
+		},
+		Disk:           diskSettings,
+		SpillThreshold: memEvents,
+		OutputTimeout:  30 * time.Second,
+	}
+}
+
+func newTestQueue(t *testing.T, settings Settings) queue.Queue[publisher.Event] {
+	t.Helper()
+	q, err := NewQueue(logptest.NewTestingLogger(t, ""), nil, settings, 0, nil, &paths.Path{})
+	require.NoError(t, err)
+	return q
+}
+
+func TestProduceConsumer(t *testing.T) {
+	events := 512
+	batchSize := 32
+
+	for name, memEvents := range map[string]int{
+		"fits in memory": 4096,
+		"spills to disk": 64,
+	} {
+		factory := func(t *testing.T) queue.Queue[publisher.Event] {
+			return newTestQueue(t, testSettings(t, memEvents))
+		}
+		t.Run(name, func(t *testing.T) {
+			t.Run("single", func(t *testing.T) {
+				queuetest.TestSingleProducerConsumer(t, events, batchSize, factory)
+			})
+			t.Run("multi", func(t *testing.T) {
+				queuetest.TestMultiProducerConsumer(t, events, batchSize, factory)
+			})
+		})
+	}
+}
+
+func TestSpillWhenMemoryIsFull(t *testing.T) {
+	const events = 100
+	q := newTestQueue(t, testSettings(t, 32))
+	defer q.Close(true)
+
+	var acked atomic.Int64
+	producer := q.Producer(queue.ProducerConfig{
+		ACK: func(count int) { acked.Add(int64(count)) },
+	})
+	for i := 0; i < events; i++ {
+		_, ok := producer.Publish(queuetest.MakeEvent(mapstr.M{"id": i}))
+		require.True(t, ok, "publishing event %d", i)
+	}
+
+	// Nothing was consumed yet, so the memory queue can't have acknowledged
+	// anything: the events that went to disk weren't acknowledged either,
+	// since they come after the ones held in memory.
+	assert.Zero(t, acked.Load())
+
+	// Events read back from disk don't keep the Go type of their fields, so
+	// the IDs are compared as strings.
+	seen := make(map[string]bool, events)
+	for len(seen) < events {
+		batch, err := q.Get(10)
+		require.NoError(t, err)
+		for i := 0; i < batch.Count(); i++ {
+			id, err := batch.Entry(i).Content.Fields.GetValue("id")
+			require.NoError(t, err)
+			seen[fmt.Sprint(id)] = true
+		}
+		batch.Done()
+	}
+	assert.Len(t, seen, events)
+
+	require.Eventually(t, func() bool { return acked.Load() == events },
+		5*time.Second, 10*time.Millisecond, "all events should be acknowledged")
+}
+
+func TestSpillOnOutputTimeout(t *testing.T) {
+	settings := testSettings(t, 64)
+	settings.OutputTimeout = 50 * time.Millisecond
+	q := newTestQueue(t, settings)
+	defer q.Close(true)
+	sq, ok := q.(*spillQueue)
+	require.True(t, ok)
+
+	producer := q.Producer(queue.ProducerConfig{})
+	for i := 0; i < 5; i++ {
+		_, ok := producer.Publish(queuetest.MakeEvent(mapstr.M{"message": "in-memory"}))
+		require.True(t, ok)
+	}
+	assert.False(t, sq.spilling.Load(), "the memory queue has room")
+
+	// Nobody reads from the queue, so once OutputTimeout passes the memory
+	// queue is considered stuck even though it isn't full.
+	time.Sleep(2 * settings.OutputTimeout)
+	for i := 0; i < 5; i++ {
+		_, ok := producer.Publish(queuetest.MakeEvent(mapstr.M{"message": "spilled"}))
+		require.True(t, ok)
+	}
+	assert.True(t, sq.spilling.Load(), "events should be spilled to disk")
+	assert.Equal(t, int64(5), sq.memPending.Load(),
+		"spilled events must not be added to the memory queue")
+
+	messages := map[string]int{}
+	for received := 0; received < 10; {
+		batch, err := q.Get(10)
+		require.NoError(t, err)
+		for i := 0; i < batch.Count(); i++ {
+			msg, err := batch.Entry(i).Content.Fields.GetValue("message")
+			require.NoError(t, err)
+			messages[fmt.Sprint(msg)]++
+		}
+		received += batch.Count()
+		batch.Done()
+	}
+	assert.Equal(t, map[string]int{"in-memory": 5, "spilled": 5}, messages)
+}
+
+func TestSpilledEventsSurviveRestart(t *testing.T) {
+	settings := testSettings(t, 4)
+	settings.OutputTimeout = 0
+
+	// Run 1: fill the memory queue and spill the following events, then
+	// close the queue without reading anything.
+	run1Queue := newTestQueue(t, settings)
+	producer := run1Queue.Producer(queue.ProducerConfig{})
+	for i := 0; i < 10; i++ {
+		_, ok := producer.Publish(queuetest.MakeEvent(mapstr.M{"id": i}))
+		require.True(t, ok, "publishing event %d", i)
+	}
+	producer.Close()
+	closeQueueAndWait(t, run1Queue)
+
+	// Run 2: the events held in memory are gone, the spilled ones are read
+	// back from disk.
+	run2Queue := newTestQueue(t, settings)
+	defer closeQueueAndWait(t, run2Queue)
+	var ids []string
+	for len(ids) < 6 {
+		batch, err := run2Queue.Get(10)
+		require.NoError(t, err)
+		for i := 0; i < batch.Count(); i++ {
+			id, err := batch.Entry(i).Content.Fields.GetValue("id")
+			require.NoError(t, err)
+			ids = append(ids, fmt.Sprint(id))
+		}
+		batch.Done()
+	}
+	assert.Equal(t, []string{"4", "5", "6", "7", "8", "9"}, ids)
+}
+
+// closeQueueAndWait force closes q, a graceful close would wait for the
+// events held in memory to be consumed.
+func closeQueueAndWait(t *testing.T, q queue.Queue[publisher.Event]) {
+	t.Helper()
+	require.NoError(t, q.Close(true))
+	select {
+	case <-q.Done():
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "queue did not close in time")
+	}
+}
+
+func TestProducerACKOrder(t *testing.T) {
+	var mu sync.Mutex
+	var acks []int
+	p := &producer{ack: func(count int) {
+		mu.Lock()
+		defer mu.Unlock()
+		acks = append(acks, count)
+	}}
+
+	// Three events in memory, two on disk, one in memory again.
+	for _, spilled := range []bool{false, false, false, true, true, false} {
+		p.published(spilled)
+	}
+	// An event that was refused by the disk queue doesn't count.
+	p.published(true)
+	p.cancelled(true)
+	p.published(false)
+
+	// Disk events can't be released before the memory events published
+	// before them.
+	p.acked(true, 2)
+	assert.Empty(t, acks)
+
+	// The first memory ACK covers the oldest memory events.
+	p.acked(false, 2)
+	assert.Equal(t, []int{2}, acks)
+	p.acked(false, 1)
+	assert.Equal(t, []int{2, 3}, acks)
+	p.acked(false, 2)
+	assert.Equal(t, []int{2, 3, 2}, acks)
+	assert.Empty(t, p.runs)
+}
+
+func TestSettingsForUserConfig(t *testing.T) {
+	cfg := config.MustNewConfigFrom(map[string]any{
+		"mem": map[string]any{
+			"events": 512,
+		},
+		"disk": map[string]any{
+			"max_size": "100MB",
+		},
+		"output_timeout": "1m",
+	})
+	settings, err := SettingsForUserConfig(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 512, settings.Mem.Events)
+	assert.Equal(t, uint64(100*1000*1000), settings.Disk.MaxBufferSize)
+	assert.Equal(t, 512, settings.SpillThreshold)
+	assert.Equal(t, time.Minute, settings.OutputTimeout)
+
+	_, err = SettingsForUserConfig(config.MustNewConfigFrom(map[string]any{
+		"mem": map[string]any{"events": 512},
+	}))
+	assert.Error(t, err, "the disk queue settings are required")
+
+	_, err = SettingsForUserConfig(config.MustNewConfigFrom(map[string]any{
+		"mem":             map[string]any{"events": 512},
+		"disk":            map[string]any{"max_size": "100MB"},
+		"spill_threshold": 1024,
+	}))
+	assert.ErrorContains(t, err, "can't be more than mem.events")
+}
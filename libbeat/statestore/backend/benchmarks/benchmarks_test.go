@@ -30,6 +30,7 @@ import (
 	"github.com/elastic/beats/v7/libbeat/statestore/backend"
 	"github.com/elastic/beats/v7/libbeat/statestore/backend/memlog"
 	"github.com/elastic/beats/v7/libbeat/statestore/backend/otelstorage"
+	"github.com/elastic/beats/v7/libbeat/statestore/backend/sqlite"
 	"github.com/elastic/elastic-agent-libs/logp"
 )
 
@@ -63,6 +64,15 @@ var factories = []registryFactory{
 			})
 		},
 	},
+	{
+		name: "sqlite",
+		newFunc: func(dir string) (backend.Registry, error) {
+			return sqlite.New(logp.NewNopLogger(), sqlite.Settings{
+				Root:     dir,
+				FileMode: 0o600,
+			})
+		},
+	},
 }
 
 func makeValue(i int) map[string]any {
@@ -0,0 +1,51 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package sqlite implements the sqlite statestore backend.
+// Every store is kept in its own SQLite database file in the registry root
+// directory, named after the store with a `.db` extension. Unlike memlog the
+// store does not hold its key-value pairs in memory, making it suitable for
+// registries tracking a large number of resources.
+//
+// Key-value pairs are stored in the `kv` table, indexed by key. Values are
+// serialized to JSON, using the same representation as memlog. Every update
+// is applied in its own transaction, so the database file always reflects a
+// consistent state. The database is opened in WAL mode with `synchronous` set
+// to NORMAL: an update is durable once the operating system has written the
+// WAL file, which gives the same guarantees as the memlog update log.
+//
+// The `meta` table holds the schema version of the store and, if the store
+// was migrated, the memlog directory it was migrated from and its
+// modification time at that point.
+//
+// Databases are created with incremental auto-vacuum. Pages freed by removed
+// entries are returned to the file system after a configurable number of
+// removals and when the store is closed, so the file does not keep growing
+// as resources come and go.
+//
+// When a store is created and the registry root directory contains a memlog
+// store of the same name, all its key-value pairs are copied into the new
+// database within the transaction creating the schema. The memlog directory
+// is left untouched, so a failed migration is retried on the next start and
+// the registry can still be used with memlog after a rollback. If the memlog
+// store is modified after the migration, it holds a more recent state than
+// the database: the content of the database is replaced with it when the
+// store is opened again.
+//
+// The backend uses the cgo based SQLite driver, so it is only available in
+// binaries built with cgo enabled.
+package sqlite
@@ -0,0 +1,175 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/statestore/backend"
+	"github.com/elastic/beats/v7/libbeat/statestore/backend/memlog"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// memlogMetaFileName is the file every memlog store directory contains.
+const memlogMetaFileName = "meta.json"
+
+// migrateMemlog copies all key-value pairs of the memlog store name in the
+// registry root directory into the kv table using tx, and records the
+// directory and the modification time of the memlog store in the meta table.
+// The migration is skipped if there is no such memlog store.
+func migrateMemlog(log *logp.Logger, tx *sql.Tx, settings Settings, name string) error {
+	home := filepath.Join(settings.Root, name)
+	if _, found, err := memlogModTime(home); !found || err != nil {
+		return err
+	}
+
+	reg, err := memlog.New(log.Named("memlog"), memlog.Settings{
+		Root:     settings.Root,
+		FileMode: settings.FileMode,
+	})
+	if err != nil {
+		return err
+	}
+	defer reg.Close()
+
+	src, err := reg.Access(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	set, err := tx.Prepare(querySet)
+	if err != nil {
+		return err
+	}
+	defer set.Close()
+
+	var n int
+	err = src.Each(func(key string, dec backend.ValueDecoder) (bool, error) {
+		var value mapstr.M
+		if err := dec.Decode(&value); err != nil {
+			return false, fmt.Errorf("failed to decode value for key %q: %w", key, err)
+		}
+		raw, err := encodeValue(value)
+		if err != nil {
+			return false, fmt.Errorf("failed to encode value for key %q: %w", key, err)
+		}
+		if _, err := set.Exec(key, raw); err != nil {
+			return false, err
+		}
+		n++
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Opening the memlog store may create files, so the modification time
+	// is read once it has been migrated.
+	modTime, _, err := memlogModTime(home)
+	if err != nil {
+		return err
+	}
+	for key, value := range map[string]string{
+		"migrated_from":    home,
+		"migrated_modtime": strconv.FormatInt(modTime.UnixNano(), 10),
+	} {
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO meta (key, value) VALUES (?, ?)`, key, value); err != nil {
+			return err
+		}
+	}
+	log.Infof("Migrated %d entries from memlog store '%v'", n, home)
+	return nil
+}
+
+// remigrateMemlog replaces the content of the kv table with the memlog store
+// name if it was modified after it was last migrated, for example because
+// the registry has been used with memlog again after a rollback. The memlog
+// store is then more recent than the database.
+func remigrateMemlog(log *logp.Logger, tx *sql.Tx, settings Settings, name string) (bool, error) {
+	home := filepath.Join(settings.Root, name)
+	modTime, found, err := memlogModTime(home)
+	if !found || err != nil {
+		return false, err
+	}
+
+	var migrated int64
+	var value string
+	err = tx.QueryRow(`SELECT value FROM meta WHERE key = 'migrated_modtime'`).Scan(&value)
+	switch {
+	case err == nil:
+		migrated, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return false, fmt.Errorf("invalid migrated_modtime %q: %w", value, err)
+		}
+	case !errors.Is(err, sql.ErrNoRows):
+		return false, err
+	}
+	if !modTime.After(time.Unix(0, migrated)) {
+		return false, nil
+	}
+
+	log.Warnf("The memlog store '%v' was modified after it was migrated, replacing the content of the database with it", home)
+	if _, err := tx.Exec(`DELETE FROM kv`); err != nil {
+		return false, err
+	}
+	return true, migrateMemlog(log, tx, settings, name)
+}
+
+// memlogModTime returns the latest modification time of the memlog store in
+// home and of the files it contains. found is false if there is no memlog
+// store in home.
+func memlogModTime(home string) (modTime time.Time, found bool, err error) {
+	if _, err := os.Stat(filepath.Join(home, memlogMetaFileName)); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return time.Time{}, false, nil
+		}
+		return time.Time{}, false, err
+	}
+
+	info, err := os.Stat(home)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	modTime = info.ModTime()
+
+	entries, err := os.ReadDir(home)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return time.Time{}, false, err
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	return modTime, true, nil
+}
@@ -0,0 +1,113 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package sqlite
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/statestore/backend"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+// Registry configures access to sqlite based stores.
+type Registry struct {
+	log *logp.Logger
+
+	mu     sync.Mutex
+	active bool
+
+	settings Settings
+}
+
+// Settings configures a new Registry.
+type Settings struct {
+	// Registry root directory. Stores will be single database files.
+	Root string
+
+	// FileMode is used to configure the file mode for new files generated by the
+	// registry. File mode 0600 will be used if this field is not set.
+	FileMode os.FileMode
+
+	// BusyTimeout configures how long an update waits for a concurrent update
+	// of the same store to finish. Defaults to 5s if not set.
+	BusyTimeout time.Duration
+
+	// VacuumThreshold configures the number of removals after which pages
+	// freed in the database file are returned to the file system. Defaults
+	// to 1000 if not set.
+	VacuumThreshold uint
+}
+
+const defaultFileMode os.FileMode = 0600
+
+const defaultBusyTimeout = 5 * time.Second
+
+const defaultVacuumThreshold = 1000
+
+// New configures a sqlite Registry that can be used to open stores.
+func New(log *logp.Logger, settings Settings) (*Registry, error) {
+	if settings.FileMode == 0 {
+		settings.FileMode = defaultFileMode
+	}
+	if settings.BusyTimeout <= 0 {
+		settings.BusyTimeout = defaultBusyTimeout
+	}
+	if settings.VacuumThreshold == 0 {
+		settings.VacuumThreshold = defaultVacuumThreshold
+	}
+
+	root, err := filepath.Abs(settings.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	settings.Root = root
+	return &Registry{
+		log:      log,
+		active:   true,
+		settings: settings,
+	}, nil
+}
+
+// Access creates or opens a store. A new database file is created if the
+// store does not exist, importing the memlog store of the same name if there
+// is one in the registry root directory.
+// Returns an error if the database can not be opened or migrated.
+func (r *Registry) Access(name string) (backend.Store, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.active {
+		return nil, errRegClosed
+	}
+
+	logger := r.log.With("store", name)
+	return openStore(logger, r.settings, name)
+}
+
+// Close closes the registry. No new store can be accessed after close.
+// Stores already accessed must be closed by their owners.
+func (r *Registry) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.active = false
+	return nil
+}
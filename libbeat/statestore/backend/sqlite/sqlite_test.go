@@ -0,0 +1,205 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/statestore/backend"
+	"github.com/elastic/beats/v7/libbeat/statestore/backend/memlog"
+	"github.com/elastic/beats/v7/libbeat/statestore/internal/storecompliance"
+	"github.com/elastic/elastic-agent-libs/logp/logptest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestCompliance(t *testing.T) {
+	storecompliance.TestBackendCompliance(t, func(testPath string) (backend.Registry, error) {
+		logger := logptest.NewTestingLogger(t, "")
+		return New(logger.Named("test"), Settings{Root: testPath})
+	})
+}
+
+func TestCompliance_AlwaysVacuum(t *testing.T) {
+	storecompliance.TestBackendCompliance(t, func(testPath string) (backend.Registry, error) {
+		logger := logptest.NewTestingLogger(t, "")
+		return New(logger.Named("test"), Settings{Root: testPath, VacuumThreshold: 1})
+	})
+}
+
+func TestMigrateMemlog(t *testing.T) {
+	root := t.TempDir()
+	logger := logptest.NewTestingLogger(t, "")
+
+	writeMemlog := func(t *testing.T, kv map[string]mapstr.M) {
+		t.Helper()
+		reg, err := memlog.New(logger, memlog.Settings{Root: root})
+		require.NoError(t, err)
+		defer reg.Close()
+		store, err := reg.Access("test")
+		require.NoError(t, err)
+		defer store.Close()
+		for k, v := range kv {
+			require.NoError(t, store.Set(k, v))
+		}
+	}
+
+	openSqlite := func(t *testing.T) *store {
+		t.Helper()
+		reg, err := New(logger, Settings{Root: root})
+		require.NoError(t, err)
+		defer reg.Close()
+		s, err := reg.Access("test")
+		require.NoError(t, err)
+		return s.(*store)
+	}
+
+	writeMemlog(t, map[string]mapstr.M{
+		"filestream::a": {"cursor": mapstr.M{"offset": int64(42)}, "ttl": "30m"},
+		"filestream::b": {"cursor": mapstr.M{"offset": int64(7)}},
+	})
+
+	s := openSqlite(t)
+	var got mapstr.M
+	require.NoError(t, s.Get("filestream::a", &got))
+	offset, err := got.GetValue("cursor.offset")
+	require.NoError(t, err)
+	assert.EqualValues(t, 42, offset)
+	assert.Equal(t, "30m", got["ttl"])
+	found, err := s.Has("filestream::b")
+	require.NoError(t, err)
+	assert.True(t, found)
+
+	var from string
+	require.NoError(t, s.db.QueryRow(`SELECT value FROM meta WHERE key = 'migrated_from'`).Scan(&from))
+	assert.Equal(t, filepath.Join(root, "test"), from)
+	require.NoError(t, s.Close())
+
+	// Updates of the database are kept as long as the memlog store is not
+	// modified.
+	s = openSqlite(t)
+	require.NoError(t, s.Set("filestream::d", mapstr.M{}))
+	require.NoError(t, s.Close())
+	s = openSqlite(t)
+	found, err = s.Has("filestream::d")
+	require.NoError(t, err)
+	assert.True(t, found, "unmodified memlog store must not be migrated again")
+	require.NoError(t, s.Close())
+
+	// A memlog store modified after the migration is more recent than the
+	// database and replaces its content. The modification time is moved
+	// forward for file systems with coarse timestamps.
+	writeMemlog(t, map[string]mapstr.M{"filestream::c": {}})
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(filepath.Join(root, "test"), future, future))
+	s = openSqlite(t)
+	defer s.Close()
+	found, err = s.Has("filestream::c")
+	require.NoError(t, err)
+	assert.True(t, found, "modified memlog store must be migrated again")
+	found, err = s.Has("filestream::d")
+	require.NoError(t, err)
+	assert.False(t, found, "database content must be replaced")
+	require.NoError(t, s.Get("filestream::a", &got))
+	assert.Equal(t, "30m", got["ttl"])
+}
+
+func TestMigrateMemlogMissing(t *testing.T) {
+	root := t.TempDir()
+	logger := logptest.NewTestingLogger(t, "")
+
+	reg, err := New(logger, Settings{Root: root})
+	require.NoError(t, err)
+	defer reg.Close()
+	s, err := reg.Access("test")
+	require.NoError(t, err)
+	defer s.Close()
+
+	assert.NoDirExists(t, filepath.Join(root, "test"), "no memlog store must be created")
+	err = s.(*store).db.QueryRow(`SELECT value FROM meta WHERE key = 'migrated_from'`).Scan(new(string))
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestIncrementalVacuum(t *testing.T) {
+	logger := logptest.NewTestingLogger(t, "")
+	reg, err := New(logger, Settings{Root: t.TempDir(), VacuumThreshold: 100})
+	require.NoError(t, err)
+	defer reg.Close()
+	bs, err := reg.Access("test")
+	require.NoError(t, err)
+	defer bs.Close()
+	s := bs.(*store)
+
+	freePages := func() int {
+		var n int
+		require.NoError(t, s.db.QueryRow(`PRAGMA freelist_count`).Scan(&n))
+		return n
+	}
+
+	value := mapstr.M{"data": strings.Repeat("x", 4096)}
+	for i := range 100 {
+		require.NoError(t, s.Set(fmt.Sprintf("key-%d", i), value))
+	}
+	for i := range 99 {
+		require.NoError(t, s.Remove(fmt.Sprintf("key-%d", i)))
+	}
+	assert.NotZero(t, freePages(), "removed entries must free pages")
+
+	require.NoError(t, s.Remove("key-99"))
+	assert.Zero(t, freePages(), "free pages must be vacuumed after the threshold")
+}
+
+func TestFilePermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file permissions are not enforced on Windows")
+	}
+
+	root := t.TempDir()
+	path := filepath.Join(root, "test.db")
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+
+	logger := logptest.NewTestingLogger(t, "")
+	reg, err := New(logger, Settings{Root: root, FileMode: 0o640})
+	require.NoError(t, err)
+	defer reg.Close()
+	s, err := reg.Access("test")
+	require.NoError(t, err)
+	defer s.Close()
+
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o640), fi.Mode().Perm())
+}
+
+func TestAccessClosedRegistry(t *testing.T) {
+	reg, err := New(logptest.NewTestingLogger(t, ""), Settings{Root: t.TempDir()})
+	require.NoError(t, err)
+	require.NoError(t, reg.Close())
+
+	_, err = reg.Access("test")
+	assert.ErrorIs(t, err, errRegClosed)
+}
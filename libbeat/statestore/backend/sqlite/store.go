@@ -0,0 +1,333 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+
+	_ "github.com/mattn/go-sqlite3" // register the sqlite3 driver
+
+	"github.com/elastic/beats/v7/libbeat/common/transform/typeconv"
+	"github.com/elastic/beats/v7/libbeat/statestore/backend"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// storeVersion is the schema version written to the meta table of new stores.
+const storeVersion = "1"
+
+const schema = `
+CREATE TABLE IF NOT EXISTS meta (
+	key   TEXT NOT NULL PRIMARY KEY,
+	value TEXT NOT NULL
+) WITHOUT ROWID;
+CREATE TABLE IF NOT EXISTS kv (
+	key   TEXT NOT NULL PRIMARY KEY,
+	value BLOB NOT NULL
+) WITHOUT ROWID;
+`
+
+const (
+	queryHas    = `SELECT 1 FROM kv WHERE key = ?`
+	queryGet    = `SELECT value FROM kv WHERE key = ?`
+	querySet    = `INSERT INTO kv (key, value) VALUES (?, ?) ON CONFLICT (key) DO UPDATE SET value = excluded.value`
+	queryRemove = `DELETE FROM kv WHERE key = ?`
+	queryEach   = `SELECT key, value FROM kv ORDER BY key`
+)
+
+// store implements a sqlite based store.
+// All operations are executed against the database, no state is held in
+// memory. The store is safe for concurrent use: readers never block each
+// other, concurrent updates wait for each other up to the busy timeout.
+type store struct {
+	log *logp.Logger
+	db  *sql.DB
+
+	has    *sql.Stmt
+	get    *sql.Stmt
+	set    *sql.Stmt
+	remove *sql.Stmt
+
+	// removals counts the removals since the last vacuum.
+	removals        atomic.Uint64
+	vacuumThreshold uint64
+}
+
+// openStore opens the store database in the registry root directory. The
+// root directory is created if it does not exist. A new database is
+// initialized with the current schema and the content of the memlog store of
+// the same name, if there is one.
+func openStore(log *logp.Logger, settings Settings, name string) (*store, error) {
+	if err := os.MkdirAll(settings.Root, os.ModeDir|0o770); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(settings.Root, name+".db")
+	if err := ensureFile(path, settings.FileMode); err != nil {
+		return nil, fmt.Errorf("failed to create database file: %w", err)
+	}
+
+	// The options are applied to every connection of the pool. auto_vacuum
+	// only takes effect on a new database, before the schema is created.
+	dsn := fmt.Sprintf("%s?_busy_timeout=%d&_journal_mode=WAL&_synchronous=NORMAL&_auto_vacuum=incremental&_txlock=immediate",
+		path, settings.BusyTimeout.Milliseconds())
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &store{
+		log:             log,
+		db:              db,
+		vacuumThreshold: uint64(settings.VacuumThreshold),
+	}
+	if err := s.init(settings, name); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := s.prepare(); err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+
+	log.Infof("Opened sqlite store '%v'", path)
+	return s, nil
+}
+
+// init creates the schema if needed and checks the store version. A new
+// store is migrated from memlog in the same transaction, so a failed
+// migration leaves the database uninitialized and is retried on next open.
+// An existing store is migrated again if the memlog store was modified since.
+func (s *store) init(settings Settings, name string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(schema); err != nil {
+		return fmt.Errorf("failed to create schema: %w", err)
+	}
+
+	var version string
+	err = tx.QueryRow(`SELECT value FROM meta WHERE key = 'version'`).Scan(&version)
+	switch {
+	case err == nil:
+		if version != storeVersion {
+			return fmt.Errorf("unsupported store version %v", version)
+		}
+		migrated, err := remigrateMemlog(s.log, tx, settings, name)
+		if err != nil {
+			return fmt.Errorf("failed to migrate memlog store: %w", err)
+		}
+		if !migrated {
+			return nil
+		}
+		return tx.Commit()
+	case !errors.Is(err, sql.ErrNoRows):
+		return err
+	}
+
+	if _, err := tx.Exec(`INSERT INTO meta (key, value) VALUES ('version', ?)`, storeVersion); err != nil {
+		return err
+	}
+	if err := migrateMemlog(s.log, tx, settings, name); err != nil {
+		return fmt.Errorf("failed to migrate memlog store: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (s *store) prepare() error {
+	var err error
+	for _, p := range []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&s.has, queryHas},
+		{&s.get, queryGet},
+		{&s.set, querySet},
+		{&s.remove, queryRemove},
+	} {
+		*p.stmt, err = s.db.Prepare(p.query)
+		if err != nil {
+			return fmt.Errorf("failed to prepare statement: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close returns the pages freed in the database file to the file system and
+// closes the database. Access to the store after close returns an error.
+func (s *store) Close() error {
+	errs := []error{s.vacuum()}
+	for _, stmt := range []*sql.Stmt{s.has, s.get, s.set, s.remove} {
+		if stmt != nil {
+			errs = append(errs, stmt.Close())
+		}
+	}
+	errs = append(errs, s.db.Close())
+	return errors.Join(errs...)
+}
+
+// Has checks if the key is known.
+func (s *store) Has(key string) (bool, error) {
+	var found int
+	err := s.has.QueryRow(key).Scan(&found)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Get retrieves and decodes the key-value pair into to.
+func (s *store) Get(key string, to any) error {
+	var raw []byte
+	err := s.get.QueryRow(key).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errKeyUnknown
+	}
+	if err != nil {
+		return err
+	}
+	if err := decodeValue(raw, to); err != nil {
+		return fmt.Errorf("failed to decode stored value for key %q: %w", key, err)
+	}
+	return nil
+}
+
+// Set inserts or overwrites a key-value pair.
+func (s *store) Set(key string, value any) error {
+	raw, err := encodeValue(value)
+	if err != nil {
+		return err
+	}
+	_, err = s.set.Exec(key, raw)
+	return err
+}
+
+// Remove removes a key from the store. The operation does not check if the
+// key exists. Every VacuumThreshold removals the pages freed in the database
+// file are returned to the file system.
+func (s *store) Remove(key string) error {
+	if _, err := s.remove.Exec(key); err != nil {
+		return err
+	}
+	if s.removals.Add(1) >= s.vacuumThreshold {
+		if err := s.vacuum(); err != nil {
+			// The removal has been committed; vacuum will be retried with
+			// the next removal.
+			s.log.Warnf("Failed to vacuum store: %v", err)
+		}
+	}
+	return nil
+}
+
+// vacuum returns the free pages of the database file to the file system.
+func (s *store) vacuum() error {
+	s.removals.Store(0)
+	// incremental_vacuum frees one page per step, so it must be iterated
+	// like a query for all pages to be freed.
+	rows, err := s.db.Query(`PRAGMA incremental_vacuum`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+	}
+	return rows.Err()
+}
+
+// Each iterates over all key-value pairs in the store in key order.
+func (s *store) Each(fn func(string, backend.ValueDecoder) (bool, error)) error {
+	rows, err := s.db.Query(queryEach)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			key string
+			raw []byte
+		)
+		if err := rows.Scan(&key, &raw); err != nil {
+			return err
+		}
+		cont, err := fn(key, jsonValueDecoder(raw))
+		if !cont || err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *store) SetID(_ string) {
+	// NOOP
+}
+
+type jsonValueDecoder []byte
+
+func (d jsonValueDecoder) Decode(to any) error {
+	return decodeValue(d, to)
+}
+
+func encodeValue(value any) ([]byte, error) {
+	var tmp mapstr.M
+	if err := typeconv.Convert(&tmp, value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(tmp)
+}
+
+func decodeValue(raw []byte, to any) error {
+	var dec mapstr.M
+	if err := json.Unmarshal(raw, &dec); err != nil {
+		return err
+	}
+	return typeconv.Convert(to, dec)
+}
+
+// ensureFile creates the file at path with the given permissions if it does
+// not exist, or updates the permissions of an existing file. SQLite creates
+// the WAL and shared memory files with the permissions of the database file.
+func ensureFile(path string, mode os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, mode)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if perm := mode & os.ModePerm; fi.Mode()&os.ModePerm != perm {
+		return f.Chmod((fi.Mode() &^ os.ModePerm) | perm)
+	}
+	return nil
+}
@@ -61,6 +61,7 @@ func MetricbeatSettings(moduleNameSpace string) instance.Settings {
 	return instance.Settings{
 		RunFlags:      runFlags,
 		Name:          Name,
+		InputsKey:     "metricbeat.modules",
 		HasDashboards: true,
 		Processing:    processing.MakeDefaultSupport(true, nil, withECSVersion, processing.WithHost, processing.WithAgentMeta()),
 		Initialize: []func(){
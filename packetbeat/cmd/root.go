@@ -59,6 +59,7 @@ func PacketbeatSettings(globals processors.PluginConfig) instance.Settings {
 	return instance.Settings{
 		RunFlags:       runFlags,
 		Name:           Name,
+		InputsKey:      "packetbeat.protocols",
 		HasDashboards:  true,
 		Processing:     processing.MakeDefaultSupport(true, globals, withECSVersion, processing.WithHost, processing.WithAgentMeta()),
 		InputQueueSize: 400,
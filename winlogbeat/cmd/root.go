@@ -51,6 +51,7 @@ var RootCmd *cmd.BeatsRootCmd
 func WinlogbeatSettings() instance.Settings {
 	return instance.Settings{
 		Name:          Name,
+		InputsKey:     "winlogbeat.event_logs",
 		HasDashboards: true,
 		Processing:    processing.MakeDefaultSupport(true, nil, withECSVersion, processing.WithAgentMeta()),
 	}